@@ -12,11 +12,17 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	chiadapter "github.com/awslabs/aws-lambda-go-api-proxy/chi"
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
 	"github.com/chris/delayed-wallet-transactions/pkg/handlers"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
 	ws "github.com/chris/delayed-wallet-transactions/pkg/handlers/websockets"
 	customMiddleware "github.com/chris/delayed-wallet-transactions/pkg/middleware"
 	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	dydbstore "github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/memory"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/postgres"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/sqlite"
 	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -30,6 +36,13 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
+// appStore is the set of storage capabilities the API process needs.
+type appStore interface {
+	storage.ApiStore
+	websockets.ConnectionManager
+	websockets.AllConnectionsGetter
+}
+
 func main() {
 	// Load environment variables from .env file (useful for local testing).
 	if err := godotenv.Load(); err != nil {
@@ -37,12 +50,26 @@ func main() {
 	}
 
 	// Get environment variables.
+	storageBackend := getEnv("STORAGE_BACKEND", "dynamodb")
+	databaseURL := getEnv("DATABASE_URL", "")
+	sqlitePath := getEnv("SQLITE_PATH", "wallet.db")
 	transactionsTable := getEnv("DYNAMODB_TRANSACTIONS_TABLE_NAME", "Transactions")
 	walletsTable := getEnv("DYNAMODB_WALLETS_TABLE_NAME", "Wallets")
 	ledgerTable := getEnv("DYNAMODB_LEDGER_TABLE_NAME", "LedgerEntries")
 	websocketConnectionsTable := getEnv("DYNAMODB_WEBSOCKET_CONNECTIONS_TABLE_NAME", "WebsocketConnections")
+	idempotencyTable := getEnv("DYNAMODB_IDEMPOTENCY_TABLE_NAME", "IdempotencyKeys")
+	recurringTransfersTable := getEnv("DYNAMODB_RECURRING_TRANSFERS_TABLE_NAME", "RecurringTransfers")
+	schedulesTable := getEnv("DYNAMODB_SCHEDULES_TABLE_NAME", "Schedules")
 	sqsQueueURL := getEnv("SQS_QUEUE_URL", "")
 	websocketAPIEndpoint := getEnv("WEBSOCKET_API_ENDPOINT", "")
+	approvalThresholds := getEnv("APPROVAL_AMOUNT_THRESHOLDS", "")
+	approvalRecipients := getEnv("APPROVAL_ALLOWED_RECIPIENTS", "")
+
+	// New transactions that match an approval rule wait for POST /transactions/{id}/approve.
+	approvalRules, err := approval.Parse(approvalThresholds, approvalRecipients)
+	if err != nil {
+		log.Fatalf("invalid approval rules, %v", err)
+	}
 
 	// Load the AWS SDK configuration.
 	cfg, err := config.LoadDefaultConfig(context.TODO())
@@ -55,17 +82,43 @@ func main() {
 	sqsClient := sqs.NewFromConfig(cfg)
 
 	// Initialize components.
-	store := dydbstore.New(dbClient, transactionsTable, walletsTable, ledgerTable, websocketConnectionsTable)
-	sqsScheduler := scheduler.NewSQSScheduler(sqsClient, sqsQueueURL)
+	var store appStore
+	var schedules scheduler.ScheduleTable
+	switch storageBackend {
+	case "memory":
+		log.Println("Using in-memory storage; all data will be lost on exit.")
+		store, schedules = memory.New(), scheduler.NewMemoryScheduleTable()
+	case "postgres":
+		pgStore, err := postgres.Open(context.TODO(), databaseURL)
+		if err != nil {
+			log.Fatalf("unable to open postgres store, %v", err)
+		}
+		if err := pgStore.Migrate(context.TODO()); err != nil {
+			log.Fatalf("unable to migrate postgres schema, %v", err)
+		}
+		store, schedules = pgStore, pgStore
+	case "sqlite":
+		sqliteStore, err := sqlite.Open(context.TODO(), sqlitePath)
+		if err != nil {
+			log.Fatalf("unable to open sqlite store, %v", err)
+		}
+		store, schedules = sqliteStore, sqliteStore
+	default:
+		store = dydbstore.New(dbClient, transactionsTable, walletsTable, ledgerTable, websocketConnectionsTable, idempotencyTable, recurringTransfersTable)
+		schedules = scheduler.NewDynamoDBScheduleTable(dbClient, schedulesTable)
+	}
+	// Delays longer than SQS can hold are kept in the schedules table until cmd/schedule_poller enqueues them.
+	txScheduler := scheduler.NewDurableScheduler(schedules, scheduler.NewSQSScheduler(sqsClient, sqsQueueURL))
 	publisher, err := websockets.NewPublisher(store, store, websocketAPIEndpoint)
 	if err != nil {
 		log.Fatalf("failed to create websocket publisher: %v", err)
 	}
-	apiHandler := handlers.NewApiHandler(store, sqsScheduler, publisher)
+	apiHandler := handlers.NewApiHandler(store, txScheduler, publisher, approvalRules)
 	websocketHandler := ws.NewHandler(store)
 
-	// Use oapi-codegen's generated handler to mount the API routes.
-	apiRouter := api.Handler(apiHandler)
+	// Use oapi-codegen's generated handler to mount the API routes, reporting
+	// malformed parameters in the same problem format as the handlers.
+	apiRouter := api.HandlerWithOptions(apiHandler, api.ChiServerOptions{ErrorHandlerFunc: problem.WriteBadRequest})
 
 	// Create a new Chi router and add middleware.
 	chiRouter := chi.NewRouter()
@@ -73,7 +126,7 @@ func main() {
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "https://chr1sbest.github.io"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any major browsers
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/audit"
+	dydbstore "github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/postgres"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/sqlite"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables for local testing.
+	godotenv.Load()
+
+	store := openStore(context.Background())
+
+	// Inside Lambda, the report is returned to the invoker and logged for the scheduled runs.
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambda.Start(func(ctx context.Context) (*audit.Report, error) {
+			report, err := audit.Run(ctx, store)
+			if err != nil {
+				log.Printf("ERROR: audit failed: %v", err)
+				return nil, err
+			}
+			logReport(report)
+			return report, nil
+		})
+		return
+	}
+
+	// On demand, the report is written to stdout and the exit status is 1 if it found discrepancies.
+	report, err := audit.Run(context.Background(), store)
+	if err != nil {
+		log.Fatalf("audit failed: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+	if !report.OK() {
+		log.Printf("Audit found %d discrepancies.", len(report.Discrepancies))
+		os.Exit(1)
+	}
+}
+
+// openStore opens the storage backend selected by STORAGE_BACKEND, as cmd/app does.
+func openStore(ctx context.Context) audit.Store {
+	switch getEnv("STORAGE_BACKEND", "dynamodb") {
+	case "postgres":
+		store, err := postgres.Open(ctx, getEnv("DATABASE_URL", ""))
+		if err != nil {
+			log.Fatalf("unable to open postgres store, %v", err)
+		}
+		return store
+	case "sqlite":
+		store, err := sqlite.Open(ctx, getEnv("SQLITE_PATH", "wallet.db"))
+		if err != nil {
+			log.Fatalf("unable to open sqlite store, %v", err)
+		}
+		return store
+	default:
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		return dydbstore.New(dynamodb.NewFromConfig(cfg),
+			getEnv("DYNAMODB_TRANSACTIONS_TABLE_NAME", "Transactions"),
+			getEnv("DYNAMODB_WALLETS_TABLE_NAME", "Wallets"),
+			getEnv("DYNAMODB_LEDGER_TABLE_NAME", "LedgerEntries"),
+			"", "", "")
+	}
+}
+
+// logReport logs the report as a single JSON line, so that discrepancies can be alarmed on.
+func logReport(report *audit.Report) {
+	line, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("ERROR: failed to marshal report: %v", err)
+		return
+	}
+	if report.OK() {
+		log.Printf("Audit passed: %s", line)
+		return
+	}
+	log.Printf("ERROR: audit found %d discrepancies: %s", len(report.Discrepancies), line)
+}
+
+// getEnv reads an environment variable or returns a default value.
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
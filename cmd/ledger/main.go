@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/export"
+	dydbstore "github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/postgres"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/sqlite"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: ledger <command> [flags]
+
+Commands:
+  export    Write the ledger entries of a time range as CSV or Beancount.
+
+Run "ledger <command> -h" for the flags of a command.
+`
+
+func main() {
+	// Load environment variables for local testing.
+	godotenv.Load()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runExport writes an export to stdout or to the file named by -o, like GET /ledger/export.
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := flags.String("format", string(export.CSV), "the export format: csv or beancount")
+	fromValue := flags.String("from", "", "the start of the export as an RFC 3339 timestamp, inclusive (default: the first entry)")
+	toValue := flags.String("to", "", "the end of the export as an RFC 3339 timestamp, exclusive (default: now)")
+	output := flags.String("o", "", "the file to write the export to (default: stdout)")
+	flags.Parse(args)
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	from, err := parseTime(*fromValue, time.Time{})
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	to, err := parseTime(*toValue, time.Now())
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+	if !from.Before(to) {
+		log.Fatal("-from must be before -to")
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			log.Fatalf("failed to create %s: %v", *output, err)
+		}
+	}
+	ctx := context.Background()
+	if err := export.Write(ctx, out, openStore(ctx), format, from, to); err != nil {
+		log.Fatalf("export failed: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("failed to write export: %v", err)
+	}
+}
+
+// parseTime parses an RFC 3339 timestamp, or returns fallback for an empty value.
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// openStore opens the storage backend selected by STORAGE_BACKEND, as cmd/app does.
+func openStore(ctx context.Context) export.Reader {
+	switch getEnv("STORAGE_BACKEND", "dynamodb") {
+	case "postgres":
+		store, err := postgres.Open(ctx, getEnv("DATABASE_URL", ""))
+		if err != nil {
+			log.Fatalf("unable to open postgres store, %v", err)
+		}
+		return store
+	case "sqlite":
+		store, err := sqlite.Open(ctx, getEnv("SQLITE_PATH", "wallet.db"))
+		if err != nil {
+			log.Fatalf("unable to open sqlite store, %v", err)
+		}
+		return store
+	default:
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		return dydbstore.New(dynamodb.NewFromConfig(cfg),
+			getEnv("DYNAMODB_TRANSACTIONS_TABLE_NAME", "Transactions"),
+			getEnv("DYNAMODB_WALLETS_TABLE_NAME", "Wallets"),
+			getEnv("DYNAMODB_LEDGER_TABLE_NAME", "LedgerEntries"),
+			"", "", "")
+	}
+}
+
+// getEnv reads an environment variable or returns a default value.
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
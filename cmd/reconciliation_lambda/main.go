@@ -10,7 +10,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	dydbstore "github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb"
@@ -50,28 +52,52 @@ func init() {
 func HandleRequest(ctx context.Context) error {
 	log.Println("Starting reconciliation process for stuck transactions...")
 
-	stuckTxs, err := store.GetStuckTransactions(ctx, stuckTransactionThreshold)
-	if err != nil {
-		log.Printf("ERROR: failed to get stuck transactions: %v", err)
-		return err
+	found := 0
+	// Every transaction waiting to be settled was enqueued, whether it was reserved or approved, and can get stuck.
+	// So can one left WORKING by a settlement that crashed, which the next settlement takes over.
+	statuses := append(lifecycle.Sources(models.WORKING), models.WORKING)
+	for _, status := range statuses {
+		page := storage.PageRequest{Limit: storage.MaxPageSize}
+		for {
+			stuckTxs, next, err := store.GetStuckTransactions(ctx, status, stuckTransactionThreshold, page)
+			if err != nil {
+				log.Printf("ERROR: failed to get stuck %s transactions: %v", status, err)
+				return err
+			}
+			for _, tx := range stuckTxs {
+				// A transaction scheduled days ahead is only stuck once it is overdue by the threshold,
+				// and one being settled only once the lease of the worker settling it has expired.
+				dueAt := storage.DueAt(&tx)
+				if tx.Status == models.WORKING {
+					if !storage.LeaseExpired(&tx, time.Now()) {
+						continue
+					}
+				} else if time.Since(dueAt) < stuckTransactionThreshold {
+					continue
+				}
+				found++
+				// Enqueue it for whatever is left of its delay, so that it never settles early.
+				apiTx := mapping.ToApiTransaction(&tx)
+				if err := sqsScheduler.ScheduleTransaction(ctx, apiTx, scheduler.DelayUntil(dueAt, time.Now())); err != nil {
+					log.Printf("ERROR: failed to re-enqueue transaction %s: %v", tx.Id, err)
+					// Continue to the next transaction, don't let one failure stop the whole batch.
+					continue
+				}
+				log.Printf("Successfully re-enqueued transaction %s", tx.Id)
+			}
+
+			if next == "" {
+				break
+			}
+			page.Cursor = next
+		}
 	}
 
-	if len(stuckTxs) == 0 {
+	if found == 0 {
 		log.Println("No stuck transactions found.")
 		return nil
 	}
-
-	log.Printf("Found %d stuck transactions. Re-enqueuing them...", len(stuckTxs))
-
-	for _, tx := range stuckTxs {
-		apiTx := mapping.ToApiTransaction(&tx)
-		if err := sqsScheduler.ScheduleTransaction(ctx, apiTx, 0); err != nil {
-			log.Printf("ERROR: failed to re-enqueue transaction %s: %v", tx.Id, err)
-			// Continue to the next transaction, don't let one failure stop the whole batch.
-			continue
-		}
-		log.Printf("Successfully re-enqueued transaction %s", tx.Id)
-	}
+	log.Printf("Found %d stuck transactions.", found)
 
 	log.Println("Reconciliation process finished.")
 	return nil
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
+	"github.com/chris/delayed-wallet-transactions/pkg/recurring"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	dydbstore "github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/postgres"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/sqlite"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables for local testing.
+	godotenv.Load()
+
+	interval := flag.Duration("interval", 0, "run repeatedly at this interval instead of once")
+	flag.Parse()
+
+	runner := newRunner(context.Background())
+
+	// Inside Lambda, each scheduled invocation runs the due occurrences once.
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambda.Start(func(ctx context.Context) error {
+			return run(ctx, runner)
+		})
+		return
+	}
+
+	if *interval <= 0 {
+		if err := run(context.Background(), runner); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	for {
+		// Errors are logged by run; failed occurrences are retried on the next tick.
+		_ = run(context.Background(), runner)
+		time.Sleep(*interval)
+	}
+}
+
+// newRunner creates a runner over the storage backend selected by STORAGE_BACKEND, as cmd/app
+// does, that schedules transactions the way the API function does.
+func newRunner(ctx context.Context) *recurring.Runner {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
+	if sqsQueueURL == "" {
+		log.Fatal("SQS_QUEUE_URL environment variable not set")
+	}
+
+	// Long delays are kept in the schedules table of the storage backend, as cmd/app does.
+	store, schedules := openStore(ctx, dynamodb.NewFromConfig(cfg))
+	txScheduler := scheduler.NewDurableScheduler(schedules, scheduler.NewSQSScheduler(sqs.NewFromConfig(cfg), sqsQueueURL))
+	runner := recurring.NewRunner(store, txScheduler)
+
+	// Occurrences are held for approval by the same rules as transactions created through the API.
+	runner.Approval, err = approval.Parse(getEnv("APPROVAL_AMOUNT_THRESHOLDS", ""), getEnv("APPROVAL_ALLOWED_RECIPIENTS", ""))
+	if err != nil {
+		log.Fatalf("invalid approval rules, %v", err)
+	}
+	return runner
+}
+
+// openStore opens the storage backend selected by STORAGE_BACKEND and its schedules table.
+func openStore(ctx context.Context, dbClient *dynamodb.Client) (recurring.Store, scheduler.ScheduleTable) {
+	switch getEnv("STORAGE_BACKEND", "dynamodb") {
+	case "postgres":
+		store, err := postgres.Open(ctx, getEnv("DATABASE_URL", ""))
+		if err != nil {
+			log.Fatalf("unable to open postgres store, %v", err)
+		}
+		return store, store
+	case "sqlite":
+		store, err := sqlite.Open(ctx, getEnv("SQLITE_PATH", "wallet.db"))
+		if err != nil {
+			log.Fatalf("unable to open sqlite store, %v", err)
+		}
+		return store, store
+	default:
+		schedules := scheduler.NewDynamoDBScheduleTable(dbClient, getEnv("DYNAMODB_SCHEDULES_TABLE_NAME", "Schedules"))
+		return dydbstore.New(dbClient,
+			getEnv("DYNAMODB_TRANSACTIONS_TABLE_NAME", "Transactions"),
+			getEnv("DYNAMODB_WALLETS_TABLE_NAME", "Wallets"),
+			getEnv("DYNAMODB_LEDGER_TABLE_NAME", "LedgerEntries"),
+			"",
+			getEnv("DYNAMODB_IDEMPOTENCY_TABLE_NAME", "IdempotencyKeys"),
+			getEnv("DYNAMODB_RECURRING_TRANSFERS_TABLE_NAME", "RecurringTransfers")), schedules
+	}
+}
+
+func run(ctx context.Context, runner *recurring.Runner) error {
+	ran, err := runner.Run(ctx)
+	if err != nil {
+		log.Printf("ERROR: ran %d recurring transfer occurrences, but some failed: %v", ran, err)
+		return err
+	}
+	log.Printf("Ran %d recurring transfer occurrences.", ran)
+	return nil
+}
+
+// getEnv reads an environment variable or returns a default value.
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
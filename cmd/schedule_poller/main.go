@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/postgres"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/sqlite"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables for local testing.
+	godotenv.Load()
+
+	interval := flag.Duration("interval", 0, "poll repeatedly at this interval instead of once; must be shorter than 15m")
+	flag.Parse()
+
+	poller := newPoller(context.Background())
+
+	// Inside Lambda, each scheduled invocation polls once.
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambda.Start(func(ctx context.Context) error {
+			return poll(ctx, poller)
+		})
+		return
+	}
+
+	if *interval <= 0 {
+		if err := poll(context.Background(), poller); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if *interval >= scheduler.MaxQueueDelay {
+		log.Fatalf("-interval must be shorter than %s, or schedules are enqueued late", scheduler.MaxQueueDelay)
+	}
+	for {
+		// Errors are logged by poll; failed schedules are retried on the next tick.
+		_ = poll(context.Background(), poller)
+		time.Sleep(*interval)
+	}
+}
+
+// newPoller creates a poller that moves schedules from the schedules table of the storage backend
+// selected by STORAGE_BACKEND to the settlement queue.
+func newPoller(ctx context.Context) *scheduler.Poller {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
+	if sqsQueueURL == "" {
+		log.Fatal("SQS_QUEUE_URL environment variable not set")
+	}
+
+	var table scheduler.ScheduleTable
+	switch getEnv("STORAGE_BACKEND", "dynamodb") {
+	case "postgres":
+		store, err := postgres.Open(ctx, getEnv("DATABASE_URL", ""))
+		if err != nil {
+			log.Fatalf("unable to open postgres store, %v", err)
+		}
+		table = store
+	case "sqlite":
+		store, err := sqlite.Open(ctx, getEnv("SQLITE_PATH", "wallet.db"))
+		if err != nil {
+			log.Fatalf("unable to open sqlite store, %v", err)
+		}
+		table = store
+	default:
+		table = scheduler.NewDynamoDBScheduleTable(dynamodb.NewFromConfig(cfg), getEnv("DYNAMODB_SCHEDULES_TABLE_NAME", "Schedules"))
+	}
+	return scheduler.NewPoller(table, scheduler.NewSQSScheduler(sqs.NewFromConfig(cfg), sqsQueueURL))
+}
+
+func poll(ctx context.Context, poller *scheduler.Poller) error {
+	moved, err := poller.Poll(ctx)
+	if err != nil {
+		log.Printf("ERROR: enqueued %d scheduled transactions, but some could not be enqueued: %v", moved, err)
+		return err
+	}
+	log.Printf("Enqueued %d scheduled transactions.", moved)
+	return nil
+}
+
+// getEnv reads an environment variable or returns a default value.
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
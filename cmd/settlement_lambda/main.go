@@ -7,21 +7,39 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"errors"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	dynamo_store "github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/postgres"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/sqlite"
+	"github.com/google/uuid"
 )
 
+// messageSender sends messages to an SQS queue; *sqs.Client implements it.
+type messageSender interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
 var (
-	store      *dynamo_store.Store
-	apiBaseURL string
+	store              storage.SettlementStore
+	txScheduler        scheduler.CronScheduler
+	sqsClient          messageSender
+	deadLetterQueueURL string
+	apiBaseURL         string
 )
 
 func init() {
@@ -30,47 +48,147 @@ func init() {
 	if err != nil {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
-
 	dbClient := dynamodb.NewFromConfig(cfg)
-	store = dynamo_store.New(dbClient, os.Getenv("DYNAMODB_TRANSACTIONS_TABLE_NAME"), os.Getenv("DYNAMODB_WALLETS_TABLE_NAME"), os.Getenv("DYNAMODB_LEDGER_TABLE_NAME"), "")
+
+	// Messages that arrive before their transaction is due are put back for the rest of the delay,
+	// through the schedules table of the storage backend if it is longer than SQS can hold.
+	var schedules scheduler.ScheduleTable
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "postgres":
+		pgStore, err := postgres.Open(context.TODO(), os.Getenv("DATABASE_URL"))
+		if err != nil {
+			log.Fatalf("unable to open postgres store, %v", err)
+		}
+		store, schedules = pgStore, pgStore
+	case "sqlite":
+		sqliteStore, err := sqlite.Open(context.TODO(), getEnv("SQLITE_PATH", "wallet.db"))
+		if err != nil {
+			log.Fatalf("unable to open sqlite store, %v", err)
+		}
+		store, schedules = sqliteStore, sqliteStore
+	default:
+		store = dynamo_store.New(dbClient, os.Getenv("DYNAMODB_TRANSACTIONS_TABLE_NAME"), os.Getenv("DYNAMODB_WALLETS_TABLE_NAME"), os.Getenv("DYNAMODB_LEDGER_TABLE_NAME"), "", "", "")
+		schedules = scheduler.NewDynamoDBScheduleTable(dbClient, getEnv("DYNAMODB_SCHEDULES_TABLE_NAME", "Schedules"))
+	}
+	client := sqs.NewFromConfig(cfg)
+	sqsClient = client
+	txScheduler = scheduler.NewDurableScheduler(schedules, scheduler.NewSQSScheduler(client, os.Getenv("SQS_QUEUE_URL")))
+	deadLetterQueueURL = os.Getenv("DEAD_LETTER_QUEUE_URL")
 	apiBaseURL = os.Getenv("API_BASE_URL")
 }
 
 // HandleRequest processes SQS messages and settles the transactions.
-func HandleRequest(ctx context.Context, sqsEvent events.SQSEvent) error {
+// Messages that failed for a reason that may go away are reported back to SQS as batch item failures,
+// so that only they are redelivered. Messages that can never be processed are moved to the dead-letter
+// queue with the reason, and every other message is deleted from the queue.
+func HandleRequest(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	owner := leaseOwner(ctx)
+	var response events.SQSEventResponse
 	for _, message := range sqsEvent.Records {
-		log.Printf("Processing message %s: %s", message.MessageId, message.Body)
-
-		var tx models.Transaction
-		if err := json.Unmarshal([]byte(message.Body), &tx); err != nil {
-			log.Printf("ERROR: failed to unmarshal transaction from SQS message %s: %v", message.MessageId, err)
-			continue
+		if err := processMessage(ctx, message, owner); err != nil {
+			log.Printf("ERROR: failed to process SQS message %s, it will be retried: %v", message.MessageId, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
 		}
+	}
+	return response, nil
+}
 
-		if tx.FromUserId == "" || tx.ToUserId == "" {
-			log.Printf("ERROR: transaction %s has empty FromUserId or ToUserId", tx.Id)
-			continue
-		}
+// processMessage settles the transaction in one SQS message. It returns an error only if the message
+// should be retried.
+func processMessage(ctx context.Context, message events.SQSMessage, owner string) error {
+	log.Printf("Processing message %s: %s", message.MessageId, message.Body)
 
-		settlementPerformed, err := store.SettleTransaction(ctx, &tx)
-		if err != nil {
-			if errors.Is(err, storage.ErrTransactionNotProcessable) {
-				log.Printf("Skipping non-processable transaction %s", tx.Id)
-			} else {
-				log.Printf("error settling transaction: %v", err)
+	var tx models.Transaction
+	if err := json.Unmarshal([]byte(message.Body), &tx); err != nil {
+		return deadLetter(ctx, message, fmt.Sprintf("invalid transaction JSON: %v", err))
+	}
+
+	if tx.FromUserId == "" || tx.ToUserId == "" {
+		return deadLetter(ctx, message, fmt.Sprintf("transaction %s has empty FromUserId or ToUserId", tx.Id))
+	}
+
+	settlementPerformed, err := store.SettleTransaction(ctx, &tx, owner)
+	if err != nil {
+		var notDue *storage.NotDueError
+		var failed *storage.SettlementFailedError
+		switch {
+		case errors.As(err, &notDue):
+			// A redelivered or re-enqueued message arrived early; settle it when it is due.
+			return redelay(ctx, &tx, notDue.DueAt)
+		case errors.As(err, &failed):
+			// The transaction can never be settled and its funds were returned to the sender; tell them.
+			log.Printf("Transaction %s failed with reason %s: %v", tx.Id, failed.Reason, failed.Err)
+			if err := notifyApi(ctx, &tx); err != nil {
+				log.Printf("error notifying API: %v", err)
 			}
-			continue
-		}
-		if !settlementPerformed {
-			log.Printf("transaction was canceled or already completed, skipping settlement for %s", tx.Id)
 			return nil
+		case errors.Is(err, storage.ErrSettlementInProgress):
+			// Another invocation is settling the transaction; retry the message in case it crashes.
+			return fmt.Errorf("transaction %s is still being settled: %w", tx.Id, err)
+		case errors.Is(err, storage.ErrTransactionNotProcessable):
+			log.Printf("Skipping non-processable transaction %s", tx.Id)
+			return nil
+		case storage.IsPermanentSettlementError(err):
+			// The transaction can never be settled and could not be failed either, e.g. because the
+			// sender's wallet is gone too; retrying will not help.
+			return deadLetter(ctx, message, err.Error())
 		}
+		return fmt.Errorf("error settling transaction %s: %w", tx.Id, err)
+	}
+	if !settlementPerformed {
+		log.Printf("transaction was canceled or already completed, skipping settlement for %s", tx.Id)
+		return nil
+	}
 
-		if err := notifyApi(ctx, &tx); err != nil {
-			log.Printf("error notifying API: %v", err)
-			// Don't block the main flow if notification fails.
-		}
+	if err := notifyApi(ctx, &tx); err != nil {
+		log.Printf("error notifying API: %v", err)
+		// Don't block the main flow if notification fails.
+	}
+	return nil
+}
+
+// deadLetter moves a message that can never be processed to the dead-letter queue, with the reason as
+// its "reason" message attribute. It returns an error, so that the message is retried, only if the
+// message could not be moved. Without a dead-letter queue the message is retried until the queue's
+// redrive policy moves it instead.
+func deadLetter(ctx context.Context, message events.SQSMessage, reason string) error {
+	log.Printf("ERROR: SQS message %s can never be processed: %s", message.MessageId, reason)
+	if deadLetterQueueURL == "" {
+		return errors.New("DEAD_LETTER_QUEUE_URL not set, cannot move message to the dead-letter queue")
+	}
+
+	_, err := sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(deadLetterQueueURL),
+		MessageBody: aws.String(message.Body),
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+			"reason":     {DataType: aws.String("String"), StringValue: aws.String(reason)},
+			"message_id": {DataType: aws.String("String"), StringValue: aws.String(message.MessageId)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move message to the dead-letter queue: %w", err)
 	}
+	log.Printf("Moved SQS message %s to the dead-letter queue", message.MessageId)
+	return nil
+}
+
+// leaseOwner identifies this invocation as the owner of the settlement leases it takes, so that
+// another invocation can take over a transaction left WORKING if this one crashes.
+func leaseOwner(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok && lc.AwsRequestID != "" {
+		return lc.AwsRequestID
+	}
+	return uuid.New().String()
+}
+
+// redelay puts a transaction that is not due yet back on the queue for the rest of its delay.
+// If that fails, the error is returned so that the message is retried.
+func redelay(ctx context.Context, tx *models.Transaction, dueAt time.Time) error {
+	delay := scheduler.DelayUntil(dueAt, time.Now())
+	if err := txScheduler.ScheduleTransaction(ctx, mapping.ToApiTransaction(tx), delay); err != nil {
+		return fmt.Errorf("transaction %s is not due until %s and could not be re-delayed: %w", tx.Id, dueAt.Format(time.RFC3339), err)
+	}
+	log.Printf("Transaction %s is not due until %s; re-delayed by %s", tx.Id, dueAt.Format(time.RFC3339), delay)
 	return nil
 }
 
@@ -95,6 +213,14 @@ func notifyApi(ctx context.Context, tx *models.Transaction) error {
 	return nil
 }
 
+// getEnv reads an environment variable or returns a default value.
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
 func main() {
 	lambda.Start(HandleRequest)
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	scheduler_mocks "github.com/chris/delayed-wallet-transactions/pkg/scheduler/mocks"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testDeadLetterQueueURL = "https://sqs.eu-west-1.amazonaws.com/123456789012/dead-letters"
+
+// fakeSQS records the messages sent to it, or fails every send with err.
+type fakeSQS struct {
+	sent []*sqs.SendMessageInput
+	err  error
+}
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.sent = append(f.sent, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// setUp replaces the dependencies of the handler with fakes for the duration of a test.
+func setUp(t *testing.T) (*mocks.Storage, *scheduler.MemoryQueue, *fakeSQS) {
+	t.Helper()
+	previousStore, previousScheduler, previousClient := store, txScheduler, sqsClient
+	previousDeadLetterQueueURL, previousAPIBaseURL := deadLetterQueueURL, apiBaseURL
+	t.Cleanup(func() {
+		store, txScheduler, sqsClient = previousStore, previousScheduler, previousClient
+		deadLetterQueueURL, apiBaseURL = previousDeadLetterQueueURL, previousAPIBaseURL
+	})
+
+	mockStore := new(mocks.Storage)
+	queue := scheduler.NewMemoryQueue()
+	client := &fakeSQS{}
+	store, txScheduler, sqsClient = mockStore, queue, client
+	deadLetterQueueURL, apiBaseURL = testDeadLetterQueueURL, ""
+	return mockStore, queue, client
+}
+
+// message returns an SQS message carrying the transaction with the given ID.
+func message(t *testing.T, txID string) events.SQSMessage {
+	t.Helper()
+	body, err := json.Marshal(models.Transaction{Id: txID, FromUserId: "user-a", ToUserId: "user-b", Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+	return events.SQSMessage{MessageId: "msg-" + txID, Body: string(body)}
+}
+
+// settling matches the transaction with the given ID.
+func settling(txID string) any {
+	return mock.MatchedBy(func(tx *models.Transaction) bool { return tx.Id == txID })
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("Reports Only The Messages To Retry", func(t *testing.T) {
+		mockStore, _, _ := setUp(t)
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-1"), mock.Anything).Return(true, nil)
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-2"), mock.Anything).Return(false, errors.New("connection reset"))
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-3"), mock.Anything).Return(false, nil)
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{message(t, "tx-1"), message(t, "tx-2"), message(t, "tx-3")}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "msg-tx-2"}}, response.BatchItemFailures)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("Re-Delays A Transaction That Is Not Due", func(t *testing.T) {
+		mockStore, queue, _ := setUp(t)
+		dueAt := time.Now().Add(10 * time.Minute)
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-1"), mock.Anything).Return(false, &storage.NotDueError{TransactionID: "tx-1", DueAt: dueAt})
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{message(t, "tx-1")}})
+
+		require.NoError(t, err)
+		assert.Empty(t, response.BatchItemFailures)
+		messages := queue.Messages()
+		require.Len(t, messages, 1)
+		assert.Equal(t, "tx-1", *messages[0].Transaction.Id)
+		assert.InDelta(t, 10*time.Minute, messages[0].Delay, float64(5*time.Second), "the message is delayed until the transaction is due")
+	})
+
+	t.Run("Retries A Transaction That Could Not Be Re-Delayed", func(t *testing.T) {
+		mockStore, _, _ := setUp(t)
+		mockScheduler := new(scheduler_mocks.CronScheduler)
+		mockScheduler.On("ScheduleTransaction", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("queue unavailable"))
+		txScheduler = mockScheduler
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-1"), mock.Anything).Return(false, &storage.NotDueError{TransactionID: "tx-1", DueAt: time.Now().Add(time.Hour)})
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{message(t, "tx-1")}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "msg-tx-1"}}, response.BatchItemFailures)
+	})
+
+	t.Run("Retries A Transaction Being Settled Under Another Lease", func(t *testing.T) {
+		mockStore, _, client := setUp(t)
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-1"), mock.Anything).Return(false, storage.ErrSettlementInProgress)
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{message(t, "tx-1")}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "msg-tx-1"}}, response.BatchItemFailures, "the message is kept until the lease has expired")
+		assert.Empty(t, client.sent)
+	})
+
+	t.Run("Notifies The API Of A Failed Settlement", func(t *testing.T) {
+		mockStore, _, client := setUp(t)
+		var notified []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			notified = append(notified, r.Method+" "+r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+		apiBaseURL = server.URL
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-1"), mock.Anything).Return(false, &storage.SettlementFailedError{TransactionID: "tx-1", Reason: storage.FailureWalletNotFound, Err: storage.ErrWalletNotFound})
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{message(t, "tx-1")}})
+
+		require.NoError(t, err)
+		assert.Empty(t, response.BatchItemFailures, "a failed settlement is final and is not retried")
+		assert.Equal(t, []string{"POST /transactions/tx-1/notify-settlement"}, notified)
+		assert.Empty(t, client.sent)
+	})
+
+	t.Run("Dead-Letters A Transaction That Can Never Be Settled", func(t *testing.T) {
+		mockStore, _, client := setUp(t)
+		settleErr := fmt.Errorf("failed to release reserved funds: %w", storage.ErrWalletNotFound)
+		mockStore.On("SettleTransaction", mock.Anything, settling("tx-1"), mock.Anything).Return(false, settleErr)
+		msg := message(t, "tx-1")
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{msg}})
+
+		require.NoError(t, err)
+		assert.Empty(t, response.BatchItemFailures)
+		require.Len(t, client.sent, 1)
+		assert.Equal(t, testDeadLetterQueueURL, aws.ToString(client.sent[0].QueueUrl))
+		assert.Equal(t, msg.Body, aws.ToString(client.sent[0].MessageBody))
+		assert.Equal(t, settleErr.Error(), aws.ToString(client.sent[0].MessageAttributes["reason"].StringValue))
+		assert.Equal(t, "msg-tx-1", aws.ToString(client.sent[0].MessageAttributes["message_id"].StringValue))
+	})
+
+	t.Run("Dead-Letters A Message That Is Not A Transaction", func(t *testing.T) {
+		mockStore, _, client := setUp(t)
+
+		response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{{MessageId: "msg-1", Body: "not json"}}})
+
+		require.NoError(t, err)
+		assert.Empty(t, response.BatchItemFailures)
+		require.Len(t, client.sent, 1)
+		assert.Contains(t, aws.ToString(client.sent[0].MessageAttributes["reason"].StringValue), "invalid transaction JSON")
+		mockStore.AssertNotCalled(t, "SettleTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Retries A Poison Message That Cannot Be Dead-Lettered", func(t *testing.T) {
+		for name, configure := range map[string]func(client *fakeSQS){
+			"Without A Dead-Letter Queue": func(client *fakeSQS) { deadLetterQueueURL = "" },
+			"When Sending Fails":          func(client *fakeSQS) { client.err = errors.New("throttled") },
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, _, client := setUp(t)
+				configure(client)
+
+				response, err := HandleRequest(context.Background(), events.SQSEvent{Records: []events.SQSMessage{{MessageId: "msg-1", Body: "not json"}}})
+
+				require.NoError(t, err)
+				assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "msg-1"}}, response.BatchItemFailures, "the message must not be lost")
+				assert.Empty(t, client.sent)
+			})
+		}
+	})
+}
@@ -13,42 +13,264 @@ import (
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
+// Defines values for Direction.
+const (
+	RECEIVED Direction = "RECEIVED"
+	SENT     Direction = "SENT"
+)
+
+// Defines values for RecurrenceScheduleFrequency.
+const (
+	DAILY   RecurrenceScheduleFrequency = "DAILY"
+	MONTHLY RecurrenceScheduleFrequency = "MONTHLY"
+	WEEKLY  RecurrenceScheduleFrequency = "WEEKLY"
+)
+
+// Defines values for RecurringTransferStatus.
+const (
+	RecurringTransferStatusACTIVE    RecurringTransferStatus = "ACTIVE"
+	RecurringTransferStatusCANCELLED RecurringTransferStatus = "CANCELLED"
+	RecurringTransferStatusFINISHED  RecurringTransferStatus = "FINISHED"
+	RecurringTransferStatusPAUSED    RecurringTransferStatus = "PAUSED"
+)
+
 // Defines values for TransactionStatus.
 const (
-	APPROVED        TransactionStatus = "APPROVED"
-	COMPLETED       TransactionStatus = "COMPLETED"
-	FAILED          TransactionStatus = "FAILED"
-	PENDINGAPPROVAL TransactionStatus = "PENDING_APPROVAL"
-	REJECTED        TransactionStatus = "REJECTED"
-	RESERVED        TransactionStatus = "RESERVED"
+	TransactionStatusAPPROVED        TransactionStatus = "APPROVED"
+	TransactionStatusCANCELLED       TransactionStatus = "CANCELLED"
+	TransactionStatusCOMPLETED       TransactionStatus = "COMPLETED"
+	TransactionStatusFAILED          TransactionStatus = "FAILED"
+	TransactionStatusPENDINGAPPROVAL TransactionStatus = "PENDING_APPROVAL"
+	TransactionStatusREJECTED        TransactionStatus = "REJECTED"
+	TransactionStatusRESERVED        TransactionStatus = "RESERVED"
 )
 
+// Defines values for ExportLedgerParamsFormat.
+const (
+	Beancount ExportLedgerParamsFormat = "beancount"
+	Csv       ExportLedgerParamsFormat = "csv"
+)
+
+// Activity defines model for Activity.
+type Activity struct {
+	// Direction Whether the user sent or received a transaction.
+	Direction   Direction   `json:"direction"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// ActivityPage defines model for ActivityPage.
+type ActivityPage struct {
+	Items []Activity `json:"items"`
+
+	// NextCursor An opaque cursor for the next page. Absent on the last page.
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+// Currency An ISO 4217 currency code.
+type Currency = string
+
+// Direction Whether the user sent or received a transaction.
+type Direction string
+
+// Error A problem details object (RFC 7807) describing why a request failed.
+type Error struct {
+	// Detail An explanation specific to this occurrence of the problem.
+	Detail *string `json:"detail,omitempty"`
+
+	// Instance The request path that the problem occurred on.
+	Instance *string `json:"instance,omitempty"`
+
+	// Status The HTTP status code of the response.
+	Status int32 `json:"status"`
+
+	// Title A short summary of the problem type.
+	Title string `json:"title"`
+
+	// Type A URI reference that identifies the problem type.
+	Type string `json:"type"`
+}
+
 // LedgerEntry defines model for LedgerEntry.
 type LedgerEntry struct {
-	AccountId     *string    `json:"account_id,omitempty"`
-	Credit        *int64     `json:"credit,omitempty"`
-	Debit         *int64     `json:"debit,omitempty"`
-	Description   *string    `json:"description,omitempty"`
-	EntryId       *string    `json:"entry_id,omitempty"`
+	AccountId *string `json:"account_id,omitempty"`
+	Credit    *int64  `json:"credit,omitempty"`
+
+	// Currency An ISO 4217 currency code.
+	Currency    *Currency `json:"currency,omitempty"`
+	Debit       *int64    `json:"debit,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	EntryId     *string   `json:"entry_id,omitempty"`
+
+	// Hash The hex-encoded SHA-256 hash of the entry's content, sequence and previous hash.
+	Hash *string `json:"hash,omitempty"`
+
+	// PreviousHash The hash of the previous entry in the chain, or empty for the first entry.
+	PreviousHash *string `json:"previous_hash,omitempty"`
+
+	// Sequence The entry's position in the hash chain of its account and currency, starting at 1. Absent on entries of system accounts, which are not chained, and on entries recorded before the ledger was chained.
+	Sequence      *int64     `json:"sequence,omitempty"`
 	Timestamp     *time.Time `json:"timestamp,omitempty"`
 	TransactionId *string    `json:"transaction_id,omitempty"`
 }
 
+// LedgerEntryPage defines model for LedgerEntryPage.
+type LedgerEntryPage struct {
+	Items []LedgerEntry `json:"items"`
+
+	// NextCursor An opaque cursor for the next page. Absent on the last page.
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+// LedgerStatement defines model for LedgerStatement.
+type LedgerStatement struct {
+	AccountId string `json:"account_id"`
+
+	// ClosingBalance The account balance after the last entry of the statement.
+	ClosingBalance int64 `json:"closing_balance"`
+
+	// Currency An ISO 4217 currency code.
+	Currency Currency        `json:"currency"`
+	From     time.Time       `json:"from"`
+	Lines    []StatementLine `json:"lines"`
+
+	// NextCursor An opaque cursor for the next page. Absent on the last page.
+	NextCursor *string `json:"next_cursor,omitempty"`
+
+	// OpeningBalance The account balance before the first entry of the statement.
+	OpeningBalance int64     `json:"opening_balance"`
+	To             time.Time `json:"to"`
+}
+
+// NewFundsMovement defines model for NewFundsMovement.
+type NewFundsMovement struct {
+	// Amount The amount to move in the smallest currency unit (e.g., cents).
+	Amount int64 `json:"amount"`
+
+	// Currency An ISO 4217 currency code.
+	Currency Currency `json:"currency"`
+}
+
+// NewRecurringTransfer defines model for NewRecurringTransfer.
+type NewRecurringTransfer struct {
+	// Amount The amount of each transaction in the smallest currency unit (e.g., cents).
+	Amount int64 `json:"amount"`
+
+	// Currency An ISO 4217 currency code.
+	Currency   Currency `json:"currency"`
+	FromUserId string   `json:"from_user_id"`
+
+	// Schedule When a recurring transfer occurs, in UTC. Set either cron or frequency, and at most one of end_at and count. Without either, the transfer repeats until it is cancelled.
+	Schedule RecurrenceSchedule `json:"schedule"`
+	ToUserId string             `json:"to_user_id"`
+}
+
 // NewTransaction defines model for NewTransaction.
 type NewTransaction struct {
 	// Amount The amount of the transaction in the smallest currency unit (e.g., cents).
 	Amount int64 `json:"amount"`
 
-	// DelaySeconds An optional delay in seconds before the transaction is processed. Maximum 900 seconds (15 minutes).
+	// Currency An ISO 4217 currency code.
+	Currency Currency `json:"currency"`
+
+	// DelaySeconds An optional delay in seconds before the transaction is processed. Maximum 7776000 seconds (90 days). Delays over 900 seconds (15 minutes) are held in the schedules table until they are due.
 	DelaySeconds *int32 `json:"delay_seconds,omitempty"`
-	FromUserId   string `json:"from_user_id"`
-	ToUserId     string `json:"to_user_id"`
+
+	// ExecuteAt An optional RFC 3339 time at which the transaction is processed, at most 90 days ahead. A time in the past is processed at once. Cannot be combined with delay_seconds.
+	ExecuteAt  *time.Time `json:"execute_at,omitempty"`
+	FromUserId string     `json:"from_user_id"`
+	ToUserId   string     `json:"to_user_id"`
 }
 
 // NewWallet defines model for NewWallet.
 type NewWallet struct {
-	Name   string `json:"name"`
-	UserId string `json:"user_id"`
+	// Currencies The currencies the wallet holds. Each balance is seeded with 1000 units by a deposit from the SYSTEM:FUNDING account.
+	Currencies []Currency `json:"currencies"`
+	Name       string     `json:"name"`
+	UserId     string     `json:"user_id"`
+}
+
+// OccurrenceList defines model for OccurrenceList.
+type OccurrenceList struct {
+	// Items The upcoming occurrences, earliest first.
+	Items []time.Time `json:"items"`
+}
+
+// RecurrenceSchedule When a recurring transfer occurs, in UTC. Set either cron or frequency, and at most one of end_at and count. Without either, the transfer repeats until it is cancelled.
+type RecurrenceSchedule struct {
+	// Count The number of transactions to create before the transfer finishes.
+	Count *int32 `json:"count,omitempty"`
+
+	// Cron A five-field cron expression (minute, hour, day of month, month, day of week) evaluated in UTC. Fields accept *, numbers, ranges, lists and steps, such as "0 9 * * 1-5". Cannot be combined with frequency.
+	Cron *string `json:"cron,omitempty"`
+
+	// EndAt The time after which the transfer does not occur.
+	EndAt *time.Time `json:"end_at,omitempty"`
+
+	// Frequency Repeat every interval days, weeks or months from start_at. Monthly transfers that start on a day a month does not have fall on that month's last day.
+	Frequency *RecurrenceScheduleFrequency `json:"frequency,omitempty"`
+
+	// Interval The number of days, weeks or months between occurrences. Defaults to 1. Only used with frequency.
+	Interval *int32 `json:"interval,omitempty"`
+
+	// StartAt The first occurrence of a frequency, or the time from which the cron expression applies.
+	StartAt time.Time `json:"start_at"`
+}
+
+// RecurrenceScheduleFrequency Repeat every interval days, weeks or months from start_at. Monthly transfers that start on a day a month does not have fall on that month's last day.
+type RecurrenceScheduleFrequency string
+
+// RecurringTransfer defines model for RecurringTransfer.
+type RecurringTransfer struct {
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Currency An ISO 4217 currency code.
+	Currency   Currency `json:"currency"`
+	FromUserId string   `json:"from_user_id"`
+	Id         string   `json:"id"`
+
+	// LastError Why the most recent occurrence was skipped. Cleared when an occurrence succeeds.
+	LastError *string `json:"last_error,omitempty"`
+
+	// LastTransactionId The ID of the transaction created by the most recent occurrence that ran.
+	LastTransactionId *string `json:"last_transaction_id,omitempty"`
+
+	// NextRunAt The next occurrence. Only set while the transfer is active.
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+
+	// Occurrences The number of occurrences that have run, including those skipped for lack of funds.
+	Occurrences int32 `json:"occurrences"`
+
+	// Schedule When a recurring transfer occurs, in UTC. Set either cron or frequency, and at most one of end_at and count. Without either, the transfer repeats until it is cancelled.
+	Schedule RecurrenceSchedule `json:"schedule"`
+
+	// Status FINISHED transfers have passed their end_at or created count transactions.
+	Status    RecurringTransferStatus `json:"status"`
+	ToUserId  string                  `json:"to_user_id"`
+	UpdatedAt time.Time               `json:"updated_at"`
+}
+
+// RecurringTransferStatus FINISHED transfers have passed their end_at or created count transactions.
+type RecurringTransferStatus string
+
+// RecurringTransferPage defines model for RecurringTransferPage.
+type RecurringTransferPage struct {
+	Items []RecurringTransfer `json:"items"`
+
+	// NextCursor An opaque cursor for the next page. Absent on the last page.
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+// StatementLine defines model for StatementLine.
+type StatementLine struct {
+	// Balance The account balance after this entry.
+	Balance       *int64     `json:"balance,omitempty"`
+	Credit        *int64     `json:"credit,omitempty"`
+	Debit         *int64     `json:"debit,omitempty"`
+	Description   *string    `json:"description,omitempty"`
+	EntryId       *string    `json:"entry_id,omitempty"`
+	Timestamp     *time.Time `json:"timestamp,omitempty"`
+	TransactionId *string    `json:"transaction_id,omitempty"`
 }
 
 // Transaction defines model for Transaction.
@@ -56,68 +278,243 @@ type Transaction struct {
 	Amount    *int64     `json:"amount,omitempty"`
 	CreatedAt *time.Time `json:"created_at,omitempty"`
 
+	// Currency An ISO 4217 currency code.
+	Currency *Currency `json:"currency,omitempty"`
+
 	// DelaySeconds The delay in seconds before the transaction is processed.
-	DelaySeconds *int32             `json:"delay_seconds,omitempty"`
-	FromUserId   *string            `json:"from_user_id,omitempty"`
-	Id           *string            `json:"id,omitempty"`
-	Status       *TransactionStatus `json:"status,omitempty"`
-	ToUserId     *string            `json:"to_user_id,omitempty"`
+	DelaySeconds *int32 `json:"delay_seconds,omitempty"`
+
+	// DueAt The time from which the transaction can settle, worked out from delay_seconds or execute_at when it was created and rounded up to the second. Absent on transactions created before due times were recorded.
+	DueAt *time.Time `json:"due_at,omitempty"`
+
+	// ExecuteAt The time at which the transaction is processed, if it was created with execute_at.
+	ExecuteAt *time.Time `json:"execute_at,omitempty"`
+
+	// FailureReason Why a `FAILED` transaction could not be settled: `wallet_not_found`, `currency_not_held` or `insufficient_reserved_funds`.
+	FailureReason *string `json:"failure_reason,omitempty"`
+	FromUserId    *string `json:"from_user_id,omitempty"`
+	Id            *string `json:"id,omitempty"`
+
+	// Status `RESERVED` until the transaction settles and becomes `COMPLETED`. A transaction that needs approval is `PENDING_APPROVAL` until it is approved, becoming `APPROVED` and then `COMPLETED`, or rejected. A transaction that has not settled can be `CANCELLED`. One that can never be settled, for example because the receiver's wallet was deleted, becomes `FAILED` and its funds are returned to the sender.
+	Status   *TransactionStatus `json:"status,omitempty"`
+	ToUserId *string            `json:"to_user_id,omitempty"`
 
 	// Ttl A Unix timestamp representing the expiration time of the transaction record.
 	Ttl       *int64     `json:"ttl,omitempty"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 }
 
-// TransactionStatus defines model for Transaction.Status.
+// TransactionStatus `RESERVED` until the transaction settles and becomes `COMPLETED`. A transaction that needs approval is `PENDING_APPROVAL` until it is approved, becoming `APPROVED` and then `COMPLETED`, or rejected. A transaction that has not settled can be `CANCELLED`. One that can never be settled, for example because the receiver's wallet was deleted, becomes `FAILED` and its funds are returned to the sender.
 type TransactionStatus string
 
+// TransactionPage defines model for TransactionPage.
+type TransactionPage struct {
+	Items []Transaction `json:"items"`
+
+	// NextCursor An opaque cursor for the next page. Absent on the last page.
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
 // Wallet defines model for Wallet.
 type Wallet struct {
-	// Balance The wallet balance in the smallest currency unit (e.g., cents).
-	Balance   *int64     `json:"balance,omitempty"`
-	CreatedAt *time.Time `json:"created_at,omitempty"`
-	Name      *string    `json:"name,omitempty"`
+	// Balances The wallet's balance in each currency it holds, ordered by currency code.
+	Balances  *[]WalletBalance `json:"balances,omitempty"`
+	CreatedAt *time.Time       `json:"created_at,omitempty"`
+	Name      *string          `json:"name,omitempty"`
+	UserId    *string          `json:"user_id,omitempty"`
+	Version   *int64           `json:"version,omitempty"`
+}
+
+// WalletBalance defines model for WalletBalance.
+type WalletBalance struct {
+	// Balance The balance in the smallest currency unit (e.g., cents).
+	Balance int64 `json:"balance"`
+
+	// Currency An ISO 4217 currency code.
+	Currency Currency `json:"currency"`
 
 	// Reserved Funds reserved for pending transactions.
-	Reserved *int64  `json:"reserved,omitempty"`
-	UserId   *string `json:"user_id,omitempty"`
-	Version  *int64  `json:"version,omitempty"`
+	Reserved int64 `json:"reserved"`
+}
+
+// WalletPage defines model for WalletPage.
+type WalletPage struct {
+	Items []Wallet `json:"items"`
+
+	// NextCursor An opaque cursor for the next page. Absent on the last page.
+	NextCursor *string `json:"next_cursor,omitempty"`
 }
 
+// Cursor defines model for Cursor.
+type Cursor = string
+
+// Limit defines model for Limit.
+type Limit = int32
+
 // ListLedgerEntriesParams defines parameters for ListLedgerEntries.
 type ListLedgerEntriesParams struct {
-	Limit *int32 `form:"limit,omitempty" json:"limit,omitempty"`
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ExportLedgerParams defines parameters for ExportLedger.
+type ExportLedgerParams struct {
+	// Format The file format of the export.
+	Format ExportLedgerParamsFormat `form:"format" json:"format"`
+
+	// From The start of the export, inclusive. Omit it to start at the first entry.
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To The end of the export, exclusive. Omit it to end now.
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+}
+
+// ExportLedgerParamsFormat defines parameters for ExportLedger.
+type ExportLedgerParamsFormat string
+
+// ListRecurringTransferOccurrencesParams defines parameters for ListRecurringTransferOccurrences.
+type ListRecurringTransferOccurrencesParams struct {
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ScheduleTransactionParams defines parameters for ScheduleTransaction.
+type ScheduleTransactionParams struct {
+	// IdempotencyKey A unique key for this request, such as a UUID. Retrying a request with the same key and body returns the transaction created by the first request instead of creating another. Keys are kept for at least 24 hours.
+	IdempotencyKey *string `json:"Idempotency-Key,omitempty"`
+}
+
+// ListUserActivityParams defines parameters for ListUserActivity.
+type ListUserActivityParams struct {
+	// Direction Only return transactions in this direction. Omit it to return both.
+	Direction *Direction `form:"direction,omitempty" json:"direction,omitempty"`
+
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
 }
 
+// ListRecurringTransfersByUserIdParams defines parameters for ListRecurringTransfersByUserId.
+type ListRecurringTransfersByUserIdParams struct {
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListTransactionsByUserIdParams defines parameters for ListTransactionsByUserId.
+type ListTransactionsByUserIdParams struct {
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// ListWalletsParams defines parameters for ListWallets.
+type ListWalletsParams struct {
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// GetLedgerStatementParams defines parameters for GetLedgerStatement.
+type GetLedgerStatementParams struct {
+	// Currency The currency of the statement.
+	Currency Currency `form:"currency" json:"currency"`
+
+	// From The start of the statement, inclusive. Omit it to start at the wallet's first entry.
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To The end of the statement, exclusive. Omit it to end now.
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+
+	// Limit The maximum number of items to return.
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+	Cursor *Cursor `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// CreateRecurringTransferJSONRequestBody defines body for CreateRecurringTransfer for application/json ContentType.
+type CreateRecurringTransferJSONRequestBody = NewRecurringTransfer
+
 // ScheduleTransactionJSONRequestBody defines body for ScheduleTransaction for application/json ContentType.
 type ScheduleTransactionJSONRequestBody = NewTransaction
 
 // CreateWalletJSONRequestBody defines body for CreateWallet for application/json ContentType.
 type CreateWalletJSONRequestBody = NewWallet
 
+// CreateDepositJSONRequestBody defines body for CreateDeposit for application/json ContentType.
+type CreateDepositJSONRequestBody = NewFundsMovement
+
+// CreateWithdrawalJSONRequestBody defines body for CreateWithdrawal for application/json ContentType.
+type CreateWithdrawalJSONRequestBody = NewFundsMovement
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
-	// List recent ledger entries
+	// List ledger entries, newest first
 	// (GET /ledger)
 	ListLedgerEntries(w http.ResponseWriter, r *http.Request, params ListLedgerEntriesParams)
+	// Export the ledger for accounting
+	// (GET /ledger/export)
+	ExportLedger(w http.ResponseWriter, r *http.Request, params ExportLedgerParams)
+	// Create a recurring transfer
+	// (POST /recurring-transfers)
+	CreateRecurringTransfer(w http.ResponseWriter, r *http.Request)
+	// Get a recurring transfer by its ID
+	// (GET /recurring-transfers/{recurringTransferId})
+	GetRecurringTransferById(w http.ResponseWriter, r *http.Request, recurringTransferId string)
+	// Cancel a recurring transfer
+	// (POST /recurring-transfers/{recurringTransferId}/cancel)
+	CancelRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string)
+	// List the upcoming occurrences of a recurring transfer
+	// (GET /recurring-transfers/{recurringTransferId}/occurrences)
+	ListRecurringTransferOccurrences(w http.ResponseWriter, r *http.Request, recurringTransferId string, params ListRecurringTransferOccurrencesParams)
+	// Pause a recurring transfer
+	// (POST /recurring-transfers/{recurringTransferId}/pause)
+	PauseRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string)
+	// Resume a paused recurring transfer
+	// (POST /recurring-transfers/{recurringTransferId}/resume)
+	ResumeRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string)
 	// Schedule a new transaction
 	// (POST /transactions)
-	ScheduleTransaction(w http.ResponseWriter, r *http.Request)
+	ScheduleTransaction(w http.ResponseWriter, r *http.Request, params ScheduleTransactionParams)
 	// Cancel a transaction by its ID
 	// (DELETE /transactions/{transactionId})
 	CancelTransactionById(w http.ResponseWriter, r *http.Request, transactionId string)
 	// Get a transaction by its ID
 	// (GET /transactions/{transactionId})
 	GetTransactionById(w http.ResponseWriter, r *http.Request, transactionId string)
+	// Approve a transaction
+	// (POST /transactions/{transactionId}/approve)
+	ApproveTransaction(w http.ResponseWriter, r *http.Request, transactionId string)
 	// Notify of transaction settlement
 	// (POST /transactions/{transactionId}/notify-settlement)
 	NotifySettlement(w http.ResponseWriter, r *http.Request, transactionId openapi_types.UUID)
-	// List all transactions for a user
+	// Reject a transaction
+	// (POST /transactions/{transactionId}/reject)
+	RejectTransaction(w http.ResponseWriter, r *http.Request, transactionId string)
+	// List the transactions sent or received by a user, newest first
+	// (GET /users/{userId}/activity)
+	ListUserActivity(w http.ResponseWriter, r *http.Request, userId string, params ListUserActivityParams)
+	// List the recurring transfers sent by a user, newest first
+	// (GET /users/{userId}/recurring-transfers)
+	ListRecurringTransfersByUserId(w http.ResponseWriter, r *http.Request, userId string, params ListRecurringTransfersByUserIdParams)
+	// List the transactions sent by a user, newest first
 	// (GET /users/{userId}/transactions)
-	ListTransactionsByUserId(w http.ResponseWriter, r *http.Request, userId string)
-	// List all wallets
+	ListTransactionsByUserId(w http.ResponseWriter, r *http.Request, userId string, params ListTransactionsByUserIdParams)
+	// List wallets, newest first
 	// (GET /wallets)
-	ListWallets(w http.ResponseWriter, r *http.Request)
+	ListWallets(w http.ResponseWriter, r *http.Request, params ListWalletsParams)
 	// Create a new wallet
 	// (POST /wallets)
 	CreateWallet(w http.ResponseWriter, r *http.Request)
@@ -127,21 +524,72 @@ type ServerInterface interface {
 	// Get a wallet by user ID
 	// (GET /wallets/{userId})
 	GetWalletByUserId(w http.ResponseWriter, r *http.Request, userId string)
+	// Deposit funds into a wallet
+	// (POST /wallets/{userId}/deposits)
+	CreateDeposit(w http.ResponseWriter, r *http.Request, userId string)
+	// Get a ledger statement for a wallet, oldest entry first
+	// (GET /wallets/{userId}/ledger)
+	GetLedgerStatement(w http.ResponseWriter, r *http.Request, userId string, params GetLedgerStatementParams)
+	// Withdraw funds from a wallet
+	// (POST /wallets/{userId}/withdrawals)
+	CreateWithdrawal(w http.ResponseWriter, r *http.Request, userId string)
 }
 
 // Unimplemented server implementation that returns http.StatusNotImplemented for each endpoint.
 
 type Unimplemented struct{}
 
-// List recent ledger entries
+// List ledger entries, newest first
 // (GET /ledger)
 func (_ Unimplemented) ListLedgerEntries(w http.ResponseWriter, r *http.Request, params ListLedgerEntriesParams) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Export the ledger for accounting
+// (GET /ledger/export)
+func (_ Unimplemented) ExportLedger(w http.ResponseWriter, r *http.Request, params ExportLedgerParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Create a recurring transfer
+// (POST /recurring-transfers)
+func (_ Unimplemented) CreateRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get a recurring transfer by its ID
+// (GET /recurring-transfers/{recurringTransferId})
+func (_ Unimplemented) GetRecurringTransferById(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Cancel a recurring transfer
+// (POST /recurring-transfers/{recurringTransferId}/cancel)
+func (_ Unimplemented) CancelRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the upcoming occurrences of a recurring transfer
+// (GET /recurring-transfers/{recurringTransferId}/occurrences)
+func (_ Unimplemented) ListRecurringTransferOccurrences(w http.ResponseWriter, r *http.Request, recurringTransferId string, params ListRecurringTransferOccurrencesParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Pause a recurring transfer
+// (POST /recurring-transfers/{recurringTransferId}/pause)
+func (_ Unimplemented) PauseRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Resume a paused recurring transfer
+// (POST /recurring-transfers/{recurringTransferId}/resume)
+func (_ Unimplemented) ResumeRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // Schedule a new transaction
 // (POST /transactions)
-func (_ Unimplemented) ScheduleTransaction(w http.ResponseWriter, r *http.Request) {
+func (_ Unimplemented) ScheduleTransaction(w http.ResponseWriter, r *http.Request, params ScheduleTransactionParams) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
@@ -157,21 +605,45 @@ func (_ Unimplemented) GetTransactionById(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Approve a transaction
+// (POST /transactions/{transactionId}/approve)
+func (_ Unimplemented) ApproveTransaction(w http.ResponseWriter, r *http.Request, transactionId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // Notify of transaction settlement
 // (POST /transactions/{transactionId}/notify-settlement)
 func (_ Unimplemented) NotifySettlement(w http.ResponseWriter, r *http.Request, transactionId openapi_types.UUID) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
-// List all transactions for a user
+// Reject a transaction
+// (POST /transactions/{transactionId}/reject)
+func (_ Unimplemented) RejectTransaction(w http.ResponseWriter, r *http.Request, transactionId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the transactions sent or received by a user, newest first
+// (GET /users/{userId}/activity)
+func (_ Unimplemented) ListUserActivity(w http.ResponseWriter, r *http.Request, userId string, params ListUserActivityParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the recurring transfers sent by a user, newest first
+// (GET /users/{userId}/recurring-transfers)
+func (_ Unimplemented) ListRecurringTransfersByUserId(w http.ResponseWriter, r *http.Request, userId string, params ListRecurringTransfersByUserIdParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the transactions sent by a user, newest first
 // (GET /users/{userId}/transactions)
-func (_ Unimplemented) ListTransactionsByUserId(w http.ResponseWriter, r *http.Request, userId string) {
+func (_ Unimplemented) ListTransactionsByUserId(w http.ResponseWriter, r *http.Request, userId string, params ListTransactionsByUserIdParams) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
-// List all wallets
+// List wallets, newest first
 // (GET /wallets)
-func (_ Unimplemented) ListWallets(w http.ResponseWriter, r *http.Request) {
+func (_ Unimplemented) ListWallets(w http.ResponseWriter, r *http.Request, params ListWalletsParams) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
@@ -193,6 +665,24 @@ func (_ Unimplemented) GetWalletByUserId(w http.ResponseWriter, r *http.Request,
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Deposit funds into a wallet
+// (POST /wallets/{userId}/deposits)
+func (_ Unimplemented) CreateDeposit(w http.ResponseWriter, r *http.Request, userId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get a ledger statement for a wallet, oldest entry first
+// (GET /wallets/{userId}/ledger)
+func (_ Unimplemented) GetLedgerStatement(w http.ResponseWriter, r *http.Request, userId string, params GetLedgerStatementParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Withdraw funds from a wallet
+// (POST /wallets/{userId}/withdrawals)
+func (_ Unimplemented) CreateWithdrawal(w http.ResponseWriter, r *http.Request, userId string) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // ServerInterfaceWrapper converts contexts to parameters.
 type ServerInterfaceWrapper struct {
 	Handler            ServerInterface
@@ -200,26 +690,349 @@ type ServerInterfaceWrapper struct {
 	ErrorHandlerFunc   func(w http.ResponseWriter, r *http.Request, err error)
 }
 
-type MiddlewareFunc func(http.Handler) http.Handler
+type MiddlewareFunc func(http.Handler) http.Handler
+
+// ListLedgerEntries operation middleware
+func (siw *ServerInterfaceWrapper) ListLedgerEntries(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListLedgerEntriesParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListLedgerEntries(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ExportLedger operation middleware
+func (siw *ServerInterfaceWrapper) ExportLedger(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ExportLedgerParams
+
+	// ------------- Required query parameter "format" -------------
+
+	if paramValue := r.URL.Query().Get("format"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "format"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "format", r.URL.Query(), &params.Format)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "format", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ExportLedger(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreateRecurringTransfer operation middleware
+func (siw *ServerInterfaceWrapper) CreateRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateRecurringTransfer(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetRecurringTransferById operation middleware
+func (siw *ServerInterfaceWrapper) GetRecurringTransferById(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "recurringTransferId" -------------
+	var recurringTransferId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "recurringTransferId", chi.URLParam(r, "recurringTransferId"), &recurringTransferId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "recurringTransferId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetRecurringTransferById(w, r, recurringTransferId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CancelRecurringTransfer operation middleware
+func (siw *ServerInterfaceWrapper) CancelRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "recurringTransferId" -------------
+	var recurringTransferId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "recurringTransferId", chi.URLParam(r, "recurringTransferId"), &recurringTransferId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "recurringTransferId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CancelRecurringTransfer(w, r, recurringTransferId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListRecurringTransferOccurrences operation middleware
+func (siw *ServerInterfaceWrapper) ListRecurringTransferOccurrences(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "recurringTransferId" -------------
+	var recurringTransferId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "recurringTransferId", chi.URLParam(r, "recurringTransferId"), &recurringTransferId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "recurringTransferId", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListRecurringTransferOccurrencesParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListRecurringTransferOccurrences(w, r, recurringTransferId, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PauseRecurringTransfer operation middleware
+func (siw *ServerInterfaceWrapper) PauseRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "recurringTransferId" -------------
+	var recurringTransferId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "recurringTransferId", chi.URLParam(r, "recurringTransferId"), &recurringTransferId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "recurringTransferId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PauseRecurringTransfer(w, r, recurringTransferId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ResumeRecurringTransfer operation middleware
+func (siw *ServerInterfaceWrapper) ResumeRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "recurringTransferId" -------------
+	var recurringTransferId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "recurringTransferId", chi.URLParam(r, "recurringTransferId"), &recurringTransferId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "recurringTransferId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ResumeRecurringTransfer(w, r, recurringTransferId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ScheduleTransaction operation middleware
+func (siw *ServerInterfaceWrapper) ScheduleTransaction(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ScheduleTransactionParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ScheduleTransaction(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CancelTransactionById operation middleware
+func (siw *ServerInterfaceWrapper) CancelTransactionById(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "transactionId" -------------
+	var transactionId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "transactionId", chi.URLParam(r, "transactionId"), &transactionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "transactionId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CancelTransactionById(w, r, transactionId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetTransactionById operation middleware
+func (siw *ServerInterfaceWrapper) GetTransactionById(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "transactionId" -------------
+	var transactionId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "transactionId", chi.URLParam(r, "transactionId"), &transactionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "transactionId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetTransactionById(w, r, transactionId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
 
-// ListLedgerEntries operation middleware
-func (siw *ServerInterfaceWrapper) ListLedgerEntries(w http.ResponseWriter, r *http.Request) {
+	handler.ServeHTTP(w, r)
+}
 
-	var err error
+// ApproveTransaction operation middleware
+func (siw *ServerInterfaceWrapper) ApproveTransaction(w http.ResponseWriter, r *http.Request) {
 
-	// Parameter object where we will unmarshal all parameters from the context
-	var params ListLedgerEntriesParams
+	var err error
 
-	// ------------- Optional query parameter "limit" -------------
+	// ------------- Path parameter "transactionId" -------------
+	var transactionId string
 
-	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	err = runtime.BindStyledParameterWithOptions("simple", "transactionId", chi.URLParam(r, "transactionId"), &transactionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "transactionId", Err: err})
 		return
 	}
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.ListLedgerEntries(w, r, params)
+		siw.Handler.ApproveTransaction(w, r, transactionId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -229,11 +1042,22 @@ func (siw *ServerInterfaceWrapper) ListLedgerEntries(w http.ResponseWriter, r *h
 	handler.ServeHTTP(w, r)
 }
 
-// ScheduleTransaction operation middleware
-func (siw *ServerInterfaceWrapper) ScheduleTransaction(w http.ResponseWriter, r *http.Request) {
+// NotifySettlement operation middleware
+func (siw *ServerInterfaceWrapper) NotifySettlement(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "transactionId" -------------
+	var transactionId openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "transactionId", chi.URLParam(r, "transactionId"), &transactionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "transactionId", Err: err})
+		return
+	}
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.ScheduleTransaction(w, r)
+		siw.Handler.NotifySettlement(w, r, transactionId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -243,8 +1067,8 @@ func (siw *ServerInterfaceWrapper) ScheduleTransaction(w http.ResponseWriter, r
 	handler.ServeHTTP(w, r)
 }
 
-// CancelTransactionById operation middleware
-func (siw *ServerInterfaceWrapper) CancelTransactionById(w http.ResponseWriter, r *http.Request) {
+// RejectTransaction operation middleware
+func (siw *ServerInterfaceWrapper) RejectTransaction(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -258,7 +1082,7 @@ func (siw *ServerInterfaceWrapper) CancelTransactionById(w http.ResponseWriter,
 	}
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.CancelTransactionById(w, r, transactionId)
+		siw.Handler.RejectTransaction(w, r, transactionId)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -268,22 +1092,49 @@ func (siw *ServerInterfaceWrapper) CancelTransactionById(w http.ResponseWriter,
 	handler.ServeHTTP(w, r)
 }
 
-// GetTransactionById operation middleware
-func (siw *ServerInterfaceWrapper) GetTransactionById(w http.ResponseWriter, r *http.Request) {
+// ListUserActivity operation middleware
+func (siw *ServerInterfaceWrapper) ListUserActivity(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
-	// ------------- Path parameter "transactionId" -------------
-	var transactionId string
+	// ------------- Path parameter "userId" -------------
+	var userId string
 
-	err = runtime.BindStyledParameterWithOptions("simple", "transactionId", chi.URLParam(r, "transactionId"), &transactionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", chi.URLParam(r, "userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "transactionId", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListUserActivityParams
+
+	// ------------- Optional query parameter "direction" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "direction", r.URL.Query(), &params.Direction)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "direction", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
 		return
 	}
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetTransactionById(w, r, transactionId)
+		siw.Handler.ListUserActivity(w, r, userId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -293,22 +1144,41 @@ func (siw *ServerInterfaceWrapper) GetTransactionById(w http.ResponseWriter, r *
 	handler.ServeHTTP(w, r)
 }
 
-// NotifySettlement operation middleware
-func (siw *ServerInterfaceWrapper) NotifySettlement(w http.ResponseWriter, r *http.Request) {
+// ListRecurringTransfersByUserId operation middleware
+func (siw *ServerInterfaceWrapper) ListRecurringTransfersByUserId(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
-	// ------------- Path parameter "transactionId" -------------
-	var transactionId openapi_types.UUID
+	// ------------- Path parameter "userId" -------------
+	var userId string
 
-	err = runtime.BindStyledParameterWithOptions("simple", "transactionId", chi.URLParam(r, "transactionId"), &transactionId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", chi.URLParam(r, "userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "transactionId", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListRecurringTransfersByUserIdParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
 		return
 	}
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.NotifySettlement(w, r, transactionId)
+		siw.Handler.ListRecurringTransfersByUserId(w, r, userId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -332,8 +1202,27 @@ func (siw *ServerInterfaceWrapper) ListTransactionsByUserId(w http.ResponseWrite
 		return
 	}
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListTransactionsByUserIdParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.ListTransactionsByUserId(w, r, userId)
+		siw.Handler.ListTransactionsByUserId(w, r, userId, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -346,8 +1235,29 @@ func (siw *ServerInterfaceWrapper) ListTransactionsByUserId(w http.ResponseWrite
 // ListWallets operation middleware
 func (siw *ServerInterfaceWrapper) ListWallets(w http.ResponseWriter, r *http.Request) {
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListWalletsParams
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.ListWallets(w, r)
+		siw.Handler.ListWallets(w, r, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -421,6 +1331,131 @@ func (siw *ServerInterfaceWrapper) GetWalletByUserId(w http.ResponseWriter, r *h
 	handler.ServeHTTP(w, r)
 }
 
+// CreateDeposit operation middleware
+func (siw *ServerInterfaceWrapper) CreateDeposit(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", chi.URLParam(r, "userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateDeposit(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetLedgerStatement operation middleware
+func (siw *ServerInterfaceWrapper) GetLedgerStatement(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", chi.URLParam(r, "userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetLedgerStatementParams
+
+	// ------------- Required query parameter "currency" -------------
+
+	if paramValue := r.URL.Query().Get("currency"); paramValue != "" {
+
+	} else {
+		siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: "currency"})
+		return
+	}
+
+	err = runtime.BindQueryParameter("form", true, true, "currency", r.URL.Query(), &params.Currency)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "currency", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetLedgerStatement(w, r, userId, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreateWithdrawal operation middleware
+func (siw *ServerInterfaceWrapper) CreateWithdrawal(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "userId" -------------
+	var userId string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "userId", chi.URLParam(r, "userId"), &userId, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "userId", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateWithdrawal(w, r, userId)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -537,6 +1572,27 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/ledger", wrapper.ListLedgerEntries)
 	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/ledger/export", wrapper.ExportLedger)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/recurring-transfers", wrapper.CreateRecurringTransfer)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/recurring-transfers/{recurringTransferId}", wrapper.GetRecurringTransferById)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/recurring-transfers/{recurringTransferId}/cancel", wrapper.CancelRecurringTransfer)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/recurring-transfers/{recurringTransferId}/occurrences", wrapper.ListRecurringTransferOccurrences)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/recurring-transfers/{recurringTransferId}/pause", wrapper.PauseRecurringTransfer)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/recurring-transfers/{recurringTransferId}/resume", wrapper.ResumeRecurringTransfer)
+	})
 	r.Group(func(r chi.Router) {
 		r.Post(options.BaseURL+"/transactions", wrapper.ScheduleTransaction)
 	})
@@ -546,9 +1602,21 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/transactions/{transactionId}", wrapper.GetTransactionById)
 	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/transactions/{transactionId}/approve", wrapper.ApproveTransaction)
+	})
 	r.Group(func(r chi.Router) {
 		r.Post(options.BaseURL+"/transactions/{transactionId}/notify-settlement", wrapper.NotifySettlement)
 	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/transactions/{transactionId}/reject", wrapper.RejectTransaction)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/users/{userId}/activity", wrapper.ListUserActivity)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/users/{userId}/recurring-transfers", wrapper.ListRecurringTransfersByUserId)
+	})
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/users/{userId}/transactions", wrapper.ListTransactionsByUserId)
 	})
@@ -564,6 +1632,15 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/wallets/{userId}", wrapper.GetWalletByUserId)
 	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/wallets/{userId}/deposits", wrapper.CreateDeposit)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/wallets/{userId}/ledger", wrapper.GetLedgerStatement)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/wallets/{userId}/withdrawals", wrapper.CreateWithdrawal)
+	})
 
 	return r
 }
@@ -0,0 +1,73 @@
+// Package approval decides which new transactions need a second person's approval before they settle.
+package approval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// Rules are the approval rules a new transaction is checked against. A transaction needs approval
+// if any rule matches it. The zero value needs approval for no transaction.
+type Rules struct {
+	// Thresholds maps a currency code to the amount, in minor units, from which a transaction in
+	// that currency needs approval. Currencies without a threshold need no approval for any amount.
+	Thresholds map[string]int64
+	// AllowedRecipients, if not empty, are the users that can be paid without approval. A
+	// transaction to any other user needs approval.
+	AllowedRecipients map[string]bool
+}
+
+// Parse builds rules from their configuration strings. thresholds is a comma-separated list of
+// CURRENCY:AMOUNT pairs, such as "USD:100000,EUR:90000", and allowedRecipients a comma-separated
+// list of user IDs. Either may be empty to leave its rule out.
+func Parse(thresholds, allowedRecipients string) (Rules, error) {
+	var rules Rules
+	for _, pair := range splitList(thresholds) {
+		code, amount, ok := strings.Cut(pair, ":")
+		if !ok {
+			return Rules{}, fmt.Errorf("invalid approval threshold %q: want CURRENCY:AMOUNT", pair)
+		}
+		code = strings.TrimSpace(code)
+		if !currency.Valid(code) {
+			return Rules{}, fmt.Errorf("invalid approval threshold %q: %q is not an ISO 4217 currency code", pair, code)
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(amount), 10, 64)
+		if err != nil || limit < 1 {
+			return Rules{}, fmt.Errorf("invalid approval threshold %q: amount must be a positive integer in minor units", pair)
+		}
+		if rules.Thresholds == nil {
+			rules.Thresholds = make(map[string]int64)
+		}
+		rules.Thresholds[code] = limit
+	}
+	for _, userID := range splitList(allowedRecipients) {
+		if rules.AllowedRecipients == nil {
+			rules.AllowedRecipients = make(map[string]bool)
+		}
+		rules.AllowedRecipients[userID] = true
+	}
+	return rules, nil
+}
+
+// Required reports whether a new transaction needs approval.
+func (r Rules) Required(tx *models.Transaction) bool {
+	if limit, ok := r.Thresholds[tx.Currency]; ok && tx.Amount >= limit {
+		return true
+	}
+	return len(r.AllowedRecipients) > 0 && !r.AllowedRecipients[tx.ToUserId]
+}
+
+// splitList splits a comma-separated list, dropping blank entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,52 @@
+package approval
+
+import (
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(" USD:100000, EUR:90000,", "alice, bob")
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"USD": 100000, "EUR": 90000}, rules.Thresholds)
+	assert.Equal(t, map[string]bool{"alice": true, "bob": true}, rules.AllowedRecipients)
+
+	empty, err := Parse("", "")
+	require.NoError(t, err)
+	assert.Equal(t, Rules{}, empty)
+
+	for _, thresholds := range []string{"USD", "usd:100", "XYZ:100", "USD:ten", "USD:0", "USD:-5"} {
+		_, err := Parse(thresholds, "")
+		assert.Error(t, err, thresholds)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	tx := func(to string, amount int64, currency string) *models.Transaction {
+		return &models.Transaction{FromUserId: "carol", ToUserId: to, Amount: amount, Currency: currency}
+	}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		tx    *models.Transaction
+		want  bool
+	}{
+		{"No Rules", Rules{}, tx("bob", 1_000_000, "USD"), false},
+		{"Below Threshold", Rules{Thresholds: map[string]int64{"USD": 1000}}, tx("bob", 999, "USD"), false},
+		{"At Threshold", Rules{Thresholds: map[string]int64{"USD": 1000}}, tx("bob", 1000, "USD"), true},
+		{"Other Currency", Rules{Thresholds: map[string]int64{"USD": 1000}}, tx("bob", 5000, "EUR"), false},
+		{"Allowed Recipient", Rules{AllowedRecipients: map[string]bool{"bob": true}}, tx("bob", 5000, "USD"), false},
+		{"Other Recipient", Rules{AllowedRecipients: map[string]bool{"bob": true}}, tx("dave", 1, "USD"), true},
+		{"Allowed Recipient Over Threshold", Rules{Thresholds: map[string]int64{"USD": 1000}, AllowedRecipients: map[string]bool{"bob": true}}, tx("bob", 1000, "USD"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rules.Required(tt.tx))
+		})
+	}
+}
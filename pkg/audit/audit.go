@@ -0,0 +1,304 @@
+// Package audit replays the ledger and checks it against the wallets and transactions it records.
+//
+// Every movement of funds writes its ledger entries in the same atomic write as the balance
+// change, so a clean audit proves that balances never drifted from the ledger.
+package audit
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Store is the read access an audit needs.
+type Store interface {
+	GetTransaction(ctx context.Context, txID string) (*models.Transaction, error)
+	ListActivityByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error)
+	ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error)
+	ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error)
+	GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error)
+}
+
+// Kind names the check that a discrepancy failed.
+type Kind string
+
+const (
+	// BalanceMismatch: an account's ledger total (Expected) differs from its wallet's balance plus
+	// reserved funds (Actual). Reserved funds have not left the sender yet, so the ledger still
+	// counts them. An account with ledger entries but no wallet has an Actual of 0.
+	BalanceMismatch Kind = "balance_mismatch"
+	// UnbalancedTransaction: a transaction's ledger debits (Expected) differ from its credits (Actual).
+	UnbalancedTransaction Kind = "unbalanced_transaction"
+	// EntryCount: a transaction has the wrong number of ledger entries. A COMPLETED transaction
+	// must have exactly two and any other transaction none.
+	EntryCount Kind = "entry_count"
+	// MissingTransaction: ledger entries (Actual) refer to a transaction that does not exist.
+	MissingTransaction Kind = "missing_transaction"
+	// FundingMismatch: the funds paid in through models.FundingAccount less those paid out through
+	// models.PayoutsAccount (Expected) differ from the balance plus reserved funds of all wallets
+	// (Actual) in a currency.
+	FundingMismatch Kind = "funding_mismatch"
+	// BrokenChain: the ledger chain of an account in a currency does not verify at the link with
+	// sequence Actual, or does not end at its stored head, so an entry was changed, removed or
+	// inserted outside the store. See storage.VerifyLinks.
+	BrokenChain Kind = "broken_chain"
+)
+
+// Discrepancy is one failed check.
+type Discrepancy struct {
+	Kind          Kind   `json:"kind"`
+	AccountID     string `json:"account_id,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	EntryID       string `json:"entry_id,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	Expected      int64  `json:"expected"`
+	Actual        int64  `json:"actual"`
+	Detail        string `json:"detail"`
+}
+
+// CurrencyTotal compares the funds held by all wallets in a currency with the net funding.
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	// Funded is the funds paid in less the funds paid out.
+	Funded int64 `json:"funded"`
+	// Held is the balance plus reserved funds of all wallets.
+	Held int64 `json:"held"`
+}
+
+// Report is the result of an audit.
+type Report struct {
+	GeneratedAt   time.Time       `json:"generated_at"`
+	Wallets       int             `json:"wallets_checked"`
+	Transactions  int             `json:"transactions_checked"`
+	LedgerEntries int             `json:"ledger_entries_checked"`
+	Totals        []CurrencyTotal `json:"totals"`
+	Discrepancies []Discrepancy   `json:"discrepancies"`
+}
+
+// OK reports whether the audit found no discrepancies.
+func (r *Report) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// txEntries sums the ledger entries of one transaction.
+type txEntries struct {
+	currency      string
+	count         int
+	debit, credit int64
+}
+
+// Run audits the whole store. Storage errors abort the audit; failed checks are reported as
+// discrepancies, ordered by kind and then by account or transaction.
+func Run(ctx context.Context, store Store) (*Report, error) {
+	report := &Report{GeneratedAt: time.Now().UTC(), Discrepancies: []Discrepancy{}}
+
+	wallets, err := collect(func(page storage.PageRequest) ([]models.Wallet, string, error) {
+		return store.ListWallets(ctx, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	// Read the chain heads of the wallets before the entries, so that an entry appended meanwhile
+	// is beyond its head instead of missing from its chain.
+	heads := make(map[storage.ChainKey]storage.ChainHead)
+	for _, wallet := range wallets {
+		for currency := range wallet.Balances {
+			key := storage.ChainKey{AccountID: wallet.UserId, Currency: currency}
+			if heads[key], err = store.GetChainHead(ctx, key); err != nil {
+				return nil, fmt.Errorf("failed to get ledger chain head of %s: %w", key, err)
+			}
+		}
+	}
+	entries, err := collect(func(page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+		return store.ListLedgerEntries(ctx, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ledger entries: %w", err)
+	}
+	report.Wallets, report.LedgerEntries = len(wallets), len(entries)
+	slices.SortFunc(wallets, func(a, b models.Wallet) int { return strings.Compare(a.UserId, b.UserId) })
+
+	// Replay the ledger per account and per transaction, and check every ledger chain.
+	sums := make(map[string]map[string]int64)
+	perTx := make(map[string]*txEntries)
+	chains := make(map[storage.ChainKey][]models.LedgerEntry)
+	for _, entry := range entries {
+		key := storage.ChainKey{AccountID: entry.AccountID, Currency: entry.Currency}
+		chains[key] = append(chains[key], entry)
+		if sums[entry.AccountID] == nil {
+			sums[entry.AccountID] = make(map[string]int64)
+		}
+		sums[entry.AccountID][entry.Currency] += entry.Credit - entry.Debit
+		if perTx[entry.TransactionID] == nil {
+			perTx[entry.TransactionID] = &txEntries{currency: entry.Currency}
+		}
+		perTx[entry.TransactionID].count++
+		perTx[entry.TransactionID].debit += entry.Debit
+		perTx[entry.TransactionID].credit += entry.Credit
+	}
+
+	for _, key := range storage.ChainKeys(entries) {
+		if _, ok := heads[key]; ok {
+			continue
+		}
+		// The chain of a deleted wallet.
+		if heads[key], err = store.GetChainHead(ctx, key); err != nil {
+			return nil, fmt.Errorf("failed to get ledger chain head of %s: %w", key, err)
+		}
+	}
+	keys := slices.SortedFunc(maps.Keys(heads), func(a, b storage.ChainKey) int {
+		return cmp.Or(strings.Compare(a.AccountID, b.AccountID), strings.Compare(a.Currency, b.Currency))
+	})
+	for _, key := range keys {
+		if broken := storage.VerifyLinks(chains[key], heads[key]); broken != nil {
+			report.add(Discrepancy{Kind: BrokenChain, AccountID: key.AccountID, EntryID: broken.EntryID, Currency: key.Currency, Actual: broken.Sequence,
+				Detail: broken.Reason})
+		}
+	}
+
+	// Every transaction touches a wallet, unless the wallet was deleted since; those are found
+	// through their ledger entries instead.
+	txs := make(map[string]models.Transaction)
+	for _, wallet := range wallets {
+		activity, err := collect(func(page storage.PageRequest) ([]models.Transaction, string, error) {
+			return store.ListActivityByUserID(ctx, wallet.UserId, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list transactions of %s: %w", wallet.UserId, err)
+		}
+		for _, tx := range activity {
+			txs[tx.Id] = tx
+		}
+	}
+	for _, txID := range slices.Sorted(maps.Keys(perTx)) {
+		if _, ok := txs[txID]; ok {
+			continue
+		}
+		tx, err := store.GetTransaction(ctx, txID)
+		if errors.Is(err, storage.ErrTransactionNotFound) {
+			report.add(Discrepancy{Kind: MissingTransaction, TransactionID: txID, Currency: perTx[txID].currency, Actual: int64(perTx[txID].count),
+				Detail: "ledger entries refer to a transaction that does not exist"})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction %s: %w", txID, err)
+		}
+		txs[txID] = *tx
+	}
+	report.Transactions = len(txs)
+
+	for _, txID := range slices.Sorted(maps.Keys(perTx)) {
+		if e := perTx[txID]; e.debit != e.credit {
+			report.add(Discrepancy{Kind: UnbalancedTransaction, TransactionID: txID, Currency: e.currency, Expected: e.debit, Actual: e.credit,
+				Detail: "ledger debits and credits of the transaction differ"})
+		}
+	}
+	for _, txID := range slices.Sorted(maps.Keys(txs)) {
+		tx, want := txs[txID], 0
+		if tx.Status == models.COMPLETED {
+			want = 2
+		}
+		var got int
+		if e := perTx[txID]; e != nil {
+			got = e.count
+		}
+		if got != want {
+			report.add(Discrepancy{Kind: EntryCount, TransactionID: txID, Currency: tx.Currency, Expected: int64(want), Actual: int64(got),
+				Detail: fmt.Sprintf("a %s transaction must have %d ledger entries", tx.Status, want)})
+		}
+	}
+
+	// Compare every wallet with its ledger total, and total the funds held per currency.
+	held := make(map[string]int64)
+	walletIDs := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		walletIDs[wallet.UserId] = true
+		currencies := make(map[string]models.CurrencyBalance, len(wallet.Balances))
+		maps.Copy(currencies, wallet.Balances)
+		for currency := range sums[wallet.UserId] {
+			if _, ok := currencies[currency]; !ok {
+				currencies[currency] = models.CurrencyBalance{}
+			}
+		}
+		for _, currency := range slices.Sorted(maps.Keys(currencies)) {
+			balance := currencies[currency]
+			held[currency] += balance.Balance + balance.Reserved
+			if ledger := sums[wallet.UserId][currency]; ledger != balance.Balance+balance.Reserved {
+				report.add(Discrepancy{Kind: BalanceMismatch, AccountID: wallet.UserId, Currency: currency, Expected: ledger, Actual: balance.Balance + balance.Reserved,
+					Detail: "the ledger total differs from the wallet's balance plus reserved funds"})
+			}
+		}
+	}
+	for _, accountID := range slices.Sorted(maps.Keys(sums)) {
+		if walletIDs[accountID] || models.IsSystemAccount(accountID) {
+			continue
+		}
+		for _, currency := range slices.Sorted(maps.Keys(sums[accountID])) {
+			if ledger := sums[accountID][currency]; ledger != 0 {
+				report.add(Discrepancy{Kind: BalanceMismatch, AccountID: accountID, Currency: currency, Expected: ledger,
+					Detail: "the account holds funds in the ledger but has no wallet"})
+			}
+		}
+	}
+
+	currencies := slices.Collect(maps.Keys(held))
+	for _, account := range []string{models.FundingAccount, models.PayoutsAccount} {
+		for currency := range sums[account] {
+			if !slices.Contains(currencies, currency) {
+				currencies = append(currencies, currency)
+			}
+		}
+	}
+	slices.Sort(currencies)
+	for _, currency := range currencies {
+		total := CurrencyTotal{
+			Currency: currency,
+			Funded:   -sums[models.FundingAccount][currency] - sums[models.PayoutsAccount][currency],
+			Held:     held[currency],
+		}
+		report.Totals = append(report.Totals, total)
+		if total.Funded != total.Held {
+			report.add(Discrepancy{Kind: FundingMismatch, Currency: currency, Expected: total.Funded, Actual: total.Held,
+				Detail: "the funds held by all wallets differ from the funds paid in less the funds paid out"})
+		}
+	}
+
+	slices.SortStableFunc(report.Discrepancies, func(a, b Discrepancy) int {
+		return kindOrder(a.Kind) - kindOrder(b.Kind)
+	})
+	return report, nil
+}
+
+func (r *Report) add(d Discrepancy) {
+	r.Discrepancies = append(r.Discrepancies, d)
+}
+
+// kindOrder orders discrepancies from the entry level up to the funding totals.
+func kindOrder(kind Kind) int {
+	return slices.Index([]Kind{BrokenChain, MissingTransaction, UnbalancedTransaction, EntryCount, BalanceMismatch, FundingMismatch}, kind)
+}
+
+// collect calls list until it stops returning a cursor and returns every item.
+func collect[T any](list func(page storage.PageRequest) ([]T, string, error)) ([]T, error) {
+	var all []T
+	page := storage.PageRequest{Limit: storage.MaxPageSize}
+	for {
+		items, next, err := list(page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if next == "" {
+			return all, nil
+		}
+		page.Cursor = next
+	}
+}
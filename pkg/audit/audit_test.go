@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// driftingStore changes what an audit reads, as if a write had drifted.
+type driftingStore struct {
+	*memory.Store
+	walletChange func(*models.Wallet)
+	keepEntry    func(models.LedgerEntry) bool
+	entryChange  func(*models.LedgerEntry)
+}
+
+func (s *driftingStore) ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error) {
+	wallets, next, err := s.Store.ListWallets(ctx, page)
+	if s.walletChange != nil {
+		for i := range wallets {
+			s.walletChange(&wallets[i])
+		}
+	}
+	return wallets, next, err
+}
+
+func (s *driftingStore) ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	entries, next, err := s.Store.ListLedgerEntries(ctx, page)
+	if s.entryChange != nil {
+		for i := range entries {
+			s.entryChange(&entries[i])
+		}
+	}
+	if s.keepEntry == nil {
+		return entries, next, err
+	}
+	var kept []models.LedgerEntry
+	for _, entry := range entries {
+		if s.keepEntry(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, next, err
+}
+
+// newStore returns a store with settled, reserved, cancelled and withdrawn transactions, and the
+// ID of the settled one.
+func newStore(t *testing.T) (*memory.Store, string) {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.New()
+	for userID, balance := range map[string]int64{"alice": 500, "bob": 0} {
+		_, err := store.CreateWallet(ctx, &models.Wallet{UserId: userID, Balances: map[string]models.CurrencyBalance{"USD": {Balance: balance}}, Version: 1, CreatedAt: time.Now()})
+		require.NoError(t, err)
+	}
+	settled, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+	_, err = store.SettleTransaction(ctx, settled, "worker-1")
+	require.NoError(t, err)
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 50, Currency: "USD"})
+	require.NoError(t, err)
+	cancelled, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 25, Currency: "USD"})
+	require.NoError(t, err)
+	require.NoError(t, store.CancelTransaction(ctx, cancelled.Id))
+	_, err = store.Withdraw(ctx, &models.Transaction{FromUserId: "bob", Amount: 30, Currency: "USD"})
+	require.NoError(t, err)
+	return store, settled.Id
+}
+
+func TestRun(t *testing.T) {
+	t.Run("Clean", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		report, err := Run(context.Background(), store)
+
+		require.NoError(t, err)
+		assert.True(t, report.OK(), "unexpected discrepancies: %+v", report.Discrepancies)
+		assert.Equal(t, 2, report.Wallets)
+		assert.Equal(t, 5, report.Transactions)
+		assert.Equal(t, 6, report.LedgerEntries)
+		assert.Equal(t, []CurrencyTotal{{Currency: "USD", Funded: 470, Held: 470}}, report.Totals)
+	})
+
+	t.Run("Balance Drift", func(t *testing.T) {
+		store, _ := newStore(t)
+		drifting := &driftingStore{Store: store, walletChange: func(wallet *models.Wallet) {
+			if wallet.UserId == "bob" {
+				wallet.Balances["USD"] = models.CurrencyBalance{Balance: wallet.Balances["USD"].Balance + 7}
+			}
+		}}
+
+		report, err := Run(context.Background(), drifting)
+
+		require.NoError(t, err)
+		assert.Equal(t, []Discrepancy{
+			{Kind: BalanceMismatch, AccountID: "bob", Currency: "USD", Expected: 70, Actual: 77, Detail: "the ledger total differs from the wallet's balance plus reserved funds"},
+			{Kind: FundingMismatch, Currency: "USD", Expected: 470, Actual: 477, Detail: "the funds held by all wallets differ from the funds paid in less the funds paid out"},
+		}, report.Discrepancies)
+	})
+
+	t.Run("Missing Ledger Entry", func(t *testing.T) {
+		store, settledID := newStore(t)
+		drifting := &driftingStore{Store: store, keepEntry: func(entry models.LedgerEntry) bool {
+			return entry.TransactionID != settledID || entry.AccountID != "bob"
+		}}
+
+		report, err := Run(context.Background(), drifting)
+
+		require.NoError(t, err)
+		kinds := make([]Kind, len(report.Discrepancies))
+		for i, d := range report.Discrepancies {
+			kinds[i] = d.Kind
+		}
+		assert.Equal(t, []Kind{BrokenChain, UnbalancedTransaction, EntryCount, BalanceMismatch}, kinds)
+		assert.Equal(t, "bob", report.Discrepancies[0].AccountID)
+		assert.Equal(t, int64(1), report.Discrepancies[0].Actual, "bob's chain starts with the missing settlement entry")
+		assert.Equal(t, settledID, report.Discrepancies[1].TransactionID)
+		assert.Equal(t, int64(2), report.Discrepancies[2].Expected)
+		assert.Equal(t, int64(1), report.Discrepancies[2].Actual)
+		assert.Equal(t, "bob", report.Discrepancies[3].AccountID)
+	})
+
+	t.Run("Truncated Chain", func(t *testing.T) {
+		store, _ := newStore(t)
+		var withdrawalID string
+		drifting := &driftingStore{Store: store, keepEntry: func(entry models.LedgerEntry) bool {
+			if entry.AccountID == "bob" && entry.Debit > 0 {
+				withdrawalID = entry.TransactionID
+				return false
+			}
+			return true
+		}}
+
+		report, err := Run(context.Background(), drifting)
+
+		require.NoError(t, err)
+		require.NotEmpty(t, report.Discrepancies)
+		assert.Equal(t, Discrepancy{Kind: BrokenChain, AccountID: "bob", Currency: "USD", Actual: 2, Detail: "entry is missing; the chain head is at sequence 2"}, report.Discrepancies[0],
+			"bob's last entry, the withdrawal, is missing from the end of the chain")
+		assert.Equal(t, withdrawalID, report.Discrepancies[1].TransactionID)
+	})
+
+	t.Run("Tampered Entry", func(t *testing.T) {
+		store, settledID := newStore(t)
+		var tamperedID string
+		drifting := &driftingStore{Store: store, entryChange: func(entry *models.LedgerEntry) {
+			if entry.TransactionID == settledID && entry.AccountID == "alice" {
+				entry.Description = "Refund"
+				tamperedID = entry.EntryID
+			}
+		}}
+
+		report, err := Run(context.Background(), drifting)
+
+		require.NoError(t, err)
+		assert.Equal(t, []Discrepancy{
+			{Kind: BrokenChain, AccountID: "alice", EntryID: tamperedID, Currency: "USD", Actual: 2, Detail: "hash does not match the entry's content"},
+		}, report.Discrepancies)
+	})
+
+	t.Run("Deleted Wallet", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.DeleteWallet(context.Background(), "bob"))
+
+		report, err := Run(context.Background(), store)
+
+		require.NoError(t, err)
+		require.Len(t, report.Discrepancies, 2)
+		assert.Equal(t, Discrepancy{Kind: BalanceMismatch, AccountID: "bob", Currency: "USD", Expected: 70, Detail: "the account holds funds in the ledger but has no wallet"}, report.Discrepancies[0])
+		assert.Equal(t, FundingMismatch, report.Discrepancies[1].Kind)
+	})
+}
@@ -0,0 +1,34 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	for _, code := range []string{"USD", "EUR", "GBP", "JPY"} {
+		assert.True(t, Valid(code), code)
+	}
+	for _, code := range []string{"", "usd", "US", "USDD", "XXX", "XAU", "ABC"} {
+		assert.False(t, Valid(code), code)
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		code   string
+		digits int
+		ok     bool
+	}{
+		{"USD", 2, true},
+		{"JPY", 0, true},
+		{"KWD", 3, true},
+		{"XXX", 0, false},
+	}
+	for _, tt := range tests {
+		digits, ok := MinorUnits(tt.code)
+		assert.Equal(t, tt.ok, ok, tt.code)
+		assert.Equal(t, tt.digits, digits, tt.code)
+	}
+}
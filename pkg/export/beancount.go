@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// Beancount exports keep the books of the wallet service: wallet balances are funds owed to their
+// holders, so wallets are liabilities, and the system accounts are the assets that fund them.
+const (
+	walletsAccount = "Liabilities:Wallets"
+	systemAccount  = "Assets:System"
+)
+
+// systemAccounts maps the system accounts to Beancount accounts.
+var systemAccounts = map[string]string{
+	models.FundingAccount: "Assets:Funding",
+	models.PayoutsAccount: "Assets:Payouts",
+}
+
+// beancountWriter writes each ledger transaction as a Beancount transaction with one posting per
+// ledger entry. Both entries of a transaction share its timestamp, so entries are buffered until
+// the timestamp changes and then grouped by transaction.
+type beancountWriter struct {
+	w *bufio.Writer
+	// group holds the entries that share the timestamp of the last entry written.
+	group []models.LedgerEntry
+	// opened holds the Beancount accounts that have been opened.
+	opened map[string]bool
+}
+
+func newBeancountWriter(w io.Writer) *beancountWriter {
+	return &beancountWriter{w: bufio.NewWriter(w), opened: make(map[string]bool)}
+}
+
+func (b *beancountWriter) write(entry *models.LedgerEntry) error {
+	if len(b.group) > 0 && !b.group[0].Timestamp.Equal(entry.Timestamp) {
+		if err := b.writeGroup(); err != nil {
+			return err
+		}
+	}
+	b.group = append(b.group, *entry)
+	return nil
+}
+
+func (b *beancountWriter) flush(final bool) error {
+	// A page may end between the entries of a transaction, so the last group is kept until the end.
+	if final && len(b.group) > 0 {
+		if err := b.writeGroup(); err != nil {
+			return err
+		}
+	}
+	return b.w.Flush()
+}
+
+// writeGroup writes the transactions of the buffered entries in the order they first appear.
+func (b *beancountWriter) writeGroup() error {
+	var order []string
+	byTx := make(map[string][]models.LedgerEntry)
+	for _, entry := range b.group {
+		if _, ok := byTx[entry.TransactionID]; !ok {
+			order = append(order, entry.TransactionID)
+		}
+		byTx[entry.TransactionID] = append(byTx[entry.TransactionID], entry)
+	}
+	b.group = b.group[:0]
+
+	for _, txID := range order {
+		if err := b.writeTransaction(byTx[txID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *beancountWriter) writeTransaction(entries []models.LedgerEntry) error {
+	first := entries[0]
+	date := first.Timestamp.UTC().Format(time.DateOnly)
+
+	for _, entry := range entries {
+		account := beancountAccount(entry.AccountID)
+		if b.opened[account] {
+			continue
+		}
+		b.opened[account] = true
+		fmt.Fprintf(b.w, "%s open %s\n  account_id: %s\n\n", date, account, quote(entry.AccountID))
+	}
+
+	fmt.Fprintf(b.w, "%s * %s\n  transaction_id: %s\n  timestamp: %s\n", date, quote(first.Description), quote(first.TransactionID),
+		quote(first.Timestamp.UTC().Format(time.RFC3339Nano)))
+	for _, entry := range entries {
+		amount, err := formatAmount(entry.Debit-entry.Credit, entry.Currency)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b.w, "  %s  %s %s\n    entry_id: %s\n", beancountAccount(entry.AccountID), amount, entry.Currency, quote(entry.EntryID))
+	}
+	_, err := b.w.WriteString("\n")
+	return err
+}
+
+// beancountAccount maps a ledger account ID to a Beancount account name, so that distinct IDs
+// always map to distinct names. The user ID is also kept on the account's open directive.
+func beancountAccount(accountID string) string {
+	if account, ok := systemAccounts[accountID]; ok {
+		return account
+	}
+	if name, ok := strings.CutPrefix(accountID, models.SystemAccountPrefix); ok {
+		return systemAccount + ":" + accountComponent(name)
+	}
+	return walletsAccount + ":" + accountComponent(accountID)
+}
+
+// accountComponent encodes a name as a Beancount account name component, which must start with a
+// capital letter or digit and may only hold letters, digits and dashes. Letters and digits are
+// kept as they are and every other byte, dashes included, becomes a dash and two upper-case hex
+// digits. A component that would not start with a capital letter or digit is prefixed with "X-",
+// which no escape produces, so that the encoding stays injective.
+func accountComponent(name string) string {
+	var component strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			component.WriteByte(c)
+		} else {
+			fmt.Fprintf(&component, "-%02X", c)
+		}
+	}
+	encoded := component.String()
+	if encoded == "" || !(encoded[0] >= 'A' && encoded[0] <= 'Z' || encoded[0] >= '0' && encoded[0] <= '9') {
+		return "X-" + encoded
+	}
+	return encoded
+}
+
+// formatAmount formats an amount in minor units as a decimal in the currency's major unit.
+func formatAmount(minor int64, code string) (string, error) {
+	digits, ok := currency.MinorUnits(code)
+	if !ok {
+		return "", fmt.Errorf("unknown currency %q", code)
+	}
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	if digits == 0 {
+		return fmt.Sprintf("%s%d", sign, minor), nil
+	}
+	scale := int64(1)
+	for range digits {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/scale, digits, minor%scale), nil
+}
+
+// quote returns s as a Beancount string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
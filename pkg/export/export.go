@@ -0,0 +1,149 @@
+// Package export writes the ledger in formats that accounting tools import.
+//
+// Exports page through the ledger and write each page as it is read, so an export never holds
+// more than about a page of entries in memory, whatever its range.
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Reader is the read access an export needs.
+type Reader interface {
+	ListLedgerEntriesBetween(ctx context.Context, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error)
+}
+
+// Format is an export file format.
+type Format string
+
+const (
+	// CSV writes one row per ledger entry, with the fields of the JSON API and amounts in minor units.
+	CSV Format = "csv"
+	// Beancount writes one balanced transaction per ledger transaction, in major units.
+	Beancount Format = "beancount"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case CSV, Beancount:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, want %q or %q", name, CSV, Beancount)
+	}
+}
+
+// ContentType returns the media type of an export in the format.
+func (f Format) ContentType() string {
+	if f == Beancount {
+		return "text/plain; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileName returns a file name for an export of [from, to) in the format.
+func (f Format) FileName(from, to time.Time) string {
+	const day = "20060102"
+	return fmt.Sprintf("ledger-%s-%s.%s", from.UTC().Format(day), to.UTC().Format(day), f)
+}
+
+// entryWriter writes a stream of ledger entries, oldest first.
+type entryWriter interface {
+	write(entry *models.LedgerEntry) error
+	// flush writes out everything buffered so far. It is called after each page and at the end.
+	flush(final bool) error
+}
+
+// Write streams the ledger entries with a timestamp in [from, to) to w in the given format.
+// Once Write has returned an error, w may hold a partial export.
+func Write(ctx context.Context, w io.Writer, reader Reader, format Format, from, to time.Time) error {
+	var out entryWriter
+	switch format {
+	case CSV:
+		out = newCSVWriter(w)
+	case Beancount:
+		out = newBeancountWriter(w)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	page := storage.PageRequest{Limit: storage.MaxPageSize}
+	for {
+		entries, next, err := reader.ListLedgerEntriesBetween(ctx, from, to, page)
+		if err != nil {
+			return fmt.Errorf("failed to read ledger entries: %w", err)
+		}
+		for i := range entries {
+			if err := out.write(&entries[i]); err != nil {
+				return fmt.Errorf("failed to write ledger entry: %w", err)
+			}
+		}
+		if err := out.flush(next == ""); err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+		if next == "" {
+			return nil
+		}
+		page.Cursor = next
+	}
+}
+
+// csvHeader names the columns of a CSV export after the fields of the JSON API.
+var csvHeader = []string{"entry_id", "transaction_id", "account_id", "currency", "debit", "credit", "timestamp", "description", "sequence", "previous_hash", "hash"}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) write(entry *models.LedgerEntry) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	var sequence string
+	if entry.Sequence != 0 {
+		sequence = strconv.FormatInt(entry.Sequence, 10)
+	}
+	return c.w.Write([]string{
+		entry.EntryID,
+		entry.TransactionID,
+		entry.AccountID,
+		entry.Currency,
+		strconv.FormatInt(entry.Debit, 10),
+		strconv.FormatInt(entry.Credit, 10),
+		entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		entry.Description,
+		sequence,
+		entry.PreviousHash,
+		entry.Hash,
+	})
+}
+
+// writeHeader writes the header row once, so that even an empty export has one.
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	return c.w.Write(csvHeader)
+}
+
+func (c *csvWriter) flush(final bool) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
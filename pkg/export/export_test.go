@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedReader serves fixed entries a few at a time, ignoring the requested limit.
+type pagedReader struct {
+	entries  []models.LedgerEntry
+	pageSize int
+	from, to time.Time
+}
+
+func (r *pagedReader) ListLedgerEntriesBetween(ctx context.Context, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	r.from, r.to = from, to
+	start := 0
+	if page.Cursor != "" {
+		start, _ = strconv.Atoi(page.Cursor)
+	}
+	end := min(start+r.pageSize, len(r.entries))
+	if end == len(r.entries) {
+		return r.entries[start:end], "", nil
+	}
+	return r.entries[start:end], strconv.Itoa(end), nil
+}
+
+var (
+	deposited = time.Date(2025, time.March, 1, 9, 30, 0, 0, time.UTC)
+	settled   = time.Date(2025, time.March, 2, 17, 5, 0, 123456000, time.UTC)
+)
+
+// ledger holds a deposit and a settlement. Pages of three split the settlement's entries.
+func ledger() []models.LedgerEntry {
+	return []models.LedgerEntry{
+		{EntryID: "e1", TransactionID: "tx-1", AccountID: models.FundingAccount, Currency: "USD", Debit: 50000, Description: "Deposit for transaction tx-1", Timestamp: deposited, Sequence: 1, Hash: "h1"},
+		{EntryID: "e2", TransactionID: "tx-1", AccountID: "alice", Currency: "USD", Credit: 50000, Description: "Deposit for transaction tx-1", Timestamp: deposited, Sequence: 1, Hash: "h2"},
+		{EntryID: "e3", TransactionID: "tx-2", AccountID: "alice", Currency: "USD", Debit: 1250, Description: "Settlement for transaction tx-2", Timestamp: settled, Sequence: 2, PreviousHash: "h2", Hash: "h3"},
+		{EntryID: "e4", TransactionID: "tx-2", AccountID: "bob.smith", Currency: "USD", Credit: 1250, Description: "Settlement for transaction tx-2", Timestamp: settled, Sequence: 1, Hash: "h4"},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	from, to := deposited.Add(-time.Hour), settled.Add(time.Hour)
+
+	t.Run("CSV", func(t *testing.T) {
+		reader := &pagedReader{entries: ledger(), pageSize: 3}
+		var out bytes.Buffer
+
+		require.NoError(t, Write(context.Background(), &out, reader, CSV, from, to))
+
+		assert.Equal(t, from, reader.from)
+		assert.Equal(t, to, reader.to)
+		assert.Equal(t, `entry_id,transaction_id,account_id,currency,debit,credit,timestamp,description,sequence,previous_hash,hash
+e1,tx-1,SYSTEM:FUNDING,USD,50000,0,2025-03-01T09:30:00Z,Deposit for transaction tx-1,1,,h1
+e2,tx-1,alice,USD,0,50000,2025-03-01T09:30:00Z,Deposit for transaction tx-1,1,,h2
+e3,tx-2,alice,USD,1250,0,2025-03-02T17:05:00.123456Z,Settlement for transaction tx-2,2,h2,h3
+e4,tx-2,bob.smith,USD,0,1250,2025-03-02T17:05:00.123456Z,Settlement for transaction tx-2,1,,h4
+`, out.String())
+	})
+
+	t.Run("Empty CSV", func(t *testing.T) {
+		var out bytes.Buffer
+
+		require.NoError(t, Write(context.Background(), &out, &pagedReader{pageSize: 3}, CSV, from, to))
+
+		assert.Equal(t, "entry_id,transaction_id,account_id,currency,debit,credit,timestamp,description,sequence,previous_hash,hash\n", out.String())
+	})
+
+	t.Run("Beancount", func(t *testing.T) {
+		reader := &pagedReader{entries: ledger(), pageSize: 3}
+		var out bytes.Buffer
+
+		require.NoError(t, Write(context.Background(), &out, reader, Beancount, from, to))
+
+		assert.Equal(t, `2025-03-01 open Assets:Funding
+  account_id: "SYSTEM:FUNDING"
+
+2025-03-01 open Liabilities:Wallets:X-alice
+  account_id: "alice"
+
+2025-03-01 * "Deposit for transaction tx-1"
+  transaction_id: "tx-1"
+  timestamp: "2025-03-01T09:30:00Z"
+  Assets:Funding  500.00 USD
+    entry_id: "e1"
+  Liabilities:Wallets:X-alice  -500.00 USD
+    entry_id: "e2"
+
+2025-03-02 open Liabilities:Wallets:X-bob-2Esmith
+  account_id: "bob.smith"
+
+2025-03-02 * "Settlement for transaction tx-2"
+  transaction_id: "tx-2"
+  timestamp: "2025-03-02T17:05:00.123456Z"
+  Liabilities:Wallets:X-alice  12.50 USD
+    entry_id: "e3"
+  Liabilities:Wallets:X-bob-2Esmith  -12.50 USD
+    entry_id: "e4"
+
+`, out.String())
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"csv", "beancount"} {
+		format, err := ParseFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, Format(name), format)
+	}
+	_, err := ParseFormat("xlsx")
+	assert.Error(t, err)
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     string
+	}{
+		{12345, "USD", "123.45"},
+		{-5, "USD", "-0.05"},
+		{0, "EUR", "0.00"},
+		{1500, "JPY", "1500"},
+		{1234, "KWD", "1.234"},
+	}
+	for _, tt := range tests {
+		got, err := formatAmount(tt.minor, tt.currency)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+	_, err := formatAmount(100, "XXX")
+	assert.Error(t, err)
+}
+
+func TestBeancountAccount(t *testing.T) {
+	assert.Equal(t, "Assets:Payouts", beancountAccount(models.PayoutsAccount))
+	assert.Equal(t, "Assets:System:FEES", beancountAccount("SYSTEM:FEES"))
+	assert.Equal(t, "Liabilities:Wallets:X-user-5F42", beancountAccount("user_42"))
+	assert.Equal(t, "Liabilities:Wallets:42", beancountAccount("42"))
+	assert.Equal(t, "Liabilities:Wallets:Bob-2D2", beancountAccount("Bob-2"))
+	assert.Equal(t, "Liabilities:Wallets:X--5Fx", beancountAccount("_x"))
+	assert.Equal(t, "Liabilities:Wallets:X-", beancountAccount(""))
+	assert.Equal(t, "Liabilities:Wallets:X-j-C3-BCrgen", beancountAccount("jürgen"))
+
+	t.Run("Distinct IDs Keep Distinct Accounts", func(t *testing.T) {
+		ids := []string{"a.b", "a_b", "a-b", "a-2Eb", "alice", "Alice", "X-alice", "x", "X", "X-", "", "-", "_", "1", "-31"}
+		accounts := make(map[string]string)
+		for _, id := range ids {
+			account := beancountAccount(id)
+			if other, ok := accounts[account]; ok {
+				t.Errorf("%q and %q both map to %s", other, id, account)
+			}
+			accounts[account] = id
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package funds
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
+	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
+)
+
+// FundsHandler holds the dependencies for deposit and withdrawal handlers.
+type FundsHandler struct {
+	Store     storage.ApiStore
+	Publisher websockets.Publisher
+}
+
+// NewFundsHandler creates a new FundsHandler.
+func NewFundsHandler(store storage.ApiStore, publisher websockets.Publisher) *FundsHandler {
+	return &FundsHandler{Store: store, Publisher: publisher}
+}
+
+// CreateDeposit handles the logic for depositing funds into a user's wallet.
+func (h *FundsHandler) CreateDeposit(w http.ResponseWriter, r *http.Request, userId string) {
+	movement, ok := decodeMovement(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.Store.Deposit(r.Context(), mapping.ToDomainDeposit(userId, movement))
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	h.publish(r, userId, tx, tx.Amount)
+	writeTransaction(w, tx)
+}
+
+// CreateWithdrawal handles the logic for withdrawing funds from a user's wallet.
+func (h *FundsHandler) CreateWithdrawal(w http.ResponseWriter, r *http.Request, userId string) {
+	movement, ok := decodeMovement(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.Store.Withdraw(r.Context(), mapping.ToDomainWithdrawal(userId, movement))
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	h.publish(r, userId, tx, -tx.Amount) // Negative because it's a deduction
+	writeTransaction(w, tx)
+}
+
+// decodeMovement reads and validates the request body. It writes a problem response and returns
+// false if the body is invalid.
+func decodeMovement(w http.ResponseWriter, r *http.Request) (*api.NewFundsMovement, bool) {
+	var movement api.NewFundsMovement
+	if err := json.NewDecoder(r.Body).Decode(&movement); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return nil, false
+	}
+	if !currency.Valid(movement.Currency) {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %q is not an ISO 4217 currency code", movement.Currency))
+		return nil, false
+	}
+	if movement.Amount <= 0 {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request: amount must be positive")
+		return nil, false
+	}
+	return &movement, true
+}
+
+// publish sends the wallet's new balance to the user via WebSocket. Failures are logged and
+// do not fail the request, because the funds have already moved.
+func (h *FundsHandler) publish(r *http.Request, userId string, tx *models.Transaction, change int64) {
+	wallet, err := h.Store.GetWallet(r.Context(), userId)
+	if err != nil {
+		log.Printf("ERROR: failed to get wallet for websocket message: %v", err)
+		return
+	}
+	msg := websockets.Message{
+		Type: websockets.MessageTypeWalletUpdate,
+		Payload: websockets.WalletUpdatePayload{
+			UserID:        userId,
+			TransactionID: tx.Id,
+			Currency:      tx.Currency,
+			Change:        change,
+			NewBalance:    wallet.Balances[tx.Currency].Balance,
+		},
+	}
+	if err := h.Publisher.Publish(r.Context(), msg); err != nil {
+		log.Printf("ERROR: failed to publish websocket message: %v", err)
+	}
+}
+
+func writeTransaction(w http.ResponseWriter, tx *models.Transaction) {
+	apiTx := mapping.ToApiTransaction(tx)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(apiTx); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
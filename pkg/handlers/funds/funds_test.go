@@ -0,0 +1,133 @@
+package funds_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/funds"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/mocks"
+	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateDeposit(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		deposited := &models.Transaction{Id: "tx-1", FromUserId: models.FundingAccount, ToUserId: "user-a", Amount: 500, Currency: "USD", Status: models.COMPLETED}
+		mockStorage.On("Deposit", mock.Anything, &models.Transaction{ToUserId: "user-a", Amount: 500, Currency: "USD"}).Return(deposited, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user-a").Return(&models.Wallet{UserId: "user-a", Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1500}}}, nil)
+
+		h := funds.NewFundsHandler(mockStorage, new(websockets.NoOpPublisher))
+
+		body, _ := json.Marshal(api.NewFundsMovement{Amount: 500, Currency: "USD"})
+		req := httptest.NewRequest(http.MethodPost, "/wallets/user-a/deposits", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateDeposit(rr, req, "user-a")
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var created api.Transaction
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, "tx-1", *created.Id)
+		assert.Equal(t, models.FundingAccount, *created.FromUserId)
+		assert.Equal(t, api.TransactionStatusCOMPLETED, *created.Status)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Request", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+
+		h := funds.NewFundsHandler(mockStorage, new(websockets.NoOpPublisher))
+
+		for _, movement := range []api.NewFundsMovement{{Amount: 500, Currency: "usd"}, {Amount: 0, Currency: "USD"}, {Amount: -5, Currency: "USD"}} {
+			body, _ := json.Marshal(movement)
+			req := httptest.NewRequest(http.MethodPost, "/wallets/user-a/deposits", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			h.CreateDeposit(rr, req, "user-a")
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		}
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Currency Not Held", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("Deposit", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: EUR", storage.ErrCurrencyNotHeld))
+
+		h := funds.NewFundsHandler(mockStorage, new(websockets.NoOpPublisher))
+
+		body, _ := json.Marshal(api.NewFundsMovement{Amount: 500, Currency: "EUR"})
+		req := httptest.NewRequest(http.MethodPost, "/wallets/user-a/deposits", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateDeposit(rr, req, "user-a")
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("Deposit", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: user ID user-x", storage.ErrWalletNotFound))
+
+		h := funds.NewFundsHandler(mockStorage, new(websockets.NoOpPublisher))
+
+		body, _ := json.Marshal(api.NewFundsMovement{Amount: 500, Currency: "USD"})
+		req := httptest.NewRequest(http.MethodPost, "/wallets/user-x/deposits", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateDeposit(rr, req, "user-x")
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestCreateWithdrawal(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		withdrawn := &models.Transaction{Id: "tx-2", FromUserId: "user-a", ToUserId: models.PayoutsAccount, Amount: 200, Currency: "USD", Status: models.COMPLETED}
+		mockStorage.On("Withdraw", mock.Anything, &models.Transaction{FromUserId: "user-a", Amount: 200, Currency: "USD"}).Return(withdrawn, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user-a").Return(&models.Wallet{UserId: "user-a", Balances: map[string]models.CurrencyBalance{"USD": {Balance: 800}}}, nil)
+
+		h := funds.NewFundsHandler(mockStorage, new(websockets.NoOpPublisher))
+
+		body, _ := json.Marshal(api.NewFundsMovement{Amount: 200, Currency: "USD"})
+		req := httptest.NewRequest(http.MethodPost, "/wallets/user-a/withdrawals", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateWithdrawal(rr, req, "user-a")
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var created api.Transaction
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, models.PayoutsAccount, *created.ToUserId)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Insufficient Funds", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("Withdraw", mock.Anything, mock.Anything).Return(nil, storage.ErrInsufficientFunds)
+
+		h := funds.NewFundsHandler(mockStorage, new(websockets.NoOpPublisher))
+
+		body, _ := json.Marshal(api.NewFundsMovement{Amount: 5000, Currency: "USD"})
+		req := httptest.NewRequest(http.MethodPost, "/wallets/user-a/withdrawals", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateWithdrawal(rr, req, "user-a")
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+}
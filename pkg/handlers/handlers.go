@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/funds"
 	"github.com/chris/delayed-wallet-transactions/pkg/handlers/ledger"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/recurringtransfers"
 	"github.com/chris/delayed-wallet-transactions/pkg/handlers/transactions"
 	"github.com/chris/delayed-wallet-transactions/pkg/handlers/wallets"
 	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
@@ -14,17 +17,24 @@ import (
 type ApiHandler struct {
 	*transactions.TransactionsHandler
 	*wallets.WalletsHandler
+	*funds.FundsHandler
 	*ledger.LedgerHandler
+	*recurringtransfers.RecurringTransfersHandler
 }
 
 // Make sure we conform to the generated server interface.
 var _ api.ServerInterface = (*ApiHandler)(nil)
 
-// NewApiHandler creates a new ApiHandler with a storage dependency.
-func NewApiHandler(store storage.ApiStore, scheduler scheduler.CronScheduler, publisher websockets.Publisher) *ApiHandler {
+// NewApiHandler creates a new ApiHandler with a storage dependency. New transactions that match
+// the approval rules wait for approval before they are enqueued.
+func NewApiHandler(store storage.ApiStore, scheduler scheduler.CronScheduler, publisher websockets.Publisher, rules approval.Rules) *ApiHandler {
+	transactionsHandler := transactions.NewTransactionsHandler(store, scheduler, publisher)
+	transactionsHandler.Approval = rules
 	return &ApiHandler{
-		TransactionsHandler: transactions.NewTransactionsHandler(store, scheduler, publisher),
-		WalletsHandler:      wallets.NewWalletsHandler(store),
-		LedgerHandler:       ledger.NewLedgerHandler(store),
+		TransactionsHandler:       transactionsHandler,
+		WalletsHandler:            wallets.NewWalletsHandler(store),
+		FundsHandler:              funds.NewFundsHandler(store, publisher),
+		LedgerHandler:             ledger.NewLedgerHandler(store),
+		RecurringTransfersHandler: recurringtransfers.NewRecurringTransfersHandler(store),
 	}
 }
@@ -3,9 +3,14 @@ package ledger
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/export"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
 	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 )
@@ -20,26 +25,96 @@ func NewLedgerHandler(store storage.LedgerReader) *LedgerHandler {
 	return &LedgerHandler{Store: store}
 }
 
+// ListLedgerEntries handles the logic for retrieving a page of ledger entries, newest first.
 func (h *LedgerHandler) ListLedgerEntries(w http.ResponseWriter, r *http.Request, params api.ListLedgerEntriesParams) {
-	limit := int32(20)
-	if params.Limit != nil {
-		limit = int32(*params.Limit)
+	page, err := mapping.ToPageRequest(params.Limit, params.Cursor)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
 	}
 
-	domainEntries, err := h.Store.ListLedgerEntries(r.Context(), limit)
+	domainEntries, next, err := h.Store.ListLedgerEntries(r.Context(), page)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve ledger entries: %v", err), http.StatusInternalServerError)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	apiEntries := make([]*api.LedgerEntry, len(domainEntries))
+	apiPage := api.LedgerEntryPage{Items: make([]api.LedgerEntry, len(domainEntries)), NextCursor: mapping.ToApiCursor(next)}
 	for i, entry := range domainEntries {
-		apiEntries[i] = mapping.ToApiLedgerEntry(&entry)
+		apiPage.Items[i] = *mapping.ToApiLedgerEntry(&entry)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(apiEntries); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(apiPage); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
+
+// GetLedgerStatement handles the logic for retrieving a page of a wallet's ledger statement, oldest entry first.
+func (h *LedgerHandler) GetLedgerStatement(w http.ResponseWriter, r *http.Request, userId string, params api.GetLedgerStatementParams) {
+	if !currency.Valid(params.Currency) {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %q is not an ISO 4217 currency code", params.Currency))
+		return
+	}
+	page, err := mapping.ToPageRequest(params.Limit, params.Cursor)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	var from time.Time
+	if params.From != nil {
+		from = *params.From
+	}
+	to := time.Now()
+	if params.To != nil {
+		to = *params.To
+	}
+	if !from.Before(to) {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request: from must be before to")
+		return
+	}
+
+	statement, next, err := storage.GetLedgerStatement(r.Context(), h.Store, userId, params.Currency, from, to, page)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mapping.ToApiLedgerStatement(statement, next)); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
+
+// ExportLedger handles the logic for streaming the ledger entries of a range as a CSV or Beancount file.
+func (h *LedgerHandler) ExportLedger(w http.ResponseWriter, r *http.Request, params api.ExportLedgerParams) {
+	format, err := export.ParseFormat(string(params.Format))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	var from time.Time
+	if params.From != nil {
+		from = *params.From
+	}
+	to := time.Now()
+	if params.To != nil {
+		to = *params.To
+	}
+	if !from.Before(to) {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request: from must be before to")
+		return
+	}
+
+	// The status is sent before the first page is read, so a storage error can only cut the export short.
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", format.FileName(from, to)))
+	w.WriteHeader(http.StatusOK)
+	if err := export.Write(r.Context(), w, h.Store, format, from, to); err != nil {
+		log.Printf("ERROR: failed to export ledger: %v", err)
 	}
 }
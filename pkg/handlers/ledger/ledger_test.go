@@ -2,18 +2,22 @@ package ledger_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
 	"github.com/chris/delayed-wallet-transactions/pkg/handlers/ledger"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/mocks"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestListLedgerEntries(t *testing.T) {
@@ -24,7 +28,7 @@ func TestListLedgerEntries(t *testing.T) {
 			{EntryID: uuid.New().String(), Timestamp: time.Now()},
 			{EntryID: uuid.New().String(), Timestamp: time.Now().Add(-1 * time.Minute)},
 		}
-		mockStorage.On("ListLedgerEntries", mock.Anything, int32(20)).Return(expectedEntries, nil)
+		mockStorage.On("ListLedgerEntries", mock.Anything, storage.PageRequest{}).Return(expectedEntries, "", nil)
 
 		h := ledger.NewLedgerHandler(mockStorage)
 
@@ -37,10 +41,11 @@ func TestListLedgerEntries(t *testing.T) {
 		// Assert
 		assert.Equal(t, http.StatusOK, rr.Code)
 
-		var returnedEntries []api.LedgerEntry
-		json.Unmarshal(rr.Body.Bytes(), &returnedEntries)
-		assert.Len(t, returnedEntries, 2)
-		assert.Equal(t, expectedEntries[0].EntryID, *returnedEntries[0].EntryId)
+		var returnedPage api.LedgerEntryPage
+		json.Unmarshal(rr.Body.Bytes(), &returnedPage)
+		assert.Len(t, returnedPage.Items, 2)
+		assert.Equal(t, expectedEntries[0].EntryID, *returnedPage.Items[0].EntryId)
+		assert.Nil(t, returnedPage.NextCursor)
 
 		mockStorage.AssertExpectations(t)
 	})
@@ -48,7 +53,7 @@ func TestListLedgerEntries(t *testing.T) {
 	t.Run("Storage Error", func(t *testing.T) {
 		// Arrange
 		mockStorage := new(mocks.Storage)
-		mockStorage.On("ListLedgerEntries", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+		mockStorage.On("ListLedgerEntries", mock.Anything, mock.Anything).Return(nil, "", assert.AnError)
 
 		h := ledger.NewLedgerHandler(mockStorage)
 
@@ -63,23 +68,310 @@ func TestListLedgerEntries(t *testing.T) {
 		mockStorage.AssertExpectations(t)
 	})
 
-	t.Run("With Limit", func(t *testing.T) {
+	t.Run("With Limit And Cursor", func(t *testing.T) {
 		// Arrange
 		mockStorage := new(mocks.Storage)
 		limit := int32(10)
+		cursor := "page-2"
 		expectedEntries := []models.LedgerEntry{{EntryID: uuid.New().String()}}
-		mockStorage.On("ListLedgerEntries", mock.Anything, int32(limit)).Return(expectedEntries, nil)
+		mockStorage.On("ListLedgerEntries", mock.Anything, storage.PageRequest{Limit: limit, Cursor: cursor}).Return(expectedEntries, "page-3", nil)
 
 		h := ledger.NewLedgerHandler(mockStorage)
 
-		req := httptest.NewRequest(http.MethodGet, "/ledger?limit=10", nil)
+		req := httptest.NewRequest(http.MethodGet, "/ledger?limit=10&cursor=page-2", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.ListLedgerEntries(rr, req, api.ListLedgerEntriesParams{Limit: &limit, Cursor: &cursor})
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var returnedPage api.LedgerEntryPage
+		json.Unmarshal(rr.Body.Bytes(), &returnedPage)
+		assert.Equal(t, "page-3", *returnedPage.NextCursor)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		limit := int32(101)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/ledger?limit=101", nil)
 		rr := httptest.NewRecorder()
 
 		// Act
 		h.ListLedgerEntries(rr, req, api.ListLedgerEntriesParams{Limit: &limit})
 
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertNotCalled(t, "ListLedgerEntries", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		cursor := "garbage"
+		mockStorage.On("ListLedgerEntries", mock.Anything, mock.Anything).Return(nil, "", fmt.Errorf("%w: bad encoding", storage.ErrInvalidCursor))
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/ledger?cursor=garbage", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.ListLedgerEntries(rr, req, api.ListLedgerEntriesParams{Cursor: &cursor})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestGetLedgerStatement(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		entries := []models.LedgerEntry{
+			{EntryID: uuid.New().String(), AccountID: "user1", Credit: 50, Timestamp: from.Add(time.Hour)},
+			{EntryID: uuid.New().String(), AccountID: "user1", Debit: 20, Timestamp: from.Add(2 * time.Hour)},
+		}
+		mockStorage.On("ListLedgerEntriesByAccount", mock.Anything, "user1", "USD", from, to, storage.PageRequest{}).Return(entries, "", nil)
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", from, "").Return(int64(100), nil)
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", to, "").Return(int64(130), nil)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.GetLedgerStatement(rr, req, "user1", api.GetLedgerStatementParams{Currency: "USD", From: &from, To: &to})
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var statement api.LedgerStatement
+		json.Unmarshal(rr.Body.Bytes(), &statement)
+		assert.Equal(t, "user1", statement.AccountId)
+		assert.Equal(t, int64(100), statement.OpeningBalance)
+		assert.Equal(t, int64(130), statement.ClosingBalance)
+		if assert.Len(t, statement.Lines, 2) {
+			assert.Equal(t, int64(150), *statement.Lines[0].Balance)
+			assert.Equal(t, int64(130), *statement.Lines[1].Balance)
+		}
+		assert.Nil(t, statement.NextCursor)
+
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Later Page", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		first := models.LedgerEntry{EntryID: uuid.New().String(), AccountID: "user1", Credit: 25, Timestamp: from.Add(time.Hour)}
+		second := models.LedgerEntry{EntryID: uuid.New().String(), AccountID: "user1", Credit: 5, Timestamp: from.Add(2 * time.Hour)}
+		mockStorage.On("ListLedgerEntriesByAccount", mock.Anything, "user1", "USD", from, to, storage.PageRequest{Limit: 1}).Return([]models.LedgerEntry{first}, "page-2", nil)
+		mockStorage.On("ListLedgerEntriesByAccount", mock.Anything, "user1", "USD", from, to, storage.PageRequest{Limit: 1, Cursor: "page-2"}).Return([]models.LedgerEntry{second}, "page-3", nil)
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", from, "").Return(int64(0), nil).Once()
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", to, "").Return(int64(40), nil).Once()
+
+		h := ledger.NewLedgerHandler(mockStorage)
+		limit := int32(1)
+		get := func(cursor *string) api.LedgerStatement {
+			rr := httptest.NewRecorder()
+			h.GetLedgerStatement(rr, httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger", nil), "user1",
+				api.GetLedgerStatementParams{Currency: "USD", From: &from, To: &to, Limit: &limit, Cursor: cursor})
+			require.Equal(t, http.StatusOK, rr.Code)
+			var statement api.LedgerStatement
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statement))
+			return statement
+		}
+
+		// Act
+		firstPage := get(nil)
+		require.NotNil(t, firstPage.NextCursor)
+		secondPage := get(firstPage.NextCursor)
+
+		// Assert
+		assert.Equal(t, int64(0), secondPage.OpeningBalance)
+		assert.Equal(t, int64(40), secondPage.ClosingBalance)
+		if assert.Len(t, secondPage.Lines, 1) {
+			assert.Equal(t, int64(30), *secondPage.Lines[0].Balance, "the running balance must continue from the entries before the page")
+		}
+		assert.NotNil(t, secondPage.NextCursor)
+
+		// The balances are only summed for the first page.
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Entries At The Same Time", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		at := from.Add(time.Hour)
+		entries := []models.LedgerEntry{
+			{EntryID: "b", AccountID: "user1", Debit: 80, Timestamp: at},
+			{EntryID: "a", AccountID: "user1", Credit: 100, Timestamp: at},
+		}
+		mockStorage.On("ListLedgerEntriesByAccount", mock.Anything, "user1", "USD", from, to, storage.PageRequest{}).Return(entries, "", nil)
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", from, "").Return(int64(0), nil)
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", to, "").Return(int64(20), nil)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.GetLedgerStatement(rr, httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger", nil), "user1", api.GetLedgerStatementParams{Currency: "USD", From: &from, To: &to})
+
 		// Assert
 		assert.Equal(t, http.StatusOK, rr.Code)
+		var statement api.LedgerStatement
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statement))
+		if assert.Len(t, statement.Lines, 2) {
+			assert.Equal(t, "a", *statement.Lines[0].EntryId, "entries at the same time are ordered by entry ID")
+			assert.Equal(t, int64(100), *statement.Lines[0].Balance)
+			assert.Equal(t, int64(20), *statement.Lines[1].Balance)
+		}
+	})
+
+	t.Run("Cursor Of Another Statement", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("ListLedgerEntriesByAccount", mock.Anything, "user1", "USD", from, to, storage.PageRequest{}).Return([]models.LedgerEntry{}, "page-2", nil)
+		mockStorage.On("SumLedgerEntries", mock.Anything, "user1", "USD", mock.Anything, "").Return(int64(0), nil)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+		rr := httptest.NewRecorder()
+		h.GetLedgerStatement(rr, httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger", nil), "user1", api.GetLedgerStatementParams{Currency: "USD", From: &from, To: &to})
+		var statement api.LedgerStatement
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statement))
+		require.NotNil(t, statement.NextCursor)
+
+		// Act
+		rr = httptest.NewRecorder()
+		h.GetLedgerStatement(rr, httptest.NewRequest(http.MethodGet, "/wallets/user2/ledger", nil), "user2", api.GetLedgerStatementParams{Currency: "USD", Cursor: statement.NextCursor})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Invalid Range", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.GetLedgerStatement(rr, req, "user1", api.GetLedgerStatementParams{Currency: "USD", From: &to, To: &from})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Currency", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger?currency=usd", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.GetLedgerStatement(rr, req, "user1", api.GetLedgerStatementParams{Currency: "usd", From: &from, To: &to})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Storage Error", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("ListLedgerEntriesByAccount", mock.Anything, "user1", "USD", from, to, storage.PageRequest{}).Return(nil, "", assert.AnError)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets/user1/ledger", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.GetLedgerStatement(rr, req, "user1", api.GetLedgerStatementParams{Currency: "USD", From: &from, To: &to})
+
+		// Assert
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestExportLedger(t *testing.T) {
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CSV", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		entries := []models.LedgerEntry{
+			{EntryID: "e1", TransactionID: "tx-1", AccountID: "user-a", Currency: "USD", Debit: 100, Timestamp: from.Add(time.Hour)},
+			{EntryID: "e2", TransactionID: "tx-1", AccountID: "user-b", Currency: "USD", Credit: 100, Timestamp: from.Add(time.Hour)},
+		}
+		mockStorage.On("ListLedgerEntriesBetween", mock.Anything, from, to, storage.PageRequest{Limit: storage.MaxPageSize}).Return(entries, "next", nil).Once()
+		mockStorage.On("ListLedgerEntriesBetween", mock.Anything, from, to, storage.PageRequest{Limit: storage.MaxPageSize, Cursor: "next"}).Return([]models.LedgerEntry{}, "", nil).Once()
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/ledger/export?format=csv", nil)
+		rr := httptest.NewRecorder()
+
+		h.ExportLedger(rr, req, api.ExportLedgerParams{Format: api.Csv, From: &from, To: &to})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="ledger-20250301-20250401.csv"`, rr.Header().Get("Content-Disposition"))
+		assert.Equal(t, 3, strings.Count(rr.Body.String(), "\n"), "a header and a row per entry")
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Beancount", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("ListLedgerEntriesBetween", mock.Anything, from, to, mock.Anything).Return([]models.LedgerEntry{}, "", nil)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/ledger/export?format=beancount", nil)
+		rr := httptest.NewRecorder()
+
+		h.ExportLedger(rr, req, api.ExportLedgerParams{Format: api.Beancount, From: &from, To: &to})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Request", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+
+		h := ledger.NewLedgerHandler(mockStorage)
+
+		for _, params := range []api.ExportLedgerParams{
+			{Format: "xlsx"},
+			{Format: api.Csv, From: &to, To: &from},
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/ledger/export", nil)
+			rr := httptest.NewRecorder()
+
+			h.ExportLedger(rr, req, params)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		}
 		mockStorage.AssertExpectations(t)
 	})
 }
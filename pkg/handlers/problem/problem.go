@@ -0,0 +1,74 @@
+// Package problem writes RFC 7807 problem details responses for the API handlers.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// ContentType is the media type of a problem details response.
+const ContentType = "application/problem+json"
+
+// Write responds with a problem details body for the given status and detail message.
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	body := api.Error{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: int32(status),
+	}
+	if detail != "" {
+		body.Detail = &detail
+	}
+	instance := r.URL.Path
+	body.Instance = &instance
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("ERROR: failed to write problem response: %v", err)
+	}
+}
+
+// WriteError responds with the problem details that match a storage error. Errors
+// that are not part of the storage contract are logged and reported as a generic
+// internal error, so that backend messages never reach the client.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrWalletNotFound):
+		Write(w, r, http.StatusNotFound, "Wallet not found")
+	case errors.Is(err, storage.ErrTransactionNotFound):
+		Write(w, r, http.StatusNotFound, "Transaction not found")
+	case errors.Is(err, storage.ErrRecurringTransferNotFound):
+		Write(w, r, http.StatusNotFound, "Recurring transfer not found")
+	case errors.Is(err, storage.ErrWalletExists):
+		Write(w, r, http.StatusConflict, "Wallet for this user already exists")
+	case errors.Is(err, storage.ErrVersionConflict):
+		Write(w, r, http.StatusConflict, "The resource was modified concurrently, please retry")
+	case errors.Is(err, storage.ErrTransactionNotCancellable):
+		Write(w, r, http.StatusConflict, "Transaction is not in a cancellable state")
+	case errors.Is(err, storage.ErrTransactionNotPendingApproval):
+		Write(w, r, http.StatusConflict, "Transaction is not pending approval")
+	case errors.Is(err, storage.ErrTransactionNotProcessable):
+		Write(w, r, http.StatusConflict, "Transaction is not in a processable state")
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		Write(w, r, http.StatusUnprocessableEntity, "Insufficient funds")
+	case errors.Is(err, storage.ErrCurrencyNotHeld):
+		Write(w, r, http.StatusUnprocessableEntity, "Wallet does not hold the transaction's currency")
+	case errors.Is(err, storage.ErrInvalidCursor):
+		Write(w, r, http.StatusBadRequest, "Invalid cursor")
+	default:
+		log.Printf("ERROR: %s %s failed: %v", r.Method, r.URL.Path, err)
+		Write(w, r, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// WriteBadRequest responds with a 400 problem for a request the generated router could not
+// bind, such as a malformed path or query parameter. It matches api.ChiServerOptions.ErrorHandlerFunc.
+func WriteBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	Write(w, r, http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,71 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"Wallet Not Found", fmt.Errorf("%w: user ID user-a", storage.ErrWalletNotFound), http.StatusNotFound},
+		{"Transaction Not Found", fmt.Errorf("%w: ID tx-1", storage.ErrTransactionNotFound), http.StatusNotFound},
+		{"Wallet Exists", fmt.Errorf("%w: user ID user-a", storage.ErrWalletExists), http.StatusConflict},
+		{"Version Conflict", fmt.Errorf("failed to execute transaction: %w", storage.ErrVersionConflict), http.StatusConflict},
+		{"Not Cancellable", storage.ErrTransactionNotCancellable, http.StatusConflict},
+		{"Not Pending Approval", storage.ErrTransactionNotPendingApproval, http.StatusConflict},
+		{"Insufficient Funds", storage.ErrInsufficientFunds, http.StatusUnprocessableEntity},
+		{"Currency Not Held", fmt.Errorf("%w: user ID user2 has no EUR balance", storage.ErrCurrencyNotHeld), http.StatusUnprocessableEntity},
+		{"Invalid Cursor", storage.ErrInvalidCursor, http.StatusBadRequest},
+		{"Internal", errors.New("operation error DynamoDB: TransactWriteItems, table Wallets"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/wallets/user-a", nil)
+			rr := httptest.NewRecorder()
+
+			problem.WriteError(rr, req, tt.err)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+
+			var body api.Error
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, "about:blank", body.Type)
+			assert.Equal(t, http.StatusText(tt.status), body.Title)
+			assert.Equal(t, int32(tt.status), body.Status)
+			require.NotNil(t, body.Instance)
+			assert.Equal(t, "/wallets/user-a", *body.Instance)
+			assert.NotContains(t, rr.Body.String(), "DynamoDB")
+			assert.NotContains(t, rr.Body.String(), "user ID")
+		})
+	}
+}
+
+func TestWriteBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ledger?limit=abc", nil)
+	rr := httptest.NewRecorder()
+
+	problem.WriteBadRequest(rr, req, errors.New("invalid format for parameter limit"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	var body api.Error
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	require.NotNil(t, body.Detail)
+	assert.Equal(t, "invalid format for parameter limit", *body.Detail)
+	assert.Equal(t, "/ledger", *body.Instance)
+}
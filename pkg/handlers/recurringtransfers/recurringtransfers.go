@@ -0,0 +1,184 @@
+package recurringtransfers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
+	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/recurring"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Store is the storage the recurring transfer handlers need.
+type Store interface {
+	storage.RecurringTransferStore
+	GetWallet(ctx context.Context, userID string) (*models.Wallet, error)
+}
+
+// RecurringTransfersHandler holds the dependencies for recurring transfer handlers.
+// The transactions of a recurring transfer are created by a recurring.Runner, not by the API.
+type RecurringTransfersHandler struct {
+	Store Store
+}
+
+// NewRecurringTransfersHandler creates a new RecurringTransfersHandler.
+func NewRecurringTransfersHandler(store Store) *RecurringTransfersHandler {
+	return &RecurringTransfersHandler{Store: store}
+}
+
+// CreateRecurringTransfer handles the logic for creating a new recurring transfer, active from its first occurrence.
+func (h *RecurringTransfersHandler) CreateRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	var newRT api.NewRecurringTransfer
+	if err := json.NewDecoder(r.Body).Decode(&newRT); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if !currency.Valid(newRT.Currency) {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %q is not an ISO 4217 currency code", newRT.Currency))
+		return
+	}
+	if newRT.Amount < 1 {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request: amount must be positive")
+		return
+	}
+
+	domainRT := mapping.ToDomainNewRecurringTransfer(&newRT)
+	if err := recurring.Start(domainRT); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// Funds are reserved at each occurrence, but the wallets must exist and hold the currency now.
+	for _, userID := range []string{domainRT.FromUserId, domainRT.ToUserId} {
+		wallet, err := h.Store.GetWallet(r.Context(), userID)
+		if err != nil {
+			problem.WriteError(w, r, err)
+			return
+		}
+		if _, err := storage.HeldBalance(wallet, domainRT.Currency); err != nil {
+			problem.WriteError(w, r, err)
+			return
+		}
+	}
+
+	createdRT, err := h.Store.CreateRecurringTransfer(r.Context(), domainRT)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, mapping.ToApiRecurringTransfer(createdRT))
+}
+
+// GetRecurringTransferById handles the logic for retrieving a recurring transfer by its ID.
+func (h *RecurringTransfersHandler) GetRecurringTransferById(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	rt, err := h.Store.GetRecurringTransfer(r.Context(), recurringTransferId)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mapping.ToApiRecurringTransfer(rt))
+}
+
+// PauseRecurringTransfer handles the logic for pausing an active recurring transfer.
+func (h *RecurringTransfersHandler) PauseRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	h.transition(w, r, recurringTransferId, recurring.Pause)
+}
+
+// ResumeRecurringTransfer handles the logic for resuming a paused recurring transfer from its next occurrence.
+func (h *RecurringTransfersHandler) ResumeRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	h.transition(w, r, recurringTransferId, func(rt *models.RecurringTransfer) error {
+		return recurring.Resume(rt, time.Now())
+	})
+}
+
+// CancelRecurringTransfer handles the logic for cancelling an active or paused recurring transfer.
+func (h *RecurringTransfersHandler) CancelRecurringTransfer(w http.ResponseWriter, r *http.Request, recurringTransferId string) {
+	h.transition(w, r, recurringTransferId, recurring.Cancel)
+}
+
+// transition applies a status change to a recurring transfer and stores it. A transfer that changed
+// since it was read, such as by the runner, is reported as a conflict for the client to retry.
+func (h *RecurringTransfersHandler) transition(w http.ResponseWriter, r *http.Request, id string, apply func(*models.RecurringTransfer) error) {
+	rt, err := h.Store.GetRecurringTransfer(r.Context(), id)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+	if err := apply(rt); err != nil {
+		if errors.Is(err, recurring.ErrInvalidTransition) {
+			problem.Write(w, r, http.StatusConflict, fmt.Sprintf("Recurring transfer is %s", rt.Status))
+			return
+		}
+		problem.WriteError(w, r, err)
+		return
+	}
+	if err := h.Store.UpdateRecurringTransfer(r.Context(), rt); err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mapping.ToApiRecurringTransfer(rt))
+}
+
+// ListRecurringTransferOccurrences handles the logic for listing the upcoming occurrences of a recurring transfer.
+func (h *RecurringTransfersHandler) ListRecurringTransferOccurrences(w http.ResponseWriter, r *http.Request, recurringTransferId string, params api.ListRecurringTransferOccurrencesParams) {
+	page, err := mapping.ToPageRequest(params.Limit, nil)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	rt, err := h.Store.GetRecurringTransfer(r.Context(), recurringTransferId)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+	occurrences, err := recurring.Upcoming(rt, int(page.PageSize()))
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.OccurrenceList{Items: occurrences})
+}
+
+// ListRecurringTransfersByUserId handles the logic for retrieving a page of the recurring transfers sent by a user.
+func (h *RecurringTransfersHandler) ListRecurringTransfersByUserId(w http.ResponseWriter, r *http.Request, userId string, params api.ListRecurringTransfersByUserIdParams) {
+	page, err := mapping.ToPageRequest(params.Limit, params.Cursor)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	transfers, next, err := h.Store.ListRecurringTransfersByUserID(r.Context(), userId, page)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	apiPage := api.RecurringTransferPage{Items: make([]api.RecurringTransfer, len(transfers)), NextCursor: mapping.ToApiCursor(next)}
+	for i, rt := range transfers {
+		apiPage.Items[i] = *mapping.ToApiRecurringTransfer(&rt)
+	}
+
+	writeJSON(w, http.StatusOK, apiPage)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package recurringtransfers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/recurringtransfers"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func wallet(userID string) *models.Wallet {
+	return &models.Wallet{UserId: userID, Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}}
+}
+
+func activeTransfer() *models.RecurringTransfer {
+	next := time.Now().Add(24 * time.Hour).Truncate(time.Second).UTC()
+	return &models.RecurringTransfer{
+		Id:         "rt-1",
+		FromUserId: "alice",
+		ToUserId:   "bob",
+		Amount:     100,
+		Currency:   "USD",
+		Schedule:   models.RecurrenceSchedule{Frequency: models.DAILY, StartAt: next},
+		Status:     models.RecurringActive,
+		NextRunAt:  &next,
+	}
+}
+
+func TestCreateRecurringTransfer(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("GetWallet", mock.Anything, "alice").Return(wallet("alice"), nil)
+		mockStorage.On("GetWallet", mock.Anything, "bob").Return(wallet("bob"), nil)
+		mockStorage.On("CreateRecurringTransfer", mock.Anything, mock.AnythingOfType("*models.RecurringTransfer")).
+			Return(func(_ context.Context, rt *models.RecurringTransfer) *models.RecurringTransfer { return rt }, nil)
+
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		body := `{"from_user_id":"alice","to_user_id":"bob","amount":100,"currency":"USD",
+			"schedule":{"frequency":"WEEKLY","start_at":"2030-01-07T09:00:00Z","count":4}}`
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.CreateRecurringTransfer(rr, req)
+
+		// Assert
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var created api.RecurringTransfer
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, api.RecurringTransferStatusACTIVE, created.Status)
+		require.NotNil(t, created.NextRunAt)
+		assert.Equal(t, time.Date(2030, time.January, 7, 9, 0, 0, 0, time.UTC), created.NextRunAt.UTC())
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Schedule", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		body := `{"from_user_id":"alice","to_user_id":"bob","amount":100,"currency":"USD",
+			"schedule":{"cron":"0 9 * * 1","frequency":"WEEKLY","start_at":"2030-01-07T09:00:00Z"}}`
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.CreateRecurringTransfer(rr, req)
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertNotCalled(t, "CreateRecurringTransfer", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wallet Not Found", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("GetWallet", mock.Anything, "alice").Return(nil, storage.ErrWalletNotFound)
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		body := `{"from_user_id":"alice","to_user_id":"bob","amount":100,"currency":"USD",
+			"schedule":{"cron":"0 9 * * 1","start_at":"2030-01-07T09:00:00Z"}}`
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.CreateRecurringTransfer(rr, req)
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		mockStorage.AssertNotCalled(t, "CreateRecurringTransfer", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRecurringTransferTransitions(t *testing.T) {
+	t.Run("Pause", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("GetRecurringTransfer", mock.Anything, "rt-1").Return(activeTransfer(), nil)
+		mockStorage.On("UpdateRecurringTransfer", mock.Anything, mock.MatchedBy(func(rt *models.RecurringTransfer) bool {
+			return rt.Status == models.RecurringPaused && rt.NextRunAt == nil
+		})).Return(nil)
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers/rt-1/pause", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.PauseRecurringTransfer(rr, req, "rt-1")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Transition", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("GetRecurringTransfer", mock.Anything, "rt-1").Return(activeTransfer(), nil)
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers/rt-1/resume", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.ResumeRecurringTransfer(rr, req, "rt-1")
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		mockStorage.AssertNotCalled(t, "UpdateRecurringTransfer", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Concurrent Update", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("GetRecurringTransfer", mock.Anything, "rt-1").Return(activeTransfer(), nil)
+		mockStorage.On("UpdateRecurringTransfer", mock.Anything, mock.Anything).Return(storage.ErrVersionConflict)
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers/rt-1/cancel", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.CancelRecurringTransfer(rr, req, "rt-1")
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		mockStorage.On("GetRecurringTransfer", mock.Anything, "missing").Return(nil, storage.ErrRecurringTransferNotFound)
+		h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodPost, "/recurring-transfers/missing/cancel", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.CancelRecurringTransfer(rr, req, "missing")
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestListRecurringTransferOccurrences(t *testing.T) {
+	// Arrange
+	mockStorage := new(mocks.Storage)
+	rt := activeTransfer()
+	mockStorage.On("GetRecurringTransfer", mock.Anything, "rt-1").Return(rt, nil)
+	h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+	limit := int32(3)
+	req := httptest.NewRequest(http.MethodGet, "/recurring-transfers/rt-1/occurrences?limit=3", nil)
+	rr := httptest.NewRecorder()
+
+	// Act
+	h.ListRecurringTransferOccurrences(rr, req, "rt-1", api.ListRecurringTransferOccurrencesParams{Limit: &limit})
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list api.OccurrenceList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list.Items, 3)
+	assert.Equal(t, *rt.NextRunAt, list.Items[0].UTC())
+	assert.Equal(t, rt.NextRunAt.AddDate(0, 0, 2), list.Items[2].UTC())
+}
+
+func TestListRecurringTransfersByUserId(t *testing.T) {
+	// Arrange
+	mockStorage := new(mocks.Storage)
+	mockStorage.On("ListRecurringTransfersByUserID", mock.Anything, "alice", storage.PageRequest{}).
+		Return([]models.RecurringTransfer{*activeTransfer()}, "next", nil)
+	h := recurringtransfers.NewRecurringTransfersHandler(mockStorage)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/alice/recurring-transfers", nil)
+	rr := httptest.NewRecorder()
+
+	// Act
+	h.ListRecurringTransfersByUserId(rr, req, "alice", api.ListRecurringTransfersByUserIdParams{})
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page api.RecurringTransferPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "rt-1", page.Items[0].Id)
+	require.NotNil(t, page.NextCursor)
+	assert.Equal(t, "next", *page.NextCursor)
+	mockStorage.AssertExpectations(t)
+}
@@ -2,6 +2,8 @@ package transactions
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,7 +12,12 @@ import (
 	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
@@ -22,6 +29,9 @@ type TransactionsHandler struct {
 	Store     storage.ApiStore
 	Scheduler scheduler.CronScheduler
 	Publisher websockets.Publisher
+	// Approval decides which new transactions wait for approval before they are enqueued.
+	// The zero value lets every transaction through.
+	Approval approval.Rules
 }
 
 // NewTransactionsHandler creates a new TransactionsHandler.
@@ -29,33 +39,70 @@ func NewTransactionsHandler(store storage.ApiStore, scheduler scheduler.CronSche
 	return &TransactionsHandler{Store: store, Scheduler: scheduler, Publisher: publisher}
 }
 
+// maxIdempotencyKeyLength is the longest Idempotency-Key header that is accepted.
+const maxIdempotencyKeyLength = 255
+
 // ScheduleTransaction handles the logic for scheduling a new transaction.
-func (h *TransactionsHandler) ScheduleTransaction(w http.ResponseWriter, r *http.Request) {
+// A request with an Idempotency-Key that was already used replays the original transaction.
+// A transaction that matches the approval rules is created PENDING_APPROVAL and is only enqueued once it is approved.
+func (h *TransactionsHandler) ScheduleTransaction(w http.ResponseWriter, r *http.Request, params api.ScheduleTransactionParams) {
 	var newTx api.NewTransaction
 	if err := json.NewDecoder(r.Body).Decode(&newTx); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	if !currency.Valid(newTx.Currency) {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %q is not an ISO 4217 currency code", newTx.Currency))
 		return
 	}
 
+	// Check the delay before funds are reserved, so that a transaction is never created that cannot be scheduled.
+	delay := time.Duration(0)
+	if newTx.DelaySeconds != nil {
+		delay = time.Duration(*newTx.DelaySeconds) * time.Second
+	}
+	if delay < 0 || delay > scheduler.MaxDelay {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: delay_seconds must be between 0 and %d", int(scheduler.MaxDelay.Seconds())))
+		return
+	}
+	if newTx.ExecuteAt != nil {
+		if newTx.DelaySeconds != nil {
+			problem.Write(w, r, http.StatusBadRequest, "Invalid request: execute_at and delay_seconds cannot both be set")
+			return
+		}
+		if time.Until(*newTx.ExecuteAt) > scheduler.MaxDelay {
+			problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: execute_at must be at most %d days ahead", int(scheduler.MaxDelay.Hours()/24)))
+			return
+		}
+	}
+
 	domainTx := mapping.ToDomainNewTransaction(&newTx)
+	if params.IdempotencyKey != nil {
+		key := *params.IdempotencyKey
+		if key == "" || len(key) > maxIdempotencyKeyLength {
+			problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: Idempotency-Key must be 1 to %d characters", maxIdempotencyKeyLength))
+			return
+		}
+		domainTx.IdempotencyKey = key
+		domainTx.RequestFingerprint = requestFingerprint(&newTx)
+	}
+	if h.Approval.Required(domainTx) {
+		domainTx.Status = models.PENDING_APPROVAL
+	}
 
 	createdTx, err := h.Store.CreateTransaction(r.Context(), domainTx)
+	if errors.Is(err, storage.ErrIdempotencyKeyExists) {
+		h.replayTransaction(w, r, domainTx)
+		return
+	}
 	if err != nil {
-		if errors.Is(err, storage.ErrInsufficientFunds) {
-			http.Error(w, "Insufficient funds", http.StatusUnprocessableEntity)
-		} else {
-			log.Printf("ERROR: Failed to create transaction in store: %v\n", err)
-			http.Error(w, fmt.Sprintf("Failed to schedule transaction: %v", err), http.StatusInternalServerError)
-		}
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	// If the database transaction was successful, enqueue it for processing.
-	if h.Scheduler != nil {
-		delay := time.Duration(0)
-		if newTx.DelaySeconds != nil {
-			delay = time.Duration(*newTx.DelaySeconds) * time.Second
-		}
+	// If the database transaction was successful, enqueue it for processing, unless it waits for approval.
+	if h.Scheduler != nil && lifecycle.Settleable(createdTx.Status) {
 		if err := h.Scheduler.ScheduleTransaction(r.Context(), mapping.ToApiTransaction(createdTx), delay); err != nil {
 			log.Printf("CRITICAL: transaction %s created but failed to enqueue: %v", createdTx.Id, err)
 		}
@@ -72,8 +119,9 @@ func (h *TransactionsHandler) ScheduleTransaction(w http.ResponseWriter, r *http
 			Payload: websockets.WalletUpdatePayload{
 				UserID:        createdTx.FromUserId,
 				TransactionID: createdTx.Id,
+				Currency:      createdTx.Currency,
 				Change:        -createdTx.Amount, // Negative because it's a deduction
-				NewBalance:    wallet.Balance,
+				NewBalance:    wallet.Balances[createdTx.Currency].Balance,
 			},
 		}
 		if err := h.Publisher.Publish(r.Context(), msg); err != nil {
@@ -86,15 +134,45 @@ func (h *TransactionsHandler) ScheduleTransaction(w http.ResponseWriter, r *http
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(apiTx); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+		log.Printf("ERROR: failed to write response: %v", err)
 	}
 }
 
+// replayTransaction responds to a request whose idempotency key was already used. The transaction created
+// with the key is returned if the request body matches the original one; otherwise the request is rejected.
+// Nothing is enqueued or published again.
+func (h *TransactionsHandler) replayTransaction(w http.ResponseWriter, r *http.Request, retry *models.Transaction) {
+	original, err := h.Store.GetTransactionByIdempotencyKey(r.Context(), retry.IdempotencyKey)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+	if original.RequestFingerprint != retry.RequestFingerprint {
+		problem.Write(w, r, http.StatusUnprocessableEntity, "Idempotency-Key has already been used with a different request body")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mapping.ToApiTransaction(original)); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
+
+// requestFingerprint identifies the content of a new transaction request, independent of its JSON formatting.
+func requestFingerprint(newTx *api.NewTransaction) string {
+	// Marshalling the decoded struct yields the fields in a fixed order; it cannot fail.
+	canonical, _ := json.Marshal(newTx)
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
 // GetTransactionById handles the logic for retrieving a transaction by its ID.
 func (h *TransactionsHandler) GetTransactionById(w http.ResponseWriter, r *http.Request, transactionId string) {
 	domainTx, err := h.Store.GetTransaction(r.Context(), transactionId)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve transaction: %v", err), http.StatusNotFound)
+		problem.WriteError(w, r, err)
 		return
 	}
 
@@ -102,7 +180,7 @@ func (h *TransactionsHandler) GetTransactionById(w http.ResponseWriter, r *http.
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(apiTx); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+		log.Printf("ERROR: failed to write response: %v", err)
 	}
 }
 
@@ -110,17 +188,78 @@ func (h *TransactionsHandler) GetTransactionById(w http.ResponseWriter, r *http.
 func (h *TransactionsHandler) CancelTransactionById(w http.ResponseWriter, r *http.Request, transactionId string) {
 	err := h.Store.CancelTransaction(r.Context(), transactionId)
 	if err != nil {
-		if errors.Is(err, storage.ErrTransactionNotCancellable) {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, fmt.Sprintf("Failed to cancel transaction: %v", err), http.StatusInternalServerError)
+		problem.WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ApproveTransaction handles the logic for approving a transaction that is pending approval.
+// The approved transaction is enqueued for settlement at its due time.
+func (h *TransactionsHandler) ApproveTransaction(w http.ResponseWriter, r *http.Request, transactionId string) {
+	tx, err := h.Store.ApproveTransaction(r.Context(), transactionId)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	if h.Scheduler != nil {
+		delay := scheduler.DelayUntil(storage.DueAt(tx), time.Now())
+		if err := h.Scheduler.ScheduleTransaction(r.Context(), mapping.ToApiTransaction(tx), delay); err != nil {
+			log.Printf("CRITICAL: transaction %s approved but failed to enqueue: %v", tx.Id, err)
+		}
+	}
+
+	apiTx := mapping.ToApiTransaction(tx)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(apiTx); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
+
+// RejectTransaction handles the logic for rejecting a transaction that is pending approval.
+// Its reserved funds are released back to the sender, who is told of the new balance.
+func (h *TransactionsHandler) RejectTransaction(w http.ResponseWriter, r *http.Request, transactionId string) {
+	if err := h.Store.RejectTransaction(r.Context(), transactionId); err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	tx, err := h.Store.GetTransaction(r.Context(), transactionId)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	wallet, err := h.Store.GetWallet(r.Context(), tx.FromUserId)
+	if err != nil {
+		log.Printf("ERROR: failed to get wallet for websocket message: %v", err)
+	} else {
+		msg := websockets.Message{
+			Type: websockets.MessageTypeWalletUpdate,
+			Payload: websockets.WalletUpdatePayload{
+				UserID:        tx.FromUserId,
+				TransactionID: tx.Id,
+				Currency:      tx.Currency,
+				Change:        tx.Amount, // Positive because the reservation is released
+				NewBalance:    wallet.Balances[tx.Currency].Balance,
+			},
+		}
+		if err := h.Publisher.Publish(r.Context(), msg); err != nil {
+			log.Printf("ERROR: failed to publish websocket message: %v", err)
+		}
+	}
+
+	apiTx := mapping.ToApiTransaction(tx)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(apiTx); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
+
 // NotifySettlement handles the internal callback after a transaction is settled.
 func (h *TransactionsHandler) NotifySettlement(w http.ResponseWriter, r *http.Request, transactionId types.UUID) {
 	// This handler is called internally, so we use a background context.
@@ -129,7 +268,11 @@ func (h *TransactionsHandler) NotifySettlement(w http.ResponseWriter, r *http.Re
 	// 1. Get the settled transaction details.
 	tx, err := h.Store.GetTransaction(ctx, transactionId.String())
 	if err != nil {
-		http.Error(w, "Transaction not found", http.StatusNotFound)
+		problem.WriteError(w, r, err)
+		return
+	}
+	if tx.Status == models.FAILED {
+		h.notifyFailure(ctx, w, r, tx)
 		return
 	}
 
@@ -137,7 +280,7 @@ func (h *TransactionsHandler) NotifySettlement(w http.ResponseWriter, r *http.Re
 	toWallet, err := h.Store.GetWallet(ctx, tx.ToUserId)
 	if err != nil {
 		log.Printf("ERROR: failed to get recipient's wallet for websocket message: %v", err)
-		http.Error(w, "Failed to get recipient wallet", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
@@ -148,8 +291,9 @@ func (h *TransactionsHandler) NotifySettlement(w http.ResponseWriter, r *http.Re
 		Payload: websockets.WalletUpdatePayload{
 			UserID:        tx.ToUserId,
 			TransactionID: tx.Id,
+			Currency:      tx.Currency,
 			Change:        tx.Amount,
-			NewBalance:    toWallet.Balance,
+			NewBalance:    toWallet.Balances[tx.Currency].Balance,
 		},
 	}
 	if err := h.Publisher.Publish(ctx, toMsg); err != nil {
@@ -159,22 +303,95 @@ func (h *TransactionsHandler) NotifySettlement(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListTransactionsByUserId handles the logic for retrieving all transactions for a user.
-func (h *TransactionsHandler) ListTransactionsByUserId(w http.ResponseWriter, r *http.Request, userId string) {
-	domainTxs, err := h.Store.ListTransactionsByUserID(r.Context(), userId)
+// notifyFailure tells the sender of a transaction that could not be settled that its funds were returned.
+func (h *TransactionsHandler) notifyFailure(ctx context.Context, w http.ResponseWriter, r *http.Request, tx *models.Transaction) {
+	fromWallet, err := h.Store.GetWallet(ctx, tx.FromUserId)
+	if err != nil {
+		log.Printf("ERROR: failed to get sender's wallet for websocket message: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	fromMsg := websockets.Message{
+		Type: websockets.MessageTypeTransactionFailed,
+		Payload: websockets.TransactionFailedPayload{
+			UserID:        tx.FromUserId,
+			TransactionID: tx.Id,
+			Currency:      tx.Currency,
+			Amount:        tx.Amount,
+			Reason:        tx.FailureReason,
+			NewBalance:    fromWallet.Balances[tx.Currency].Balance,
+		},
+	}
+	if err := h.Publisher.Publish(ctx, fromMsg); err != nil {
+		log.Printf("ERROR: failed to publish websocket message to sender: %v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTransactionsByUserId handles the logic for retrieving a page of the transactions sent by a user.
+func (h *TransactionsHandler) ListTransactionsByUserId(w http.ResponseWriter, r *http.Request, userId string, params api.ListTransactionsByUserIdParams) {
+	page, err := mapping.ToPageRequest(params.Limit, params.Cursor)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	domainTxs, next, err := h.Store.ListTransactionsByUserID(r.Context(), userId, page)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	apiPage := api.TransactionPage{Items: make([]api.Transaction, len(domainTxs)), NextCursor: mapping.ToApiCursor(next)}
+	for i, tx := range domainTxs {
+		apiPage.Items[i] = *mapping.ToApiTransaction(&tx)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(apiPage); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
+	}
+}
+
+// ListUserActivity handles the logic for retrieving a page of the transactions sent or received by a user,
+// optionally only those in one direction.
+func (h *TransactionsHandler) ListUserActivity(w http.ResponseWriter, r *http.Request, userId string, params api.ListUserActivityParams) {
+	page, err := mapping.ToPageRequest(params.Limit, params.Cursor)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	list := h.Store.ListActivityByUserID
+	if params.Direction != nil {
+		switch *params.Direction {
+		case api.SENT:
+			list = h.Store.ListTransactionsByUserID
+		case api.RECEIVED:
+			list = h.Store.ListIncomingTransactionsByUserID
+		default:
+			problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: unknown direction %q", *params.Direction))
+			return
+		}
+	}
+
+	domainTxs, next, err := list(r.Context(), userId, page)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve transactions: %v", err), http.StatusInternalServerError)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	apiTxs := make([]*api.Transaction, len(domainTxs))
+	apiPage := api.ActivityPage{Items: make([]api.Activity, len(domainTxs)), NextCursor: mapping.ToApiCursor(next)}
 	for i, tx := range domainTxs {
-		apiTxs[i] = mapping.ToApiTransaction(&tx)
+		apiPage.Items[i] = *mapping.ToApiActivity(&tx, userId)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(apiTxs); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(apiPage); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
 	}
 }
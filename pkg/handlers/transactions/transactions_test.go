@@ -2,6 +2,7 @@ package transactions
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +10,11 @@ import (
 	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
 	scheduler_mocks "github.com/chris/delayed-wallet-transactions/pkg/scheduler/mocks"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	storage_mocks "github.com/chris/delayed-wallet-transactions/pkg/storage/mocks"
 	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
 	"github.com/google/uuid"
@@ -30,6 +34,7 @@ func TestScheduleTransaction_Success(t *testing.T) {
 			FromUserId: "user1",
 			ToUserId:   "user2",
 			Amount:     100,
+			Currency:   "USD",
 		}
 
 		createdTx := &models.Transaction{
@@ -37,12 +42,13 @@ func TestScheduleTransaction_Success(t *testing.T) {
 			FromUserId: newTx.FromUserId,
 			ToUserId:   newTx.ToUserId,
 			Amount:     newTx.Amount,
+			Currency:   newTx.Currency,
 			Status:     models.RESERVED,
 		}
 
 		// 2. Mock expectations
 		mockStorage.On("CreateTransaction", mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(createdTx, nil)
-		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balance: 1000}, nil).Maybe()
+		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}}, nil).Maybe()
 		mockScheduler.On("ScheduleTransaction", mock.Anything, mock.AnythingOfType("*api.Transaction"), time.Duration(0)).Return(nil)
 
 		// 3. Execute
@@ -50,7 +56,7 @@ func TestScheduleTransaction_Success(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
 		rr := httptest.NewRecorder()
 
-		handler.ScheduleTransaction(rr, req)
+		handler.ScheduleTransaction(rr, req, api.ScheduleTransactionParams{})
 
 		// 4. Assert
 		assert.Equal(t, http.StatusCreated, rr.Code)
@@ -70,6 +76,7 @@ func TestScheduleTransaction_Success(t *testing.T) {
 			FromUserId:   "user1",
 			ToUserId:     "user2",
 			Amount:       100,
+			Currency:     "USD",
 			DelaySeconds: &delay,
 		}
 
@@ -78,12 +85,13 @@ func TestScheduleTransaction_Success(t *testing.T) {
 			FromUserId: newTx.FromUserId,
 			ToUserId:   newTx.ToUserId,
 			Amount:     newTx.Amount,
+			Currency:   newTx.Currency,
 			Status:     models.RESERVED,
 		}
 
 		// 2. Mock expectations
 		mockStorage.On("CreateTransaction", mock.Anything, mock.AnythingOfType("*models.Transaction")).Return(createdTx, nil)
-		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balance: 1000}, nil).Maybe()
+		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}}, nil).Maybe()
 		mockScheduler.On("ScheduleTransaction", mock.Anything, mock.AnythingOfType("*api.Transaction"), time.Duration(delay)*time.Second).Return(nil)
 
 		// 3. Execute
@@ -91,11 +99,427 @@ func TestScheduleTransaction_Success(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
 		rr := httptest.NewRecorder()
 
-		handler.ScheduleTransaction(rr, req)
+		handler.ScheduleTransaction(rr, req, api.ScheduleTransactionParams{})
 
 		// 4. Assert
 		assert.Equal(t, http.StatusCreated, rr.Code)
 		mockStorage.AssertExpectations(t)
 		mockScheduler.AssertExpectations(t)
 	})
+
+	t.Run("With Execute At", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		mockScheduler := new(scheduler_mocks.CronScheduler)
+		handler := NewTransactionsHandler(mockStorage, mockScheduler, new(websockets.NoOpPublisher))
+
+		executeAt := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+		newTx := &api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", ExecuteAt: &executeAt}
+		createdTx := &models.Transaction{Id: uuid.New().String(), FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", ExecuteAt: &executeAt, Status: models.RESERVED}
+
+		mockStorage.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(tx *models.Transaction) bool {
+			return tx.ExecuteAt != nil && tx.ExecuteAt.Equal(executeAt) && tx.DelaySeconds == nil
+		})).Return(createdTx, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}}, nil).Maybe()
+		mockScheduler.On("ScheduleTransaction", mock.Anything, mock.MatchedBy(func(tx *api.Transaction) bool {
+			return tx.ExecuteAt != nil && tx.ExecuteAt.Equal(executeAt)
+		}), time.Duration(0)).Return(nil)
+
+		body, _ := json.Marshal(newTx)
+		req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		handler.ScheduleTransaction(rr, req, api.ScheduleTransactionParams{})
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var created api.Transaction
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		if assert.NotNil(t, created.ExecuteAt) {
+			assert.True(t, executeAt.Equal(*created.ExecuteAt))
+		}
+		mockStorage.AssertExpectations(t)
+		mockScheduler.AssertExpectations(t)
+	})
+}
+
+func TestScheduleTransaction_InvalidCurrency(t *testing.T) {
+	mockStorage := new(storage_mocks.ApiStore)
+	handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+
+	body, _ := json.Marshal(&api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "XYZ"})
+	req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler.ScheduleTransaction(rr, req, api.ScheduleTransactionParams{})
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockStorage.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything)
+}
+
+func TestScheduleTransaction_InvalidDelay(t *testing.T) {
+	negative, tooLong := int32(-1), int32(scheduler.MaxDelay.Seconds())+1
+	delay := int32(60)
+	tooLate := time.Now().Add(scheduler.MaxDelay + time.Hour)
+	soon := time.Now().Add(time.Hour)
+	tests := map[string]*api.NewTransaction{
+		"Negative":              {DelaySeconds: &negative},
+		"Too Long":              {DelaySeconds: &tooLong},
+		"Execute At Too Late":   {ExecuteAt: &tooLate},
+		"Execute At With Delay": {ExecuteAt: &soon, DelaySeconds: &delay},
+	}
+	for name, newTx := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockStorage := new(storage_mocks.ApiStore)
+			handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+			newTx.FromUserId, newTx.ToUserId, newTx.Amount, newTx.Currency = "user1", "user2", 100, "USD"
+
+			body, _ := json.Marshal(newTx)
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			handler.ScheduleTransaction(rr, req, api.ScheduleTransactionParams{})
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			mockStorage.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestScheduleTransaction_IdempotencyKey(t *testing.T) {
+	newTx := &api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"}
+	key := "key-1"
+	params := api.ScheduleTransactionParams{IdempotencyKey: &key}
+	post := func(handler *TransactionsHandler, newTx *api.NewTransaction, params api.ScheduleTransactionParams) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(newTx)
+		req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ScheduleTransaction(rr, req, params)
+		return rr
+	}
+
+	t.Run("First Request", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		mockScheduler := new(scheduler_mocks.CronScheduler)
+		handler := NewTransactionsHandler(mockStorage, mockScheduler, new(websockets.NoOpPublisher))
+
+		createdTx := &models.Transaction{Id: uuid.New().String(), FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", Status: models.RESERVED}
+		mockStorage.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(tx *models.Transaction) bool {
+			return tx.IdempotencyKey == key && tx.RequestFingerprint == requestFingerprint(newTx)
+		})).Return(createdTx, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}}, nil).Maybe()
+		mockScheduler.On("ScheduleTransaction", mock.Anything, mock.AnythingOfType("*api.Transaction"), time.Duration(0)).Return(nil)
+
+		rr := post(handler, newTx, params)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Empty(t, rr.Header().Get("Idempotent-Replayed"))
+		mockStorage.AssertExpectations(t)
+		mockScheduler.AssertExpectations(t)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		mockScheduler := new(scheduler_mocks.CronScheduler)
+		handler := NewTransactionsHandler(mockStorage, mockScheduler, new(websockets.NoOpPublisher))
+
+		original := &models.Transaction{Id: uuid.New().String(), FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", Status: models.RESERVED, IdempotencyKey: key, RequestFingerprint: requestFingerprint(newTx)}
+		mockStorage.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil, storage.ErrIdempotencyKeyExists)
+		mockStorage.On("GetTransactionByIdempotencyKey", mock.Anything, key).Return(original, nil)
+
+		rr := post(handler, newTx, params)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, "true", rr.Header().Get("Idempotent-Replayed"))
+		var replayed api.Transaction
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &replayed))
+		assert.Equal(t, original.Id, *replayed.Id)
+		mockStorage.AssertExpectations(t)
+		mockScheduler.AssertNotCalled(t, "ScheduleTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Different Body", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+
+		original := &models.Transaction{Id: uuid.New().String(), IdempotencyKey: key, RequestFingerprint: requestFingerprint(newTx)}
+		mockStorage.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil, storage.ErrIdempotencyKeyExists)
+		mockStorage.On("GetTransactionByIdempotencyKey", mock.Anything, key).Return(original, nil)
+
+		rr := post(handler, &api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 200, Currency: "USD"}, params)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Empty Key", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+
+		empty := ""
+		rr := post(handler, newTx, api.ScheduleTransactionParams{IdempotencyKey: &empty})
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything)
+	})
+}
+
+func TestScheduleTransaction_NeedsApproval(t *testing.T) {
+	// 1. Setup
+	mockStorage := new(storage_mocks.ApiStore)
+	mockScheduler := new(scheduler_mocks.CronScheduler)
+	handler := NewTransactionsHandler(mockStorage, mockScheduler, new(websockets.NoOpPublisher))
+	handler.Approval = approval.Rules{Thresholds: map[string]int64{"USD": 1000}}
+
+	newTx := &api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 1000, Currency: "USD"}
+	createdTx := &models.Transaction{Id: uuid.New().String(), FromUserId: "user1", ToUserId: "user2", Amount: 1000, Currency: "USD", Status: models.PENDING_APPROVAL}
+
+	// 2. Mock expectations
+	mockStorage.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(tx *models.Transaction) bool {
+		return tx.Status == models.PENDING_APPROVAL
+	})).Return(createdTx, nil)
+	mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 0, Reserved: 1000}}}, nil)
+
+	// 3. Execute
+	body, _ := json.Marshal(newTx)
+	req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler.ScheduleTransaction(rr, req, api.ScheduleTransactionParams{})
+
+	// 4. Assert
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var returned api.Transaction
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+	assert.Equal(t, api.TransactionStatusPENDINGAPPROVAL, *returned.Status)
+	mockStorage.AssertExpectations(t)
+	mockScheduler.AssertNotCalled(t, "ScheduleTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApproveTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(storage_mocks.ApiStore)
+		mockScheduler := new(scheduler_mocks.CronScheduler)
+		handler := NewTransactionsHandler(mockStorage, mockScheduler, new(websockets.NoOpPublisher))
+		due := time.Now().Add(-time.Minute)
+		approved := &models.Transaction{Id: "tx-1", FromUserId: "user1", ToUserId: "user2", Amount: 1000, Currency: "USD", Status: models.APPROVED, DueAt: &due}
+		mockStorage.On("ApproveTransaction", mock.Anything, "tx-1").Return(approved, nil)
+		mockScheduler.On("ScheduleTransaction", mock.Anything, mock.AnythingOfType("*api.Transaction"), time.Duration(0)).Return(nil)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.ApproveTransaction(rr, httptest.NewRequest(http.MethodPost, "/transactions/tx-1/approve", nil), "tx-1")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var returned api.Transaction
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+		assert.Equal(t, api.TransactionStatusAPPROVED, *returned.Status)
+		mockStorage.AssertExpectations(t)
+		mockScheduler.AssertExpectations(t)
+	})
+
+	t.Run("Not Pending Approval", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(storage_mocks.ApiStore)
+		mockScheduler := new(scheduler_mocks.CronScheduler)
+		handler := NewTransactionsHandler(mockStorage, mockScheduler, new(websockets.NoOpPublisher))
+		mockStorage.On("ApproveTransaction", mock.Anything, "tx-1").Return(nil, storage.ErrTransactionNotPendingApproval)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.ApproveTransaction(rr, httptest.NewRequest(http.MethodPost, "/transactions/tx-1/approve", nil), "tx-1")
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		mockScheduler.AssertNotCalled(t, "ScheduleTransaction", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRejectTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+		rejected := &models.Transaction{Id: "tx-1", FromUserId: "user1", ToUserId: "user2", Amount: 1000, Currency: "USD", Status: models.REJECTED}
+		mockStorage.On("RejectTransaction", mock.Anything, "tx-1").Return(nil)
+		mockStorage.On("GetTransaction", mock.Anything, "tx-1").Return(rejected, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}}, nil)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.RejectTransaction(rr, httptest.NewRequest(http.MethodPost, "/transactions/tx-1/reject", nil), "tx-1")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var returned api.Transaction
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+		assert.Equal(t, api.TransactionStatusREJECTED, *returned.Status)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+		mockStorage.On("RejectTransaction", mock.Anything, "missing").Return(storage.ErrTransactionNotFound)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.RejectTransaction(rr, httptest.NewRequest(http.MethodPost, "/transactions/missing/reject", nil), "missing")
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+// recordingPublisher records the messages it is asked to publish.
+type recordingPublisher struct {
+	messages []websockets.Message
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, message websockets.Message) error {
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func TestNotifySettlement(t *testing.T) {
+	id := uuid.New()
+
+	t.Run("Completed", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(storage_mocks.ApiStore)
+		publisher := new(recordingPublisher)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), publisher)
+		completed := &models.Transaction{Id: id.String(), FromUserId: "user1", ToUserId: "user2", Amount: 1000, Currency: "USD", Status: models.COMPLETED}
+		mockStorage.On("GetTransaction", mock.Anything, id.String()).Return(completed, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user2").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1500}}}, nil)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.NotifySettlement(rr, httptest.NewRequest(http.MethodPost, "/transactions/"+id.String()+"/notify-settlement", nil), id)
+
+		// Assert
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		if assert.Len(t, publisher.messages, 1) {
+			assert.Equal(t, websockets.MessageTypeWalletUpdate, publisher.messages[0].Type)
+			assert.Equal(t, websockets.WalletUpdatePayload{UserID: "user2", TransactionID: id.String(), Currency: "USD", Change: 1000, NewBalance: 1500}, publisher.messages[0].Payload)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(storage_mocks.ApiStore)
+		publisher := new(recordingPublisher)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), publisher)
+		failed := &models.Transaction{Id: id.String(), FromUserId: "user1", ToUserId: "user2", Amount: 1000, Currency: "USD", Status: models.FAILED, FailureReason: "wallet not found"}
+		mockStorage.On("GetTransaction", mock.Anything, id.String()).Return(failed, nil)
+		mockStorage.On("GetWallet", mock.Anything, "user1").Return(&models.Wallet{Balances: map[string]models.CurrencyBalance{"USD": {Balance: 5000}}}, nil)
+
+		// Act
+		rr := httptest.NewRecorder()
+		handler.NotifySettlement(rr, httptest.NewRequest(http.MethodPost, "/transactions/"+id.String()+"/notify-settlement", nil), id)
+
+		// Assert
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		if assert.Len(t, publisher.messages, 1) {
+			assert.Equal(t, websockets.MessageTypeTransactionFailed, publisher.messages[0].Type)
+			assert.Equal(t, websockets.TransactionFailedPayload{UserID: "user1", TransactionID: id.String(), Currency: "USD", Amount: 1000, Reason: "wallet not found", NewBalance: 5000}, publisher.messages[0].Payload)
+		}
+		mockStorage.AssertNotCalled(t, "GetWallet", mock.Anything, "user2")
+	})
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	delay := int32(60)
+	a := requestFingerprint(&api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+	b := requestFingerprint(&api.NewTransaction{ToUserId: "user2", FromUserId: "user1", Amount: 100, Currency: "USD"})
+
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, requestFingerprint(&api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 101, Currency: "USD"}))
+	assert.NotEqual(t, a, requestFingerprint(&api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "EUR"}))
+	assert.NotEqual(t, a, requestFingerprint(&api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", DelaySeconds: &delay}))
+	executeAt := time.Date(2025, time.March, 31, 9, 0, 0, 0, time.UTC)
+	assert.NotEqual(t, a, requestFingerprint(&api.NewTransaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", ExecuteAt: &executeAt}))
+}
+
+func TestListUserActivity(t *testing.T) {
+	sent := models.Transaction{Id: uuid.New().String(), FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"}
+	received := models.Transaction{Id: uuid.New().String(), FromUserId: "user2", ToUserId: "user1", Amount: 50, Currency: "USD"}
+
+	t.Run("Both Directions", func(t *testing.T) {
+		// 1. Setup
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+
+		// 2. Mock expectations
+		mockStorage.On("ListActivityByUserID", mock.Anything, "user1", storage.PageRequest{}).Return([]models.Transaction{received, sent}, "page-2", nil)
+
+		// 3. Execute
+		req := httptest.NewRequest(http.MethodGet, "/users/user1/activity", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ListUserActivity(rr, req, "user1", api.ListUserActivityParams{})
+
+		// 4. Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var returnedPage api.ActivityPage
+		json.Unmarshal(rr.Body.Bytes(), &returnedPage)
+		if assert.Len(t, returnedPage.Items, 2) {
+			assert.Equal(t, api.RECEIVED, returnedPage.Items[0].Direction)
+			assert.Equal(t, received.Id, *returnedPage.Items[0].Transaction.Id)
+			assert.Equal(t, api.SENT, returnedPage.Items[1].Direction)
+			assert.Equal(t, sent.Id, *returnedPage.Items[1].Transaction.Id)
+		}
+		assert.Equal(t, "page-2", *returnedPage.NextCursor)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Direction Filter", func(t *testing.T) {
+		for direction, method := range map[api.Direction]string{
+			api.SENT:     "ListTransactionsByUserID",
+			api.RECEIVED: "ListIncomingTransactionsByUserID",
+		} {
+			mockStorage := new(storage_mocks.ApiStore)
+			handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+			mockStorage.On(method, mock.Anything, "user1", storage.PageRequest{}).Return([]models.Transaction{}, "", nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/user1/activity?direction="+string(direction), nil)
+			rr := httptest.NewRecorder()
+
+			handler.ListUserActivity(rr, req, "user1", api.ListUserActivityParams{Direction: &direction})
+
+			assert.Equal(t, http.StatusOK, rr.Code, direction)
+			mockStorage.AssertExpectations(t)
+		}
+	})
+
+	t.Run("Invalid Direction", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+		direction := api.Direction("SIDEWAYS")
+
+		req := httptest.NewRequest(http.MethodGet, "/users/user1/activity?direction=SIDEWAYS", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ListUserActivity(rr, req, "user1", api.ListUserActivityParams{Direction: &direction})
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		mockStorage := new(storage_mocks.ApiStore)
+		handler := NewTransactionsHandler(mockStorage, new(scheduler_mocks.CronScheduler), new(websockets.NoOpPublisher))
+		cursor := "bogus"
+		mockStorage.On("ListActivityByUserID", mock.Anything, "user1", storage.PageRequest{Cursor: cursor}).Return(nil, "", storage.ErrInvalidCursor)
+
+		req := httptest.NewRequest(http.MethodGet, "/users/user1/activity?cursor=bogus", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ListUserActivity(rr, req, "user1", api.ListUserActivityParams{Cursor: &cursor})
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertExpectations(t)
+	})
 }
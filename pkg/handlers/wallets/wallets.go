@@ -3,14 +3,15 @@ package wallets
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"sort"
 	"log"
-	"strings"
+	"net/http"
 	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/currency"
+	"github.com/chris/delayed-wallet-transactions/pkg/handlers/problem"
 	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 )
 
@@ -28,20 +29,30 @@ func NewWalletsHandler(store storage.WalletStore) *WalletsHandler {
 func (h *WalletsHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 	var newWallet api.NewWallet
 	if err := json.NewDecoder(r.Body).Decode(&newWallet); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if models.IsSystemAccount(newWallet.UserId) {
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: user IDs starting with %q are reserved for system accounts", models.SystemAccountPrefix))
 		return
 	}
+	if len(newWallet.Currencies) == 0 {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request: a wallet must hold at least one currency")
+		return
+	}
+	for _, code := range newWallet.Currencies {
+		if !currency.Valid(code) {
+			problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %q is not an ISO 4217 currency code", code))
+			return
+		}
+	}
 
 	domainWallet := mapping.ToDomainNewWallet(&newWallet)
 	domainWallet.CreatedAt = time.Now()
 
 	createdWallet, err := h.Store.CreateWallet(r.Context(), domainWallet)
 	if err != nil {
-		if strings.Contains(err.Error(), "wallet already exists") { // This is a simplistic check.
-			http.Error(w, "Wallet for this user already exists", http.StatusConflict)
-		} else {
-			http.Error(w, fmt.Sprintf("Failed to create wallet: %v", err), http.StatusInternalServerError)
-		}
+		problem.WriteError(w, r, err)
 		return
 	}
 
@@ -49,44 +60,43 @@ func (h *WalletsHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(apiWallet); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+		log.Printf("ERROR: failed to write response: %v", err)
 	}
 }
 
 // DeleteWallet handles the logic for deleting a user's wallet.
 func (h *WalletsHandler) DeleteWallet(w http.ResponseWriter, r *http.Request, userId string) {
 	if err := h.Store.DeleteWallet(r.Context(), userId); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete wallet: %v", err), http.StatusNotFound)
+		problem.WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListWallets handles the logic for retrieving all wallets.
-
-func (h *WalletsHandler) ListWallets(w http.ResponseWriter, r *http.Request) {
-	domainWallets, err := h.Store.ListWallets(r.Context())
+// ListWallets handles the logic for retrieving a page of wallets, newest first.
+func (h *WalletsHandler) ListWallets(w http.ResponseWriter, r *http.Request, params api.ListWalletsParams) {
+	page, err := mapping.ToPageRequest(params.Limit, params.Cursor)
 	if err != nil {
-		log.Printf("ERROR: Failed to list wallets from store: %v\n", err)
-		http.Error(w, fmt.Sprintf("Failed to retrieve wallets: %v", err), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
 		return
 	}
 
-	// Sort wallets by CreatedAt in descending order.
-	sort.Slice(domainWallets, func(i, j int) bool {
-		return domainWallets[i].CreatedAt.After(domainWallets[j].CreatedAt)
-	})
+	domainWallets, next, err := h.Store.ListWallets(r.Context(), page)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
 
-	apiWallets := make([]*api.Wallet, len(domainWallets))
+	apiPage := api.WalletPage{Items: make([]api.Wallet, len(domainWallets)), NextCursor: mapping.ToApiCursor(next)}
 	for i, wallet := range domainWallets {
-		apiWallets[i] = mapping.ToApiWallet(&wallet)
+		apiPage.Items[i] = *mapping.ToApiWallet(&wallet)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(apiWallets); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(apiPage); err != nil {
+		log.Printf("ERROR: failed to write response: %v", err)
 	}
 }
 
@@ -94,7 +104,7 @@ func (h *WalletsHandler) ListWallets(w http.ResponseWriter, r *http.Request) {
 func (h *WalletsHandler) GetWalletByUserId(w http.ResponseWriter, r *http.Request, userId string) {
 	domainWallet, err := h.Store.GetWallet(r.Context(), userId)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve wallet: %v", err), http.StatusNotFound)
+		problem.WriteError(w, r, err)
 		return
 	}
 
@@ -102,6 +112,6 @@ func (h *WalletsHandler) GetWalletByUserId(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(apiWallet); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write response: %v", err), http.StatusInternalServerError)
+		log.Printf("ERROR: failed to write response: %v", err)
 	}
 }
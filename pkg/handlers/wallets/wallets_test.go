@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,14 +12,15 @@ import (
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
 	"github.com/chris/delayed-wallet-transactions/pkg/handlers/wallets"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 func TestCreateWallet(t *testing.T) {
-	newApiWallet := api.NewWallet{UserId: "user-c"}
-	expectedWallet := &models.Wallet{UserId: "user-c", Balance: 0, Reserved: 0, Version: 1}
+	newApiWallet := api.NewWallet{UserId: "user-c", Currencies: []string{"USD"}}
+	expectedWallet := &models.Wallet{UserId: "user-c", Balances: map[string]models.CurrencyBalance{"USD": {Balance: 1000}}, Version: 1}
 
 	t.Run("Success", func(t *testing.T) {
 		mockStorage := new(mocks.Storage)
@@ -33,12 +35,47 @@ func TestCreateWallet(t *testing.T) {
 		h.CreateWallet(rr, req)
 
 		assert.Equal(t, http.StatusCreated, rr.Code)
+		var created api.Wallet
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, []api.WalletBalance{{Currency: "USD", Balance: 1000, Reserved: 0}}, *created.Balances)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Currency", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+
+		h := wallets.NewWalletsHandler(mockStorage)
+
+		for _, currencies := range [][]string{nil, {"USD", "usd"}} {
+			body, _ := json.Marshal(api.NewWallet{UserId: "user-c", Currencies: currencies})
+			req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			h.CreateWallet(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		}
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("System Account", func(t *testing.T) {
+		mockStorage := new(mocks.Storage)
+
+		h := wallets.NewWalletsHandler(mockStorage)
+
+		body, _ := json.Marshal(api.NewWallet{UserId: models.FundingAccount, Currencies: []string{"USD"}})
+		req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		h.CreateWallet(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		mockStorage.AssertExpectations(t)
 	})
 
 	t.Run("Conflict", func(t *testing.T) {
 		mockStorage := new(mocks.Storage)
-		mockStorage.On("CreateWallet", mock.Anything, mock.Anything).Return(nil, errors.New("wallet already exists"))
+		mockStorage.On("CreateWallet", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: user ID user-c", storage.ErrWalletExists))
 
 		h := wallets.NewWalletsHandler(mockStorage)
 
@@ -49,6 +86,11 @@ func TestCreateWallet(t *testing.T) {
 		h.CreateWallet(rr, req)
 
 		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		var problem api.Error
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, int32(http.StatusConflict), problem.Status)
+		assert.Equal(t, "/wallets", *problem.Instance)
 		mockStorage.AssertExpectations(t)
 	})
 
@@ -65,6 +107,7 @@ func TestCreateWallet(t *testing.T) {
 		h.CreateWallet(rr, req)
 
 		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "some other storage error")
 		mockStorage.AssertExpectations(t)
 	})
 }
@@ -87,7 +130,7 @@ func TestDeleteWallet(t *testing.T) {
 
 	t.Run("Not Found", func(t *testing.T) {
 		mockStorage := new(mocks.Storage)
-		mockStorage.On("DeleteWallet", mock.Anything, "user-c").Return(assert.AnError)
+		mockStorage.On("DeleteWallet", mock.Anything, "user-c").Return(storage.ErrWalletNotFound)
 
 		h := wallets.NewWalletsHandler(mockStorage)
 
@@ -105,7 +148,7 @@ func TestListWallets(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		// Arrange
 		mockStorage := new(mocks.Storage)
-		mockStorage.On("ListWallets", mock.Anything).Return([]models.Wallet{}, nil)
+		mockStorage.On("ListWallets", mock.Anything, storage.PageRequest{}).Return([]models.Wallet{{UserId: "user-c"}}, "", nil)
 
 		h := wallets.NewWalletsHandler(mockStorage)
 
@@ -113,17 +156,81 @@ func TestListWallets(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		// Act
-		h.ListWallets(rr, req)
+		h.ListWallets(rr, req, api.ListWalletsParams{})
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var returnedPage api.WalletPage
+		json.Unmarshal(rr.Body.Bytes(), &returnedPage)
+		assert.Len(t, returnedPage.Items, 1)
+		assert.Nil(t, returnedPage.NextCursor)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Next Page", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		limit := int32(1)
+		cursor := "page-2"
+		mockStorage.On("ListWallets", mock.Anything, storage.PageRequest{Limit: limit, Cursor: cursor}).Return([]models.Wallet{{UserId: "user-c"}}, "page-3", nil)
+
+		h := wallets.NewWalletsHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets?limit=1&cursor=page-2", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.ListWallets(rr, req, api.ListWalletsParams{Limit: &limit, Cursor: &cursor})
 
 		// Assert
 		assert.Equal(t, http.StatusOK, rr.Code)
+		var returnedPage api.WalletPage
+		json.Unmarshal(rr.Body.Bytes(), &returnedPage)
+		assert.Equal(t, "page-3", *returnedPage.NextCursor)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		limit := int32(0)
+
+		h := wallets.NewWalletsHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets?limit=0", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.ListWallets(rr, req, api.ListWalletsParams{Limit: &limit})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStorage.AssertNotCalled(t, "ListWallets", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		// Arrange
+		mockStorage := new(mocks.Storage)
+		cursor := "garbage"
+		mockStorage.On("ListWallets", mock.Anything, mock.Anything).Return(nil, "", storage.ErrInvalidCursor)
+
+		h := wallets.NewWalletsHandler(mockStorage)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets?cursor=garbage", nil)
+		rr := httptest.NewRecorder()
+
+		// Act
+		h.ListWallets(rr, req, api.ListWalletsParams{Cursor: &cursor})
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		mockStorage.AssertExpectations(t)
 	})
 
 	t.Run("Storage Error", func(t *testing.T) {
 		// Arrange
 		mockStorage := new(mocks.Storage)
-		mockStorage.On("ListWallets", mock.Anything).Return(nil, assert.AnError)
+		mockStorage.On("ListWallets", mock.Anything, mock.Anything).Return(nil, "", assert.AnError)
 
 		h := wallets.NewWalletsHandler(mockStorage)
 
@@ -131,7 +238,7 @@ func TestListWallets(t *testing.T) {
 		rr := httptest.NewRecorder()
 
 		// Act
-		h.ListWallets(rr, req)
+		h.ListWallets(rr, req, api.ListWalletsParams{})
 
 		// Assert
 		assert.Equal(t, http.StatusInternalServerError, rr.Code)
@@ -140,7 +247,7 @@ func TestListWallets(t *testing.T) {
 }
 
 func TestGetWalletByUserId(t *testing.T) {
-	expectedWallet := &models.Wallet{UserId: "user-c", Balance: 100, Reserved: 50, Version: 2}
+	expectedWallet := &models.Wallet{UserId: "user-c", Balances: map[string]models.CurrencyBalance{"USD": {Balance: 100, Reserved: 50}}, Version: 2}
 
 	t.Run("Success", func(t *testing.T) {
 		mockStorage := new(mocks.Storage)
@@ -159,7 +266,7 @@ func TestGetWalletByUserId(t *testing.T) {
 
 	t.Run("Not Found", func(t *testing.T) {
 		mockStorage := new(mocks.Storage)
-		mockStorage.On("GetWallet", mock.Anything, "user-c").Return(nil, assert.AnError)
+		mockStorage.On("GetWallet", mock.Anything, "user-c").Return(nil, storage.ErrWalletNotFound)
 
 		h := wallets.NewWalletsHandler(mockStorage)
 
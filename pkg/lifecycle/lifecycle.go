@@ -0,0 +1,134 @@
+// Package lifecycle is the state machine of a transaction. It declares the statuses a transaction
+// is created in, the transitions allowed between statuses and how each status is reported by the
+// API. Stores check every status change against it, and the DynamoDB store writes the conditions
+// it builds, so that every store and handler applies the same rules.
+package lifecycle
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// Statuses lists every status a transaction can be in.
+var Statuses = []models.TransactionStatus{
+	models.RESERVED,
+	models.PENDING_APPROVAL,
+	models.APPROVED,
+	models.WORKING,
+	models.COMPLETED,
+	models.REJECTED,
+	models.CANCELLED,
+	models.FAILED,
+}
+
+// transitions maps each status to the statuses a transaction in it may move to. A transaction
+// waiting to be settled moves to WORKING while it is settled and then to COMPLETED, or to FAILED if
+// it can never be settled. Until then its funds are reserved and it can be cancelled; one that needs
+// approval can also be rejected. Statuses that are not keys are final.
+var transitions = map[models.TransactionStatus][]models.TransactionStatus{
+	models.RESERVED:         {models.WORKING, models.CANCELLED},
+	models.PENDING_APPROVAL: {models.APPROVED, models.REJECTED, models.CANCELLED},
+	models.APPROVED:         {models.WORKING, models.CANCELLED},
+	models.WORKING:          {models.COMPLETED, models.FAILED},
+}
+
+// leased lists the statuses in which a transaction is leased to the worker that moved it there. Once
+// the lease expires the transaction is taken over: another worker moves it to the same status again
+// under a new lease. A settlement that crashed leaves its transaction WORKING, and another settlement
+// takes it over.
+var leased = []models.TransactionStatus{models.WORKING}
+
+// apiStatuses maps each status to the status reported by the API. WORKING is internal to
+// settlement and is reported as RESERVED.
+var apiStatuses = map[models.TransactionStatus]api.TransactionStatus{
+	models.RESERVED:         api.TransactionStatusRESERVED,
+	models.PENDING_APPROVAL: api.TransactionStatusPENDINGAPPROVAL,
+	models.APPROVED:         api.TransactionStatusAPPROVED,
+	models.WORKING:          api.TransactionStatusRESERVED,
+	models.COMPLETED:        api.TransactionStatusCOMPLETED,
+	models.REJECTED:         api.TransactionStatusREJECTED,
+	models.CANCELLED:        api.TransactionStatusCANCELLED,
+	models.FAILED:           api.TransactionStatusFAILED,
+}
+
+// InitialStatus returns the status a new transaction is created in: PENDING_APPROVAL if the caller
+// asked for it because the transaction needs approval, otherwise RESERVED.
+func InitialStatus(tx *models.Transaction) models.TransactionStatus {
+	if tx.Status == models.PENDING_APPROVAL {
+		return models.PENDING_APPROVAL
+	}
+	return models.RESERVED
+}
+
+// CanTransition reports whether a transaction may move from one status to another.
+func CanTransition(from, to models.TransactionStatus) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Sources returns the statuses from which a transaction may move to a status, in the order of Statuses.
+func Sources(to models.TransactionStatus) []models.TransactionStatus {
+	var sources []models.TransactionStatus
+	for _, from := range Statuses {
+		if CanTransition(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// CanTakeOver reports whether a transaction in a status is leased, and may be taken over in that
+// status by another worker once its lease has expired.
+func CanTakeOver(status models.TransactionStatus) bool {
+	return slices.Contains(leased, status)
+}
+
+// Settleable reports whether a transaction in a status is waiting to be settled: it is RESERVED,
+// or it needed approval and was APPROVED.
+func Settleable(status models.TransactionStatus) bool {
+	return CanTransition(status, models.WORKING)
+}
+
+// ApiStatus returns the status the API reports for a transaction in a status.
+func ApiStatus(status models.TransactionStatus) api.TransactionStatus {
+	if apiStatus, ok := apiStatuses[status]; ok {
+		return apiStatus
+	}
+	return api.TransactionStatus(status)
+}
+
+// Condition returns a DynamoDB condition expression that holds when a transaction may move to a
+// status, together with the expression attribute values it refers to. The expression refers to
+// the status attribute as #status, which the caller must name, and the caller may add its own values
+// to the map. A status with no sources gives a condition that never holds.
+func Condition(to models.TransactionStatus) (string, map[string]types.AttributeValue) {
+	sources := Sources(to)
+	values := make(map[string]types.AttributeValue, len(sources))
+	if len(sources) == 0 {
+		return "attribute_not_exists(id)", values
+	}
+	placeholders := make([]string, len(sources))
+	for i, from := range sources {
+		placeholders[i] = ":" + strings.ToLower(string(from)) + "_status"
+		values[placeholders[i]] = &types.AttributeValueMemberS{Value: string(from)}
+	}
+	return "#status IN (" + strings.Join(placeholders, ", ") + ")", values
+}
+
+// TakeoverCondition returns a DynamoDB condition expression that holds when a transaction in a status
+// may be taken over in it, together with the expression attribute values it refers to. Besides
+// #status, the expression refers to :now, the current time in Unix seconds, which the caller must
+// add to the map. A transaction locked before leases were recorded has no lease_expires_at and may
+// always be taken over. A status that is not leased gives a condition that never holds.
+func TakeoverCondition(status models.TransactionStatus) (string, map[string]types.AttributeValue) {
+	values := make(map[string]types.AttributeValue, 1)
+	if !CanTakeOver(status) {
+		return "attribute_not_exists(id)", values
+	}
+	placeholder := ":" + strings.ToLower(string(status)) + "_status"
+	values[placeholder] = &types.AttributeValueMemberS{Value: string(status)}
+	return "#status = " + placeholder + " AND (attribute_not_exists(lease_expires_at) OR lease_expires_at < :now)", values
+}
@@ -0,0 +1,109 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	testCases := []struct {
+		from, to models.TransactionStatus
+		allowed  bool
+	}{
+		{models.RESERVED, models.WORKING, true},
+		{models.APPROVED, models.WORKING, true},
+		{models.PENDING_APPROVAL, models.WORKING, false},
+		{models.WORKING, models.COMPLETED, true},
+		{models.WORKING, models.FAILED, true},
+		{models.RESERVED, models.FAILED, false},
+		{models.FAILED, models.CANCELLED, false},
+		{models.RESERVED, models.COMPLETED, false},
+		{models.PENDING_APPROVAL, models.APPROVED, true},
+		{models.RESERVED, models.APPROVED, false},
+		{models.PENDING_APPROVAL, models.REJECTED, true},
+		{models.APPROVED, models.REJECTED, false},
+		{models.RESERVED, models.CANCELLED, true},
+		{models.PENDING_APPROVAL, models.CANCELLED, true},
+		{models.APPROVED, models.CANCELLED, true},
+		{models.WORKING, models.CANCELLED, false},
+		{models.COMPLETED, models.CANCELLED, false},
+		{models.CANCELLED, models.CANCELLED, false},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.from)+" To "+string(tc.to), func(t *testing.T) {
+			assert.Equal(t, tc.allowed, CanTransition(tc.from, tc.to))
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	assert.Equal(t, []models.TransactionStatus{models.RESERVED, models.APPROVED}, Sources(models.WORKING))
+	assert.Equal(t, []models.TransactionStatus{models.RESERVED, models.PENDING_APPROVAL, models.APPROVED}, Sources(models.CANCELLED))
+	assert.Empty(t, Sources(models.RESERVED))
+}
+
+func TestCanTakeOver(t *testing.T) {
+	for _, status := range Statuses {
+		assert.Equal(t, status == models.WORKING, CanTakeOver(status), "status %s", status)
+	}
+}
+
+func TestInitialStatus(t *testing.T) {
+	assert.Equal(t, models.RESERVED, InitialStatus(&models.Transaction{}))
+	assert.Equal(t, models.RESERVED, InitialStatus(&models.Transaction{Status: models.COMPLETED}))
+	assert.Equal(t, models.PENDING_APPROVAL, InitialStatus(&models.Transaction{Status: models.PENDING_APPROVAL}))
+}
+
+func TestApiStatus(t *testing.T) {
+	valid := map[api.TransactionStatus]bool{
+		api.TransactionStatusRESERVED:        true,
+		api.TransactionStatusPENDINGAPPROVAL: true,
+		api.TransactionStatusAPPROVED:        true,
+		api.TransactionStatusREJECTED:        true,
+		api.TransactionStatusCOMPLETED:       true,
+		api.TransactionStatusCANCELLED:       true,
+		api.TransactionStatusFAILED:          true,
+	}
+	for _, status := range Statuses {
+		assert.True(t, valid[ApiStatus(status)], "%s is reported as %s, which is not in the API enum", status, ApiStatus(status))
+	}
+	assert.Equal(t, api.TransactionStatusRESERVED, ApiStatus(models.WORKING))
+	assert.Equal(t, api.TransactionStatusCANCELLED, ApiStatus(models.CANCELLED))
+}
+
+func TestCondition(t *testing.T) {
+	t.Run("Lists The Sources Of A Status", func(t *testing.T) {
+		condition, values := Condition(models.WORKING)
+		assert.Equal(t, "#status IN (:reserved_status, :approved_status)", condition)
+		assert.Equal(t, map[string]types.AttributeValue{
+			":reserved_status": &types.AttributeValueMemberS{Value: "RESERVED"},
+			":approved_status": &types.AttributeValueMemberS{Value: "APPROVED"},
+		}, values)
+	})
+
+	t.Run("Never Holds For A Status Without Sources", func(t *testing.T) {
+		condition, values := Condition(models.RESERVED)
+		assert.Equal(t, "attribute_not_exists(id)", condition)
+		assert.Empty(t, values)
+	})
+}
+
+func TestTakeoverCondition(t *testing.T) {
+	t.Run("Holds Once The Lease Has Expired", func(t *testing.T) {
+		condition, values := TakeoverCondition(models.WORKING)
+		assert.Equal(t, "#status = :working_status AND (attribute_not_exists(lease_expires_at) OR lease_expires_at < :now)", condition)
+		assert.Equal(t, map[string]types.AttributeValue{
+			":working_status": &types.AttributeValueMemberS{Value: "WORKING"},
+		}, values)
+	})
+
+	t.Run("Never Holds For A Status That Is Not Leased", func(t *testing.T) {
+		condition, values := TakeoverCondition(models.RESERVED)
+		assert.Equal(t, "attribute_not_exists(id)", condition)
+		assert.Empty(t, values)
+	})
+}
@@ -1,23 +1,50 @@
 package mapping
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
 	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 )
 
 // ToApiTransaction converts a domain Transaction model to an API Transaction model.
 func ToApiTransaction(tx *models.Transaction) *api.Transaction {
-	status := toApiStatus(tx.Status)
-	return &api.Transaction{
+	status := lifecycle.ApiStatus(tx.Status)
+	apiTx := &api.Transaction{
 		Id:          &tx.Id,
 		FromUserId:  &tx.FromUserId,
 		ToUserId:    &tx.ToUserId,
 		Amount:      &tx.Amount,
+		Currency:    &tx.Currency,
 		Status:      &status,
 		DelaySeconds: tx.DelaySeconds,
+		ExecuteAt:   tx.ExecuteAt,
+		DueAt:       tx.DueAt,
 		CreatedAt:   &tx.CreatedAt,
 		UpdatedAt:   &tx.UpdatedAt,
 	}
+	if tx.FailureReason != "" {
+		apiTx.FailureReason = &tx.FailureReason
+	}
+	return apiTx
+}
+
+// ToApiActivity converts a domain Transaction model to an API Activity model as seen by userID,
+// who either sent or received it.
+func ToApiActivity(tx *models.Transaction, userID string) *api.Activity {
+	direction := api.RECEIVED
+	if tx.FromUserId == userID {
+		direction = api.SENT
+	}
+	return &api.Activity{
+		Direction:   direction,
+		Transaction: *ToApiTransaction(tx),
+	}
 }
 
 // ToDomainNewTransaction converts an API NewTransaction model to a domain Transaction model.
@@ -27,17 +54,48 @@ func ToDomainNewTransaction(newTx *api.NewTransaction) *models.Transaction {
 		FromUserId:  newTx.FromUserId,
 		ToUserId:    newTx.ToUserId,
 		Amount:      newTx.Amount,
+		Currency:    newTx.Currency,
 		DelaySeconds: newTx.DelaySeconds,
+		ExecuteAt:   newTx.ExecuteAt,
 	}
 }
 
-// ToApiWallet converts a domain Wallet model to an API Wallet model.
+// ToDomainDeposit converts an API NewFundsMovement model to a domain Transaction that deposits
+// funds into the wallet of userID. The store fills in the funding account.
+func ToDomainDeposit(userID string, movement *api.NewFundsMovement) *models.Transaction {
+	return &models.Transaction{
+		ToUserId: userID,
+		Amount:   movement.Amount,
+		Currency: movement.Currency,
+	}
+}
+
+// ToDomainWithdrawal converts an API NewFundsMovement model to a domain Transaction that withdraws
+// funds from the wallet of userID. The store fills in the payouts account.
+func ToDomainWithdrawal(userID string, movement *api.NewFundsMovement) *models.Transaction {
+	return &models.Transaction{
+		FromUserId: userID,
+		Amount:     movement.Amount,
+		Currency:   movement.Currency,
+	}
+}
+
+// ToApiWallet converts a domain Wallet model to an API Wallet model. Its balances are ordered by
+// currency code.
 func ToApiWallet(wallet *models.Wallet) *api.Wallet {
+	balances := make([]api.WalletBalance, 0, len(wallet.Balances))
+	for _, currency := range slices.Sorted(maps.Keys(wallet.Balances)) {
+		balance := wallet.Balances[currency]
+		balances = append(balances, api.WalletBalance{
+			Currency: currency,
+			Balance:  balance.Balance,
+			Reserved: balance.Reserved,
+		})
+	}
 	return &api.Wallet{
 		UserId:    &wallet.UserId,
 		Name:      &wallet.Name,
-		Balance:   &wallet.Balance,
-		Reserved:  &wallet.Reserved,
+		Balances:  &balances,
 		Version:   &wallet.Version,
 		CreatedAt: &wallet.CreatedAt,
 	}
@@ -45,34 +103,129 @@ func ToApiWallet(wallet *models.Wallet) *api.Wallet {
 
 // ToDomainNewWallet converts an API NewWallet model to a domain Wallet model.
 func ToDomainNewWallet(newWallet *api.NewWallet) *models.Wallet {
+	balances := make(map[string]models.CurrencyBalance, len(newWallet.Currencies))
+	for _, currency := range newWallet.Currencies {
+		balances[currency] = models.CurrencyBalance{Balance: 1000} // Seed new wallets with 1000 units, which the store records as a deposit.
+	}
 	return &models.Wallet{
-		UserId:  newWallet.UserId,
-		Name:    newWallet.Name,
-		Balance: 1000, // Seed new wallets with 1000 units.
-		Version: 1,
+		UserId:   newWallet.UserId,
+		Name:     newWallet.Name,
+		Balances: balances,
+		Version:  1,
 	}
 }
 
 
 func ToApiLedgerEntry(entry *models.LedgerEntry) *api.LedgerEntry {
-	return &api.LedgerEntry{
+	apiEntry := &api.LedgerEntry{
 		TransactionId: &entry.TransactionID,
 		EntryId:       &entry.EntryID,
 		AccountId:     &entry.AccountID,
+		Currency:      &entry.Currency,
 		Debit:         &entry.Debit,
 		Credit:        &entry.Credit,
 		Description:   &entry.Description,
 		Timestamp:     &entry.Timestamp,
 	}
+	// Entries recorded before the ledger was chained have no chain fields.
+	if entry.Sequence != 0 {
+		apiEntry.Sequence = &entry.Sequence
+		apiEntry.PreviousHash = &entry.PreviousHash
+		apiEntry.Hash = &entry.Hash
+	}
+	return apiEntry
 }
 
-// toApiStatus converts an internal domain status to a public API status.
-// It hides internal statuses like 'WORKING' from the API consumer.
-func toApiStatus(status models.TransactionStatus) api.TransactionStatus {
-	if status == models.WORKING {
-		return api.RESERVED
+// ToApiLedgerStatement converts a page of a domain LedgerStatement to an API LedgerStatement.
+func ToApiLedgerStatement(statement *models.LedgerStatement, next string) *api.LedgerStatement {
+	apiStatement := &api.LedgerStatement{
+		AccountId:      statement.AccountID,
+		Currency:       statement.Currency,
+		From:           statement.From,
+		To:             statement.To,
+		OpeningBalance: statement.OpeningBalance,
+		ClosingBalance: statement.ClosingBalance,
+		Lines:          make([]api.StatementLine, len(statement.Lines)),
+		NextCursor:     ToApiCursor(next),
+	}
+	for i := range statement.Lines {
+		line := &statement.Lines[i]
+		apiStatement.Lines[i] = api.StatementLine{
+			EntryId:       &line.EntryID,
+			TransactionId: &line.TransactionID,
+			Debit:         &line.Debit,
+			Credit:        &line.Credit,
+			Balance:       &line.Balance,
+			Timestamp:     &line.Timestamp,
+			Description:   &line.Description,
+		}
 	}
-	return api.TransactionStatus(status)
+	return apiStatement
+}
+
+// ToDomainNewRecurringTransfer converts an API NewRecurringTransfer model to a domain RecurringTransfer model.
+// Start times are kept to the second.
+func ToDomainNewRecurringTransfer(newRT *api.NewRecurringTransfer) *models.RecurringTransfer {
+	schedule := models.RecurrenceSchedule{
+		StartAt: newRT.Schedule.StartAt.UTC().Truncate(time.Second),
+		EndAt:   newRT.Schedule.EndAt,
+		Count:   newRT.Schedule.Count,
+	}
+	if newRT.Schedule.Cron != nil {
+		schedule.Cron = *newRT.Schedule.Cron
+	}
+	if newRT.Schedule.Frequency != nil {
+		schedule.Frequency = models.Frequency(*newRT.Schedule.Frequency)
+	}
+	if newRT.Schedule.Interval != nil {
+		schedule.Interval = *newRT.Schedule.Interval
+	}
+	return &models.RecurringTransfer{
+		FromUserId: newRT.FromUserId,
+		ToUserId:   newRT.ToUserId,
+		Amount:     newRT.Amount,
+		Currency:   newRT.Currency,
+		Schedule:   schedule,
+	}
+}
+
+// ToApiRecurringTransfer converts a domain RecurringTransfer model to an API RecurringTransfer model.
+func ToApiRecurringTransfer(rt *models.RecurringTransfer) *api.RecurringTransfer {
+	schedule := api.RecurrenceSchedule{
+		StartAt: rt.Schedule.StartAt,
+		EndAt:   rt.Schedule.EndAt,
+		Count:   rt.Schedule.Count,
+	}
+	if rt.Schedule.Cron != "" {
+		schedule.Cron = &rt.Schedule.Cron
+	}
+	if rt.Schedule.Frequency != "" {
+		frequency := api.RecurrenceScheduleFrequency(rt.Schedule.Frequency)
+		schedule.Frequency = &frequency
+	}
+	if rt.Schedule.Interval != 0 {
+		schedule.Interval = &rt.Schedule.Interval
+	}
+	apiRT := &api.RecurringTransfer{
+		Id:          rt.Id,
+		FromUserId:  rt.FromUserId,
+		ToUserId:    rt.ToUserId,
+		Amount:      rt.Amount,
+		Currency:    rt.Currency,
+		Schedule:    schedule,
+		Status:      api.RecurringTransferStatus(rt.Status),
+		Occurrences: rt.Occurrences,
+		NextRunAt:   rt.NextRunAt,
+		CreatedAt:   rt.CreatedAt,
+		UpdatedAt:   rt.UpdatedAt,
+	}
+	if rt.LastTransactionId != "" {
+		apiRT.LastTransactionId = &rt.LastTransactionId
+	}
+	if rt.LastError != "" {
+		apiRT.LastError = &rt.LastError
+	}
+	return apiRT
 }
 
 func ToDomainTransaction(tx *api.Transaction) *models.Transaction {
@@ -81,9 +234,36 @@ func ToDomainTransaction(tx *api.Transaction) *models.Transaction {
 		FromUserId:  *tx.FromUserId,
 		ToUserId:    *tx.ToUserId,
 		Amount:      *tx.Amount,
+		Currency:    *tx.Currency,
 		Status:      models.TransactionStatus(*tx.Status),
 		DelaySeconds: tx.DelaySeconds,
+		ExecuteAt:   tx.ExecuteAt,
+		DueAt:       tx.DueAt,
 		CreatedAt:   *tx.CreatedAt,
 		UpdatedAt:   *tx.UpdatedAt,
 	}
 }
+
+// ToPageRequest converts the limit and cursor query parameters of a list endpoint to a PageRequest.
+// It returns an error if the limit is outside the range allowed by the API.
+func ToPageRequest(limit *api.Limit, cursor *api.Cursor) (storage.PageRequest, error) {
+	var page storage.PageRequest
+	if limit != nil {
+		if *limit < 1 || *limit > storage.MaxPageSize {
+			return page, fmt.Errorf("limit must be between 1 and %d", storage.MaxPageSize)
+		}
+		page.Limit = *limit
+	}
+	if cursor != nil {
+		page.Cursor = *cursor
+	}
+	return page, nil
+}
+
+// ToApiCursor converts a storage next cursor to the API field, which is omitted on the last page.
+func ToApiCursor(next string) *string {
+	if next == "" {
+		return nil
+	}
+	return &next
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
@@ -8,36 +9,131 @@ import (
 type TransactionStatus string
 
 const (
-	RESERVED  TransactionStatus = "RESERVED"
-	WORKING   TransactionStatus = "WORKING"
-	APPROVED  TransactionStatus = "APPROVED"
-	COMPLETED TransactionStatus = "COMPLETED"
-	CANCELLED TransactionStatus = "CANCELLED"
+	RESERVED         TransactionStatus = "RESERVED"
+	PENDING_APPROVAL TransactionStatus = "PENDING_APPROVAL"
+	WORKING          TransactionStatus = "WORKING"
+	APPROVED         TransactionStatus = "APPROVED"
+	REJECTED         TransactionStatus = "REJECTED"
+	COMPLETED        TransactionStatus = "COMPLETED"
+	CANCELLED        TransactionStatus = "CANCELLED"
+	FAILED           TransactionStatus = "FAILED"
 )
 
+// System accounts are ledger accounts that are not backed by a wallet. Funds enter the system
+// by a deposit from FundingAccount and leave it by a withdrawal to PayoutsAccount.
+const (
+	FundingAccount = "SYSTEM:FUNDING"
+	PayoutsAccount = "SYSTEM:PAYOUTS"
+
+	// SystemAccountPrefix starts the ID of every system account. No wallet may use it.
+	SystemAccountPrefix = "SYSTEM:"
+)
+
+// IsSystemAccount reports whether an account ID names a system account.
+func IsSystemAccount(accountID string) bool {
+	return strings.HasPrefix(accountID, SystemAccountPrefix)
+}
+
 // Transaction represents the internal domain model for a transaction.
 // It includes dynamodbav and json tags for marshalling.
+// A scheduled transaction has either DelaySeconds, relative to CreatedAt, or ExecuteAt, an absolute time.
+// DueAt is the time it was due for settlement when it was created; it cannot settle before then.
+// FailureReason says why a FAILED transaction could not be settled.
+// LeaseOwner and LeaseExpiresAt record the settlement worker holding a WORKING transaction and
+// when its lease runs out, after which another worker may take the settlement over.
 type Transaction struct {
 	Id           string            `json:"id" dynamodbav:"id"`
 	FromUserId   string            `json:"from_user_id" dynamodbav:"from_user_id"`
 	ToUserId     string            `json:"to_user_id" dynamodbav:"to_user_id"`
 	Amount       int64             `json:"amount" dynamodbav:"amount"`
+	Currency     string            `json:"currency" dynamodbav:"currency"`
 	DelaySeconds *int32            `json:"delay_seconds,omitempty" dynamodbav:"delay_seconds,omitempty"`
+	ExecuteAt    *time.Time        `json:"execute_at,omitempty" dynamodbav:"execute_at,omitempty"`
+	DueAt        *time.Time        `json:"due_at,omitempty" dynamodbav:"due_at,omitempty,unixtime"`
 	Status       TransactionStatus `json:"status" dynamodbav:"status"`
 	CreatedAt    time.Time         `json:"created_at" dynamodbav:"created_at"`
 	UpdatedAt    time.Time         `json:"updated_at" dynamodbav:"updated_at"`
 	TTL          int64             `json:"ttl,omitempty" dynamodbav:"ttl,omitempty"`
+
+	FailureReason string `json:"failure_reason,omitempty" dynamodbav:"failure_reason,omitempty"`
+
+	LeaseOwner     string     `json:"lease_owner,omitempty" dynamodbav:"lease_owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" dynamodbav:"lease_expires_at,omitempty,unixtime"`
+
+	// IdempotencyKey is the client-supplied key the transaction was created with, if any, and
+	// RequestFingerprint identifies the request body so that a reused key can be detected.
+	IdempotencyKey     string `json:"idempotency_key,omitempty" dynamodbav:"idempotency_key,omitempty"`
+	RequestFingerprint string `json:"request_fingerprint,omitempty" dynamodbav:"request_fingerprint,omitempty"`
+}
+
+// RecurringTransferStatus defines the possible states of a recurring transfer.
+type RecurringTransferStatus string
+
+const (
+	RecurringActive    RecurringTransferStatus = "ACTIVE"
+	RecurringPaused    RecurringTransferStatus = "PAUSED"
+	RecurringCancelled RecurringTransferStatus = "CANCELLED"
+	// RecurringFinished transfers have run their last occurrence.
+	RecurringFinished RecurringTransferStatus = "FINISHED"
+)
+
+// Frequency is the period of a simple recurrence rule.
+type Frequency string
+
+const (
+	DAILY   Frequency = "DAILY"
+	WEEKLY  Frequency = "WEEKLY"
+	MONTHLY Frequency = "MONTHLY"
+)
+
+// RecurrenceSchedule describes when a recurring transfer occurs: at the times matching a cron
+// expression, or every Interval days, weeks or months, starting at StartAt. It ends after EndAt
+// or after Count occurrences, whichever is set; with neither, it runs until it is cancelled.
+type RecurrenceSchedule struct {
+	Cron      string     `json:"cron,omitempty" dynamodbav:"cron,omitempty"`
+	Frequency Frequency  `json:"frequency,omitempty" dynamodbav:"frequency,omitempty"`
+	Interval  int32      `json:"interval,omitempty" dynamodbav:"interval,omitempty"`
+	StartAt   time.Time  `json:"start_at" dynamodbav:"start_at"`
+	EndAt     *time.Time `json:"end_at,omitempty" dynamodbav:"end_at,omitempty"`
+	Count     *int32     `json:"count,omitempty" dynamodbav:"count,omitempty"`
+}
+
+// RecurringTransfer creates an ordinary transaction from FromUserId to ToUserId at every
+// occurrence of its schedule. NextRunAt is the time of the next occurrence and is only set
+// while the transfer is active. Version is used for optimistic locking.
+type RecurringTransfer struct {
+	Id                string                  `json:"id" dynamodbav:"id"`
+	FromUserId        string                  `json:"from_user_id" dynamodbav:"from_user_id"`
+	ToUserId          string                  `json:"to_user_id" dynamodbav:"to_user_id"`
+	Amount            int64                   `json:"amount" dynamodbav:"amount"`
+	Currency          string                  `json:"currency" dynamodbav:"currency"`
+	Schedule          RecurrenceSchedule      `json:"schedule" dynamodbav:"schedule"`
+	Status            RecurringTransferStatus `json:"status" dynamodbav:"status"`
+	Occurrences       int32                   `json:"occurrences" dynamodbav:"occurrences"`
+	NextRunAt         *time.Time              `json:"next_run_at,omitempty" dynamodbav:"next_run_at,omitempty"`
+	LastTransactionId string                  `json:"last_transaction_id,omitempty" dynamodbav:"last_transaction_id,omitempty"`
+	LastError         string                  `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	Version           int64                   `json:"version" dynamodbav:"version"`
+	CreatedAt         time.Time               `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt         time.Time               `json:"updated_at" dynamodbav:"updated_at"`
 }
 
 // Wallet represents the internal domain model for a user's wallet.
+// Balances holds the wallet's funds in each currency it can send and receive, keyed by ISO 4217 code.
 type Wallet struct {
-	UserId    string    `json:"user_id" dynamodbav:"user_id"`
-	Name      string    `json:"name" dynamodbav:"name"`
-	Balance   int64     `json:"balance" dynamodbav:"balance"`
-	Reserved  int64     `json:"reserved" dynamodbav:"reserved"`
-	Version   int64     `json:"version" dynamodbav:"version"`
-	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
-	TTL       int64     `json:"ttl,omitempty" dynamodbav:"ttl,omitempty"`
+	UserId    string                     `json:"user_id" dynamodbav:"user_id"`
+	Name      string                     `json:"name" dynamodbav:"name"`
+	Balances  map[string]CurrencyBalance `json:"balances" dynamodbav:"balances"`
+	Version   int64                      `json:"version" dynamodbav:"version"`
+	CreatedAt time.Time                  `json:"created_at" dynamodbav:"created_at"`
+	GSI1PK    string                     `json:"gsi1pk,omitempty" dynamodbav:"gsi1pk,omitempty"`
+}
+
+// CurrencyBalance is the available balance of a wallet in one currency and the funds reserved
+// from it for pending transactions.
+type CurrencyBalance struct {
+	Balance  int64 `json:"balance" dynamodbav:"balance"`
+	Reserved int64 `json:"reserved" dynamodbav:"reserved"`
 }
 
 // LedgerEntry represents a single entry in the double-entry ledger.
@@ -45,9 +141,34 @@ type LedgerEntry struct {
 	EntryID       string    `json:"entry_id" dynamodbav:"entry_id"`
 	TransactionID string    `json:"transaction_id" dynamodbav:"transaction_id"`
 	AccountID     string    `json:"account_id" dynamodbav:"account_id"`
+	Currency      string    `json:"currency" dynamodbav:"currency"`
 	Debit         int64     `json:"debit,omitempty" dynamodbav:"debit,omitempty"`
 	Credit        int64     `json:"credit,omitempty" dynamodbav:"credit,omitempty"`
 	Description   string    `json:"description" dynamodbav:"description"`
 	Timestamp     time.Time `json:"timestamp" dynamodbav:"timestamp"`
 	GSI1PK        string    `json:"gsi1pk" dynamodbav:"gsi1pk"`
+	// Sequence, PreviousHash and Hash link the entry into the hash chain of its account and
+	// currency; see storage.LinkEntries. Entries written before the chain existed have none.
+	Sequence     int64  `json:"sequence,omitempty" dynamodbav:"sequence,omitempty"`
+	PreviousHash string `json:"previous_hash,omitempty" dynamodbav:"previous_hash,omitempty"`
+	Hash         string `json:"hash,omitempty" dynamodbav:"hash,omitempty"`
+}
+
+// StatementLine is a ledger entry on an account statement, with the account's balance after it.
+type StatementLine struct {
+	LedgerEntry
+	Balance int64 `json:"balance"`
+}
+
+// LedgerStatement lists the ledger entries of one account in one currency with a timestamp in
+// [From, To), oldest first. The opening and closing balances are the credits minus the debits
+// of all the account's entries in that currency before From and before To.
+type LedgerStatement struct {
+	AccountID      string          `json:"account_id"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance int64           `json:"opening_balance"`
+	ClosingBalance int64           `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
 }
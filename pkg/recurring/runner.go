@@ -0,0 +1,149 @@
+package recurring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/mapping"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Store is the storage a Runner needs.
+type Store interface {
+	storage.RecurringTransferStore
+	CreateTransaction(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error)
+	GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error)
+}
+
+// Runner creates the transactions of recurring transfers as their occurrences come due. Each
+// occurrence becomes an ordinary transaction, created with CreateTransaction and handed to the
+// scheduler like one created through the API.
+type Runner struct {
+	Store     Store
+	Scheduler scheduler.CronScheduler
+	// Approval decides, as in the API, which transactions wait for approval instead of being
+	// enqueued. The zero value lets every transaction through.
+	Approval approval.Rules
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewRunner creates a new Runner.
+func NewRunner(store Store, scheduler scheduler.CronScheduler) *Runner {
+	return &Runner{Store: store, Scheduler: scheduler, Now: time.Now}
+}
+
+// Run runs every due occurrence of every active recurring transfer and returns the number of
+// occurrences run. A transfer whose occurrence fails is left as it is and retried by the next
+// run; the errors are joined and the remaining transfers are still run.
+func (r *Runner) Run(ctx context.Context) (int, error) {
+	now := r.now()
+	ran := 0
+	var errs []error
+	page := storage.PageRequest{Limit: storage.MaxPageSize}
+	for {
+		due, next, err := r.Store.ListDueRecurringTransfers(ctx, now, page)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list due recurring transfers: %w", err))
+			break
+		}
+		for i := range due {
+			n, err := r.runTransfer(ctx, &due[i], now)
+			ran += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("recurring transfer %s: %w", due[i].Id, err))
+			}
+		}
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	return ran, errors.Join(errs...)
+}
+
+// runTransfer runs the occurrences of a transfer that are due at or before now, so that a
+// transfer missed by earlier runs catches up.
+func (r *Runner) runTransfer(ctx context.Context, rt *models.RecurringTransfer, now time.Time) (int, error) {
+	ran := 0
+	for rt.Status == models.RecurringActive && rt.NextRunAt != nil && !rt.NextRunAt.After(now) {
+		if err := r.runOccurrence(ctx, rt); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// runOccurrence creates the transaction for the occurrence at rt.NextRunAt and moves the
+// transfer on to its next occurrence. An occurrence the sender cannot pay for is skipped and
+// recorded in LastError, rather than retried, so that a sender is never charged for several
+// missed occurrences at once.
+func (r *Runner) runOccurrence(ctx context.Context, rt *models.RecurringTransfer) error {
+	occurrence := *rt.NextRunAt
+	tx, err := r.createTransaction(ctx, rt, occurrence)
+	switch {
+	case err == nil:
+		rt.LastTransactionId = tx.Id
+		rt.LastError = ""
+	case errors.Is(err, storage.ErrInsufficientFunds), errors.Is(err, storage.ErrCurrencyNotHeld), errors.Is(err, storage.ErrWalletNotFound):
+		rt.LastError = fmt.Sprintf("occurrence at %s skipped: %v", occurrence.Format(time.RFC3339), err)
+	default:
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if err := Advance(rt); err != nil {
+		return err
+	}
+	if err := r.Store.UpdateRecurringTransfer(ctx, rt); err != nil {
+		return fmt.Errorf("failed to update recurring transfer: %w", err)
+	}
+	return nil
+}
+
+// createTransaction creates and schedules the transaction for an occurrence. The idempotency
+// key is derived from the occurrence, so if an earlier run created the transaction but failed
+// to record it, the same transaction is returned rather than a second one created.
+func (r *Runner) createTransaction(ctx context.Context, rt *models.RecurringTransfer, occurrence time.Time) (*models.Transaction, error) {
+	key := fmt.Sprintf("recurring:%s:%d", rt.Id, occurrence.Unix())
+	newTx := &models.Transaction{
+		FromUserId:     rt.FromUserId,
+		ToUserId:       rt.ToUserId,
+		Amount:         rt.Amount,
+		Currency:       rt.Currency,
+		ExecuteAt:      &occurrence,
+		IdempotencyKey: key,
+	}
+	if r.Approval.Required(newTx) {
+		newTx.Status = models.PENDING_APPROVAL
+	}
+	tx, err := r.Store.CreateTransaction(ctx, newTx)
+	if errors.Is(err, storage.ErrIdempotencyKeyExists) {
+		return r.Store.GetTransactionByIdempotencyKey(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// As in the API, a transaction that fails to enqueue is left reserved for reconciliation.
+	if r.Scheduler != nil && lifecycle.Settleable(tx.Status) {
+		if err := r.Scheduler.ScheduleTransaction(ctx, mapping.ToApiTransaction(tx), 0); err != nil {
+			log.Printf("CRITICAL: transaction %s created but failed to enqueue: %v", tx.Id, err)
+		}
+	}
+	return tx, nil
+}
+
+func (r *Runner) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
@@ -0,0 +1,251 @@
+package recurring
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/approval"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = date(2025, time.March, 1, 9, 0)
+
+func newStore(t *testing.T, balances map[string]int64) *memory.Store {
+	t.Helper()
+	store := memory.New()
+	for userID, balance := range balances {
+		_, err := store.CreateWallet(context.Background(), &models.Wallet{
+			UserId:    userID,
+			Balances:  map[string]models.CurrencyBalance{"USD": {Balance: balance}},
+			Version:   1,
+			CreatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+	return store
+}
+
+// newTransfer creates and starts a daily transfer of 10 USD from alice to bob.
+func newTransfer(t *testing.T, store storage.RecurringTransferStore, count *int32) *models.RecurringTransfer {
+	t.Helper()
+	rt := &models.RecurringTransfer{
+		FromUserId: "alice",
+		ToUserId:   "bob",
+		Amount:     10,
+		Currency:   "USD",
+		Schedule:   models.RecurrenceSchedule{Frequency: models.DAILY, StartAt: start, Count: count},
+	}
+	require.NoError(t, Start(rt))
+	rt, err := store.CreateRecurringTransfer(context.Background(), rt)
+	require.NoError(t, err)
+	return rt
+}
+
+func runAt(now time.Time, store Store, queue scheduler.CronScheduler) *Runner {
+	r := NewRunner(store, queue)
+	r.Now = func() time.Time { return now }
+	return r
+}
+
+func TestTransitions(t *testing.T) {
+	count := int32(3)
+	rt := &models.RecurringTransfer{Schedule: models.RecurrenceSchedule{Frequency: models.DAILY, StartAt: start, Count: &count}}
+	require.NoError(t, Start(rt))
+	assert.Equal(t, models.RecurringActive, rt.Status)
+	assert.Equal(t, start, *rt.NextRunAt)
+
+	upcoming, err := Upcoming(rt, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{start, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)}, upcoming, "upcoming occurrences stop at the count")
+
+	require.NoError(t, Advance(rt))
+	assert.Equal(t, int32(1), rt.Occurrences)
+	upcoming, err = Upcoming(rt, 10)
+	require.NoError(t, err)
+	assert.Len(t, upcoming, 2)
+
+	require.NoError(t, Pause(rt))
+	assert.Nil(t, rt.NextRunAt)
+	assert.ErrorIs(t, Pause(rt), ErrInvalidTransition)
+	upcoming, err = Upcoming(rt, 10)
+	require.NoError(t, err)
+	assert.Empty(t, upcoming, "a paused transfer has no upcoming occurrences")
+
+	require.NoError(t, Resume(rt, start.AddDate(0, 0, 1).Add(time.Minute)))
+	assert.Equal(t, models.RecurringActive, rt.Status)
+	assert.Equal(t, start.AddDate(0, 0, 2), *rt.NextRunAt, "occurrences while paused are skipped")
+	assert.ErrorIs(t, Resume(rt, start), ErrInvalidTransition)
+
+	require.NoError(t, Advance(rt))
+	assert.Equal(t, start.AddDate(0, 0, 3), *rt.NextRunAt, "skipped occurrences do not count")
+	require.NoError(t, Advance(rt))
+	assert.Equal(t, models.RecurringFinished, rt.Status, "the transfer finishes after count occurrences")
+	assert.Nil(t, rt.NextRunAt)
+	assert.ErrorIs(t, Cancel(rt), ErrInvalidTransition)
+
+	t.Run("Cancel", func(t *testing.T) {
+		rt := &models.RecurringTransfer{Schedule: models.RecurrenceSchedule{Frequency: models.WEEKLY, StartAt: start}}
+		require.NoError(t, Start(rt))
+		require.NoError(t, Cancel(rt))
+		assert.Equal(t, models.RecurringCancelled, rt.Status)
+		assert.Nil(t, rt.NextRunAt)
+		assert.ErrorIs(t, Resume(rt, start), ErrInvalidTransition)
+	})
+
+	t.Run("Resume After End", func(t *testing.T) {
+		end := start.AddDate(0, 0, 2)
+		rt := &models.RecurringTransfer{Schedule: models.RecurrenceSchedule{Frequency: models.DAILY, StartAt: start, EndAt: &end}}
+		require.NoError(t, Start(rt))
+		require.NoError(t, Pause(rt))
+		require.NoError(t, Resume(rt, end.Add(time.Hour)))
+		assert.Equal(t, models.RecurringFinished, rt.Status)
+	})
+
+	t.Run("Never Occurs", func(t *testing.T) {
+		rt := &models.RecurringTransfer{Schedule: models.RecurrenceSchedule{Cron: "0 0 31 4 *", StartAt: start}}
+		assert.ErrorIs(t, Start(rt), ErrInvalidSchedule)
+	})
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Creates Due Transactions", func(t *testing.T) {
+		store := newStore(t, map[string]int64{"alice": 100, "bob": 0})
+		queue := scheduler.NewMemoryQueue()
+		rt := newTransfer(t, store, nil)
+
+		ran, err := runAt(start.AddDate(0, 0, 2).Add(time.Hour), store, queue).Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, ran, "missed occurrences are caught up")
+		require.Len(t, queue.Messages(), 3)
+		assert.Equal(t, time.Duration(0), queue.Messages()[0].Delay)
+		assert.Equal(t, start, *queue.Messages()[0].Transaction.ExecuteAt)
+
+		stored, err := store.GetRecurringTransfer(ctx, rt.Id)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), stored.Occurrences)
+		assert.Equal(t, start.AddDate(0, 0, 3), *stored.NextRunAt)
+		assert.Equal(t, *queue.Messages()[2].Transaction.Id, stored.LastTransactionId)
+		tx, err := store.GetTransaction(ctx, stored.LastTransactionId)
+		require.NoError(t, err)
+		assert.Equal(t, models.RESERVED, tx.Status)
+		assert.Equal(t, int64(10), tx.Amount)
+		assert.Equal(t, int64(70), getBalance(t, store, "alice").Balance)
+
+		ran, err = runAt(start.AddDate(0, 0, 2).Add(time.Hour), store, queue).Run(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, ran, "an occurrence runs once")
+	})
+
+	t.Run("Finishes After Count", func(t *testing.T) {
+		store := newStore(t, map[string]int64{"alice": 100, "bob": 0})
+		count := int32(2)
+		rt := newTransfer(t, store, &count)
+
+		ran, err := runAt(start.AddDate(0, 1, 0), store, scheduler.NewMemoryQueue()).Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, ran)
+		stored, err := store.GetRecurringTransfer(ctx, rt.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.RecurringFinished, stored.Status)
+		assert.Nil(t, stored.NextRunAt)
+	})
+
+	t.Run("Skips Occurrences Without Funds", func(t *testing.T) {
+		store := newStore(t, map[string]int64{"alice": 15, "bob": 0})
+		rt := newTransfer(t, store, nil)
+
+		ran, err := runAt(start.AddDate(0, 0, 1), store, scheduler.NewMemoryQueue()).Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, ran)
+		stored, err := store.GetRecurringTransfer(ctx, rt.Id)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), stored.Occurrences)
+		assert.Contains(t, stored.LastError, storage.ErrInsufficientFunds.Error())
+		assert.NotEmpty(t, stored.LastTransactionId, "the first occurrence still ran")
+		assert.Equal(t, int64(5), getBalance(t, store, "alice").Balance)
+	})
+
+	t.Run("Holds Transactions For Approval", func(t *testing.T) {
+		store := newStore(t, map[string]int64{"alice": 100, "bob": 0})
+		queue := scheduler.NewMemoryQueue()
+		rt := newTransfer(t, store, nil)
+		runner := runAt(start, store, queue)
+		runner.Approval = approval.Rules{AllowedRecipients: map[string]bool{"carol": true}}
+
+		ran, err := runner.Run(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, ran)
+		assert.Empty(t, queue.Messages(), "a transaction waiting for approval is not enqueued")
+		stored, err := store.GetRecurringTransfer(ctx, rt.Id)
+		require.NoError(t, err)
+		tx, err := store.GetTransaction(ctx, stored.LastTransactionId)
+		require.NoError(t, err)
+		assert.Equal(t, models.PENDING_APPROVAL, tx.Status)
+		assert.Equal(t, int64(10), getBalance(t, store, "alice").Reserved)
+	})
+
+	t.Run("Ignores Paused Transfers", func(t *testing.T) {
+		store := newStore(t, map[string]int64{"alice": 100, "bob": 0})
+		rt := newTransfer(t, store, nil)
+		require.NoError(t, Pause(rt))
+		require.NoError(t, store.UpdateRecurringTransfer(ctx, rt))
+
+		ran, err := runAt(start.AddDate(0, 0, 5), store, scheduler.NewMemoryQueue()).Run(ctx)
+
+		require.NoError(t, err)
+		assert.Zero(t, ran)
+	})
+
+	t.Run("Retries Without Creating A Second Transaction", func(t *testing.T) {
+		store := newStore(t, map[string]int64{"alice": 100, "bob": 0})
+		rt := newTransfer(t, store, nil)
+		failing := &failingUpdates{Store: store, failures: 1}
+
+		ran, err := runAt(start, failing, scheduler.NewMemoryQueue()).Run(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), rt.Id)
+		assert.Zero(t, ran)
+
+		ran, err = runAt(start, failing, scheduler.NewMemoryQueue()).Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, ran)
+		assert.Equal(t, int64(90), getBalance(t, store, "alice").Balance, "the retried occurrence reuses the transaction")
+		stored, err := store.GetRecurringTransfer(ctx, rt.Id)
+		require.NoError(t, err)
+		assert.NotEmpty(t, stored.LastTransactionId)
+	})
+}
+
+// failingUpdates fails the given number of recurring transfer updates.
+type failingUpdates struct {
+	*memory.Store
+	failures int
+}
+
+func (s *failingUpdates) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("update failed")
+	}
+	return s.Store.UpdateRecurringTransfer(ctx, rt)
+}
+
+func getBalance(t *testing.T, store *memory.Store, userID string) models.CurrencyBalance {
+	t.Helper()
+	wallet, err := store.GetWallet(context.Background(), userID)
+	require.NoError(t, err)
+	return wallet.Balances["USD"]
+}
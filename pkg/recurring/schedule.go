@@ -0,0 +1,138 @@
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// ErrInvalidSchedule is returned for a recurrence schedule that is malformed or never occurs.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule works out the occurrences of a models.RecurrenceSchedule, in UTC.
+type Schedule struct {
+	spec models.RecurrenceSchedule
+	cron *cronExpr
+}
+
+// NewSchedule validates a recurrence schedule. Exactly one of Cron and Frequency must be set,
+// and at most one of EndAt and Count.
+func NewSchedule(spec models.RecurrenceSchedule) (*Schedule, error) {
+	s := &Schedule{spec: spec}
+	s.spec.StartAt = spec.StartAt.UTC()
+
+	switch {
+	case spec.Cron != "" && spec.Frequency != "":
+		return nil, fmt.Errorf("%w: set either cron or frequency, not both", ErrInvalidSchedule)
+	case spec.Cron != "":
+		if spec.Interval != 0 {
+			return nil, fmt.Errorf("%w: interval can only be used with frequency", ErrInvalidSchedule)
+		}
+		cron, err := parseCron(spec.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		s.cron = cron
+	case spec.Frequency == models.DAILY || spec.Frequency == models.WEEKLY || spec.Frequency == models.MONTHLY:
+		if spec.Interval < 0 {
+			return nil, fmt.Errorf("%w: interval must be positive", ErrInvalidSchedule)
+		}
+		if spec.Interval == 0 {
+			s.spec.Interval = 1
+		}
+	case spec.Frequency != "":
+		return nil, fmt.Errorf("%w: unknown frequency %q", ErrInvalidSchedule, spec.Frequency)
+	default:
+		return nil, fmt.Errorf("%w: set either cron or frequency", ErrInvalidSchedule)
+	}
+
+	if spec.StartAt.IsZero() {
+		return nil, fmt.Errorf("%w: start_at is required", ErrInvalidSchedule)
+	}
+	if spec.EndAt != nil && spec.Count != nil {
+		return nil, fmt.Errorf("%w: set either end_at or count, not both", ErrInvalidSchedule)
+	}
+	if spec.EndAt != nil && spec.EndAt.Before(spec.StartAt) {
+		return nil, fmt.Errorf("%w: end_at must not be before start_at", ErrInvalidSchedule)
+	}
+	if spec.Count != nil && *spec.Count < 1 {
+		return nil, fmt.Errorf("%w: count must be at least 1", ErrInvalidSchedule)
+	}
+	return s, nil
+}
+
+// From returns the first occurrence at or after t. It returns false if there is none before
+// the schedule's end. Count is not taken into account; the caller tracks how many
+// occurrences have run.
+func (s *Schedule) From(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	if t.Before(s.spec.StartAt) {
+		t = s.spec.StartAt
+	}
+
+	var next time.Time
+	if s.cron != nil {
+		var ok bool
+		if next, ok = s.cron.next(t); !ok {
+			return time.Time{}, false
+		}
+	} else {
+		next = s.fromRule(t)
+	}
+
+	if s.spec.EndAt != nil && next.After(*s.spec.EndAt) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// After returns the first occurrence strictly after t.
+func (s *Schedule) After(t time.Time) (time.Time, bool) {
+	return s.From(t.Add(time.Nanosecond))
+}
+
+// fromRule returns the first occurrence of a frequency rule at or after t, which is not
+// before the start.
+func (s *Schedule) fromRule(t time.Time) time.Time {
+	start, interval := s.spec.StartAt, int(s.spec.Interval)
+	var k int
+	switch s.spec.Frequency {
+	case models.DAILY, models.WEEKLY:
+		days := interval
+		if s.spec.Frequency == models.WEEKLY {
+			days *= 7
+		}
+		period := time.Duration(days) * 24 * time.Hour
+		k = int((t.Sub(start) + period - 1) / period)
+	case models.MONTHLY:
+		months := (t.Year()-start.Year())*12 + int(t.Month()-start.Month())
+		k = months / interval
+	}
+	for s.nth(k).Before(t) {
+		k++
+	}
+	return s.nth(k)
+}
+
+// nth returns occurrence k of a frequency rule, counting from zero. Monthly occurrences keep
+// the start's day of month, or fall on the last day of shorter months.
+func (s *Schedule) nth(k int) time.Time {
+	start, interval := s.spec.StartAt, int(s.spec.Interval)
+	switch s.spec.Frequency {
+	case models.DAILY:
+		return start.AddDate(0, 0, k*interval)
+	case models.WEEKLY:
+		return start.AddDate(0, 0, 7*k*interval)
+	default:
+		months := int(start.Month()) - 1 + k*interval
+		year, month := start.Year()+months/12, time.Month(months%12+1)
+		day := min(start.Day(), daysIn(year, month))
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+	}
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
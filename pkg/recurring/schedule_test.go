@@ -0,0 +1,175 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+// occurrences lists the first n occurrences of a schedule from its start.
+func occurrences(t *testing.T, spec models.RecurrenceSchedule, n int) []time.Time {
+	t.Helper()
+	schedule, err := NewSchedule(spec)
+	require.NoError(t, err)
+	var got []time.Time
+	next, ok := schedule.From(spec.StartAt)
+	for ok && len(got) < n {
+		got = append(got, next)
+		next, ok = schedule.After(next)
+	}
+	return got
+}
+
+func TestScheduleCron(t *testing.T) {
+	tests := []struct {
+		name  string
+		cron  string
+		start time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "Every 15 Minutes",
+			cron:  "*/15 * * * *",
+			start: date(2025, time.March, 1, 12, 5),
+			want:  []time.Time{date(2025, time.March, 1, 12, 15), date(2025, time.March, 1, 12, 30), date(2025, time.March, 1, 12, 45)},
+		},
+		{
+			name:  "Weekdays At 9",
+			cron:  "0 9 * * 1-5",
+			start: date(2025, time.February, 28, 10, 0), // a Friday
+			want:  []time.Time{date(2025, time.March, 3, 9, 0), date(2025, time.March, 4, 9, 0), date(2025, time.March, 5, 9, 0)},
+		},
+		{
+			name:  "Sunday As 7",
+			cron:  "30 8 * * 7",
+			start: date(2025, time.March, 1, 0, 0),
+			want:  []time.Time{date(2025, time.March, 2, 8, 30), date(2025, time.March, 9, 8, 30)},
+		},
+		{
+			name:  "Day Of Month Or Day Of Week",
+			cron:  "0 0 1 * 1",
+			start: date(2025, time.March, 1, 0, 0),
+			want:  []time.Time{date(2025, time.March, 1, 0, 0), date(2025, time.March, 3, 0, 0), date(2025, time.March, 10, 0, 0)},
+		},
+		{
+			name:  "Lists And Months",
+			cron:  "0 12 1,15 1,7 *",
+			start: date(2025, time.January, 2, 0, 0),
+			want:  []time.Time{date(2025, time.January, 15, 12, 0), date(2025, time.July, 1, 12, 0), date(2025, time.July, 15, 12, 0), date(2026, time.January, 1, 12, 0)},
+		},
+		{
+			name:  "Leap Day",
+			cron:  "0 0 29 2 *",
+			start: date(2025, time.January, 1, 0, 0),
+			want:  []time.Time{date(2028, time.February, 29, 0, 0), date(2032, time.February, 29, 0, 0)},
+		},
+		{
+			name:  "Never",
+			cron:  "0 0 30 2 *",
+			start: date(2025, time.January, 1, 0, 0),
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, models.RecurrenceSchedule{Cron: tt.cron, StartAt: tt.start}, len(tt.want))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScheduleRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency models.Frequency
+		interval  int32
+		start     time.Time
+		want      []time.Time
+	}{
+		{
+			name:      "Daily",
+			frequency: models.DAILY,
+			start:     date(2025, time.February, 27, 9, 30),
+			want:      []time.Time{date(2025, time.February, 27, 9, 30), date(2025, time.February, 28, 9, 30), date(2025, time.March, 1, 9, 30)},
+		},
+		{
+			name:      "Every Two Weeks",
+			frequency: models.WEEKLY,
+			interval:  2,
+			start:     date(2025, time.March, 3, 9, 0),
+			want:      []time.Time{date(2025, time.March, 3, 9, 0), date(2025, time.March, 17, 9, 0), date(2025, time.March, 31, 9, 0)},
+		},
+		{
+			name:      "Monthly On The 31st",
+			frequency: models.MONTHLY,
+			start:     date(2025, time.January, 31, 9, 0),
+			want:      []time.Time{date(2025, time.January, 31, 9, 0), date(2025, time.February, 28, 9, 0), date(2025, time.March, 31, 9, 0), date(2025, time.April, 30, 9, 0)},
+		},
+		{
+			name:      "Quarterly",
+			frequency: models.MONTHLY,
+			interval:  3,
+			start:     date(2025, time.November, 15, 0, 0),
+			want:      []time.Time{date(2025, time.November, 15, 0, 0), date(2026, time.February, 15, 0, 0), date(2026, time.May, 15, 0, 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := models.RecurrenceSchedule{Frequency: tt.frequency, Interval: tt.interval, StartAt: tt.start}
+			assert.Equal(t, tt.want, occurrences(t, spec, len(tt.want)))
+		})
+	}
+
+	t.Run("From", func(t *testing.T) {
+		schedule, err := NewSchedule(models.RecurrenceSchedule{Frequency: models.MONTHLY, StartAt: date(2025, time.January, 31, 9, 0)})
+		require.NoError(t, err)
+
+		next, ok := schedule.From(date(2025, time.February, 28, 9, 0))
+		assert.True(t, ok)
+		assert.Equal(t, date(2025, time.February, 28, 9, 0), next, "an occurrence at the given time is included")
+		next, ok = schedule.From(date(2025, time.February, 28, 9, 1))
+		assert.True(t, ok)
+		assert.Equal(t, date(2025, time.March, 31, 9, 0), next)
+	})
+
+	t.Run("End At", func(t *testing.T) {
+		end := date(2025, time.March, 3, 9, 0)
+		spec := models.RecurrenceSchedule{Frequency: models.DAILY, StartAt: date(2025, time.March, 1, 9, 0), EndAt: &end}
+		assert.Len(t, occurrences(t, spec, 10), 3, "an occurrence at the end time is included")
+	})
+}
+
+func TestNewScheduleValidation(t *testing.T) {
+	start := date(2025, time.March, 1, 0, 0)
+	before := start.Add(-time.Hour)
+	zero, one := int32(0), int32(1)
+	tests := map[string]models.RecurrenceSchedule{
+		"No Rule":           {StartAt: start},
+		"Cron And Rule":     {Cron: "* * * * *", Frequency: models.DAILY, StartAt: start},
+		"Unknown Frequency": {Frequency: "YEARLY", StartAt: start},
+		"Negative Interval": {Frequency: models.DAILY, Interval: -1, StartAt: start},
+		"Cron Interval":     {Cron: "* * * * *", Interval: 2, StartAt: start},
+		"No Start":          {Frequency: models.DAILY},
+		"End Before Start":  {Frequency: models.DAILY, StartAt: start, EndAt: &before},
+		"Zero Count":        {Frequency: models.DAILY, StartAt: start, Count: &zero},
+		"End And Count":     {Frequency: models.DAILY, StartAt: start, EndAt: &start, Count: &one},
+		"Too Few Fields":    {Cron: "0 9 * *", StartAt: start},
+		"Out Of Range":      {Cron: "60 * * * *", StartAt: start},
+		"Bad Range":         {Cron: "0 9 5-1 * *", StartAt: start},
+		"Bad Step":          {Cron: "*/0 * * * *", StartAt: start},
+		"Not A Number":      {Cron: "0 9 * JAN *", StartAt: start},
+	}
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewSchedule(spec)
+			assert.ErrorIs(t, err, ErrInvalidSchedule)
+		})
+	}
+}
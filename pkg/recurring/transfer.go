@@ -0,0 +1,115 @@
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// ErrInvalidTransition is returned when a recurring transfer is paused, resumed or cancelled
+// from a status that does not allow it.
+var ErrInvalidTransition = errors.New("invalid recurring transfer transition")
+
+// Start makes a new recurring transfer active, due at the first occurrence of its schedule.
+func Start(rt *models.RecurringTransfer) error {
+	schedule, err := NewSchedule(rt.Schedule)
+	if err != nil {
+		return err
+	}
+	first, ok := schedule.From(rt.Schedule.StartAt)
+	if !ok {
+		return fmt.Errorf("%w: the schedule never occurs", ErrInvalidSchedule)
+	}
+	rt.Status = models.RecurringActive
+	rt.Occurrences = 0
+	rt.NextRunAt = &first
+	return nil
+}
+
+// Pause stops an active recurring transfer until it is resumed.
+func Pause(rt *models.RecurringTransfer) error {
+	if rt.Status != models.RecurringActive {
+		return fmt.Errorf("%w: cannot pause a %s transfer", ErrInvalidTransition, rt.Status)
+	}
+	rt.Status = models.RecurringPaused
+	rt.NextRunAt = nil
+	return nil
+}
+
+// Resume makes a paused recurring transfer active again. Occurrences that fell while it was
+// paused are skipped, and do not count towards the schedule's Count: it is next due at the
+// first occurrence at or after now. If the schedule ended while it was paused, the transfer
+// is finished.
+func Resume(rt *models.RecurringTransfer, now time.Time) error {
+	if rt.Status != models.RecurringPaused {
+		return fmt.Errorf("%w: cannot resume a %s transfer", ErrInvalidTransition, rt.Status)
+	}
+	schedule, err := NewSchedule(rt.Schedule)
+	if err != nil {
+		return err
+	}
+	next, ok := schedule.From(now)
+	if !ok {
+		rt.Status = models.RecurringFinished
+		return nil
+	}
+	rt.Status = models.RecurringActive
+	rt.NextRunAt = &next
+	return nil
+}
+
+// Cancel stops an active or paused recurring transfer for good.
+func Cancel(rt *models.RecurringTransfer) error {
+	if rt.Status != models.RecurringActive && rt.Status != models.RecurringPaused {
+		return fmt.Errorf("%w: cannot cancel a %s transfer", ErrInvalidTransition, rt.Status)
+	}
+	rt.Status = models.RecurringCancelled
+	rt.NextRunAt = nil
+	return nil
+}
+
+// Advance records that the occurrence at NextRunAt has run and moves the transfer on to its
+// next occurrence, finishing it after the last one.
+func Advance(rt *models.RecurringTransfer) error {
+	if rt.Status != models.RecurringActive || rt.NextRunAt == nil {
+		return fmt.Errorf("%w: cannot advance a %s transfer", ErrInvalidTransition, rt.Status)
+	}
+	schedule, err := NewSchedule(rt.Schedule)
+	if err != nil {
+		return err
+	}
+	rt.Occurrences++
+	next, ok := schedule.After(*rt.NextRunAt)
+	if !ok || (rt.Schedule.Count != nil && rt.Occurrences >= *rt.Schedule.Count) {
+		rt.Status = models.RecurringFinished
+		rt.NextRunAt = nil
+		return nil
+	}
+	rt.NextRunAt = &next
+	return nil
+}
+
+// Upcoming returns up to n of the next occurrences of an active recurring transfer, earliest
+// first. A transfer that is not active has none.
+func Upcoming(rt *models.RecurringTransfer, n int) ([]time.Time, error) {
+	if rt.Status != models.RecurringActive || rt.NextRunAt == nil {
+		return []time.Time{}, nil
+	}
+	schedule, err := NewSchedule(rt.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	if rt.Schedule.Count != nil {
+		n = min(n, int(*rt.Schedule.Count-rt.Occurrences))
+	}
+
+	occurrences := make([]time.Time, 0, max(n, 0))
+	next, ok := *rt.NextRunAt, true
+	for ok && len(occurrences) < n {
+		occurrences = append(occurrences, next)
+		next, ok = schedule.After(next)
+	}
+	return occurrences, nil
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+)
+
+// MaxQueueDelay is the longest delay the settlement queue can hold a message for.
+const MaxQueueDelay = 15 * time.Minute
+
+// MaxDelay is the longest delay a transaction can be scheduled with.
+const MaxDelay = 90 * 24 * time.Hour
+
+// ErrInvalidDelay is returned for a delay that is negative or longer than MaxDelay.
+var ErrInvalidDelay = errors.New("delay must be between 0 and 90 days")
+
+// Schedule is a transaction held in a ScheduleTable until it is due for settlement.
+type Schedule struct {
+	TransactionID string
+	DueAt         time.Time
+	// Body is the JSON of the api.Transaction to enqueue.
+	Body string
+}
+
+// ScheduleTable stores the schedules of transactions whose delay is too long for the queue.
+type ScheduleTable interface {
+	// PutSchedule stores a schedule, replacing any schedule of the same transaction.
+	PutSchedule(ctx context.Context, schedule *Schedule) error
+	// ListDue returns up to limit schedules due at or before the given time, earliest first.
+	ListDue(ctx context.Context, before time.Time, limit int) ([]Schedule, error)
+	// DeleteSchedule removes the schedule of a transaction. Removing a missing schedule is not an error.
+	DeleteSchedule(ctx context.Context, transactionID string) error
+}
+
+// DurableScheduler schedules transactions days or weeks ahead. Delays the queue can hold are
+// enqueued directly; longer ones are written to a schedule table, and a Poller enqueues them
+// once they are within MaxQueueDelay of their due time.
+type DurableScheduler struct {
+	Table ScheduleTable
+	Queue CronScheduler
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewDurableScheduler creates a new DurableScheduler.
+func NewDurableScheduler(table ScheduleTable, queue CronScheduler) *DurableScheduler {
+	return &DurableScheduler{Table: table, Queue: queue, Now: time.Now}
+}
+
+// Make sure we conform to the interface
+var _ CronScheduler = (*DurableScheduler)(nil)
+
+// ScheduleTransaction enqueues the transaction, or stores it until it is due if the delay is
+// longer than MaxQueueDelay. A transaction with an ExecuteAt is due at that time, whatever the
+// given delay; if the time has passed, it is enqueued without delay.
+func (s *DurableScheduler) ScheduleTransaction(ctx context.Context, tx *api.Transaction, delay time.Duration) error {
+	now := s.now()
+	if tx.ExecuteAt != nil {
+		delay = max(tx.ExecuteAt.Sub(now), 0)
+	}
+	if delay < 0 || delay > MaxDelay {
+		return ErrInvalidDelay
+	}
+	if delay <= MaxQueueDelay {
+		return s.Queue.ScheduleTransaction(ctx, tx, delay)
+	}
+	if tx.Id == nil || *tx.Id == "" {
+		return fmt.Errorf("transaction has no ID")
+	}
+
+	body, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction for the schedule table: %w", err)
+	}
+	schedule := &Schedule{TransactionID: *tx.Id, DueAt: now.Add(delay), Body: string(body)}
+	if err := s.Table.PutSchedule(ctx, schedule); err != nil {
+		return fmt.Errorf("failed to store schedule: %w", err)
+	}
+	return nil
+}
+
+// DelayUntil returns the delay after which a transaction due at the given time can settle: the
+// time left until it, rounded up to whole seconds because SQS truncates delays to the second,
+// or 0 if it has passed.
+func DelayUntil(due, now time.Time) time.Duration {
+	remaining := due.Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+	return (remaining + time.Second - 1).Truncate(time.Second)
+}
+
+func (s *DurableScheduler) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
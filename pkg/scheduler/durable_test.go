@@ -0,0 +1,254 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/dynamodbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// clock is a settable time source.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTransaction(id string) *api.Transaction {
+	amount := int64(100)
+	from, to := "alice", "bob"
+	return &api.Transaction{Id: &id, FromUserId: &from, ToUserId: &to, Amount: &amount}
+}
+
+func newDynamoDBScheduleTable(t *testing.T) *DynamoDBScheduleTable {
+	t.Helper()
+	client := dynamodbtest.New()
+	_, err := client.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String("schedules"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("transaction_id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("gsi1pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("due_at"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{{AttributeName: aws.String("transaction_id"), KeyType: types.KeyTypeHash}},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String(dueIndex),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("gsi1pk"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("due_at"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	require.NoError(t, err)
+	return NewDynamoDBScheduleTable(client, "schedules")
+}
+
+func TestScheduleTable(t *testing.T) {
+	tables := map[string]func(t *testing.T) ScheduleTable{
+		"Memory":   func(t *testing.T) ScheduleTable { return NewMemoryScheduleTable() },
+		"DynamoDB": func(t *testing.T) ScheduleTable { return newDynamoDBScheduleTable(t) },
+	}
+	for name, newTable := range tables {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			table := newTable(t)
+			for i, hours := range []int{48, 1, 24} {
+				require.NoError(t, table.PutSchedule(ctx, &Schedule{
+					TransactionID: fmt.Sprintf("tx-%d", i),
+					DueAt:         start.Add(time.Duration(hours) * time.Hour),
+					Body:          fmt.Sprintf(`{"id":"tx-%d"}`, i),
+				}))
+			}
+
+			due, err := table.ListDue(ctx, start.Add(24*time.Hour), 10)
+			require.NoError(t, err)
+			require.Len(t, due, 2)
+			assert.Equal(t, Schedule{TransactionID: "tx-1", DueAt: start.Add(time.Hour), Body: `{"id":"tx-1"}`}, due[0])
+			assert.Equal(t, "tx-2", due[1].TransactionID, "a schedule due exactly at the bound is listed")
+
+			due, err = table.ListDue(ctx, start.Add(72*time.Hour), 1)
+			require.NoError(t, err)
+			require.Len(t, due, 1)
+			assert.Equal(t, "tx-1", due[0].TransactionID)
+
+			require.NoError(t, table.PutSchedule(ctx, &Schedule{TransactionID: "tx-1", DueAt: start.Add(96 * time.Hour), Body: "{}"}))
+			require.NoError(t, table.DeleteSchedule(ctx, "tx-2"))
+			require.NoError(t, table.DeleteSchedule(ctx, "missing"))
+
+			due, err = table.ListDue(ctx, start.Add(96*time.Hour), 10)
+			require.NoError(t, err)
+			require.Len(t, due, 2)
+			assert.Equal(t, "tx-0", due[0].TransactionID)
+			assert.Equal(t, "tx-1", due[1].TransactionID, "putting a schedule again replaces it")
+		})
+	}
+}
+
+func TestDurableScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Short Delay Is Enqueued", func(t *testing.T) {
+		table, queue := NewMemoryScheduleTable(), NewMemoryQueue()
+		s := NewDurableScheduler(table, queue)
+
+		require.NoError(t, s.ScheduleTransaction(ctx, newTransaction("tx-1"), MaxQueueDelay))
+
+		require.Len(t, queue.Messages(), 1)
+		assert.Equal(t, MaxQueueDelay, queue.Messages()[0].Delay)
+		due, err := table.ListDue(ctx, start.Add(MaxDelay), 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+	})
+
+	t.Run("Long Delay Is Stored", func(t *testing.T) {
+		table, queue := NewMemoryScheduleTable(), NewMemoryQueue()
+		s := NewDurableScheduler(table, queue)
+		s.Now = (&clock{now: start}).Now
+
+		require.NoError(t, s.ScheduleTransaction(ctx, newTransaction("tx-1"), 14*24*time.Hour))
+
+		assert.Empty(t, queue.Messages())
+		due, err := table.ListDue(ctx, start.Add(MaxDelay), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, "tx-1", due[0].TransactionID)
+		assert.Equal(t, start.Add(14*24*time.Hour), due[0].DueAt)
+		assert.JSONEq(t, `{"id":"tx-1","from_user_id":"alice","to_user_id":"bob","amount":100}`, due[0].Body)
+	})
+
+	t.Run("Execute At", func(t *testing.T) {
+		table, queue := NewMemoryScheduleTable(), NewMemoryQueue()
+		s := NewDurableScheduler(table, queue)
+		s.Now = (&clock{now: start}).Now
+		future, past := start.Add(30*24*time.Hour), start.Add(-time.Minute)
+		later, overdue := newTransaction("later"), newTransaction("overdue")
+		later.ExecuteAt, overdue.ExecuteAt = &future, &past
+
+		require.NoError(t, s.ScheduleTransaction(ctx, later, 0))
+		require.NoError(t, s.ScheduleTransaction(ctx, overdue, time.Hour))
+
+		due, err := table.ListDue(ctx, start.Add(MaxDelay), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, "later", due[0].TransactionID)
+		assert.Equal(t, future, due[0].DueAt, "the delay is worked out from execute_at")
+		require.Len(t, queue.Messages(), 1)
+		assert.Equal(t, "overdue", *queue.Messages()[0].Transaction.Id)
+		assert.Equal(t, time.Duration(0), queue.Messages()[0].Delay, "a past execute_at is enqueued without delay")
+	})
+
+	t.Run("Invalid Delay", func(t *testing.T) {
+		s := NewDurableScheduler(NewMemoryScheduleTable(), NewMemoryQueue())
+
+		assert.ErrorIs(t, s.ScheduleTransaction(ctx, newTransaction("tx-1"), -time.Second), ErrInvalidDelay)
+		assert.ErrorIs(t, s.ScheduleTransaction(ctx, newTransaction("tx-1"), MaxDelay+time.Second), ErrInvalidDelay)
+	})
+}
+
+func TestDelayUntil(t *testing.T) {
+	assert.Equal(t, 10*time.Minute, DelayUntil(start.Add(10*time.Minute), start))
+	assert.Equal(t, 2*time.Second, DelayUntil(start.Add(2*time.Second), start.Add(100*time.Millisecond)), "a part second is rounded up")
+	assert.Equal(t, time.Duration(0), DelayUntil(start, start))
+	assert.Equal(t, time.Duration(0), DelayUntil(start.Add(-time.Hour), start))
+}
+
+// failingQueue rejects the transactions with the given IDs and enqueues the rest.
+type failingQueue struct {
+	*MemoryQueue
+	fail map[string]bool
+}
+
+func (q *failingQueue) ScheduleTransaction(ctx context.Context, tx *api.Transaction, delay time.Duration) error {
+	if q.fail[*tx.Id] {
+		return errors.New("queue unavailable")
+	}
+	return q.MemoryQueue.ScheduleTransaction(ctx, tx, delay)
+}
+
+func TestPoller(t *testing.T) {
+	ctx := context.Background()
+
+	schedule := func(t *testing.T, table ScheduleTable, delays map[string]time.Duration) {
+		t.Helper()
+		c := &clock{now: start}
+		s := NewDurableScheduler(table, NewMemoryQueue())
+		s.Now = c.Now
+		for id, delay := range delays {
+			require.NoError(t, s.ScheduleTransaction(ctx, newTransaction(id), delay))
+		}
+	}
+
+	t.Run("Moves Due Schedules", func(t *testing.T) {
+		table, queue := NewMemoryScheduleTable(), NewMemoryQueue()
+		schedule(t, table, map[string]time.Duration{"overdue": time.Hour, "soon": 3 * time.Hour, "later": 7 * 24 * time.Hour})
+		c := &clock{now: start.Add(3*time.Hour - 10*time.Minute)}
+		p := NewPoller(table, queue)
+		p.Now = c.Now
+
+		moved, err := p.Poll(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, moved)
+		messages := queue.Messages()
+		require.Len(t, messages, 2)
+		assert.Equal(t, "overdue", *messages[0].Transaction.Id)
+		assert.Equal(t, time.Duration(0), messages[0].Delay, "an overdue schedule is enqueued without delay")
+		assert.Equal(t, "soon", *messages[1].Transaction.Id)
+		assert.Equal(t, 10*time.Minute, messages[1].Delay, "a schedule is enqueued with the rest of its delay")
+		assert.Equal(t, "bob", *messages[1].Transaction.ToUserId)
+
+		remaining, err := table.ListDue(ctx, start.Add(MaxDelay), 10)
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "later", remaining[0].TransactionID)
+
+		moved, err = p.Poll(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, moved, "an enqueued schedule is not enqueued again")
+	})
+
+	t.Run("Keeps Schedules That Fail To Enqueue", func(t *testing.T) {
+		table := NewMemoryScheduleTable()
+		schedule(t, table, map[string]time.Duration{"tx-1": time.Hour, "tx-2": 2 * time.Hour})
+		queue := &failingQueue{MemoryQueue: NewMemoryQueue(), fail: map[string]bool{"tx-1": true}}
+		p := NewPoller(table, queue)
+		p.Now = (&clock{now: start.Add(3 * time.Hour)}).Now
+
+		moved, err := p.Poll(ctx)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tx-1")
+		assert.Equal(t, 1, moved)
+		remaining, err := table.ListDue(ctx, start.Add(MaxDelay), 10)
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "tx-1", remaining[0].TransactionID)
+	})
+
+	t.Run("Reads In Batches", func(t *testing.T) {
+		table, queue := NewMemoryScheduleTable(), NewMemoryQueue()
+		delays := make(map[string]time.Duration)
+		for i := range pollBatchSize + 5 {
+			delays[fmt.Sprintf("tx-%03d", i)] = time.Hour + time.Duration(i)*time.Second
+		}
+		schedule(t, table, delays)
+		p := NewPoller(table, queue)
+		p.Now = (&clock{now: start.Add(2 * time.Hour)}).Now
+
+		moved, err := p.Poll(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, pollBatchSize+5, moved)
+		assert.Len(t, queue.Messages(), pollBatchSize+5)
+	})
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ScheduleTableAPI is the subset of the DynamoDB client a DynamoDBScheduleTable uses.
+type ScheduleTableAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+const (
+	// dueIndex orders every schedule by due time. All schedules share one partition, gsi1pk.
+	dueIndex        = "gsi1pk-due_at-index"
+	schedulePartKey = "SCHEDULE"
+)
+
+// DynamoDBScheduleTable implements ScheduleTable with a DynamoDB table keyed by transaction_id.
+// Due times are stored as Unix milliseconds in due_at, so that the due index sorts by time.
+type DynamoDBScheduleTable struct {
+	Client    ScheduleTableAPI
+	TableName string
+}
+
+// NewDynamoDBScheduleTable creates a new DynamoDBScheduleTable.
+func NewDynamoDBScheduleTable(client ScheduleTableAPI, tableName string) *DynamoDBScheduleTable {
+	return &DynamoDBScheduleTable{Client: client, TableName: tableName}
+}
+
+// Make sure we conform to the interface
+var _ ScheduleTable = (*DynamoDBScheduleTable)(nil)
+
+// PutSchedule stores a schedule, replacing any schedule of the same transaction.
+func (t *DynamoDBScheduleTable) PutSchedule(ctx context.Context, schedule *Schedule) error {
+	_, err := t.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(t.TableName),
+		Item: map[string]types.AttributeValue{
+			"transaction_id": &types.AttributeValueMemberS{Value: schedule.TransactionID},
+			"gsi1pk":         &types.AttributeValueMemberS{Value: schedulePartKey},
+			"due_at":         &types.AttributeValueMemberN{Value: strconv.FormatInt(schedule.DueAt.UnixMilli(), 10)},
+			"body":           &types.AttributeValueMemberS{Value: schedule.Body},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put schedule: %w", err)
+	}
+	return nil
+}
+
+// ListDue returns up to limit schedules due at or before the given time, earliest first.
+func (t *DynamoDBScheduleTable) ListDue(ctx context.Context, before time.Time, limit int) ([]Schedule, error) {
+	out, err := t.Client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(t.TableName),
+		IndexName:              aws.String(dueIndex),
+		KeyConditionExpression: aws.String("gsi1pk = :pk AND due_at <= :before"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: schedulePartKey},
+			":before": &types.AttributeValueMemberN{Value: strconv.FormatInt(before.UnixMilli(), 10)},
+		},
+		ScanIndexForward: aws.Bool(true),
+		Limit:            aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due schedules: %w", err)
+	}
+
+	schedules := make([]Schedule, 0, len(out.Items))
+	for _, item := range out.Items {
+		schedule, err := scheduleFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule removes the schedule of a transaction.
+func (t *DynamoDBScheduleTable) DeleteSchedule(ctx context.Context, transactionID string) error {
+	_, err := t.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(t.TableName),
+		Key: map[string]types.AttributeValue{
+			"transaction_id": &types.AttributeValueMemberS{Value: transactionID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+func scheduleFromItem(item map[string]types.AttributeValue) (Schedule, error) {
+	id, ok := item["transaction_id"].(*types.AttributeValueMemberS)
+	if !ok {
+		return Schedule{}, fmt.Errorf("schedule item has no transaction_id")
+	}
+	dueAt, ok := item["due_at"].(*types.AttributeValueMemberN)
+	if !ok {
+		return Schedule{}, fmt.Errorf("schedule %s has no due_at", id.Value)
+	}
+	millis, err := strconv.ParseInt(dueAt.Value, 10, 64)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("schedule %s has an invalid due_at: %w", id.Value, err)
+	}
+	body, ok := item["body"].(*types.AttributeValueMemberS)
+	if !ok {
+		return Schedule{}, fmt.Errorf("schedule %s has no body", id.Value)
+	}
+	return Schedule{TransactionID: id.Value, DueAt: time.UnixMilli(millis).UTC(), Body: body.Value}, nil
+}
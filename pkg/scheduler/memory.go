@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+)
+
+// MemoryScheduleTable is an in-memory ScheduleTable, a local stand-in for the DynamoDB table.
+// It is safe for concurrent use.
+type MemoryScheduleTable struct {
+	mu        sync.Mutex
+	schedules map[string]Schedule
+}
+
+// NewMemoryScheduleTable creates an empty MemoryScheduleTable.
+func NewMemoryScheduleTable() *MemoryScheduleTable {
+	return &MemoryScheduleTable{schedules: make(map[string]Schedule)}
+}
+
+// Make sure we conform to the interface
+var _ ScheduleTable = (*MemoryScheduleTable)(nil)
+
+// PutSchedule stores a schedule, replacing any schedule of the same transaction.
+func (t *MemoryScheduleTable) PutSchedule(ctx context.Context, schedule *Schedule) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.schedules[schedule.TransactionID] = *schedule
+	return nil
+}
+
+// ListDue returns up to limit schedules due at or before the given time, earliest first.
+func (t *MemoryScheduleTable) ListDue(ctx context.Context, before time.Time, limit int) ([]Schedule, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var due []Schedule
+	for _, schedule := range t.schedules {
+		if !schedule.DueAt.After(before) {
+			due = append(due, schedule)
+		}
+	}
+	slices.SortFunc(due, func(a, b Schedule) int {
+		return cmp.Or(a.DueAt.Compare(b.DueAt), cmp.Compare(a.TransactionID, b.TransactionID))
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// DeleteSchedule removes the schedule of a transaction.
+func (t *MemoryScheduleTable) DeleteSchedule(ctx context.Context, transactionID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.schedules, transactionID)
+	return nil
+}
+
+// QueuedTransaction is a transaction sent to a MemoryQueue.
+type QueuedTransaction struct {
+	Transaction *api.Transaction
+	Delay       time.Duration
+}
+
+// MemoryQueue is an in-memory CronScheduler, a local stand-in for the settlement queue that
+// records what is sent to it. It is safe for concurrent use.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	messages []QueuedTransaction
+}
+
+// NewMemoryQueue creates an empty MemoryQueue.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{}
+}
+
+// Make sure we conform to the interface
+var _ CronScheduler = (*MemoryQueue)(nil)
+
+// ScheduleTransaction records the transaction and its delay.
+func (q *MemoryQueue) ScheduleTransaction(ctx context.Context, tx *api.Transaction, delay time.Duration) error {
+	if delay < 0 || delay > MaxQueueDelay {
+		return fmt.Errorf("delay must be between 0 and 15 minutes")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = append(q.messages, QueuedTransaction{Transaction: tx, Delay: delay})
+	return nil
+}
+
+// Messages returns the transactions sent to the queue, in the order they were sent.
+func (q *MemoryQueue) Messages() []QueuedTransaction {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.messages)
+}
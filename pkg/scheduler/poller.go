@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/api"
+)
+
+// pollBatchSize is the number of schedules a Poller reads from the table at a time.
+const pollBatchSize = 100
+
+// Poller moves schedules from a schedule table onto the settlement queue as they come due.
+//
+// A schedule is enqueued once it is due within MaxQueueDelay, with the rest of its delay, so it
+// settles on time as long as the poller runs more often than every MaxQueueDelay. It is deleted
+// from the table only after it has been enqueued; if the delete fails, it is enqueued again by
+// the next poll, which settlement tolerates because settling a transaction twice is a no-op.
+type Poller struct {
+	Table ScheduleTable
+	Queue CronScheduler
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewPoller creates a new Poller.
+func NewPoller(table ScheduleTable, queue CronScheduler) *Poller {
+	return &Poller{Table: table, Queue: queue, Now: time.Now}
+}
+
+// Poll enqueues every schedule due within MaxQueueDelay and returns how many it enqueued.
+// A schedule that cannot be enqueued is left in the table for the next poll, and the errors
+// are returned once the rest of the batch has been handled.
+func (p *Poller) Poll(ctx context.Context) (int, error) {
+	now := p.now()
+	moved := 0
+	for {
+		schedules, err := p.Table.ListDue(ctx, now.Add(MaxQueueDelay), pollBatchSize)
+		if err != nil {
+			return moved, fmt.Errorf("failed to list due schedules: %w", err)
+		}
+
+		var errs []error
+		for i := range schedules {
+			if err := p.enqueue(ctx, &schedules[i], now); err != nil {
+				errs = append(errs, fmt.Errorf("transaction %s: %w", schedules[i].TransactionID, err))
+				continue
+			}
+			moved++
+		}
+		// Failed schedules are still due, so reading on would return them again.
+		if len(errs) > 0 {
+			return moved, errors.Join(errs...)
+		}
+		if len(schedules) < pollBatchSize {
+			return moved, nil
+		}
+	}
+}
+
+// enqueue sends a schedule to the queue with the rest of its delay and removes it from the table.
+func (p *Poller) enqueue(ctx context.Context, schedule *Schedule, now time.Time) error {
+	var tx api.Transaction
+	if err := json.Unmarshal([]byte(schedule.Body), &tx); err != nil {
+		return fmt.Errorf("failed to unmarshal scheduled transaction: %w", err)
+	}
+	delay := min(max(schedule.DueAt.Sub(now), 0), MaxQueueDelay)
+	if err := p.Queue.ScheduleTransaction(ctx, &tx, delay); err != nil {
+		return fmt.Errorf("failed to enqueue: %w", err)
+	}
+	if err := p.Table.DeleteSchedule(ctx, schedule.TransactionID); err != nil {
+		return fmt.Errorf("enqueued but failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+func (p *Poller) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
@@ -7,5 +7,7 @@ package storage
 type ApiStore interface {
 	TransactionStore
 	WalletStore
+	FundsManager
 	LedgerReader
+	RecurringTransferStore
 }
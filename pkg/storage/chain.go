@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// The ledger is tamper-evident: the entries of each account in each currency form a hash chain.
+// Every entry carries its position in the chain, the hash of the entry before it and a hash of
+// its own content, so rewriting or removing a historical entry breaks every link after it.
+// Stores link new entries with LinkEntries in the same atomic write that records them, keeping
+// the head of each chain alongside the entries so that concurrent writers cannot fork it.
+//
+// System accounts are not chained. They are the counterparty of every deposit or withdrawal in a
+// currency, so chaining them would serialise all funding through a single chain head. Each of
+// their entries is matched by a chained wallet entry of the same transaction, and the audit checks
+// that the two balance.
+
+// ChainKey identifies a ledger chain: the entries of one account in one currency.
+type ChainKey struct {
+	AccountID string
+	Currency  string
+}
+
+// String returns the key as "<account>#<currency>", for stores that need a single string key.
+func (k ChainKey) String() string {
+	return k.AccountID + "#" + k.Currency
+}
+
+// ChainHead is the last link of a ledger chain. The zero value is the head of an empty chain.
+type ChainHead struct {
+	Sequence int64
+	Hash     string
+}
+
+// Chained reports whether an entry belongs to a ledger chain: it does unless it is an entry of a
+// system account.
+func Chained(entry *models.LedgerEntry) bool {
+	return !models.IsSystemAccount(entry.AccountID)
+}
+
+// ChainKeys returns the distinct chains that entries belong to, ordered by account and then
+// currency, so that stores lock chain heads in a stable order. Entries that are not Chained
+// belong to none.
+func ChainKeys(entries []models.LedgerEntry) []ChainKey {
+	var keys []ChainKey
+	for _, entry := range entries {
+		if !Chained(&entry) {
+			continue
+		}
+		key := ChainKey{AccountID: entry.AccountID, Currency: entry.Currency}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b ChainKey) int {
+		return cmp.Or(cmp.Compare(a.AccountID, b.AccountID), cmp.Compare(a.Currency, b.Currency))
+	})
+	return keys
+}
+
+// LinkEntries appends entries, in order, to the chains whose current heads are given. It sets
+// the sequence, previous hash and hash of each entry and advances heads to the new last links.
+// Timestamps are truncated to microseconds, the finest precision every store keeps, so that a
+// hash still matches the entry once it has been read back. Entries that are not Chained are
+// left unlinked.
+func LinkEntries(entries []models.LedgerEntry, heads map[ChainKey]ChainHead) {
+	for i := range entries {
+		entry := &entries[i]
+		entry.Timestamp = entry.Timestamp.UTC().Truncate(time.Microsecond)
+		if !Chained(entry) {
+			continue
+		}
+		key := ChainKey{AccountID: entry.AccountID, Currency: entry.Currency}
+		head := heads[key]
+		entry.Sequence = head.Sequence + 1
+		entry.PreviousHash = head.Hash
+		entry.Hash = EntryHash(entry)
+		heads[key] = ChainHead{Sequence: entry.Sequence, Hash: entry.Hash}
+	}
+}
+
+// EntryHash returns the hex-encoded SHA-256 hash of an entry's content and chain position.
+// Every field but the hash itself and the index key is covered.
+func EntryHash(entry *models.LedgerEntry) string {
+	// Struct fields are encoded in declaration order, which fixes the hashed representation.
+	content, _ := json.Marshal(struct {
+		Sequence      int64  `json:"sequence"`
+		PreviousHash  string `json:"previous_hash"`
+		EntryID       string `json:"entry_id"`
+		TransactionID string `json:"transaction_id"`
+		AccountID     string `json:"account_id"`
+		Currency      string `json:"currency"`
+		Debit         int64  `json:"debit"`
+		Credit        int64  `json:"credit"`
+		Description   string `json:"description"`
+		Timestamp     string `json:"timestamp"`
+	}{
+		Sequence:      entry.Sequence,
+		PreviousHash:  entry.PreviousHash,
+		EntryID:       entry.EntryID,
+		TransactionID: entry.TransactionID,
+		AccountID:     entry.AccountID,
+		Currency:      entry.Currency,
+		Debit:         entry.Debit,
+		Credit:        entry.Credit,
+		Description:   entry.Description,
+		Timestamp:     entry.Timestamp.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainBreak describes the first link of a ledger chain that does not verify.
+type ChainBreak struct {
+	// Sequence is the position in the chain at which the break was found.
+	Sequence int64  `json:"sequence"`
+	EntryID  string `json:"entry_id,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// VerifyLinks checks that entries form an intact chain ending at head, the stored head of the
+// chain: their sequences run from 1 without gaps or duplicates, each entry names the hash of the
+// one before it, each hash matches its entry's content, and the last entry is the head, so that
+// entries removed from the end of the chain are found too. It returns the first broken link, or
+// nil. Entries may be in any order.
+// Entries without a sequence were written before the ledger was chained, or belong to a system
+// account, and are not checked. Neither are entries beyond the head, which were appended after
+// the head was read; read the head before the entries.
+func VerifyLinks(entries []models.LedgerEntry, head ChainHead) *ChainBreak {
+	var chain []models.LedgerEntry
+	for _, entry := range entries {
+		if entry.Sequence != 0 && entry.Sequence <= head.Sequence {
+			chain = append(chain, entry)
+		}
+	}
+	slices.SortStableFunc(chain, func(a, b models.LedgerEntry) int { return cmp.Compare(a.Sequence, b.Sequence) })
+
+	var previous, previousID string
+	for i, entry := range chain {
+		want := int64(i + 1)
+		switch {
+		case entry.Sequence < want:
+			return &ChainBreak{Sequence: entry.Sequence, EntryID: entry.EntryID, Reason: "sequence is used by more than one entry"}
+		case entry.Sequence > want:
+			return &ChainBreak{Sequence: want, Reason: fmt.Sprintf("entry is missing; the chain continues at sequence %d", entry.Sequence)}
+		case entry.PreviousHash != previous:
+			return &ChainBreak{Sequence: entry.Sequence, EntryID: entry.EntryID, Reason: "previous hash does not match the hash of the entry before it"}
+		case entry.Hash != EntryHash(&entry):
+			return &ChainBreak{Sequence: entry.Sequence, EntryID: entry.EntryID, Reason: "hash does not match the entry's content"}
+		}
+		previous, previousID = entry.Hash, entry.EntryID
+	}
+
+	last := int64(len(chain))
+	switch {
+	case last < head.Sequence:
+		return &ChainBreak{Sequence: last + 1, Reason: fmt.Sprintf("entry is missing; the chain head is at sequence %d", head.Sequence)}
+	case previous != head.Hash:
+		return &ChainBreak{Sequence: last, EntryID: previousID, Reason: "hash does not match the chain head"}
+	}
+	return nil
+}
+
+// endOfTime is later than any ledger entry.
+var endOfTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// VerifyChain reads the head and then the whole ledger chain of an account in one currency and
+// returns its first broken link, or nil if the chain is intact. See VerifyLinks.
+func VerifyChain(ctx context.Context, reader LedgerReader, accountID, currency string) (*ChainBreak, error) {
+	head, err := reader.GetChainHead(ctx, ChainKey{AccountID: accountID, Currency: currency})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger chain head: %w", err)
+	}
+	var entries []models.LedgerEntry
+	page := PageRequest{Limit: MaxPageSize}
+	for {
+		items, next, err := reader.ListLedgerEntriesByAccount(ctx, accountID, currency, time.Time{}, endOfTime, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ledger chain: %w", err)
+		}
+		entries = append(entries, items...)
+		if next == "" {
+			return VerifyLinks(entries, head), nil
+		}
+		page.Cursor = next
+	}
+}
@@ -0,0 +1,71 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// ApproveTransaction atomically moves a PENDING_APPROVAL transaction to APPROVED. Its funds stay
+// reserved, so the sender's wallet is not written.
+func (s *Store) ApproveTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	nowAV, err := attributevalue.Marshal(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal timestamp for approval: %w", err)
+	}
+
+	condition, values := lifecycle.Condition(models.APPROVED)
+	values[":approved_status"] = &types.AttributeValueMemberS{Value: string(models.APPROVED)}
+	values[":now"] = nowAV
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TransactionsTableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: txID},
+		},
+		UpdateExpression:    aws.String("SET #status = :approved_status, updated_at = :now"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+		// The current item tells a missing transaction apart from one in another status.
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	result, err := s.Client.UpdateItem(ctx, input)
+	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			if condCheckFailed.Item == nil {
+				return nil, fmt.Errorf("failed to get transaction for approval: %w: ID %s", storage.ErrTransactionNotFound, txID)
+			}
+			return nil, storage.ErrTransactionNotPendingApproval
+		}
+		return nil, fmt.Errorf("failed to execute approval: %w", err)
+	}
+
+	var tx models.Transaction
+	if err := attributevalue.UnmarshalMap(result.Attributes, &tx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal approved transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// RejectTransaction atomically releases the reserved funds of a PENDING_APPROVAL transaction back to
+// the sender and marks it as rejected. It is retried if the sender's wallet changes between being
+// read and being written.
+func (s *Store) RejectTransaction(ctx context.Context, txID string) error {
+	return retryOnConflict(ctx, func() error {
+		return s.releaseReservation(ctx, txID, models.REJECTED, "", "", storage.ErrTransactionNotPendingApproval, "rejection")
+	})
+}
@@ -0,0 +1,63 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/audit"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expireAll deletes every item of a table that has a ttl, as DynamoDB does once the time has passed.
+func expireAll(t *testing.T, store *Store, tableName string) {
+	t.Helper()
+	ctx := context.Background()
+	result, err := store.Client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String(tableName)})
+	require.NoError(t, err)
+	for _, item := range result.Items {
+		if _, ok := item["ttl"]; !ok {
+			continue
+		}
+		_, err := store.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key:       map[string]types.AttributeValue{"id": item["id"]},
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestAuditAfterTransactionsExpire(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+	_, err := store.CreateWallet(ctx, &models.Wallet{UserId: "alice", Balances: usd(500), Version: 1})
+	require.NoError(t, err)
+	_, err = store.CreateWallet(ctx, &models.Wallet{UserId: "bob", Balances: usd(0), Version: 1})
+	require.NoError(t, err)
+	_, err = store.Deposit(ctx, &models.Transaction{ToUserId: "bob", Amount: 30, Currency: "USD"})
+	require.NoError(t, err)
+	_, err = store.Withdraw(ctx, &models.Transaction{FromUserId: "alice", Amount: 20, Currency: "USD"})
+	require.NoError(t, err)
+	settled, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+	ok, err := store.SettleTransaction(ctx, settled, "worker-1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	cancelled, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 50, Currency: "USD"})
+	require.NoError(t, err)
+	require.NoError(t, store.CancelTransaction(ctx, cancelled.Id))
+
+	// Every transaction with ledger entries outlives its TTL; the cancelled one expires.
+	expireAll(t, store, store.TransactionsTableName)
+
+	_, err = store.GetTransaction(ctx, cancelled.Id)
+	assert.ErrorIs(t, err, storage.ErrTransactionNotFound, "a cancelled transaction expires")
+	report, err := audit.Run(ctx, store)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "discrepancies: %+v", report.Discrepancies)
+}
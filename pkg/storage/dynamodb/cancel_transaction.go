@@ -9,38 +9,71 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 )
 
+// CancelTransaction atomically releases a reserved transaction's funds back to the sender and marks it
+// as cancelled. It is retried if the sender's wallet changes between being read and being written.
 func (s *Store) CancelTransaction(ctx context.Context, txID string) error {
+	return retryOnConflict(ctx, func() error {
+		return s.releaseReservation(ctx, txID, models.CANCELLED, "", "", storage.ErrTransactionNotCancellable, "cancellation")
+	})
+}
+
+// releaseReservation makes a single attempt at releasing the reserved funds of a transaction back to
+// the sender and moving it to the given status, recording reason as its failure reason if it is not
+// empty. If owner is not empty, the transaction must still be leased to it, or ErrLeaseLost is returned.
+// notAllowed is returned for a transaction whose status may not move to it, including one whose
+// status changes before it is written. If the wallet changes, ErrVersionConflict is returned so that
+// the attempt is retried.
+func (s *Store) releaseReservation(ctx context.Context, txID string, status models.TransactionStatus, reason, owner string, notAllowed error, operation string) error {
 	tx, err := s.GetTransaction(ctx, txID)
 	if err != nil {
-		return fmt.Errorf("failed to get transaction for cancellation: %w", err)
+		return fmt.Errorf("failed to get transaction for %s: %w", operation, err)
 	}
 
-	if tx.Status != models.RESERVED {
-		return storage.ErrTransactionNotCancellable
+	if owner != "" && tx.LeaseOwner != owner {
+		return storage.ErrLeaseLost
+	}
+
+	if !lifecycle.CanTransition(tx.Status, status) {
+		return notAllowed
 	}
 
 	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
 	if err != nil {
-		return fmt.Errorf("failed to get sender's wallet for cancellation: %w", err)
+		return fmt.Errorf("failed to get sender's wallet for %s: %w", operation, err)
 	}
 
 	now := time.Now()
 	amountAV, err := attributevalue.Marshal(tx.Amount)
 	if err != nil {
-		return fmt.Errorf("failed to marshal amount for cancellation: %w", err)
+		return fmt.Errorf("failed to marshal amount for %s: %w", operation, err)
 	}
 
-	cancelledStatusAV, err := attributevalue.Marshal(models.CANCELLED)
+	statusAV, err := attributevalue.Marshal(status)
 	if err != nil {
-		return fmt.Errorf("failed to marshal cancelled status: %w", err)
+		return fmt.Errorf("failed to marshal %s status: %w", status, err)
 	}
 	nowAV, err := attributevalue.Marshal(now)
 	if err != nil {
-		return fmt.Errorf("failed to marshal timestamp for cancellation: %w", err)
+		return fmt.Errorf("failed to marshal timestamp for %s: %w", operation, err)
+	}
+	condition, values := lifecycle.Condition(status)
+	values[":new_status"] = statusAV
+	values[":now"] = nowAV
+	// The transaction is final and has no ledger entries, so it is kept for a day and then expires.
+	values[":ttl"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", now.Add(24*time.Hour).Unix())}
+	update := "SET #status = :new_status, updated_at = :now, #ttl = :ttl"
+	if reason != "" {
+		update += ", failure_reason = :reason"
+		values[":reason"] = &types.AttributeValueMemberS{Value: reason}
+	}
+	if owner != "" {
+		condition += " AND lease_owner = :owner"
+		values[":owner"] = &types.AttributeValueMemberS{Value: owner}
 	}
 
 	input := &dynamodb.TransactWriteItemsInput{
@@ -49,8 +82,11 @@ func (s *Store) CancelTransaction(ctx context.Context, txID string) error {
 				Update: &types.Update{
 					TableName: aws.String(s.WalletsTableName),
 					Key:       map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: tx.FromUserId}},
-					UpdateExpression:    aws.String("SET balance = balance + :amount, reserved = reserved - :amount, version = version + :inc"),
+					UpdateExpression:    aws.String("SET balances.#currency.balance = balances.#currency.balance + :amount, balances.#currency.reserved = balances.#currency.reserved - :amount, version = version + :inc"),
 					ConditionExpression: aws.String("version = :version"),
+					ExpressionAttributeNames: map[string]string{
+						"#currency": tx.Currency,
+					},
 					ExpressionAttributeValues: map[string]types.AttributeValue{
 						":amount":   amountAV,
 						":version":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", senderWallet.Version)},
@@ -62,16 +98,13 @@ func (s *Store) CancelTransaction(ctx context.Context, txID string) error {
 				Update: &types.Update{
 					TableName: aws.String(s.TransactionsTableName),
 					Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: tx.Id}},
-					UpdateExpression:    aws.String("SET #status = :cancelled_status, updated_at = :now"),
-					ConditionExpression: aws.String("#status = :reserved_status"),
+					UpdateExpression:    aws.String(update),
+					ConditionExpression: aws.String(condition),
 					ExpressionAttributeNames: map[string]string{
 						"#status": "status",
+						"#ttl":    "ttl",
 					},
-					ExpressionAttributeValues: map[string]types.AttributeValue{
-						":cancelled_status": cancelledStatusAV,
-						":reserved_status":  &types.AttributeValueMemberS{Value: string(models.RESERVED)},
-						":now":              nowAV,
-					},
+					ExpressionAttributeValues: values,
 				},
 			},
 		},
@@ -79,7 +112,18 @@ func (s *Store) CancelTransaction(ctx context.Context, txID string) error {
 
 	_, err = s.Client.TransactWriteItems(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to execute cancellation transaction: %w", err)
+		switch {
+		case conditionFailed(err, 0):
+			// The sender's wallet changed since it was read.
+			return fmt.Errorf("failed to execute %s transaction: %w: %w", operation, storage.ErrVersionConflict, err)
+		case conditionFailed(err, 1) && owner != "":
+			// Our lease expired and another process took the transaction over.
+			return storage.ErrLeaseLost
+		case conditionFailed(err, 1):
+			// The transaction's status changed since it was read to one that may not move to status.
+			return notAllowed
+		}
+		return fmt.Errorf("failed to execute %s transaction: %w", operation, err)
 	}
 
 	return nil
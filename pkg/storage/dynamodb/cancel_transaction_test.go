@@ -5,8 +5,10 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/mocks"
@@ -17,8 +19,8 @@ import (
 
 func TestCancelTransaction(t *testing.T) {
 	txID := uuid.New().String()
-	tx := &models.Transaction{Id: txID, FromUserId: "user1", Status: models.RESERVED, Amount: 100}
-	senderWallet := &models.Wallet{UserId: "user1", Balance: 100, Reserved: 100, Version: 1}
+	tx := &models.Transaction{Id: txID, FromUserId: "user1", Status: models.RESERVED, Amount: 100, Currency: "USD"}
+	senderWallet := &models.Wallet{UserId: "user1", Balances: map[string]models.CurrencyBalance{"USD": {Balance: 100, Reserved: 100}}, Version: 1}
 
 	t.Run("Success", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
@@ -102,4 +104,63 @@ func TestCancelTransaction(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to execute cancellation transaction")
 		mockClient.AssertExpectations(t)
 	})
+	t.Run("Version Conflict Is Retried", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, TransactionsTableName: "transactions", WalletsTableName: "wallets"}
+
+		// Each attempt re-reads the transaction and the wallet before writing.
+		txAV, _ := attributevalue.MarshalMap(tx)
+		walletAV, _ := attributevalue.MarshalMap(senderWallet)
+		for range 2 {
+			mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(&dynamodb.GetItemOutput{Item: txAV}, nil)
+			mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(&dynamodb.GetItemOutput{Item: walletAV}, nil)
+		}
+		conflict := &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}}}
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Once().Return(nil, conflict)
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Once().Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		err := store.CancelTransaction(context.Background(), txID)
+
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestTransactionsExpireOnceFinal(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+	_, err := store.CreateWallet(ctx, &models.Wallet{UserId: "user1", Balances: usd(300), Version: 1})
+	assert.NoError(t, err)
+	_, err = store.CreateWallet(ctx, &models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+	assert.NoError(t, err)
+	expires := func(txID string) bool {
+		result, err := store.Client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(store.TransactionsTableName),
+			Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: txID}},
+		})
+		assert.NoError(t, err)
+		_, ok := result.Item["ttl"]
+		return ok
+	}
+
+	reserved, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+	assert.NoError(t, err)
+	pending, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", Status: models.PENDING_APPROVAL})
+	assert.NoError(t, err)
+	approved, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", Status: models.PENDING_APPROVAL})
+	assert.NoError(t, err)
+	_, err = store.ApproveTransaction(ctx, approved.Id)
+	assert.NoError(t, err)
+
+	// A transaction that holds reserved funds must never expire, however long it waits.
+	for _, txID := range []string{reserved.Id, pending.Id, approved.Id} {
+		assert.False(t, expires(txID), "transaction %s holds reserved funds", txID)
+	}
+
+	assert.NoError(t, store.CancelTransaction(ctx, reserved.Id))
+	assert.NoError(t, store.RejectTransaction(ctx, pending.Id))
+	assert.NoError(t, store.CancelTransaction(ctx, approved.Id))
+	for _, txID := range []string{reserved.Id, pending.Id, approved.Id} {
+		assert.True(t, expires(txID), "final transaction %s expires", txID)
+	}
 }
@@ -0,0 +1,12 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage { return newFakeStore(t) })
+}
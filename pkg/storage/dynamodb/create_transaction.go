@@ -4,50 +4,73 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"errors"
 
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/google/uuid"
 )
 
-// CreateTransaction atomically reserves funds from the sender's wallet and creates a new transaction record.
+// CreateTransaction atomically reserves funds from the sender's wallet in the transaction's currency
+// and creates a new transaction record, provided the receiver's wallet holds that currency.
+// The reservation is retried if the sender's wallet changes between being read and being written.
 func (s *Store) CreateTransaction(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	if err := retryOnConflict(ctx, func() error { return s.createTransaction(ctx, tx) }); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// receiverCheck is the index of the condition check on the receiver's wallet in the transaction that
+// creates a transaction.
+const receiverCheck = 1
+
+// createTransaction makes a single attempt at reserving funds and creating the transaction record.
+func (s *Store) createTransaction(ctx context.Context, tx *models.Transaction) error {
 	// 1. Get the current state of the sender's wallet.
 	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sender's wallet: %w", err)
+		return fmt.Errorf("failed to get sender's wallet: %w", err)
 	}
 
 	// 2. Complete the transaction object with server-side details.
 	now := time.Now()
 	tx.Id = uuid.New().String()
-	tx.Status = models.RESERVED
+	tx.Status = lifecycle.InitialStatus(tx)
 	tx.CreatedAt = now
 	tx.UpdatedAt = now
-	tx.TTL = time.Now().Add(24 * time.Hour).Unix()
+	due := storage.DueAt(tx)
+	tx.DueAt = &due
+	// The transaction never expires while it holds reserved funds; releaseReservation sets its TTL
+	// once it is final. Its idempotency key is kept for a day after it is due.
+	keyExpiresAt := now
+	if due.After(now) {
+		keyExpiresAt = due
+	}
 
 	slog.Log(ctx, slog.LevelDebug, "creating transaction", "transaction", tx)
 
 	// Marshal the transaction for the Put operation.
 	txAV, err := attributevalue.MarshalMap(tx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
+		return fmt.Errorf("failed to marshal transaction: %w", err)
 	}
 
 	// Marshal the amount for the wallet update.
 	amountAV, err := attributevalue.Marshal(tx.Amount)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal amount: %w", err)
+		return fmt.Errorf("failed to marshal amount: %w", err)
 	}
 
-	// 4. Construct the TransactWriteItems input.
+	// 4. Construct the TransactWriteItems input. The balances are a map of currency codes to
+	// {balance, reserved} maps, so the sender's balance is a document path into it.
 	input := &dynamodb.TransactWriteItemsInput{
 		TransactItems: []types.TransactWriteItem{
 			{
@@ -57,21 +80,33 @@ func (s *Store) CreateTransaction(ctx context.Context, tx *models.Transaction) (
 					Key: map[string]types.AttributeValue{
 						"user_id": &types.AttributeValueMemberS{Value: tx.FromUserId},
 					},
-					UpdateExpression:    aws.String("SET balance = balance - :amount, reserved = reserved + :amount, version = version + :inc, #ttl = :ttl"),
-					ConditionExpression: aws.String("balance >= :amount AND version = :version"),
+					UpdateExpression:    aws.String("SET balances.#currency.balance = balances.#currency.balance - :amount, balances.#currency.reserved = balances.#currency.reserved + :amount, version = version + :inc"),
+					ConditionExpression: aws.String("balances.#currency.balance >= :amount AND version = :version"),
 					ExpressionAttributeNames: map[string]string{
-						"#ttl": "ttl",
+						"#currency": tx.Currency,
 					},
 					ExpressionAttributeValues: map[string]types.AttributeValue{
-						":amount":   amountAV,
-						":version":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", senderWallet.Version)},
-						":inc":      &types.AttributeValueMemberN{Value: "1"},
-						":ttl":      &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Add(24*time.Hour).Unix())},
+						":amount":  amountAV,
+						":version": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", senderWallet.Version)},
+						":inc":     &types.AttributeValueMemberN{Value: "1"},
 					},
 				},
 			},
 			{
-				// Operation 2: Create the new transaction record.
+				// Operation 2: Check that the receiver's wallet holds the currency.
+				ConditionCheck: &types.ConditionCheck{
+					TableName: aws.String(s.WalletsTableName),
+					Key: map[string]types.AttributeValue{
+						"user_id": &types.AttributeValueMemberS{Value: tx.ToUserId},
+					},
+					ConditionExpression: aws.String("attribute_exists(balances.#currency)"),
+					ExpressionAttributeNames: map[string]string{
+						"#currency": tx.Currency,
+					},
+				},
+			},
+			{
+				// Operation 3: Create the new transaction record.
 				Put: &types.Put{
 					TableName:           aws.String(s.TransactionsTableName),
 					Item:                txAV,
@@ -80,19 +115,73 @@ func (s *Store) CreateTransaction(ctx context.Context, tx *models.Transaction) (
 			},
 		},
 	}
+	if tx.FromUserId == tx.ToUserId {
+		// A transaction cannot name the same item twice, and the sender's update already requires the currency.
+		input.TransactItems = slices.Delete(input.TransactItems, receiverCheck, receiverCheck+1)
+	}
+	if tx.IdempotencyKey != "" {
+		// Operation 4: Claim the idempotency key, so that a retried request cannot create a second transaction.
+		input.TransactItems = append(input.TransactItems, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(s.IdempotencyTableName),
+				Item: map[string]types.AttributeValue{
+					"idempotency_key":     &types.AttributeValueMemberS{Value: tx.IdempotencyKey},
+					"transaction_id":      &types.AttributeValueMemberS{Value: tx.Id},
+					"request_fingerprint": &types.AttributeValueMemberS{Value: tx.RequestFingerprint},
+					"ttl":                 &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", keyExpiresAt.Add(24*time.Hour).Unix())},
+				},
+				ConditionExpression: aws.String("attribute_not_exists(idempotency_key)"),
+			},
+		})
+	}
 
 	// 5. Execute the transaction.
 	_, err = s.Client.TransactWriteItems(ctx, input)
 	if err != nil {
-		var tce *types.TransactionCanceledException
-		if errors.As(err, &tce) {
-			// Check if the first operation (updating the sender's wallet) failed due to a conditional check.
-			if len(tce.CancellationReasons) > 0 && *tce.CancellationReasons[0].Code == "ConditionalCheckFailed" {
-				return nil, storage.ErrInsufficientFunds
-			}
+		// A reused idempotency key takes precedence over the state of either wallet.
+		if tx.IdempotencyKey != "" && conditionFailed(err, len(input.TransactItems)-1) {
+			return fmt.Errorf("%w: %s", storage.ErrIdempotencyKeyExists, tx.IdempotencyKey)
+		}
+		// Check if the first operation (updating the sender's wallet) failed due to a conditional check.
+		if conditionFailed(err, 0) {
+			return s.reservationFailure(ctx, tx, err)
+		}
+		if tx.FromUserId != tx.ToUserId && conditionFailed(err, receiverCheck) {
+			return s.receiverFailure(ctx, tx)
 		}
-		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+		return fmt.Errorf("failed to execute transaction: %w", err)
 	}
 
-	return tx, nil
+	return nil
+}
+
+// reservationFailure works out why the sender's wallet failed its condition check. The condition
+// covers the currency, the balance and the version, so the wallet is read again to tell them apart.
+func (s *Store) reservationFailure(ctx context.Context, tx *models.Transaction, cause error) error {
+	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
+	if err != nil {
+		return fmt.Errorf("failed to re-read sender's wallet: %w", err)
+	}
+	balance, err := storage.HeldBalance(senderWallet, tx.Currency)
+	if err != nil {
+		return err
+	}
+	if balance.Balance < tx.Amount {
+		return storage.ErrInsufficientFunds
+	}
+	return fmt.Errorf("failed to execute transaction: %w: %w", storage.ErrVersionConflict, cause)
+}
+
+// receiverFailure works out why the receiver's wallet failed its condition check: either it does
+// not exist or it does not hold the transaction's currency.
+func (s *Store) receiverFailure(ctx context.Context, tx *models.Transaction) error {
+	receiverWallet, err := s.GetWallet(ctx, tx.ToUserId)
+	if err != nil {
+		return fmt.Errorf("failed to get receiver's wallet: %w", err)
+	}
+	if _, err := storage.HeldBalance(receiverWallet, tx.Currency); err != nil {
+		return err
+	}
+	// The receiver's wallet changed since the check, so it is safe to try again.
+	return fmt.Errorf("failed to execute transaction: %w: receiver's wallet changed", storage.ErrVersionConflict)
 }
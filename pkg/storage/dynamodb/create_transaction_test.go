@@ -17,8 +17,8 @@ import (
 )
 
 func TestCreateTransaction(t *testing.T) {
-	tx := &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100}
-	senderWallet := &models.Wallet{UserId: "user1", Balance: 200, Version: 1}
+	tx := &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"}
+	senderWallet := &models.Wallet{UserId: "user1", Balances: usd(200), Version: 1}
 
 	t.Run("Success", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
@@ -70,8 +70,9 @@ func TestCreateTransaction(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		store := &Store{Client: mockClient, WalletsTableName: "wallets", TransactionsTableName: "transactions"}
 
-		senderWalletAV, _ := attributevalue.MarshalMap(senderWallet)
-		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: senderWalletAV}, nil)
+		// The wallet is re-read after the failed condition check and its balance is too low.
+		poorWalletAV, _ := attributevalue.MarshalMap(&models.Wallet{UserId: "user1", Balances: usd(50), Version: 1})
+		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: poorWalletAV}, nil)
 		cancellationReasons := make([]types.CancellationReason, 1)
 		cancellationReasons[0] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed")}
 		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Return(nil, &types.TransactionCanceledException{CancellationReasons: cancellationReasons})
@@ -82,4 +83,42 @@ func TestCreateTransaction(t *testing.T) {
 		assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
 		mockClient.AssertExpectations(t)
 	})
+	t.Run("Version Conflict Is Retried", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, WalletsTableName: "wallets", TransactionsTableName: "transactions"}
+
+		// The first write loses to a concurrent change; the re-read shows enough funds, so the write is retried.
+		staleWalletAV, _ := attributevalue.MarshalMap(senderWallet)
+		freshWalletAV, _ := attributevalue.MarshalMap(&models.Wallet{UserId: "user1", Balances: usd(150), Version: 2})
+		mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(&dynamodb.GetItemOutput{Item: staleWalletAV}, nil)
+		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: freshWalletAV}, nil)
+		conflict := &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}}}
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Once().Return(nil, conflict)
+		mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			version := input.TransactItems[0].Update.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN)
+			return version.Value == "2"
+		})).Once().Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+		createdTx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, createdTx.Id)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Version Conflict Retries Are Bounded", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, WalletsTableName: "wallets", TransactionsTableName: "transactions"}
+
+		senderWalletAV, _ := attributevalue.MarshalMap(senderWallet)
+		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: senderWalletAV}, nil)
+		conflict := &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}}}
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Return(nil, conflict)
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.ErrorIs(t, err, storage.ErrVersionConflict)
+		assert.NotErrorIs(t, err, storage.ErrInsufficientFunds)
+		mockClient.AssertNumberOfCalls(t, "TransactWriteItems", maxConflictAttempts)
+	})
 }
@@ -0,0 +1,980 @@
+// Package dynamodbtest provides an in-memory DynamoDB client for tests.
+//
+// Client implements the operations of dynamodb.DynamoDBAPI with the semantics the store relies
+// on: condition, update, key condition, filter and projection expressions are parsed and
+// evaluated, secondary indexes are maintained and can be queried, and TransactWriteItems is
+// applied all-or-nothing, failing with a TransactionCanceledException that carries one
+// cancellation reason per item. Capacity, item size limits, TTL expiry and reserved-word
+// checks are not modelled.
+package dynamodbtest
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// Client is an in-memory DynamoDB client. Tables must be created with CreateTable before use.
+// It is safe for concurrent use; every operation is applied atomically.
+type Client struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+// New creates a client with no tables.
+func New() *Client {
+	return &Client{tables: make(map[string]*table)}
+}
+
+type keyAttr struct {
+	name string
+	typ  types.ScalarAttributeType
+}
+
+type keySchema struct {
+	hash  keyAttr
+	rng   keyAttr // rng.name is empty for tables and indexes without a sort key
+	index string  // empty for the table's primary key
+}
+
+func (ks keySchema) attrs() []keyAttr {
+	if ks.rng.name == "" {
+		return []keyAttr{ks.hash}
+	}
+	return []keyAttr{ks.hash, ks.rng}
+}
+
+type secondaryIndex struct {
+	key        keySchema
+	projection types.ProjectionType
+	include    []string
+}
+
+type table struct {
+	name    string
+	key     keySchema
+	indexes map[string]*secondaryIndex
+	items   map[string]item
+}
+
+var errConditionalCheckFailed = errors.New("The conditional request failed")
+
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: fmt.Sprintf(format, args...)}
+}
+
+func resourceNotFound() error {
+	return &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+}
+
+// CreateTable creates a table with the key schema and secondary indexes of the input.
+func (c *Client) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := aws.ToString(params.TableName)
+	if _, exists := c.tables[name]; exists {
+		return nil, &types.ResourceInUseException{Message: aws.String(fmt.Sprintf("Table already exists: %s", name))}
+	}
+
+	definitions := make(map[string]types.ScalarAttributeType)
+	for _, def := range params.AttributeDefinitions {
+		definitions[aws.ToString(def.AttributeName)] = def.AttributeType
+	}
+	parseKeySchema := func(elements []types.KeySchemaElement, index string) (keySchema, error) {
+		ks := keySchema{index: index}
+		for _, e := range elements {
+			attrName := aws.ToString(e.AttributeName)
+			typ, ok := definitions[attrName]
+			if !ok {
+				return ks, validationError("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]", attrName)
+			}
+			switch e.KeyType {
+			case types.KeyTypeHash:
+				ks.hash = keyAttr{attrName, typ}
+			case types.KeyTypeRange:
+				ks.rng = keyAttr{attrName, typ}
+			}
+		}
+		if ks.hash.name == "" {
+			return ks, validationError("Invalid KeySchema: The first KeySchemaElement is not a HASH key type")
+		}
+		return ks, nil
+	}
+
+	key, err := parseKeySchema(params.KeySchema, "")
+	if err != nil {
+		return nil, err
+	}
+	t := &table{name: name, key: key, indexes: make(map[string]*secondaryIndex), items: make(map[string]item)}
+	addIndex := func(indexName string, elements []types.KeySchemaElement, projection *types.Projection) error {
+		ks, err := parseKeySchema(elements, indexName)
+		if err != nil {
+			return err
+		}
+		idx := &secondaryIndex{key: ks, projection: types.ProjectionTypeAll}
+		if projection != nil && projection.ProjectionType != "" {
+			idx.projection = projection.ProjectionType
+			idx.include = projection.NonKeyAttributes
+		}
+		t.indexes[indexName] = idx
+		return nil
+	}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		if err := addIndex(aws.ToString(gsi.IndexName), gsi.KeySchema, gsi.Projection); err != nil {
+			return nil, err
+		}
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		if err := addIndex(aws.ToString(lsi.IndexName), lsi.KeySchema, lsi.Projection); err != nil {
+			return nil, err
+		}
+	}
+	c.tables[name] = t
+
+	return &dynamodb.CreateTableOutput{
+		TableDescription: &types.TableDescription{
+			TableName:   aws.String(name),
+			TableStatus: types.TableStatusActive,
+		},
+	}, nil
+}
+
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.ToString(name)]
+	if !ok {
+		return nil, resourceNotFound()
+	}
+	return t, nil
+}
+
+// encodeKey returns a canonical string for the values of the given key attributes.
+func encodeKey(it item, attrs []keyAttr) string {
+	parts := make([]string, len(attrs))
+	for i, a := range attrs {
+		switch v := it[a.name].(type) {
+		case *types.AttributeValueMemberN:
+			if n, err := parseNumber(v.Value); err == nil {
+				parts[i] = "N:" + formatNumber(n)
+			} else {
+				parts[i] = "N:" + v.Value
+			}
+		case *types.AttributeValueMemberS:
+			parts[i] = "S:" + v.Value
+		case *types.AttributeValueMemberB:
+			parts[i] = "B:" + hex.EncodeToString(v.Value)
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// checkKeyAttr validates the value of one key attribute of an item.
+func checkKeyAttr(it item, a keyAttr, index string) error {
+	v, ok := it[a.name]
+	if !ok {
+		if index != "" {
+			return nil // Items without the index key are simply not indexed.
+		}
+		return validationError("One or more parameter values were invalid: Missing the key %s in the item", a.name)
+	}
+	if typeOf(v) != string(a.typ) {
+		if index != "" {
+			return validationError("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s", a.name, a.typ, typeOf(v), index)
+		}
+		return validationError("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", a.name, a.typ, typeOf(v))
+	}
+	if s, isS := v.(*types.AttributeValueMemberS); isS && s.Value == "" {
+		return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", a.name)
+	}
+	if b, isB := v.(*types.AttributeValueMemberB); isB && len(b.Value) == 0 {
+		return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty binary value. Key: %s", a.name)
+	}
+	return nil
+}
+
+// checkItem validates the primary and secondary index keys of a full item.
+func (t *table) checkItem(it item) error {
+	for _, a := range t.key.attrs() {
+		if err := checkKeyAttr(it, a, ""); err != nil {
+			return err
+		}
+	}
+	for _, idx := range t.indexes {
+		for _, a := range idx.key.attrs() {
+			if err := checkKeyAttr(it, a, idx.key.index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkKey validates that key contains exactly the table's primary key attributes.
+func (t *table) checkKey(key item) error {
+	attrs := t.key.attrs()
+	if len(key) != len(attrs) {
+		return validationError("The provided key element does not match the schema")
+	}
+	for _, a := range attrs {
+		if v, ok := key[a.name]; !ok || typeOf(v) != string(a.typ) {
+			return validationError("The provided key element does not match the schema")
+		}
+		if err := checkKeyAttr(key, a, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *table) keyOf(it item) item {
+	key := make(item)
+	for _, a := range t.key.attrs() {
+		key[a.name] = cloneValue(it[a.name])
+	}
+	return key
+}
+
+type writeKind int
+
+const (
+	writeConditionCheck writeKind = iota
+	writePut
+	writeUpdate
+	writeDelete
+)
+
+// writeOp is a validated, parsed write against a single item.
+type writeOp struct {
+	table           *table
+	kind            writeKind
+	key             item
+	put             item
+	cond            *condition
+	update          *update
+	returnOnFailure types.ReturnValuesOnConditionCheckFailure
+}
+
+func (op *writeOp) id() string {
+	return op.table.name + "\x01" + encodeKey(op.key, op.table.key.attrs())
+}
+
+type writeParams struct {
+	key             item
+	put             item
+	condition       *string
+	update          *string
+	names           map[string]string
+	values          map[string]types.AttributeValue
+	returnOnFailure types.ReturnValuesOnConditionCheckFailure
+}
+
+// prepareWrite validates a write and parses its expressions.
+func (c *Client) prepareWrite(tableName *string, kind writeKind, in writeParams) (*writeOp, error) {
+	t, err := c.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	op := &writeOp{table: t, kind: kind, returnOnFailure: in.returnOnFailure}
+	if kind == writePut {
+		if err := t.checkItem(in.put); err != nil {
+			return nil, err
+		}
+		op.put = cloneItem(in.put)
+		op.key = t.keyOf(in.put)
+	} else {
+		if err := t.checkKey(in.key); err != nil {
+			return nil, err
+		}
+		op.key = cloneItem(in.key)
+	}
+
+	p := newParser(in.names, in.values)
+	if in.condition != nil {
+		if op.cond, err = p.parseCondition(*in.condition); err != nil {
+			return nil, validationError("%v", err)
+		}
+	} else if kind == writeConditionCheck {
+		return nil, validationError("The ConditionExpression for a ConditionCheck must be provided")
+	}
+	if in.update != nil {
+		if op.update, err = p.parseUpdate(*in.update); err != nil {
+			return nil, validationError("%v", err)
+		}
+		for _, name := range op.update.updatedAttributes() {
+			for _, a := range t.key.attrs() {
+				if a.name == name {
+					return nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", name)
+				}
+			}
+		}
+	}
+	if in.names != nil || in.values != nil {
+		if in.condition == nil && in.update == nil {
+			return nil, validationError("ExpressionAttributeNames and ExpressionAttributeValues can only be specified when using expressions")
+		}
+		if err := p.checkUnused(); err != nil {
+			return nil, validationError("%v", err)
+		}
+	}
+	return op, nil
+}
+
+// evaluate checks the write's condition against the current state and computes the item that
+// would be stored. A nil result with a nil error means the item would be deleted (or, for a
+// condition check, left alone).
+func (op *writeOp) evaluate() (old, result item, err error) {
+	old = op.table.items[encodeKey(op.key, op.table.key.attrs())]
+	if op.cond != nil {
+		target := old
+		if target == nil {
+			target = item{}
+		}
+		ok, err := evalCondition(target, op.cond)
+		if err != nil {
+			return old, nil, err
+		}
+		if !ok {
+			return old, nil, errConditionalCheckFailed
+		}
+	}
+
+	switch op.kind {
+	case writePut:
+		return old, cloneItem(op.put), nil
+	case writeUpdate:
+		base := old
+		if base == nil {
+			base = cloneItem(op.key)
+		}
+		if op.update == nil {
+			return old, cloneItem(base), nil
+		}
+		result, err := applyUpdate(base, op.update)
+		if err != nil {
+			return old, nil, err
+		}
+		if err := op.table.checkItem(result); err != nil {
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) {
+				return old, nil, errors.New(apiErr.ErrorMessage())
+			}
+			return old, nil, err
+		}
+		return old, result, nil
+	}
+	return old, nil, nil
+}
+
+// commit stores the result of a successful evaluate.
+func (op *writeOp) commit(result item) {
+	key := encodeKey(op.key, op.table.key.attrs())
+	switch op.kind {
+	case writePut, writeUpdate:
+		op.table.items[key] = result
+	case writeDelete:
+		delete(op.table.items, key)
+	}
+}
+
+// run evaluates and commits a single write, translating failures into API errors.
+func (op *writeOp) run() (old, result item, err error) {
+	old, result, err = op.evaluate()
+	if errors.Is(err, errConditionalCheckFailed) {
+		ccf := &types.ConditionalCheckFailedException{Message: aws.String(err.Error())}
+		if op.returnOnFailure == types.ReturnValuesOnConditionCheckFailureAllOld && old != nil {
+			ccf.Item = cloneItem(old)
+		}
+		return nil, nil, ccf
+	}
+	if err != nil {
+		return nil, nil, validationError("%v", err)
+	}
+	op.commit(result)
+	return old, result, nil
+}
+
+// PutItem creates or replaces an item.
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if params.ReturnValues != "" && params.ReturnValues != types.ReturnValueNone && params.ReturnValues != types.ReturnValueAllOld {
+		return nil, validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+	op, err := c.prepareWrite(params.TableName, writePut, writeParams{
+		put:             params.Item,
+		condition:       params.ConditionExpression,
+		names:           params.ExpressionAttributeNames,
+		values:          params.ExpressionAttributeValues,
+		returnOnFailure: params.ReturnValuesOnConditionCheckFailure,
+	})
+	if err != nil {
+		return nil, err
+	}
+	old, _, err := op.run()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = cloneItem(old)
+	}
+	return out, nil
+}
+
+// GetItem returns an item by its primary key.
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkKey(params.Key); err != nil {
+		return nil, err
+	}
+	projection, err := parseProjection(params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	found, ok := t.items[encodeKey(params.Key, t.key.attrs())]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: project(found, projection)}, nil
+}
+
+func parseProjection(expr *string, names map[string]string) ([]path, error) {
+	if expr == nil {
+		if names != nil {
+			return nil, validationError("ExpressionAttributeNames can only be specified when using expressions")
+		}
+		return nil, nil
+	}
+	p := newParser(names, nil)
+	paths, err := p.parseProjection(*expr)
+	if err != nil {
+		return nil, validationError("%v", err)
+	}
+	if err := p.checkUnused(); err != nil {
+		return nil, validationError("%v", err)
+	}
+	return paths, nil
+}
+
+// UpdateItem edits an item's attributes, creating the item if it does not exist.
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op, err := c.prepareWrite(params.TableName, writeUpdate, writeParams{
+		key:             params.Key,
+		condition:       params.ConditionExpression,
+		update:          params.UpdateExpression,
+		names:           params.ExpressionAttributeNames,
+		values:          params.ExpressionAttributeValues,
+		returnOnFailure: params.ReturnValuesOnConditionCheckFailure,
+	})
+	if err != nil {
+		return nil, err
+	}
+	old, result, err := op.run()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllOld:
+		out.Attributes = cloneItem(old)
+	case types.ReturnValueAllNew:
+		out.Attributes = cloneItem(result)
+	case types.ReturnValueUpdatedOld, types.ReturnValueUpdatedNew:
+		source := result
+		if params.ReturnValues == types.ReturnValueUpdatedOld {
+			source = old
+		}
+		if op.update != nil && source != nil {
+			out.Attributes = make(item)
+			for _, name := range op.update.updatedAttributes() {
+				if v, ok := source[name]; ok {
+					out.Attributes[name] = cloneValue(v)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// DeleteItem removes an item by its primary key.
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if params.ReturnValues != "" && params.ReturnValues != types.ReturnValueNone && params.ReturnValues != types.ReturnValueAllOld {
+		return nil, validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+	op, err := c.prepareWrite(params.TableName, writeDelete, writeParams{
+		key:             params.Key,
+		condition:       params.ConditionExpression,
+		names:           params.ExpressionAttributeNames,
+		values:          params.ExpressionAttributeValues,
+		returnOnFailure: params.ReturnValuesOnConditionCheckFailure,
+	})
+	if err != nil {
+		return nil, err
+	}
+	old, _, err := op.run()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = cloneItem(old)
+	}
+	return out, nil
+}
+
+// TransactWriteItems applies up to 100 writes atomically. If any condition fails, nothing is
+// written and a TransactionCanceledException reports a reason for every item.
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > 100 {
+		return nil, validationError("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to 100")
+	}
+
+	ops := make([]*writeOp, len(params.TransactItems))
+	seen := make(map[string]bool)
+	for i, ti := range params.TransactItems {
+		var (
+			op  *writeOp
+			err error
+		)
+		switch {
+		case ti.ConditionCheck != nil && ti.Put == nil && ti.Update == nil && ti.Delete == nil:
+			cc := ti.ConditionCheck
+			op, err = c.prepareWrite(cc.TableName, writeConditionCheck, writeParams{
+				key: cc.Key, condition: cc.ConditionExpression,
+				names: cc.ExpressionAttributeNames, values: cc.ExpressionAttributeValues,
+				returnOnFailure: cc.ReturnValuesOnConditionCheckFailure,
+			})
+		case ti.Put != nil && ti.ConditionCheck == nil && ti.Update == nil && ti.Delete == nil:
+			put := ti.Put
+			op, err = c.prepareWrite(put.TableName, writePut, writeParams{
+				put: put.Item, condition: put.ConditionExpression,
+				names: put.ExpressionAttributeNames, values: put.ExpressionAttributeValues,
+				returnOnFailure: put.ReturnValuesOnConditionCheckFailure,
+			})
+		case ti.Update != nil && ti.ConditionCheck == nil && ti.Put == nil && ti.Delete == nil:
+			upd := ti.Update
+			if upd.UpdateExpression == nil {
+				return nil, validationError("The UpdateExpression for an Update must be provided")
+			}
+			op, err = c.prepareWrite(upd.TableName, writeUpdate, writeParams{
+				key: upd.Key, condition: upd.ConditionExpression, update: upd.UpdateExpression,
+				names: upd.ExpressionAttributeNames, values: upd.ExpressionAttributeValues,
+				returnOnFailure: upd.ReturnValuesOnConditionCheckFailure,
+			})
+		case ti.Delete != nil && ti.ConditionCheck == nil && ti.Put == nil && ti.Update == nil:
+			del := ti.Delete
+			op, err = c.prepareWrite(del.TableName, writeDelete, writeParams{
+				key: del.Key, condition: del.ConditionExpression,
+				names: del.ExpressionAttributeNames, values: del.ExpressionAttributeValues,
+				returnOnFailure: del.ReturnValuesOnConditionCheckFailure,
+			})
+		default:
+			return nil, validationError("TransactItems can only contain one of ConditionCheck, Put, Update or Delete")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen[op.id()] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[op.id()] = true
+		ops[i] = op
+	}
+
+	results := make([]item, len(ops))
+	reasons := make([]types.CancellationReason, len(ops))
+	codes := make([]string, len(ops))
+	cancelled := false
+	for i, op := range ops {
+		old, result, err := op.evaluate()
+		switch {
+		case err == nil:
+			results[i] = result
+			reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		case errors.Is(err, errConditionalCheckFailed):
+			cancelled = true
+			reasons[i] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Message: aws.String(err.Error())}
+			if op.returnOnFailure == types.ReturnValuesOnConditionCheckFailureAllOld && old != nil {
+				reasons[i].Item = cloneItem(old)
+			}
+		default:
+			cancelled = true
+			reasons[i] = types.CancellationReason{Code: aws.String("ValidationError"), Message: aws.String(err.Error())}
+		}
+		codes[i] = aws.ToString(reasons[i].Code)
+	}
+	if cancelled {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String(fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(codes, ", "))),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for i, op := range ops {
+		op.commit(results[i])
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// readParams holds the inputs shared by Query and Scan.
+type readParams struct {
+	tableName  *string
+	indexName  *string
+	keyCond    *string
+	filter     *string
+	projection *string
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	limit      *int32
+	startKey   item
+	forward    bool
+	consistent bool
+	selectMode types.Select
+}
+
+type readResult struct {
+	items   []item
+	count   int32
+	scanned int32
+	lastKey item
+}
+
+// Query returns the items with a given partition key from a table or secondary index, in sort
+// key order.
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if params.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res, err := c.read(readParams{
+		tableName:  params.TableName,
+		indexName:  params.IndexName,
+		keyCond:    params.KeyConditionExpression,
+		filter:     params.FilterExpression,
+		projection: params.ProjectionExpression,
+		names:      params.ExpressionAttributeNames,
+		values:     params.ExpressionAttributeValues,
+		limit:      params.Limit,
+		startKey:   params.ExclusiveStartKey,
+		forward:    params.ScanIndexForward == nil || *params.ScanIndexForward,
+		consistent: aws.ToBool(params.ConsistentRead),
+		selectMode: params.Select,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{Items: res.items, Count: res.count, ScannedCount: res.scanned, LastEvaluatedKey: res.lastKey}, nil
+}
+
+// Scan returns every item in a table or secondary index.
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if params.Segment != nil || params.TotalSegments != nil {
+		return nil, validationError("parallel scans are not supported by dynamodbtest")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res, err := c.read(readParams{
+		tableName:  params.TableName,
+		indexName:  params.IndexName,
+		filter:     params.FilterExpression,
+		projection: params.ProjectionExpression,
+		names:      params.ExpressionAttributeNames,
+		values:     params.ExpressionAttributeValues,
+		limit:      params.Limit,
+		startKey:   params.ExclusiveStartKey,
+		forward:    true,
+		consistent: aws.ToBool(params.ConsistentRead),
+		selectMode: params.Select,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{Items: res.items, Count: res.count, ScannedCount: res.scanned, LastEvaluatedKey: res.lastKey}, nil
+}
+
+func (c *Client) read(in readParams) (*readResult, error) {
+	t, err := c.table(in.tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the key schema the read is ordered by.
+	ks := t.key
+	var idx *secondaryIndex
+	if in.indexName != nil {
+		var ok bool
+		if idx, ok = t.indexes[*in.indexName]; !ok {
+			return nil, validationError("The table does not have the specified index: %s", *in.indexName)
+		}
+		ks = idx.key
+		if in.consistent {
+			return nil, validationError("Consistent reads are not supported on global secondary indexes")
+		}
+	}
+	if in.limit != nil && *in.limit <= 0 {
+		return nil, validationError("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value greater than or equal to 1", *in.limit)
+	}
+
+	p := newParser(in.names, in.values)
+	var keyCond, filter *condition
+	if in.keyCond != nil {
+		if keyCond, err = p.parseCondition(*in.keyCond); err != nil {
+			return nil, validationError("%v", err)
+		}
+		if err := checkKeyCondition(keyCond, ks); err != nil {
+			return nil, err
+		}
+	}
+	if in.filter != nil {
+		if filter, err = p.parseCondition(*in.filter); err != nil {
+			return nil, validationError("%v", err)
+		}
+		if in.keyCond != nil {
+			for _, a := range ks.attrs() {
+				if referencesAttr(filter, a.name) {
+					return nil, validationError("Filter Expression can only contain non-primary key attributes: Primary key attribute: %s", a.name)
+				}
+			}
+		}
+	}
+	var projection []path
+	if in.projection != nil {
+		if projection, err = p.parseProjection(*in.projection); err != nil {
+			return nil, validationError("%v", err)
+		}
+	}
+	if err := p.checkUnused(); err != nil {
+		return nil, validationError("%v", err)
+	}
+
+	// Items are ordered by the key schema being read, with the table's primary key as a tiebreaker
+	// for index entries that share a key.
+	order := append(ks.attrs(), t.key.attrs()...)
+
+	var candidates []item
+	for _, it := range t.items {
+		indexed := true
+		for _, a := range ks.attrs() {
+			if _, ok := it[a.name]; !ok {
+				indexed = false
+			}
+		}
+		if !indexed {
+			continue
+		}
+		if keyCond != nil {
+			ok, err := evalCondition(it, keyCond)
+			if err != nil {
+				return nil, validationError("%v", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		candidates = append(candidates, it)
+	}
+	compare := func(a, b item) int {
+		for _, k := range order {
+			if cmp, _ := compareValues(a[k.name], b[k.name]); cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		cmp := compare(candidates[i], candidates[j])
+		if in.forward {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+
+	if in.startKey != nil {
+		for _, a := range append(ks.attrs(), t.key.attrs()...) {
+			if v, ok := in.startKey[a.name]; !ok || typeOf(v) != string(a.typ) {
+				return nil, validationError("The provided starting key is invalid")
+			}
+		}
+		start := len(candidates)
+		for i, it := range candidates {
+			cmp := compare(it, in.startKey)
+			if (in.forward && cmp > 0) || (!in.forward && cmp < 0) {
+				start = i
+				break
+			}
+		}
+		candidates = candidates[start:]
+	}
+
+	res := &readResult{}
+	for i, it := range candidates {
+		if in.limit != nil && int32(i) == *in.limit {
+			break
+		}
+		res.scanned++
+		if in.limit != nil && res.scanned == *in.limit {
+			res.lastKey = t.keyOf(it)
+			for _, a := range ks.attrs() {
+				res.lastKey[a.name] = cloneValue(it[a.name])
+			}
+		}
+		if filter != nil {
+			ok, err := evalCondition(it, filter)
+			if err != nil {
+				return nil, validationError("%v", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		res.count++
+		if in.selectMode == types.SelectCount {
+			continue
+		}
+		visible := it
+		if idx != nil {
+			visible = idx.project(it, t.key)
+		}
+		res.items = append(res.items, project(visible, projection))
+	}
+	return res, nil
+}
+
+// project applies an index's projection type to an item.
+func (idx *secondaryIndex) project(it item, tableKey keySchema) item {
+	if idx.projection == types.ProjectionTypeAll {
+		return it
+	}
+	out := make(item)
+	keep := append(append([]keyAttr{}, tableKey.attrs()...), idx.key.attrs()...)
+	for _, a := range keep {
+		if v, ok := it[a.name]; ok {
+			out[a.name] = v
+		}
+	}
+	if idx.projection == types.ProjectionTypeInclude {
+		for _, name := range idx.include {
+			if v, ok := it[name]; ok {
+				out[name] = v
+			}
+		}
+	}
+	return out
+}
+
+// checkKeyCondition validates that a KeyConditionExpression tests the partition key for
+// equality and, optionally, the sort key with a single supported operator.
+func checkKeyCondition(c *condition, ks keySchema) error {
+	conjuncts := []*condition{c}
+	if c.kind == condAnd {
+		conjuncts = c.children
+	}
+	if len(conjuncts) > 2 {
+		return validationError("Query key condition not supported")
+	}
+
+	var hashOK bool
+	for _, cond := range conjuncts {
+		if len(cond.operands) == 0 || cond.operands[0].kind != opPath || len(cond.operands[0].path) != 1 {
+			return validationError("Query key condition not supported")
+		}
+		for _, o := range cond.operands[1:] {
+			if o.kind != opValue {
+				return validationError("Query key condition not supported")
+			}
+		}
+		attr := cond.operands[0].path[0].name
+		switch {
+		case attr == ks.hash.name:
+			if cond.kind != condCompare || cond.op != "=" {
+				return validationError("Query key condition not supported")
+			}
+			hashOK = true
+		case attr == ks.rng.name && ks.rng.name != "":
+			switch {
+			case cond.kind == condCompare && cond.op != "<>":
+			case cond.kind == condBetween:
+			case cond.kind == condFunc && cond.op == "begins_with":
+			default:
+				return validationError("Query key condition not supported")
+			}
+		default:
+			return validationError("Query condition missed key schema element: %s", ks.hash.name)
+		}
+	}
+	if !hashOK {
+		return validationError("Query condition missed key schema element: %s", ks.hash.name)
+	}
+	return nil
+}
+
+// referencesAttr reports whether a condition refers to a top-level attribute.
+func referencesAttr(c *condition, name string) bool {
+	for _, child := range c.children {
+		if referencesAttr(child, name) {
+			return true
+		}
+	}
+	for _, o := range c.operands {
+		if (o.kind == opPath || o.kind == opSize) && o.path[0].name == name {
+			return true
+		}
+	}
+	return false
+}
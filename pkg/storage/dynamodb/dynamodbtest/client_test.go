@@ -0,0 +1,532 @@
+package dynamodbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func s(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+func n(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+
+// newTestClient creates a client with an "accounts" table keyed by id and a
+// "by_owner" index on owner and created_at.
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	client := New()
+	_, err := client.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String("accounts"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("owner"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("created_at"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash}},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String("by_owner"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("owner"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("created_at"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func put(t *testing.T, client *Client, it map[string]types.AttributeValue) {
+	t.Helper()
+	_, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("accounts"), Item: it})
+	require.NoError(t, err)
+}
+
+func get(t *testing.T, client *Client, id string) map[string]types.AttributeValue {
+	t.Helper()
+	out, err := client.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String("accounts"),
+		Key:       map[string]types.AttributeValue{"id": s(id)},
+	})
+	require.NoError(t, err)
+	return out.Item
+}
+
+func isValidationError(err error) bool {
+	var apiErr smithy.APIError
+	return err != nil && errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException"
+}
+
+func TestPutItemCondition(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String("accounts"),
+		Item:                map[string]types.AttributeValue{"id": s("a"), "balance": n("10")},
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+
+	_, err := client.PutItem(ctx, input)
+	require.NoError(t, err)
+
+	input.Item = map[string]types.AttributeValue{"id": s("a"), "balance": n("99")}
+	input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	_, err = client.PutItem(ctx, input)
+
+	var ccf *types.ConditionalCheckFailedException
+	require.ErrorAs(t, err, &ccf)
+	assert.Equal(t, n("10"), ccf.Item["balance"])
+	assert.Equal(t, n("10"), get(t, client, "a")["balance"])
+}
+
+func TestConditionExpressions(t *testing.T) {
+	client := newTestClient(t)
+	put(t, client, map[string]types.AttributeValue{
+		"id":      s("a"),
+		"status":  s("RESERVED"),
+		"balance": n("100"),
+		"tags":    &types.AttributeValueMemberSS{Value: []string{"vip", "beta"}},
+		"profile": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"name": s("Alice")}},
+		"history": &types.AttributeValueMemberL{Value: []types.AttributeValue{n("1"), n("2")}},
+	})
+
+	tests := []struct {
+		expr   string
+		values map[string]types.AttributeValue
+		want   bool
+	}{
+		{"balance >= :v", map[string]types.AttributeValue{":v": n("100")}, true},
+		{"balance > :v", map[string]types.AttributeValue{":v": n("100.0")}, false},
+		{"balance = :v", map[string]types.AttributeValue{":v": n("1e2")}, true},
+		{"balance <> :v", map[string]types.AttributeValue{":v": s("100")}, true},
+		{"balance < :v", map[string]types.AttributeValue{":v": s("200")}, false},
+		{"balance BETWEEN :lo AND :hi", map[string]types.AttributeValue{":lo": n("50"), ":hi": n("100")}, true},
+		{"#s IN (:a, :b)", map[string]types.AttributeValue{":a": s("WORKING"), ":b": s("RESERVED")}, true},
+		{"attribute_exists(profile.name) AND attribute_not_exists(profile.age)", nil, true},
+		{"attribute_type(tags, :t)", map[string]types.AttributeValue{":t": s("SS")}, true},
+		{"begins_with(#s, :p)", map[string]types.AttributeValue{":p": s("RES")}, true},
+		{"contains(tags, :v)", map[string]types.AttributeValue{":v": s("vip")}, true},
+		{"contains(history, :v)", map[string]types.AttributeValue{":v": n("3")}, false},
+		{"size(history) = :v AND history[1] = :v", map[string]types.AttributeValue{":v": n("2")}, true},
+		{"missing = :v OR balance = :v", map[string]types.AttributeValue{":v": n("100")}, true},
+		{"NOT missing = :v", map[string]types.AttributeValue{":v": n("100")}, true},
+		{"balance = :a OR balance = :b AND balance = :c", map[string]types.AttributeValue{":a": n("100"), ":b": n("1"), ":c": n("2")}, true},
+		{"(balance = :a OR balance = :b) AND balance = :c", map[string]types.AttributeValue{":a": n("100"), ":b": n("1"), ":c": n("2")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			input := &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("accounts"),
+				Key:                       map[string]types.AttributeValue{"id": s("a")},
+				UpdateExpression:          aws.String("SET touched = :touched"),
+				ConditionExpression:       aws.String(tt.expr),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":touched": &types.AttributeValueMemberBOOL{Value: true}},
+			}
+			for k, v := range tt.values {
+				input.ExpressionAttributeValues[k] = v
+			}
+			if strings.Contains(tt.expr, "#s") {
+				input.ExpressionAttributeNames = map[string]string{"#s": "status"}
+			}
+
+			_, err := client.UpdateItem(context.Background(), input)
+
+			if tt.want {
+				assert.NoError(t, err)
+			} else {
+				var ccf *types.ConditionalCheckFailedException
+				assert.ErrorAs(t, err, &ccf)
+			}
+		})
+	}
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	put(t, client, map[string]types.AttributeValue{
+		"id":      s("a"),
+		"balance": n("100"),
+		"old":     s("x"),
+		"tags":    &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"labels":  &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"history": &types.AttributeValueMemberL{Value: []types.AttributeValue{n("1")}},
+	})
+
+	out, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String("accounts"),
+		Key:              map[string]types.AttributeValue{"id": s("a")},
+		UpdateExpression: aws.String("SET balance = balance - :amt, reserved = if_not_exists(reserved, :zero) + :amt, history = list_append(history, :h) REMOVE old ADD visits :one, tags :c DELETE labels :a"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":amt":  n("30"),
+			":zero": n("0"),
+			":h":    &types.AttributeValueMemberL{Value: []types.AttributeValue{n("2")}},
+			":one":  n("1"),
+			":c":    &types.AttributeValueMemberSS{Value: []string{"c"}},
+			":a":    &types.AttributeValueMemberSS{Value: []string{"a"}},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	require.NoError(t, err)
+
+	var got struct {
+		Balance  int64    `dynamodbav:"balance"`
+		Reserved int64    `dynamodbav:"reserved"`
+		Visits   int64    `dynamodbav:"visits"`
+		History  []int    `dynamodbav:"history"`
+		Tags     []string `dynamodbav:"tags,stringset"`
+		Labels   []string `dynamodbav:"labels,stringset"`
+		Old      *string  `dynamodbav:"old"`
+	}
+	require.NoError(t, attributevalue.UnmarshalMap(out.Attributes, &got))
+	assert.Equal(t, int64(70), got.Balance)
+	assert.Equal(t, int64(30), got.Reserved)
+	assert.Equal(t, int64(1), got.Visits)
+	assert.Equal(t, []int{1, 2}, got.History)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, got.Tags)
+	assert.ElementsMatch(t, []string{"b"}, got.Labels)
+	assert.Nil(t, got.Old)
+
+	t.Run("Operands see the item before the update", func(t *testing.T) {
+		_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String("accounts"),
+			Key:              map[string]types.AttributeValue{"id": s("a")},
+			UpdateExpression: aws.String("SET balance = reserved, reserved = balance"),
+		})
+		require.NoError(t, err)
+		it := get(t, client, "a")
+		assert.Equal(t, n("30"), it["balance"])
+		assert.Equal(t, n("70"), it["reserved"])
+	})
+
+	t.Run("Arithmetic on a missing attribute fails", func(t *testing.T) {
+		_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String("accounts"),
+			Key:                       map[string]types.AttributeValue{"id": s("a")},
+			UpdateExpression:          aws.String("SET nope = nope + :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1")},
+		})
+		assert.True(t, isValidationError(err), "got %v", err)
+	})
+
+	t.Run("Key attributes cannot be updated", func(t *testing.T) {
+		_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String("accounts"),
+			Key:                       map[string]types.AttributeValue{"id": s("a")},
+			UpdateExpression:          aws.String("SET id = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": s("b")},
+		})
+		assert.True(t, isValidationError(err), "got %v", err)
+	})
+
+	t.Run("Update creates a missing item", func(t *testing.T) {
+		_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String("accounts"),
+			Key:                       map[string]types.AttributeValue{"id": s("new")},
+			UpdateExpression:          aws.String("ADD balance :v"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v": n("5")},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]types.AttributeValue{"id": s("new"), "balance": n("5")}, get(t, client, "new"))
+	})
+}
+
+func TestExpressionValidation(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	key := map[string]types.AttributeValue{"id": s("a")}
+
+	tests := map[string]*dynamodb.UpdateItemInput{
+		"unused value": {
+			UpdateExpression:          aws.String("SET a = :a"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": n("1"), ":b": n("2")},
+		},
+		"unused name": {
+			UpdateExpression:          aws.String("SET a = :a"),
+			ExpressionAttributeNames:  map[string]string{"#n": "name"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": n("1")},
+		},
+		"undefined value": {
+			UpdateExpression: aws.String("SET a = :a"),
+		},
+		"syntax error": {
+			UpdateExpression:          aws.String("SET a = = :a"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": n("1")},
+		},
+		"overlapping paths": {
+			UpdateExpression:          aws.String("SET a = :a REMOVE a"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": n("1")},
+		},
+		"repeated clause": {
+			UpdateExpression:          aws.String("SET a = :a SET b = :a"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": n("1")},
+		},
+		"wrong key": {
+			UpdateExpression:          aws.String("SET a = :a"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": n("1")},
+			Key:                       map[string]types.AttributeValue{"user_id": s("a")},
+		},
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			input.TableName = aws.String("accounts")
+			if input.Key == nil {
+				input.Key = key
+			}
+			_, err := client.UpdateItem(ctx, input)
+			assert.True(t, isValidationError(err), "got %v", err)
+		})
+	}
+
+	_, err := client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("missing"), Key: key})
+	var notFound *types.ResourceNotFoundException
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestTransactWriteItems(t *testing.T) {
+	ctx := context.Background()
+	debit := func(id, amount, version string) types.TransactWriteItem {
+		return types.TransactWriteItem{Update: &types.Update{
+			TableName:           aws.String("accounts"),
+			Key:                 map[string]types.AttributeValue{"id": s(id)},
+			UpdateExpression:    aws.String("SET balance = balance - :amt, version = version + :one"),
+			ConditionExpression: aws.String("balance >= :amt AND version = :version"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":amt": n(amount), ":one": n("1"), ":version": n(version),
+			},
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}}
+	}
+	newClient := func(t *testing.T) *Client {
+		client := newTestClient(t)
+		put(t, client, map[string]types.AttributeValue{"id": s("a"), "balance": n("100"), "version": n("1")})
+		put(t, client, map[string]types.AttributeValue{"id": s("b"), "balance": n("10"), "version": n("1")})
+		return client
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		client := newClient(t)
+
+		_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+			debit("a", "50", "1"),
+			{Put: &types.Put{TableName: aws.String("accounts"), Item: map[string]types.AttributeValue{"id": s("c")}, ConditionExpression: aws.String("attribute_not_exists(id)")}},
+			{Delete: &types.Delete{TableName: aws.String("accounts"), Key: map[string]types.AttributeValue{"id": s("b")}}},
+		}})
+
+		require.NoError(t, err)
+		assert.Equal(t, n("50"), get(t, client, "a")["balance"])
+		assert.NotNil(t, get(t, client, "c"))
+		assert.Nil(t, get(t, client, "b"))
+	})
+
+	t.Run("Cancelled transactions write nothing", func(t *testing.T) {
+		client := newClient(t)
+
+		_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+			debit("a", "50", "1"),
+			debit("b", "50", "1"),
+			{ConditionCheck: &types.ConditionCheck{TableName: aws.String("accounts"), Key: map[string]types.AttributeValue{"id": s("c")}, ConditionExpression: aws.String("attribute_exists(id)")}},
+		}})
+
+		var tce *types.TransactionCanceledException
+		require.ErrorAs(t, err, &tce)
+		require.Len(t, tce.CancellationReasons, 3)
+		assert.Equal(t, "None", *tce.CancellationReasons[0].Code)
+		assert.Equal(t, "ConditionalCheckFailed", *tce.CancellationReasons[1].Code)
+		assert.Equal(t, n("10"), tce.CancellationReasons[1].Item["balance"])
+		assert.Equal(t, "ConditionalCheckFailed", *tce.CancellationReasons[2].Code)
+		assert.Contains(t, tce.ErrorMessage(), "[None, ConditionalCheckFailed, ConditionalCheckFailed]")
+		assert.Equal(t, n("100"), get(t, client, "a")["balance"], "the successful item must be rolled back")
+		assert.Equal(t, n("1"), get(t, client, "a")["version"])
+	})
+
+	t.Run("Runtime errors cancel with ValidationError", func(t *testing.T) {
+		client := newClient(t)
+
+		_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+			debit("a", "50", "1"),
+			{Update: &types.Update{
+				TableName:                 aws.String("accounts"),
+				Key:                       map[string]types.AttributeValue{"id": s("b")},
+				UpdateExpression:          aws.String("SET missing = missing + :one"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1")},
+			}},
+		}})
+
+		var tce *types.TransactionCanceledException
+		require.ErrorAs(t, err, &tce)
+		assert.Equal(t, "ValidationError", *tce.CancellationReasons[1].Code)
+		assert.Equal(t, n("100"), get(t, client, "a")["balance"])
+	})
+
+	t.Run("Multiple operations on one item", func(t *testing.T) {
+		client := newClient(t)
+
+		_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+			debit("a", "10", "1"),
+			debit("a", "10", "1"),
+		}})
+
+		assert.True(t, isValidationError(err), "got %v", err)
+		assert.Equal(t, n("100"), get(t, client, "a")["balance"])
+	})
+}
+
+func TestQuery(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		put(t, client, map[string]types.AttributeValue{
+			"id": s(fmt.Sprintf("alice-%d", i)), "owner": s("alice"), "created_at": n(fmt.Sprint(i * 10)), "kind": s([]string{"even", "odd"}[i%2]),
+		})
+	}
+	put(t, client, map[string]types.AttributeValue{"id": s("bob-1"), "owner": s("bob"), "created_at": n("15")})
+	put(t, client, map[string]types.AttributeValue{"id": s("unindexed")})
+
+	ids := func(items []map[string]types.AttributeValue) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it["id"].(*types.AttributeValueMemberS).Value)
+		}
+		return out
+	}
+	query := func(input *dynamodb.QueryInput) *dynamodb.QueryOutput {
+		t.Helper()
+		input.TableName = aws.String("accounts")
+		input.IndexName = aws.String("by_owner")
+		out, err := client.Query(ctx, input)
+		require.NoError(t, err)
+		return out
+	}
+
+	t.Run("Sort key condition and order", func(t *testing.T) {
+		out := query(&dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("#o = :o AND created_at > :after"),
+			ExpressionAttributeNames:  map[string]string{"#o": "owner"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":o": s("alice"), ":after": n("20")},
+			ScanIndexForward:          aws.Bool(false),
+		})
+		assert.Equal(t, []string{"alice-5", "alice-4", "alice-3"}, ids(out.Items))
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		input := &dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("#o = :o"),
+			ExpressionAttributeNames:  map[string]string{"#o": "owner"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":o": s("alice")},
+			Limit:                     aws.Int32(2),
+		}
+		var pages [][]string
+		for {
+			out := query(input)
+			pages = append(pages, ids(out.Items))
+			if out.LastEvaluatedKey == nil {
+				break
+			}
+			assert.Contains(t, out.LastEvaluatedKey, "created_at", "index keys are part of LastEvaluatedKey")
+			input.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+		assert.Equal(t, [][]string{{"alice-1", "alice-2"}, {"alice-3", "alice-4"}, {"alice-5"}}, pages)
+	})
+
+	t.Run("Filter is applied after the limit", func(t *testing.T) {
+		out := query(&dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("#o = :o"),
+			FilterExpression:          aws.String("kind = :kind"),
+			ExpressionAttributeNames:  map[string]string{"#o": "owner"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":o": s("alice"), ":kind": s("even")},
+			Limit:                     aws.Int32(3),
+		})
+		assert.Equal(t, []string{"alice-2"}, ids(out.Items))
+		assert.Equal(t, int32(1), out.Count)
+		assert.Equal(t, int32(3), out.ScannedCount)
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		out := query(&dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("#o = :o"),
+			ExpressionAttributeNames:  map[string]string{"#o": "owner"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":o": s("bob")},
+			ProjectionExpression:      aws.String("id"),
+		})
+		assert.Equal(t, []map[string]types.AttributeValue{{"id": s("bob-1")}}, out.Items)
+	})
+
+	t.Run("Key condition must use the partition key", func(t *testing.T) {
+		_, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String("accounts"),
+			IndexName:                 aws.String("by_owner"),
+			KeyConditionExpression:    aws.String("created_at > :after"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":after": n("20")},
+		})
+		assert.True(t, isValidationError(err), "got %v", err)
+	})
+
+	t.Run("Unknown index", func(t *testing.T) {
+		_, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String("accounts"),
+			IndexName:                 aws.String("nope"),
+			KeyConditionExpression:    aws.String("id = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": s("a")},
+		})
+		assert.True(t, isValidationError(err), "got %v", err)
+	})
+
+	t.Run("Scan sees every item", func(t *testing.T) {
+		out, err := client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("accounts")})
+		require.NoError(t, err)
+		assert.Len(t, out.Items, 7)
+	})
+}
+
+func TestIndexKeyTypesAreValidated(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String("accounts"),
+		Item:      map[string]types.AttributeValue{"id": s("a"), "owner": s("alice"), "created_at": s("yesterday")},
+	})
+
+	assert.True(t, isValidationError(err), "got %v", err)
+}
+
+func TestItemsAreCopied(t *testing.T) {
+	client := newTestClient(t)
+	it := map[string]types.AttributeValue{"id": s("a"), "profile": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"name": s("Alice")}}}
+	put(t, client, it)
+
+	it["profile"].(*types.AttributeValueMemberM).Value["name"] = s("Mallory")
+	got := get(t, client, "a")
+	got["profile"].(*types.AttributeValueMemberM).Value["name"] = s("Eve")
+
+	assert.Equal(t, s("Alice"), get(t, client, "a")["profile"].(*types.AttributeValueMemberM).Value["name"])
+}
+
+func TestEmptyExpressionsAreRejected(t *testing.T) {
+	client := newTestClient(t)
+
+	for _, expr := range []string{"", "SET", "a ="} {
+		_, err := client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+			TableName:        aws.String("accounts"),
+			Key:              map[string]types.AttributeValue{"id": s("a")},
+			UpdateExpression: aws.String(expr),
+		})
+		assert.True(t, isValidationError(err), "%q: got %v", expr, err)
+
+		_, err = client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+			TableName:           aws.String("accounts"),
+			Key:                 map[string]types.AttributeValue{"id": s("a")},
+			ConditionExpression: aws.String(expr),
+		})
+		assert.True(t, isValidationError(err), "%q: got %v", expr, err)
+	}
+}
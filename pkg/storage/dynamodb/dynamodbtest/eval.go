@@ -0,0 +1,665 @@
+package dynamodbtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type item = map[string]types.AttributeValue
+
+// typeOf returns the DynamoDB type descriptor of a value, e.g. "S" or "NS".
+func typeOf(v types.AttributeValue) string {
+	switch v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	}
+	return ""
+}
+
+func parseNumber(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("The parameter cannot be converted to a numeric value: %s", s)
+	}
+	return r, nil
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	return strings.TrimRight(r.FloatString(38), "0")
+}
+
+// compareValues orders two scalar values of the same type. ok is false when the values
+// are not comparable, in which case DynamoDB treats the comparison as false.
+func compareValues(a, b types.AttributeValue) (cmp int, ok bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		if b, isS := b.(*types.AttributeValueMemberS); isS {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if b, isN := b.(*types.AttributeValueMemberN); isN {
+			x, errA := parseNumber(a.Value)
+			y, errB := parseNumber(b.Value)
+			if errA != nil || errB != nil {
+				return 0, false
+			}
+			return x.Cmp(y), true
+		}
+	case *types.AttributeValueMemberB:
+		if b, isB := b.(*types.AttributeValueMemberB); isB {
+			return bytes.Compare(a.Value, b.Value), true
+		}
+	}
+	return 0, false
+}
+
+// equalValues reports whether two values are equal, comparing sets without regard to order.
+func equalValues(a, b types.AttributeValue) bool {
+	if typeOf(a) != typeOf(b) {
+		return false
+	}
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		cmp, ok := compareValues(a, b)
+		return ok && cmp == 0
+	case *types.AttributeValueMemberBOOL:
+		return a.Value == b.(*types.AttributeValueMemberBOOL).Value
+	case *types.AttributeValueMemberNULL:
+		return true
+	case *types.AttributeValueMemberL:
+		bl := b.(*types.AttributeValueMemberL).Value
+		if len(a.Value) != len(bl) {
+			return false
+		}
+		for i := range a.Value {
+			if !equalValues(a.Value[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		bm := b.(*types.AttributeValueMemberM).Value
+		if len(a.Value) != len(bm) {
+			return false
+		}
+		for k, v := range a.Value {
+			other, ok := bm[k]
+			if !ok || !equalValues(v, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		as, bs := setMembers(a), setMembers(b)
+		if len(as) != len(bs) {
+			return false
+		}
+		for _, x := range as {
+			if !containsMember(bs, x) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// setMembers returns the members of a string, number or binary set as scalar values.
+func setMembers(v types.AttributeValue) []types.AttributeValue {
+	var members []types.AttributeValue
+	switch v := v.(type) {
+	case *types.AttributeValueMemberSS:
+		for _, s := range v.Value {
+			members = append(members, &types.AttributeValueMemberS{Value: s})
+		}
+	case *types.AttributeValueMemberNS:
+		for _, n := range v.Value {
+			members = append(members, &types.AttributeValueMemberN{Value: n})
+		}
+	case *types.AttributeValueMemberBS:
+		for _, b := range v.Value {
+			members = append(members, &types.AttributeValueMemberB{Value: b})
+		}
+	}
+	return members
+}
+
+func containsMember(members []types.AttributeValue, v types.AttributeValue) bool {
+	for _, m := range members {
+		if equalValues(m, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// makeSet builds a set of the given type ("SS", "NS" or "BS") from scalar members.
+func makeSet(setType string, members []types.AttributeValue) types.AttributeValue {
+	switch setType {
+	case "SS":
+		set := &types.AttributeValueMemberSS{}
+		for _, m := range members {
+			set.Value = append(set.Value, m.(*types.AttributeValueMemberS).Value)
+		}
+		return set
+	case "NS":
+		set := &types.AttributeValueMemberNS{}
+		for _, m := range members {
+			set.Value = append(set.Value, m.(*types.AttributeValueMemberN).Value)
+		}
+		return set
+	default:
+		set := &types.AttributeValueMemberBS{}
+		for _, m := range members {
+			set.Value = append(set.Value, m.(*types.AttributeValueMemberB).Value)
+		}
+		return set
+	}
+}
+
+func isSetType(t string) bool {
+	return t == "SS" || t == "NS" || t == "BS"
+}
+
+func cloneValue(v types.AttributeValue) types.AttributeValue {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: bytes.Clone(v.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = cloneValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(v.Value)}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		set := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			set[i] = bytes.Clone(b)
+		}
+		return &types.AttributeValueMemberBS{Value: set}
+	}
+	return v
+}
+
+func cloneItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	out := make(item, len(it))
+	for k, v := range it {
+		out[k] = cloneValue(v)
+	}
+	return out
+}
+
+// lookup resolves a document path within an item.
+func lookup(it item, p path) (types.AttributeValue, bool) {
+	v, ok := it[p[0].name]
+	if !ok {
+		return nil, false
+	}
+	for _, e := range p[1:] {
+		switch cur := v.(type) {
+		case *types.AttributeValueMemberM:
+			if e.isIndex {
+				return nil, false
+			}
+			if v, ok = cur.Value[e.name]; !ok {
+				return nil, false
+			}
+		case *types.AttributeValueMemberL:
+			if !e.isIndex || e.index >= len(cur.Value) {
+				return nil, false
+			}
+			v = cur.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// evalOperand resolves an operand against an item. ok is false when a path does not exist.
+func evalOperand(it item, o *operand) (types.AttributeValue, bool, error) {
+	switch o.kind {
+	case opValue:
+		return o.value, true, nil
+	case opPath:
+		v, ok := lookup(it, o.path)
+		return v, ok, nil
+	case opSize:
+		v, ok := lookup(it, o.path)
+		if !ok {
+			return nil, false, nil
+		}
+		var size int
+		switch v := v.(type) {
+		case *types.AttributeValueMemberS:
+			size = len(v.Value)
+		case *types.AttributeValueMemberB:
+			size = len(v.Value)
+		case *types.AttributeValueMemberL:
+			size = len(v.Value)
+		case *types.AttributeValueMemberM:
+			size = len(v.Value)
+		default:
+			if !isSetType(typeOf(v)) {
+				return nil, false, nil
+			}
+			size = len(setMembers(v))
+		}
+		return &types.AttributeValueMemberN{Value: fmt.Sprint(size)}, true, nil
+	case opIfNotExists:
+		if v, ok := lookup(it, o.args[0].path); ok {
+			return v, true, nil
+		}
+		return evalOperand(it, o.args[1])
+	case opListAppend:
+		var out []types.AttributeValue
+		for _, arg := range o.args {
+			v, ok, err := evalOperand(it, arg)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				return nil, false, fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+			}
+			list, isList := v.(*types.AttributeValueMemberL)
+			if !isList {
+				return nil, false, fmt.Errorf("An operand in the update expression has an incorrect data type")
+			}
+			out = append(out, list.Value...)
+		}
+		return &types.AttributeValueMemberL{Value: out}, true, nil
+	case opPlus, opMinus:
+		var nums [2]*big.Rat
+		for i, arg := range o.args {
+			v, ok, err := evalOperand(it, arg)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				return nil, false, fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+			}
+			n, isN := v.(*types.AttributeValueMemberN)
+			if !isN {
+				return nil, false, fmt.Errorf("An operand in the update expression has an incorrect data type")
+			}
+			if nums[i], err = parseNumber(n.Value); err != nil {
+				return nil, false, err
+			}
+		}
+		if o.kind == opPlus {
+			return &types.AttributeValueMemberN{Value: formatNumber(nums[0].Add(nums[0], nums[1]))}, true, nil
+		}
+		return &types.AttributeValueMemberN{Value: formatNumber(nums[0].Sub(nums[0], nums[1]))}, true, nil
+	}
+	return nil, false, fmt.Errorf("unsupported operand")
+}
+
+// evalCondition evaluates a parsed condition against an item. A missing item is an empty map.
+func evalCondition(it item, c *condition) (bool, error) {
+	switch c.kind {
+	case condAnd:
+		left, err := evalCondition(it, c.children[0])
+		if err != nil || !left {
+			return false, err
+		}
+		return evalCondition(it, c.children[1])
+	case condOr:
+		left, err := evalCondition(it, c.children[0])
+		if err != nil || left {
+			return left, err
+		}
+		return evalCondition(it, c.children[1])
+	case condNot:
+		result, err := evalCondition(it, c.children[0])
+		return !result, err
+	}
+
+	values := make([]types.AttributeValue, len(c.operands))
+	present := make([]bool, len(c.operands))
+	for i, o := range c.operands {
+		v, ok, err := evalOperand(it, o)
+		if err != nil {
+			return false, err
+		}
+		values[i], present[i] = v, ok
+	}
+
+	switch c.kind {
+	case condCompare:
+		if !present[0] || !present[1] {
+			return false, nil
+		}
+		switch c.op {
+		case "=":
+			return equalValues(values[0], values[1]), nil
+		case "<>":
+			return !equalValues(values[0], values[1]), nil
+		}
+		cmp, ok := compareValues(values[0], values[1])
+		if !ok {
+			return false, nil
+		}
+		switch c.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	case condBetween:
+		if !present[0] || !present[1] || !present[2] {
+			return false, nil
+		}
+		if lowHigh, ok := compareValues(values[1], values[2]); ok && lowHigh > 0 {
+			return false, fmt.Errorf("Invalid KeyConditionExpression: The BETWEEN operator requires upper bound to be greater than or equal to lower bound")
+		}
+		low, okLow := compareValues(values[0], values[1])
+		high, okHigh := compareValues(values[0], values[2])
+		return okLow && okHigh && low >= 0 && high <= 0, nil
+	case condIn:
+		if !present[0] {
+			return false, nil
+		}
+		for i := 1; i < len(values); i++ {
+			if present[i] && equalValues(values[0], values[i]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case condFunc:
+		switch c.op {
+		case "attribute_exists":
+			return present[0], nil
+		case "attribute_not_exists":
+			return !present[0], nil
+		case "attribute_type":
+			want, ok := values[1].(*types.AttributeValueMemberS)
+			if !ok {
+				return false, fmt.Errorf("Invalid ConditionExpression: Incorrect operand type for operator or function; operator or function: attribute_type")
+			}
+			return present[0] && typeOf(values[0]) == want.Value, nil
+		case "begins_with":
+			if !present[0] || !present[1] {
+				return false, nil
+			}
+			switch v := values[0].(type) {
+			case *types.AttributeValueMemberS:
+				prefix, ok := values[1].(*types.AttributeValueMemberS)
+				return ok && strings.HasPrefix(v.Value, prefix.Value), nil
+			case *types.AttributeValueMemberB:
+				prefix, ok := values[1].(*types.AttributeValueMemberB)
+				return ok && bytes.HasPrefix(v.Value, prefix.Value), nil
+			}
+			return false, nil
+		case "contains":
+			if !present[0] || !present[1] {
+				return false, nil
+			}
+			switch v := values[0].(type) {
+			case *types.AttributeValueMemberS:
+				sub, ok := values[1].(*types.AttributeValueMemberS)
+				return ok && strings.Contains(v.Value, sub.Value), nil
+			case *types.AttributeValueMemberL:
+				for _, e := range v.Value {
+					if equalValues(e, values[1]) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			return containsMember(setMembers(values[0]), values[1]), nil
+		}
+	}
+	return false, fmt.Errorf("unsupported condition")
+}
+
+// applyUpdate returns a copy of it with the update applied. As in DynamoDB, every operand
+// is evaluated against the item as it was before the update.
+func applyUpdate(it item, u *update) (item, error) {
+	out := cloneItem(it)
+
+	type assignment struct {
+		path  path
+		value types.AttributeValue
+	}
+	var sets []assignment
+	for _, action := range u.set {
+		v, ok, err := evalOperand(it, action.value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+		}
+		sets = append(sets, assignment{action.path, cloneValue(v)})
+	}
+	for _, action := range u.add {
+		current, exists := lookup(it, action.path)
+		addend := action.value.value
+		switch addend.(type) {
+		case *types.AttributeValueMemberN:
+			if !exists {
+				sets = append(sets, assignment{action.path, cloneValue(addend)})
+				continue
+			}
+			v, _, err := evalOperand(it, &operand{kind: opPlus, args: []*operand{{kind: opPath, path: action.path}, action.value}})
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, assignment{action.path, v})
+		case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			if !exists {
+				sets = append(sets, assignment{action.path, cloneValue(addend)})
+				continue
+			}
+			if typeOf(current) != typeOf(addend) {
+				return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+			}
+			members := setMembers(current)
+			for _, m := range setMembers(addend) {
+				if !containsMember(members, m) {
+					members = append(members, m)
+				}
+			}
+			sets = append(sets, assignment{action.path, makeSet(typeOf(current), members)})
+		default:
+			return nil, fmt.Errorf("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD, operand type: %s", typeOf(addend))
+		}
+	}
+	var removes []path
+	for _, action := range u.delete {
+		current, exists := lookup(it, action.path)
+		subtrahend := action.value.value
+		if !isSetType(typeOf(subtrahend)) {
+			return nil, fmt.Errorf("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: DELETE, operand type: %s", typeOf(subtrahend))
+		}
+		if !exists {
+			continue
+		}
+		if typeOf(current) != typeOf(subtrahend) {
+			return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+		}
+		var remaining []types.AttributeValue
+		for _, m := range setMembers(current) {
+			if !containsMember(setMembers(subtrahend), m) {
+				remaining = append(remaining, m)
+			}
+		}
+		if len(remaining) == 0 {
+			removes = append(removes, action.path)
+			continue
+		}
+		sets = append(sets, assignment{action.path, makeSet(typeOf(current), remaining)})
+	}
+	removes = append(removes, u.remove...)
+
+	for _, s := range sets {
+		if err := setPath(out, s.path, s.value); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range removes {
+		removePath(out, p)
+	}
+	return out, nil
+}
+
+func setPath(it item, p path, v types.AttributeValue) error {
+	if len(p) == 1 {
+		it[p[0].name] = v
+		return nil
+	}
+	parent, ok := lookup(it, p[:len(p)-1])
+	if !ok {
+		return fmt.Errorf("The document path provided in the update expression is invalid for update")
+	}
+	last := p[len(p)-1]
+	switch parent := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.isIndex {
+			return fmt.Errorf("The document path provided in the update expression is invalid for update")
+		}
+		parent.Value[last.name] = v
+	case *types.AttributeValueMemberL:
+		if !last.isIndex {
+			return fmt.Errorf("The document path provided in the update expression is invalid for update")
+		}
+		if last.index < len(parent.Value) {
+			parent.Value[last.index] = v
+		} else {
+			parent.Value = append(parent.Value, v)
+		}
+	default:
+		return fmt.Errorf("The document path provided in the update expression is invalid for update")
+	}
+	return nil
+}
+
+func removePath(it item, p path) {
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+	parent, ok := lookup(it, p[:len(p)-1])
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	switch parent := parent.(type) {
+	case *types.AttributeValueMemberM:
+		delete(parent.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index < len(parent.Value) {
+			parent.Value = append(parent.Value[:last.index], parent.Value[last.index+1:]...)
+		}
+	}
+}
+
+// updatedAttributes returns the top-level attribute names an update touches.
+func (u *update) updatedAttributes() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(p path) {
+		if !seen[p[0].name] {
+			seen[p[0].name] = true
+			names = append(names, p[0].name)
+		}
+	}
+	for _, a := range u.set {
+		add(a.path)
+	}
+	for _, p := range u.remove {
+		add(p)
+	}
+	for _, a := range u.add {
+		add(a.path)
+	}
+	for _, a := range u.delete {
+		add(a.path)
+	}
+	return names
+}
+
+// project returns the subset of an item selected by the given paths. Paths through maps keep
+// the enclosing maps; a path containing a list index keeps the whole top-level attribute.
+func project(it item, paths []path) item {
+	if paths == nil {
+		return cloneItem(it)
+	}
+	out := make(item)
+	for _, p := range paths {
+		v, ok := lookup(it, p)
+		if !ok {
+			continue
+		}
+		if p.hasIndex() {
+			top, _ := lookup(it, p[:1])
+			out[p[0].name] = cloneValue(top)
+			continue
+		}
+		cur := out
+		for _, e := range p[:len(p)-1] {
+			next, ok := cur[e.name].(*types.AttributeValueMemberM)
+			if !ok {
+				next = &types.AttributeValueMemberM{Value: make(item)}
+				cur[e.name] = next
+			}
+			cur = next.Value
+		}
+		cur[p[len(p)-1].name] = cloneValue(v)
+	}
+	return out
+}
+
+func (p path) hasIndex() bool {
+	for _, e := range p {
+		if e.isIndex {
+			return true
+		}
+	}
+	return false
+}
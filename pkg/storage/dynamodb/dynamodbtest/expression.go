@@ -0,0 +1,693 @@
+package dynamodbtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent            // bare attribute name, keyword or function name
+	tokName             // #name placeholder
+	tokValue            // :value placeholder
+	tokNumber           // list index
+	tokPunct            // ( ) [ ] , . = <> < <= > >= + -
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// lex splits an expression into tokens.
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == ':':
+			start := i
+			i++
+			for i < len(expr) && isIdentPart(expr[i]) {
+				i++
+			}
+			if i == start+1 {
+				return nil, fmt.Errorf("syntax error; token: %q, near: %q", string(c), expr[start:])
+			}
+			kind := tokName
+			if c == ':' {
+				kind = tokValue
+			}
+			tokens = append(tokens, token{kind: kind, text: expr[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(expr) && isIdentPart(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: expr[start:i], pos: start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: expr[start:i], pos: start})
+		case c == '<' || c == '>':
+			start := i
+			i++
+			if i < len(expr) && (expr[i] == '=' || (c == '<' && expr[i] == '>')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokPunct, text: expr[start:i], pos: start})
+		case strings.IndexByte("()[],.=+-", c) >= 0:
+			tokens = append(tokens, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q at position %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(expr)}), nil
+}
+
+// pathElem is one step of a document path: either a map key or a list index.
+type pathElem struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type path []pathElem
+
+func (p path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			fmt.Fprintf(&sb, "[%d]", e.index)
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(e.name)
+	}
+	return sb.String()
+}
+
+type operandKind int
+
+const (
+	opPath operandKind = iota
+	opValue
+	opSize
+	opIfNotExists
+	opListAppend
+	opPlus
+	opMinus
+)
+
+type operand struct {
+	kind  operandKind
+	path  path
+	value types.AttributeValue
+	args  []*operand
+}
+
+type condKind int
+
+const (
+	condAnd condKind = iota
+	condOr
+	condNot
+	condCompare
+	condBetween
+	condIn
+	condFunc
+)
+
+type condition struct {
+	kind     condKind
+	op       string // comparator or function name
+	children []*condition
+	operands []*operand
+}
+
+type updateAction struct {
+	path  path
+	value *operand
+}
+
+// update is a parsed UpdateExpression.
+type update struct {
+	set    []updateAction
+	remove []path
+	add    []updateAction
+	delete []updateAction
+}
+
+var conditionFuncs = map[string]int{
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+	"begins_with":          2,
+	"contains":             2,
+}
+
+// parser parses the expressions of a single request. It records which placeholders were
+// used so that unused ExpressionAttributeNames and ExpressionAttributeValues can be rejected
+// the same way DynamoDB does.
+type parser struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+
+	tokens []token
+	pos    int
+	expr   string
+}
+
+func newParser(names map[string]string, values map[string]types.AttributeValue) *parser {
+	return &parser{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+// checkUnused reports placeholders that were supplied but never referenced.
+func (p *parser) checkUnused() error {
+	for name := range p.names {
+		if !p.usedNames[name] {
+			return fmt.Errorf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", name)
+		}
+	}
+	for value := range p.values {
+		if !p.usedValues[value] {
+			return fmt.Errorf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", value)
+		}
+	}
+	return nil
+}
+
+func (p *parser) reset(expr string) error {
+	tokens, err := lex(expr)
+	if err != nil {
+		return fmt.Errorf("Invalid expression: %w", err)
+	}
+	p.tokens, p.pos, p.expr = tokens, 0, expr
+	return nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// backup returns t, the token most recently read by next, to the input.
+func (p *parser) backup(t token) {
+	if t.kind != tokEOF {
+		p.pos--
+	}
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == text
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	t := p.peek()
+	near := "<EOF>"
+	if t.kind != tokEOF {
+		near = p.expr[t.pos:]
+	}
+	return fmt.Errorf("Invalid expression: %s; near: %q", fmt.Sprintf(format, args...), near)
+}
+
+func (p *parser) expectPunct(text string) error {
+	if !p.isPunct(text) {
+		return p.errorf("expected %q", text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectEOF() error {
+	if p.peek().kind != tokEOF {
+		return p.errorf("unexpected token %q", p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) resolveName(t token) (string, error) {
+	name, ok := p.names[t.text]
+	if !ok {
+		return "", fmt.Errorf("Invalid expression: An expression attribute name used in the document path is not defined; attribute name: %s", t.text)
+	}
+	p.usedNames[t.text] = true
+	return name, nil
+}
+
+func (p *parser) resolveValue(t token) (types.AttributeValue, error) {
+	value, ok := p.values[t.text]
+	if !ok {
+		return nil, fmt.Errorf("Invalid expression: An expression attribute value used in expression is not defined; attribute value: %s", t.text)
+	}
+	p.usedValues[t.text] = true
+	return value, nil
+}
+
+func (p *parser) parsePathElem() (pathElem, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		return pathElem{name: t.text}, nil
+	case tokName:
+		name, err := p.resolveName(t)
+		return pathElem{name: name}, err
+	default:
+		p.backup(t)
+		return pathElem{}, p.errorf("expected attribute name")
+	}
+}
+
+func (p *parser) parsePath() (path, error) {
+	first, err := p.parsePathElem()
+	if err != nil {
+		return nil, err
+	}
+	result := path{first}
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			elem, err := p.parsePathElem()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, elem)
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if t.kind != tokNumber {
+				p.backup(t)
+				return nil, p.errorf("expected list index")
+			}
+			index, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, p.errorf("invalid list index %q", t.text)
+			}
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathElem{index: index, isIndex: true})
+		default:
+			return result, nil
+		}
+	}
+}
+
+// parseProjection parses a comma-separated list of document paths.
+func (p *parser) parseProjection(expr string) ([]path, error) {
+	if err := p.reset(expr); err != nil {
+		return nil, err
+	}
+	var paths []path
+	for {
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, pth)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return paths, p.expectEOF()
+}
+
+// parseCondition parses a ConditionExpression, FilterExpression or KeyConditionExpression.
+func (p *parser) parseCondition(expr string) (*condition, error) {
+	if err := p.reset(expr); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return cond, p.expectEOF()
+}
+
+func (p *parser) parseOr() (*condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &condition{kind: condOr, children: []*condition{left, right}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (*condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &condition{kind: condAnd, children: []*condition{left, right}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (*condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &condition{kind: condNot, children: []*condition{child}}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (*condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expectPunct(")")
+	}
+
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == "(" {
+		if arity, ok := conditionFuncs[t.text]; ok {
+			p.next()
+			p.next()
+			args, err := p.parseConditionArgs()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != arity {
+				return nil, fmt.Errorf("Invalid expression: Incorrect number of operands for operator or function; operator or function: %s, number of operands: %d", t.text, len(args))
+			}
+			if args[0].kind != opPath {
+				return nil, fmt.Errorf("Invalid expression: Operator or function requires a document path; operator or function: %s", t.text)
+			}
+			return &condition{kind: condFunc, op: t.text, operands: args}, nil
+		}
+	}
+
+	left, err := p.parseConditionOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseConditionOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN")
+		}
+		p.next()
+		high, err := p.parseConditionOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &condition{kind: condBetween, operands: []*operand{left, low, high}}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		args, err := p.parseConditionArgs()
+		if err != nil {
+			return nil, err
+		}
+		return &condition{kind: condIn, operands: append([]*operand{left}, args...)}, nil
+	}
+
+	t = p.peek()
+	if t.kind == tokPunct {
+		switch t.text {
+		case "=", "<>", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseConditionOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &condition{kind: condCompare, op: t.text, operands: []*operand{left, right}}, nil
+		}
+	}
+	return nil, p.errorf("expected comparator")
+}
+
+// parseConditionArgs parses operands up to and including the closing parenthesis.
+func (p *parser) parseConditionArgs() ([]*operand, error) {
+	var args []*operand
+	for {
+		arg, err := p.parseConditionOperand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return args, p.expectPunct(")")
+}
+
+func (p *parser) parseConditionOperand() (*operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokValue:
+		p.next()
+		value, err := p.resolveValue(t)
+		return &operand{kind: opValue, value: value}, err
+	case t.kind == tokIdent && t.text == "size" && p.tokens[p.pos+1].text == "(":
+		p.next()
+		p.next()
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return &operand{kind: opSize, path: pth}, p.expectPunct(")")
+	case t.kind == tokIdent || t.kind == tokName:
+		pth, err := p.parsePath()
+		return &operand{kind: opPath, path: pth}, err
+	}
+	return nil, p.errorf("expected operand")
+}
+
+// parseUpdate parses an UpdateExpression.
+func (p *parser) parseUpdate(expr string) (*update, error) {
+	if err := p.reset(expr); err != nil {
+		return nil, err
+	}
+	u := &update{}
+	seen := make(map[string]bool)
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokIdent || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			p.backup(t)
+			return nil, p.errorf("expected SET, REMOVE, ADD or DELETE")
+		}
+		if seen[clause] {
+			return nil, fmt.Errorf("Invalid UpdateExpression: The \"%s\" section can only be used once in an update expression", clause)
+		}
+		seen[clause] = true
+
+		for {
+			pth, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			switch clause {
+			case "SET":
+				if err := p.expectPunct("="); err != nil {
+					return nil, err
+				}
+				value, err := p.parseSetValue()
+				if err != nil {
+					return nil, err
+				}
+				u.set = append(u.set, updateAction{path: pth, value: value})
+			case "REMOVE":
+				u.remove = append(u.remove, pth)
+			case "ADD", "DELETE":
+				t := p.next()
+				if t.kind != tokValue {
+					p.backup(t)
+					return nil, p.errorf("expected value placeholder")
+				}
+				value, err := p.resolveValue(t)
+				if err != nil {
+					return nil, err
+				}
+				action := updateAction{path: pth, value: &operand{kind: opValue, value: value}}
+				if clause == "ADD" {
+					u.add = append(u.add, action)
+				} else {
+					u.delete = append(u.delete, action)
+				}
+			}
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("Invalid UpdateExpression: The expression can not be empty")
+	}
+	if err := u.checkOverlap(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// checkOverlap rejects updates in which one document path is a prefix of another.
+func (u *update) checkOverlap() error {
+	var paths []path
+	for _, a := range u.set {
+		paths = append(paths, a.path)
+	}
+	paths = append(paths, u.remove...)
+	for _, a := range u.add {
+		paths = append(paths, a.path)
+	}
+	for _, a := range u.delete {
+		paths = append(paths, a.path)
+	}
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if paths[i].overlaps(paths[j]) {
+				return fmt.Errorf("Invalid UpdateExpression: Two document paths overlap with each other; must remove or rewrite one of these paths; path one: [%s], path two: [%s]", paths[i], paths[j])
+			}
+		}
+	}
+	return nil
+}
+
+func (p path) overlaps(other path) bool {
+	for i := 0; i < len(p) && i < len(other); i++ {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) parseSetValue() (*operand, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isPunct("+"):
+		p.next()
+		right, err := p.parseSetOperand()
+		return &operand{kind: opPlus, args: []*operand{left, right}}, err
+	case p.isPunct("-"):
+		p.next()
+		right, err := p.parseSetOperand()
+		return &operand{kind: opMinus, args: []*operand{left, right}}, err
+	}
+	return left, nil
+}
+
+func (p *parser) parseSetOperand() (*operand, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].text == "(" {
+		switch t.text {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			pth, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &operand{kind: opIfNotExists, args: []*operand{{kind: opPath, path: pth}, fallback}}, p.expectPunct(")")
+		case "list_append":
+			p.next()
+			p.next()
+			first, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &operand{kind: opListAppend, args: []*operand{first, second}}, p.expectPunct(")")
+		default:
+			return nil, p.errorf("invalid function name %q", t.text)
+		}
+	}
+	switch t.kind {
+	case tokValue:
+		p.next()
+		value, err := p.resolveValue(t)
+		return &operand{kind: opValue, value: value}, err
+	case tokIdent, tokName:
+		pth, err := p.parsePath()
+		return &operand{kind: opPath, path: pth}, err
+	}
+	return nil, p.errorf("expected operand")
+}
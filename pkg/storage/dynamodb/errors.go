@@ -0,0 +1,33 @@
+package dynamodb
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// conditionFailed reports whether err is a cancelled TransactWriteItems call whose item at
+// index failed its condition check. Cancellation reasons are listed in request order.
+func conditionFailed(err error, index int) bool {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) || index >= len(tce.CancellationReasons) {
+		return false
+	}
+	code := tce.CancellationReasons[index].Code
+	return code != nil && *code == "ConditionalCheckFailed"
+}
+
+// conditionFailedFrom reports whether err is a cancelled TransactWriteItems call in which any item
+// at or after index failed its condition check.
+func conditionFailedFrom(err error, index int) bool {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) {
+		return false
+	}
+	for i := index; i < len(tce.CancellationReasons); i++ {
+		if conditionFailed(err, i) {
+			return true
+		}
+	}
+	return false
+}
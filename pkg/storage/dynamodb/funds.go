@@ -0,0 +1,121 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Deposit credits a wallet with funds from the funding account. The wallet update, the completed
+// transaction and its ledger entries are written in a single TransactWriteItems call, which is
+// retried if the wallet changes between being read and being written.
+func (s *Store) Deposit(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	tx.FromUserId = models.FundingAccount
+	if err := s.moveFunds(ctx, tx, tx.ToUserId, tx.Amount); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Withdraw debits a wallet's available balance to the payouts account. The wallet update, the
+// completed transaction and its ledger entries are written in a single TransactWriteItems call,
+// which is retried if the wallet changes between being read and being written.
+func (s *Store) Withdraw(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	tx.ToUserId = models.PayoutsAccount
+	if err := s.moveFunds(ctx, tx, tx.FromUserId, -tx.Amount); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// moveFunds changes the balance of a wallet by change, together with the transaction record
+// and its ledger entries.
+func (s *Store) moveFunds(ctx context.Context, tx *models.Transaction, userID string, change int64) error {
+	now := time.Now()
+	storage.CompleteFundsTransaction(tx, now)
+
+	return retryOnConflict(ctx, func() error {
+		// 1. Get the current state of the wallet for optimistic locking.
+		wallet, err := s.GetWallet(ctx, userID)
+		if err != nil {
+			return err
+		}
+		balance, err := storage.HeldBalance(wallet, tx.Currency)
+		if err != nil {
+			return err
+		}
+		if balance.Balance+change < 0 {
+			return storage.ErrInsufficientFunds
+		}
+
+		// 2. Update the wallet and record the funds transaction atomically.
+		items, heads, err := s.fundsItems(ctx, tx)
+		if err != nil {
+			return err
+		}
+		input := &dynamodb.TransactWriteItemsInput{
+			TransactItems: append([]types.TransactWriteItem{{
+				Update: &types.Update{
+					TableName: aws.String(s.WalletsTableName),
+					Key: map[string]types.AttributeValue{
+						"user_id": &types.AttributeValueMemberS{Value: userID},
+					},
+					UpdateExpression:    aws.String("SET balances.#currency.balance = balances.#currency.balance + :change, version = version + :inc"),
+					ConditionExpression: aws.String("version = :version"),
+					ExpressionAttributeNames: map[string]string{
+						"#currency": tx.Currency,
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":change":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", change)},
+						":version": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", wallet.Version)},
+						":inc":     &types.AttributeValueMemberN{Value: "1"},
+					},
+				},
+			}}, append(items, heads...)...),
+		}
+
+		if _, err := s.Client.TransactWriteItems(ctx, input); err != nil {
+			// The balance and currency were checked against this version, so a failed condition
+			// means the wallet or a ledger chain changed since it was read.
+			if conditionFailed(err, 0) || conditionFailedFrom(err, len(input.TransactItems)-len(heads)) {
+				return fmt.Errorf("failed to move funds: %w: %w", storage.ErrVersionConflict, err)
+			}
+			return fmt.Errorf("failed to move funds: %w", err)
+		}
+		return nil
+	})
+}
+
+// fundsItems returns the writes that record completed deposits or withdrawals: the transaction
+// records, their debit and credit ledger entries and the updates of the ledger chain heads. The
+// head updates come last; see ledgerItems.
+func (s *Store) fundsItems(ctx context.Context, txs ...*models.Transaction) (items, heads []types.TransactWriteItem, err error) {
+	var entries []models.LedgerEntry
+	for _, tx := range txs {
+		txAV, err := attributevalue.MarshalMap(tx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal transaction: %w", err)
+		}
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(s.TransactionsTableName),
+				Item:                txAV,
+				ConditionExpression: aws.String("attribute_not_exists(id)"),
+			},
+		})
+		entries = append(entries, storage.FundsLedgerEntries(tx)...)
+	}
+	puts, heads, err := s.ledgerItems(ctx, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(items, puts...), heads, nil
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 )
 
 // GetTransaction retrieves a transaction from DynamoDB by its ID.
@@ -27,7 +29,7 @@ func (s *Store) GetTransaction(ctx context.Context, txID string) (*models.Transa
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("transaction with ID %s not found", txID)
+		return nil, fmt.Errorf("%w: ID %s", storage.ErrTransactionNotFound, txID)
 	}
 
 	var tx models.Transaction
@@ -37,3 +39,19 @@ func (s *Store) GetTransaction(ctx context.Context, txID string) (*models.Transa
 
 	return &tx, nil
 }
+
+// GetTransactionByIdempotencyKey looks up the transaction recorded against an idempotency key.
+func (s *Store) GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error) {
+	result, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &s.IdempotencyTableName,
+		Key:       map[string]types.AttributeValue{"idempotency_key": &types.AttributeValueMemberS{Value: key}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key from DynamoDB: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: idempotency key %s", storage.ErrTransactionNotFound, key)
+	}
+
+	return s.GetTransaction(ctx, stringAttribute(result.Item, "transaction_id"))
+}
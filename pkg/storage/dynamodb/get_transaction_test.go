@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/mocks"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +17,7 @@ import (
 
 func TestGetTransaction(t *testing.T) {
 	txID := uuid.New().String()
-	tx := &models.Transaction{Id: txID, FromUserId: "user1", ToUserId: "user2", Amount: 100}
+	tx := &models.Transaction{Id: txID, FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"}
 
 	t.Run("Success", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
@@ -41,7 +42,7 @@ func TestGetTransaction(t *testing.T) {
 		_, err := store.GetTransaction(context.Background(), txID)
 
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "not found")
+		assert.ErrorIs(t, err, storage.ErrTransactionNotFound)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -0,0 +1,93 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// chainHeadPrefix starts the entry_id of the ledger table items that hold the head of each ledger
+// chain. Head items have no gsi1pk or account_id, so they never appear in the ledger's indexes.
+const chainHeadPrefix = "HEAD#"
+
+// chainHead is the ledger table item that holds the head of a ledger chain.
+type chainHead struct {
+	Sequence int64  `dynamodbav:"head_sequence"`
+	Hash     string `dynamodbav:"head_hash"`
+}
+
+// ledgerItems links entries into the ledger chains of their accounts and returns the writes that
+// record them: a Put of each entry, and a conditional Update of each chain head that fails if
+// another writer extended the chain after its head was read. Callers put the head updates last
+// and report their failure as a storage.ErrVersionConflict, so that the write is retried.
+func (s *Store) ledgerItems(ctx context.Context, entries []models.LedgerEntry) (puts, heads []types.TransactWriteItem, err error) {
+	links := make(map[storage.ChainKey]storage.ChainHead)
+	for _, key := range storage.ChainKeys(entries) {
+		if links[key], err = s.GetChainHead(ctx, key); err != nil {
+			return nil, nil, err
+		}
+	}
+	previous := maps.Clone(links)
+	storage.LinkEntries(entries, links)
+
+	for _, entry := range entries {
+		entryAV, err := attributevalue.MarshalMap(entry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal ledger entry: %w", err)
+		}
+		puts = append(puts, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(s.LedgerTableName),
+				Item:                entryAV,
+				ConditionExpression: aws.String("attribute_not_exists(entry_id)"),
+			},
+		})
+	}
+
+	for _, key := range storage.ChainKeys(entries) {
+		update := &types.Update{
+			TableName:           aws.String(s.LedgerTableName),
+			Key:                 map[string]types.AttributeValue{"entry_id": &types.AttributeValueMemberS{Value: chainHeadPrefix + key.String()}},
+			UpdateExpression:    aws.String("SET head_sequence = :sequence, head_hash = :hash"),
+			ConditionExpression: aws.String("attribute_not_exists(entry_id)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sequence": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", links[key].Sequence)},
+				":hash":     &types.AttributeValueMemberS{Value: links[key].Hash},
+			},
+		}
+		if seq := previous[key].Sequence; seq != 0 {
+			update.ConditionExpression = aws.String("head_sequence = :previous")
+			update.ExpressionAttributeValues[":previous"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", seq)}
+		}
+		heads = append(heads, types.TransactWriteItem{Update: update})
+	}
+	return puts, heads, nil
+}
+
+// GetChainHead returns the stored head of a ledger chain. A chain without a head item is empty.
+func (s *Store) GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error) {
+	result, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.LedgerTableName),
+		Key:            map[string]types.AttributeValue{"entry_id": &types.AttributeValueMemberS{Value: chainHeadPrefix + key.String()}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return storage.ChainHead{}, fmt.Errorf("failed to get ledger chain head: %w", err)
+	}
+	if result.Item == nil {
+		return storage.ChainHead{}, nil
+	}
+	var head chainHead
+	if err := attributevalue.UnmarshalMap(result.Item, &head); err != nil {
+		return storage.ChainHead{}, fmt.Errorf("failed to unmarshal ledger chain head: %w", err)
+	}
+	return storage.ChainHead{Sequence: head.Sequence, Hash: head.Hash}, nil
+}
@@ -3,29 +3,33 @@ package dynamodb
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 const (
 	stuckTransactionGSI = "status-created_at-index"
-	fromUserIDIndex     = "from_user_id-index"
+	fromUserIDIndex     = "from_user_id-created_at-index"
+	toUserIDIndex       = "to_user_id-created_at-index"
 )
 
-func (s *Store) GetStuckTransactions(ctx context.Context, maxAge time.Duration) ([]models.Transaction, error) {
+// GetStuckTransactions retrieves a page of the transactions in a status that were created longer than maxAge ago, oldest first.
+func (s *Store) GetStuckTransactions(ctx context.Context, status models.TransactionStatus, maxAge time.Duration, page storage.PageRequest) ([]models.Transaction, string, error) {
 	// Calculate the cutoff time.
 	cutoffTime := time.Now().Add(-maxAge)
 	cutoffTimeStr, err := cutoffTime.MarshalText()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal cutoff time: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal cutoff time: %w", err)
 	}
 
-	// Prepare the query input.
+	// Prepare the query input, oldest first.
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(s.TransactionsTableName),
 		IndexName:              aws.String(stuckTransactionGSI),
@@ -35,29 +39,37 @@ func (s *Store) GetStuckTransactions(ctx context.Context, maxAge time.Duration)
 			"#createdAt": "created_at",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":status": &types.AttributeValueMemberS{Value: string(models.RESERVED)},
+			":status": &types.AttributeValueMemberS{Value: string(status)},
 			":cutoff": &types.AttributeValueMemberS{Value: string(cutoffTimeStr)},
 		},
 	}
+	keyAttributes := []string{"id", "status", "created_at"}
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
+	}
 
 	// Execute the query.
 	result, err := s.Client.Query(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query for stuck transactions: %w", err)
+		return nil, "", fmt.Errorf("failed to query for stuck transactions: %w", err)
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build stuck transactions cursor: %w", err)
 	}
 
 	// Unmarshal the results.
 	var transactions []models.Transaction
-	if err := attributevalue.UnmarshalListOfMaps(result.Items, &transactions); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal stuck transactions: %w", err)
+	if err := attributevalue.UnmarshalListOfMaps(items, &transactions); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal stuck transactions: %w", err)
 	}
 
-	return transactions, nil
+	return transactions, next, nil
 }
 
 const ledgerGSI = "gsi1pk-timestamp-index"
 
-func (s *Store) ListLedgerEntries(ctx context.Context, limit int32) ([]models.LedgerEntry, error) {
+func (s *Store) ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(s.LedgerTableName),
 		IndexName:              aws.String(ledgerGSI),
@@ -66,44 +78,335 @@ func (s *Store) ListLedgerEntries(ctx context.Context, limit int32) ([]models.Le
 			":pk": &types.AttributeValueMemberS{Value: "LEDGER_ENTRIES"},
 		},
 		ScanIndexForward: aws.Bool(false), // Sort by timestamp in descending order
-		Limit:            &limit,
+	}
+	keyAttributes := []string{"entry_id", "gsi1pk", "timestamp"}
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
 	}
 
 	result, err := s.Client.Query(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query for ledger entries: %w", err)
+		return nil, "", fmt.Errorf("failed to query for ledger entries: %w", err)
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build ledger entries cursor: %w", err)
 	}
 
 	var entries []models.LedgerEntry
-	if err := attributevalue.UnmarshalListOfMaps(result.Items, &entries); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal ledger entries: %w", err)
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal ledger entries: %w", err)
 	}
 
-	return entries, nil
+	return entries, next, nil
 }
 
-func (s *Store) ListTransactionsByUserID(ctx context.Context, userID string) ([]models.Transaction, error) {
-	// Prepare the query input.
+const accountLedgerIndex = "account_id-timestamp-index"
+
+// ListLedgerEntriesByAccount filters the account's entries by currency after reading them, so a
+// page may hold fewer entries than the limit even when more follow.
+func (s *Store) ListLedgerEntriesByAccount(ctx context.Context, accountID, currency string, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	fromAV, err := attributevalue.Marshal(from.UTC())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal from time: %w", err)
+	}
+	toAV, err := attributevalue.Marshal(to.UTC())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal to time: %w", err)
+	}
+
 	input := &dynamodb.QueryInput{
-		TableName:              aws.String(s.TransactionsTableName),
-		IndexName:              aws.String(fromUserIDIndex),
-		KeyConditionExpression: aws.String("from_user_id = :userID"),
+		TableName:              aws.String(s.LedgerTableName),
+		IndexName:              aws.String(accountLedgerIndex),
+		KeyConditionExpression: aws.String("account_id = :accountID AND #timestamp BETWEEN :from AND :to"),
+		FilterExpression:       aws.String("currency = :currency"),
+		ExpressionAttributeNames: map[string]string{
+			"#timestamp": "timestamp",
+		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":userID": &types.AttributeValueMemberS{Value: userID},
+			":accountID": &types.AttributeValueMemberS{Value: accountID},
+			":currency":  &types.AttributeValueMemberS{Value: currency},
+			":from":      fromAV,
+			":to":        toAV,
+		},
+	}
+	keyAttributes := []string{"entry_id", "account_id", "timestamp"}
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for ledger entries by account: %w", err)
+	}
+	// Key conditions have no exclusive upper bound and cannot be filtered on, so entries exactly
+	// at to are dropped here. They sort last, so once one is seen the range is exhausted.
+	upper := toAV.(*types.AttributeValueMemberS).Value
+	for i, item := range result.Items {
+		if stringAttribute(item, "timestamp") == upper {
+			result.Items, result.LastEvaluatedKey = result.Items[:i], nil
+			break
+		}
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build ledger entries cursor: %w", err)
+	}
+
+	var entries []models.LedgerEntry
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal ledger entries: %w", err)
+	}
+
+	return entries, next, nil
+}
+
+// ListLedgerEntriesBetween reads the ledger index in timestamp order, so pages are always full.
+func (s *Store) ListLedgerEntriesBetween(ctx context.Context, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	fromAV, err := attributevalue.Marshal(from.UTC())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal from time: %w", err)
+	}
+	toAV, err := attributevalue.Marshal(to.UTC())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal to time: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.LedgerTableName),
+		IndexName:              aws.String(ledgerGSI),
+		KeyConditionExpression: aws.String("gsi1pk = :pk AND #timestamp BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#timestamp": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":   &types.AttributeValueMemberS{Value: "LEDGER_ENTRIES"},
+			":from": fromAV,
+			":to":   toAV,
+		},
+	}
+	keyAttributes := []string{"entry_id", "gsi1pk", "timestamp"}
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for ledger entries between times: %w", err)
+	}
+	// As in ListLedgerEntriesByAccount, entries exactly at to are dropped here.
+	upper := toAV.(*types.AttributeValueMemberS).Value
+	for i, item := range result.Items {
+		if stringAttribute(item, "timestamp") == upper {
+			result.Items, result.LastEvaluatedKey = result.Items[:i], nil
+			break
+		}
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build ledger entries cursor: %w", err)
+	}
+
+	var entries []models.LedgerEntry
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal ledger entries: %w", err)
+	}
+
+	return entries, next, nil
+}
+
+// SumLedgerEntries reads every entry of the account up to the given timestamp, in every currency,
+// so its cost grows with the account's history. Entries are compared by their stored timestamp strings, the order
+// of the index that ListLedgerEntriesByAccount reads.
+func (s *Store) SumLedgerEntries(ctx context.Context, accountID, currency string, before time.Time, entryID string) (int64, error) {
+	beforeAV, err := attributevalue.Marshal(before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal before time: %w", err)
+	}
+	bound := beforeAV.(*types.AttributeValueMemberS).Value
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.LedgerTableName),
+		IndexName:              aws.String(accountLedgerIndex),
+		KeyConditionExpression: aws.String("account_id = :accountID AND #timestamp <= :before"),
+		FilterExpression:       aws.String("currency = :currency"),
+		ProjectionExpression:   aws.String("entry_id, #timestamp, debit, credit"),
+		ExpressionAttributeNames: map[string]string{
+			"#timestamp": "timestamp",
 		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":accountID": &types.AttributeValueMemberS{Value: accountID},
+			":currency":  &types.AttributeValueMemberS{Value: currency},
+			":before":    beforeAV,
+		},
+	}
+
+	var sum int64
+	for {
+		result, err := s.Client.Query(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to query for ledger entries to sum: %w", err)
+		}
+		for _, item := range result.Items {
+			timestamp := stringAttribute(item, "timestamp")
+			if timestamp == bound && stringAttribute(item, "entry_id") >= entryID {
+				continue
+			}
+			var amounts struct {
+				Debit  int64 `dynamodbav:"debit"`
+				Credit int64 `dynamodbav:"credit"`
+			}
+			if err := attributevalue.UnmarshalMap(item, &amounts); err != nil {
+				return 0, fmt.Errorf("failed to unmarshal ledger entry: %w", err)
+			}
+			sum += amounts.Credit - amounts.Debit
+		}
+		if result.LastEvaluatedKey == nil {
+			return sum, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func (s *Store) ListTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	transactions, next, err := s.listByParticipant(ctx, fromUserIDIndex, "from_user_id", userID, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for transactions by user ID: %w", err)
+	}
+	return transactions, next, nil
+}
+
+func (s *Store) ListIncomingTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	transactions, next, err := s.listByParticipant(ctx, toUserIDIndex, "to_user_id", userID, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for incoming transactions by user ID: %w", err)
+	}
+	return transactions, next, nil
+}
+
+// ListActivityByUserID queries the sent and received indexes from the same position and merges
+// them. Its cursor is the (created_at, id) position of the last transaction returned, from which
+// the exclusive start key of either index can be rebuilt.
+func (s *Store) ListActivityByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	var after map[string]string
+	if page.Cursor != "" {
+		if err := storage.DecodeCursor(page.Cursor, &after); err != nil {
+			return nil, "", err
+		}
+		if len(after) != 2 || after["id"] == "" || after["created_at"] == "" {
+			return nil, "", fmt.Errorf("%w: unexpected key attributes", storage.ErrInvalidCursor)
+		}
+	}
+
+	// Each side asks for one more item than the page size, so together they hold the whole page
+	// and tell whether another follows. A page of at most 101 transactions stays far below the
+	// 1 MB response limit, so neither side stops short.
+	var items []map[string]types.AttributeValue
+	seen := make(map[string]bool)
+	for _, side := range []struct{ index, attribute string }{
+		{fromUserIDIndex, "from_user_id"},
+		{toUserIDIndex, "to_user_id"},
+	} {
+		input := participantQuery(s.TransactionsTableName, side.index, side.attribute, userID)
+		size := page.PageSize() + 1
+		input.Limit = &size
+		if after != nil {
+			input.ExclusiveStartKey = map[string]types.AttributeValue{
+				"id":           &types.AttributeValueMemberS{Value: after["id"]},
+				side.attribute: &types.AttributeValueMemberS{Value: userID},
+				"created_at":   &types.AttributeValueMemberS{Value: after["created_at"]},
+			}
+		}
+
+		result, err := s.Client.Query(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to query for activity by user ID: %w", err)
+		}
+		for _, item := range result.Items {
+			// A transfer to oneself is returned by both indexes.
+			id := stringAttribute(item, "id")
+			if !seen[id] {
+				seen[id] = true
+				items = append(items, item)
+			}
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		ci, cj := stringAttribute(items[i], "created_at"), stringAttribute(items[j], "created_at")
+		if ci != cj {
+			return ci > cj
+		}
+		return stringAttribute(items[i], "id") > stringAttribute(items[j], "id")
+	})
+	var next string
+	if size := int(page.PageSize()); len(items) > size {
+		items = items[:size]
+		cursor, err := storage.EncodeCursor(map[string]string{
+			"id":         stringAttribute(items[size-1], "id"),
+			"created_at": stringAttribute(items[size-1], "created_at"),
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to build activity cursor: %w", err)
+		}
+		next = cursor
+	}
+
+	var transactions []models.Transaction
+	if err := attributevalue.UnmarshalListOfMaps(items, &transactions); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal transactions: %w", err)
+	}
+
+	return transactions, next, nil
+}
+
+// listByParticipant queries index, keyed by attribute, for the transactions of userID, newest first.
+func (s *Store) listByParticipant(ctx context.Context, index, attribute, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	input := participantQuery(s.TransactionsTableName, index, attribute, userID)
+	keyAttributes := []string{"id", attribute, "created_at"}
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
 	}
 
 	// Execute the query.
 	result, err := s.Client.Query(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query for transactions by user ID: %w", err)
+		return nil, "", err
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build transactions cursor: %w", err)
 	}
 
 	// Unmarshal the results.
 	var transactions []models.Transaction
-	if err := attributevalue.UnmarshalListOfMaps(result.Items, &transactions); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal transactions: %w", err)
+	if err := attributevalue.UnmarshalListOfMaps(items, &transactions); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal transactions: %w", err)
 	}
 
-	return transactions, nil
+	return transactions, next, nil
+}
+
+// participantQuery prepares a query of index for the transactions whose attribute is userID, newest first.
+func participantQuery(tableName, index, attribute, userID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("#participant = :userID"),
+		ExpressionAttributeNames: map[string]string{
+			"#participant": attribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+}
+
+// stringAttribute returns the value of a string attribute of item, or "" if it is not a string.
+func stringAttribute(item map[string]types.AttributeValue, name string) string {
+	if value, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return value.Value
+	}
+	return ""
 }
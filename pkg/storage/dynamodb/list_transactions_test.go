@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/mocks"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -31,10 +32,11 @@ func TestGetStuckTransactions(t *testing.T) {
 		}
 		mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: stuckTxsAV}, nil)
 
-		result, err := store.GetStuckTransactions(context.Background(), time.Minute)
+		result, next, err := store.GetStuckTransactions(context.Background(), models.RESERVED, time.Minute, storage.PageRequest{})
 
 		assert.NoError(t, err)
 		assert.Equal(t, stuckTxs, result)
+		assert.Empty(t, next)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -44,7 +46,7 @@ func TestGetStuckTransactions(t *testing.T) {
 
 		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
 
-		_, err := store.GetStuckTransactions(context.Background(), time.Minute)
+		_, _, err := store.GetStuckTransactions(context.Background(), models.RESERVED, time.Minute, storage.PageRequest{})
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to query for stuck transactions")
@@ -68,10 +70,11 @@ func TestListTransactionsByUserID(t *testing.T) {
 		}
 		mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: txsAV}, nil)
 
-		result, err := store.ListTransactionsByUserID(context.Background(), userID)
+		result, next, err := store.ListTransactionsByUserID(context.Background(), userID, storage.PageRequest{})
 
 		assert.NoError(t, err)
 		assert.Equal(t, txs, result)
+		assert.Empty(t, next)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -81,7 +84,7 @@ func TestListTransactionsByUserID(t *testing.T) {
 
 		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
 
-		_, err := store.ListTransactionsByUserID(context.Background(), userID)
+		_, _, err := store.ListTransactionsByUserID(context.Background(), userID, storage.PageRequest{})
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to query for transactions by user ID")
@@ -89,6 +92,86 @@ func TestListTransactionsByUserID(t *testing.T) {
 	})
 }
 
+func TestListIncomingTransactionsByUserID(t *testing.T) {
+	userID := "test-user"
+	txs := []models.Transaction{{Id: uuid.New().String()}, {Id: uuid.New().String()}}
+
+	t.Run("Success", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, TransactionsTableName: "transactions"}
+
+		var txsAV []map[string]types.AttributeValue
+		for _, tx := range txs {
+			av, err := attributevalue.MarshalMap(tx)
+			assert.NoError(t, err)
+			txsAV = append(txsAV, av)
+		}
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: txsAV}, nil)
+
+		result, next, err := store.ListIncomingTransactionsByUserID(context.Background(), userID, storage.PageRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, txs, result)
+		assert.Empty(t, next)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Storage Error", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, TransactionsTableName: "transactions"}
+
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
+
+		_, _, err := store.ListIncomingTransactionsByUserID(context.Background(), userID, storage.PageRequest{})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to query for incoming transactions by user ID")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestListActivityByUserID(t *testing.T) {
+	userID := "test-user"
+	now := time.Now().UTC()
+	sent := models.Transaction{Id: uuid.New().String(), FromUserId: userID, CreatedAt: now.Add(-time.Minute)}
+	received := models.Transaction{Id: uuid.New().String(), ToUserId: userID, CreatedAt: now}
+
+	t.Run("Success", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, TransactionsTableName: "transactions"}
+
+		onIndex := func(index string, tx models.Transaction) {
+			av, err := attributevalue.MarshalMap(tx)
+			assert.NoError(t, err)
+			mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+				return *input.IndexName == index
+			})).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{av}}, nil)
+		}
+		onIndex(fromUserIDIndex, sent)
+		onIndex(toUserIDIndex, received)
+
+		result, next, err := store.ListActivityByUserID(context.Background(), userID, storage.PageRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Transaction{received, sent}, result, "sent and received transactions must be merged newest first")
+		assert.Empty(t, next)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Storage Error", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, TransactionsTableName: "transactions"}
+
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
+
+		_, _, err := store.ListActivityByUserID(context.Background(), userID, storage.PageRequest{})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to query for activity by user ID")
+		mockClient.AssertExpectations(t)
+	})
+}
+
 func TestListLedgerEntries(t *testing.T) {
 	entries := []models.LedgerEntry{{EntryID: uuid.New().String()}, {EntryID: uuid.New().String()}}
 
@@ -104,10 +187,11 @@ func TestListLedgerEntries(t *testing.T) {
 		}
 		mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: entriesAV}, nil)
 
-		result, err := store.ListLedgerEntries(context.Background(), 2)
+		result, next, err := store.ListLedgerEntries(context.Background(), storage.PageRequest{Limit: 2})
 
 		assert.NoError(t, err)
 		assert.Equal(t, entries, result)
+		assert.Empty(t, next)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -117,10 +201,104 @@ func TestListLedgerEntries(t *testing.T) {
 
 		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
 
-		_, err := store.ListLedgerEntries(context.Background(), 2)
+		_, _, err := store.ListLedgerEntries(context.Background(), storage.PageRequest{Limit: 2})
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to query for ledger entries")
 		mockClient.AssertExpectations(t)
 	})
 }
+
+func TestListLedgerEntriesByAccount(t *testing.T) {
+	from, to := time.Now().Add(-time.Hour).UTC(), time.Now().UTC()
+	entries := []models.LedgerEntry{
+		{EntryID: uuid.New().String(), AccountID: "user1", Credit: 10, Timestamp: from},
+		{EntryID: uuid.New().String(), AccountID: "user1", Debit: 5, Timestamp: from.Add(time.Minute)},
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, LedgerTableName: "ledger"}
+
+		var entriesAV []map[string]types.AttributeValue
+		for _, entry := range append(entries, models.LedgerEntry{EntryID: uuid.New().String(), AccountID: "user1", Timestamp: to}) {
+			av, err := attributevalue.MarshalMap(entry)
+			assert.NoError(t, err)
+			entriesAV = append(entriesAV, av)
+		}
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: entriesAV}, nil)
+
+		result, next, err := store.ListLedgerEntriesByAccount(context.Background(), "user1", "USD", from, to, storage.PageRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, entries, result, "entries at the end of the range must be excluded")
+		assert.Empty(t, next)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Storage Error", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, LedgerTableName: "ledger"}
+
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
+
+		_, _, err := store.ListLedgerEntriesByAccount(context.Background(), "user1", "USD", from, to, storage.PageRequest{})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to query for ledger entries by account")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestSumLedgerEntries(t *testing.T) {
+	before := time.Now().UTC()
+
+	t.Run("Success", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, LedgerTableName: "ledger"}
+
+		page := func(entries ...models.LedgerEntry) []map[string]types.AttributeValue {
+			var items []map[string]types.AttributeValue
+			for _, entry := range entries {
+				av, err := attributevalue.MarshalMap(entry)
+				assert.NoError(t, err)
+				items = append(items, av)
+			}
+			return items
+		}
+		lastKey := map[string]types.AttributeValue{"entry_id": &types.AttributeValueMemberS{Value: "a"}}
+		mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey == nil
+		})).Return(&dynamodb.QueryOutput{
+			Items:            page(models.LedgerEntry{EntryID: "a", Credit: 30, Timestamp: before.Add(-time.Hour)}),
+			LastEvaluatedKey: lastKey,
+		}, nil).Once()
+		mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey != nil
+		})).Return(&dynamodb.QueryOutput{
+			Items: page(
+				models.LedgerEntry{EntryID: "b", Debit: 10, Timestamp: before.Add(-time.Minute)},
+				models.LedgerEntry{EntryID: "c", Debit: 5, Timestamp: before},
+			),
+		}, nil).Once()
+
+		sum, err := store.SumLedgerEntries(context.Background(), "user1", "USD", before, "c")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(20), sum, "every page must be summed, excluding the entry at the bound")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Storage Error", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		store := &Store{Client: mockClient, LedgerTableName: "ledger"}
+
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("query failed"))
+
+		_, err := store.SumLedgerEntries(context.Background(), "user1", "USD", before, "")
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to query for ledger entries to sum")
+		mockClient.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,66 @@
+package dynamodb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// paginate prepares a query for one page. It decodes the page's cursor into the query's
+// ExclusiveStartKey, checking that it names exactly keyAttributes (the table and index key
+// attributes), and asks for one item more than the page size so a full last page does not
+// produce a cursor to an empty page.
+func paginate(input *dynamodb.QueryInput, page storage.PageRequest, keyAttributes ...string) error {
+	size := page.PageSize() + 1
+	input.Limit = &size
+	if page.Cursor == "" {
+		return nil
+	}
+
+	var key map[string]string
+	if err := storage.DecodeCursor(page.Cursor, &key); err != nil {
+		return err
+	}
+	if len(key) != len(keyAttributes) {
+		return fmt.Errorf("%w: unexpected key attributes", storage.ErrInvalidCursor)
+	}
+	input.ExclusiveStartKey = make(map[string]types.AttributeValue, len(key))
+	for _, name := range keyAttributes {
+		value, ok := key[name]
+		if !ok {
+			return fmt.Errorf("%w: missing key attribute %s", storage.ErrInvalidCursor, name)
+		}
+		input.ExclusiveStartKey[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return nil
+}
+
+// nextPage trims the items of a query prepared by paginate to the page size and returns the
+// cursor for the following page, or an empty cursor if there is none. DynamoDB may stop
+// early at its 1 MB response limit, in which case its LastEvaluatedKey becomes the cursor.
+func nextPage(result *dynamodb.QueryOutput, page storage.PageRequest, keyAttributes ...string) ([]map[string]types.AttributeValue, string, error) {
+	items, last := result.Items, result.LastEvaluatedKey
+	if size := int(page.PageSize()); len(items) > size {
+		items = items[:size]
+		last = items[size-1]
+	}
+	if last == nil {
+		return items, "", nil
+	}
+
+	key := make(map[string]string, len(keyAttributes))
+	for _, name := range keyAttributes {
+		value, ok := last[name].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, "", fmt.Errorf("key attribute %s is not a string", name)
+		}
+		key[name] = value.Value
+	}
+	next, err := storage.EncodeCursor(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
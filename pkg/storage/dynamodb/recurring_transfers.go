@@ -0,0 +1,166 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	recurringFromUserIDIndex = "from_user_id-created_at-index"
+	// recurringDueIndex is sparse: only active transfers have a next_run_at.
+	recurringDueIndex = "status-next_run_at-index"
+)
+
+// CreateRecurringTransfer stores a new recurring transfer in DynamoDB.
+func (s *Store) CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	now := time.Now().UTC()
+	rt.Id = uuid.New().String()
+	rt.Version = 1
+	rt.CreatedAt = now
+	rt.UpdatedAt = now
+
+	item, err := attributevalue.MarshalMap(rt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recurring transfer: %w", err)
+	}
+	_, err = s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.RecurringTransfersTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recurring transfer in DynamoDB: %w", err)
+	}
+
+	return rt, nil
+}
+
+// GetRecurringTransfer retrieves a recurring transfer from DynamoDB by its ID.
+func (s *Store) GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error) {
+	result, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.RecurringTransfersTableName),
+		Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recurring transfer from DynamoDB: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: ID %s", storage.ErrRecurringTransferNotFound, id)
+	}
+
+	var rt models.RecurringTransfer
+	if err := attributevalue.UnmarshalMap(result.Item, &rt); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recurring transfer: %w", err)
+	}
+
+	return &rt, nil
+}
+
+// ListRecurringTransfersByUserID retrieves a page of the recurring transfers sent by a specific user, newest first.
+func (s *Store) ListRecurringTransfersByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.RecurringTransfersTableName),
+		IndexName:              aws.String(recurringFromUserIDIndex),
+		KeyConditionExpression: aws.String("from_user_id = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	keyAttributes := []string{"id", "from_user_id", "created_at"}
+
+	return s.queryRecurringTransfers(ctx, input, page, keyAttributes)
+}
+
+// ListDueRecurringTransfers retrieves a page of the active recurring transfers due at or before the given time, earliest first.
+func (s *Store) ListDueRecurringTransfers(ctx context.Context, before time.Time, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	beforeAV, err := attributevalue.Marshal(before.UTC())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal due time: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.RecurringTransfersTableName),
+		IndexName:              aws.String(recurringDueIndex),
+		KeyConditionExpression: aws.String("#status = :status AND next_run_at <= :before"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: string(models.RecurringActive)},
+			":before": beforeAV,
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+	keyAttributes := []string{"id", "status", "next_run_at"}
+
+	return s.queryRecurringTransfers(ctx, input, page, keyAttributes)
+}
+
+func (s *Store) queryRecurringTransfers(ctx context.Context, input *dynamodb.QueryInput, page storage.PageRequest, keyAttributes []string) ([]models.RecurringTransfer, string, error) {
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for recurring transfers: %w", err)
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build recurring transfers cursor: %w", err)
+	}
+
+	var transfers []models.RecurringTransfer
+	if err := attributevalue.UnmarshalListOfMaps(items, &transfers); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal recurring transfers: %w", err)
+	}
+
+	return transfers, next, nil
+}
+
+// UpdateRecurringTransfer replaces a recurring transfer if it still has the version that was read.
+func (s *Store) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	updated := *rt
+	updated.Version++
+	updated.UpdatedAt = time.Now().UTC()
+	item, err := attributevalue.MarshalMap(&updated)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recurring transfer: %w", err)
+	}
+
+	_, err = s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.RecurringTransfersTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(id) AND #version = :version"),
+		ExpressionAttributeNames: map[string]string{
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: fmt.Sprint(rt.Version)},
+		},
+	})
+	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			if _, err := s.GetRecurringTransfer(ctx, rt.Id); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: recurring transfer %s", storage.ErrVersionConflict, rt.Id)
+		}
+		return fmt.Errorf("failed to update recurring transfer in DynamoDB: %w", err)
+	}
+
+	*rt = updated
+	return nil
+}
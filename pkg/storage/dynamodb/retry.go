@@ -0,0 +1,48 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Writes guarded by a wallet's version are retried a bounded number of times when
+// another writer got there first, with exponential backoff and full jitter so that
+// competing writers spread out instead of colliding again.
+const (
+	maxConflictAttempts = 5
+	conflictBaseDelay   = 10 * time.Millisecond
+	conflictMaxDelay    = 200 * time.Millisecond
+)
+
+// retryOnConflict calls attempt until it returns anything other than a storage.ErrVersionConflict
+// or maxConflictAttempts is reached, in which case the last conflict is returned. Each attempt must
+// re-read the state it writes against.
+func retryOnConflict(ctx context.Context, attempt func() error) error {
+	var err error
+	for i := range maxConflictAttempts {
+		if i > 0 {
+			timer := time.NewTimer(conflictBackoff(i))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+		err = attempt()
+		if !errors.Is(err, storage.ErrVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// conflictBackoff returns a random delay below conflictBaseDelay * 2^(retry-1), capped at conflictMaxDelay.
+func conflictBackoff(retry int) time.Duration {
+	ceiling := min(conflictBaseDelay<<(retry-1), conflictMaxDelay)
+	return rand.N(ceiling)
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/google/uuid"
@@ -17,13 +21,15 @@ import (
 
 // SettleTransaction performs the final atomic settlement of a transaction.
 // It uses a two-step process to ensure idempotency.
-// 1. Attempt to acquire a lock by setting the transaction status to WORKING.
-// 2. If the lock is acquired, proceed with the settlement.
-// This prevents a transaction from being processed multiple times if the lambda is invoked more than once for the same SQS message.
-func (s *Store) SettleTransaction(ctx context.Context, tx *models.Transaction) (bool, error) {
+// 1. Attempt to acquire a lock by setting the transaction status to WORKING, leased to owner.
+// 2. If the lock is acquired, proceed with the settlement, provided owner still holds the lease.
+// This prevents a transaction from being processed multiple times if the lambda is invoked more than once for the same SQS message,
+// while a transaction left WORKING by a lambda that crashed is taken over once its lease expires.
+func (s *Store) SettleTransaction(ctx context.Context, tx *models.Transaction, owner string) (bool, error) {
 	// Step 1: Attempt to acquire a lock on the transaction by setting its status to WORKING.
-	// This is an atomic operation that will only succeed if the current status is RESERVED.
-	if err := s.acquireTransactionLock(ctx, tx.Id); err != nil {
+	// This is an atomic operation that will only succeed if the current status is RESERVED or APPROVED,
+	// or if it is WORKING and the lease of the previous owner has expired.
+	if err := s.acquireTransactionLock(ctx, tx.Id, owner, storage.LeaseExpiry(ctx, time.Now())); err != nil {
 		if errors.Is(err, storage.ErrTransactionAlreadyProcessing) {
 			// Another process has already acquired the lock, or the transaction is in a non-processable state (e.g. cancelled).
 			// In either case, the settlement was not performed by this invocation, so we return false.
@@ -32,36 +38,77 @@ func (s *Store) SettleTransaction(ctx context.Context, tx *models.Transaction) (
 		return false, fmt.Errorf("failed to acquire transaction lock: %w", err)
 	}
 
-	// Step 2: Proceed with the settlement logic.
-	if err := s.executeSettlement(ctx, tx); err != nil {
+	// Step 2: Proceed with the settlement logic, retrying if either wallet changes while we hold the lock.
+	err := retryOnConflict(ctx, func() error { return s.executeSettlement(ctx, tx, owner) })
+	if err != nil && storage.IsPermanentSettlementError(err) {
+		// Step 3: The transaction can never be settled, so give the sender their funds back.
+		err = s.failSettlement(ctx, tx.Id, owner, err)
+	}
+	if errors.Is(err, storage.ErrLeaseLost) {
+		// Our lease expired and another process took the transaction over; it settles it instead.
+		return false, nil
+	}
+	if err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
-// acquireTransactionLock atomically updates the transaction status from RESERVED to WORKING.
-func (s *Store) acquireTransactionLock(ctx context.Context, txID string) error {
+// failSettlement atomically moves a transaction that can never be settled from WORKING to FAILED and
+// releases its reserved funds back to the sender, retrying if the sender's wallet changes. It returns
+// the *SettlementFailedError that reports it.
+func (s *Store) failSettlement(ctx context.Context, txID, owner string, cause error) error {
+	reason := storage.FailureReason(cause)
+	if err := retryOnConflict(ctx, func() error {
+		return s.releaseReservation(ctx, txID, models.FAILED, reason, owner, storage.ErrTransactionNotProcessable, "failure")
+	}); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to release reserved funds of failed settlement: %w", err))
+	}
+	return &storage.SettlementFailedError{TransactionID: txID, Reason: reason, Err: cause}
+}
+
+// acquireTransactionLock atomically updates the transaction status from RESERVED or APPROVED to WORKING,
+// provided the transaction is due, and leases it to owner until expiresAt. Transactions created before
+// due times were recorded have no due_at and are always due. A WORKING transaction whose lease has
+// expired, or that was locked before leases were recorded, is taken over.
+func (s *Store) acquireTransactionLock(ctx context.Context, txID, owner string, expiresAt time.Time) error {
+	now := time.Now()
+	condition, values := lifecycle.Condition(models.WORKING)
+	takeover, takeoverValues := lifecycle.TakeoverCondition(models.WORKING)
+	maps.Copy(values, takeoverValues)
+	values[":working_status"] = &types.AttributeValueMemberS{Value: string(models.WORKING)}
+	values[":now"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)}
+	values[":owner"] = &types.AttributeValueMemberS{Value: owner}
+	values[":lease_expires_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)}
 	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String(s.TransactionsTableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: txID},
 		},
-		UpdateExpression:    aws.String("SET #status = :working_status"),
-		ConditionExpression: aws.String("#status = :reserved_status"),
+		UpdateExpression: aws.String("SET #status = :working_status, lease_owner = :owner, lease_expires_at = :lease_expires_at"),
+		ConditionExpression: aws.String("(" + condition + " AND (attribute_not_exists(due_at) OR due_at <= :now)) OR (" + takeover + ")"),
 		ExpressionAttributeNames: map[string]string{
 			"#status": "status",
 		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":working_status":  &types.AttributeValueMemberS{Value: string(models.WORKING)},
-			":reserved_status": &types.AttributeValueMemberS{Value: string(models.RESERVED)},
-		},
+		ExpressionAttributeValues: values,
+		// The current item tells a transaction that is not due yet apart from one already processed.
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
 
 	_, err := s.Client.UpdateItem(ctx, input)
 	if err != nil {
 		var condCheckFailed *types.ConditionalCheckFailedException
 		if errors.As(err, &condCheckFailed) {
+			var current models.Transaction
+			if condCheckFailed.Item != nil && attributevalue.UnmarshalMap(condCheckFailed.Item, &current) == nil {
+				if err := storage.CheckDue(&current, now); err != nil {
+					return err
+				}
+				if err := storage.CheckLease(&current, now); err != nil {
+					return err
+				}
+			}
 			return storage.ErrTransactionAlreadyProcessing
 		}
 		return fmt.Errorf("failed to update transaction status to WORKING: %w", err)
@@ -70,8 +117,9 @@ func (s *Store) acquireTransactionLock(ctx context.Context, txID string) error {
 	return nil
 }
 
-// executeSettlement performs the actual financial settlement of the transaction.
-func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) error {
+// executeSettlement performs the actual financial settlement of the transaction, provided owner
+// still holds its lease.
+func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction, owner string) error {
 	// 1. Get the current state of both wallets for optimistic locking.
 	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
 	if err != nil {
@@ -81,6 +129,15 @@ func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) e
 	if err != nil {
 		return fmt.Errorf("failed to get receiver's wallet for settlement: %w", err)
 	}
+	for _, wallet := range []*models.Wallet{senderWallet, receiverWallet} {
+		if _, err := storage.HeldBalance(wallet, tx.Currency); err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+	}
+	// The write checks this again, but a failed condition cannot tell it apart from a version conflict.
+	if senderWallet.Balances[tx.Currency].Reserved < tx.Amount {
+		return fmt.Errorf("failed to execute settlement transaction: %w: user ID %s", storage.ErrInsufficientReservedFunds, tx.FromUserId)
+	}
 
 	// 2. Prepare common values.
 	now := time.Now()
@@ -89,11 +146,12 @@ func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) e
 		return fmt.Errorf("failed to marshal amount for settlement: %w", err)
 	}
 
-	// 3. Prepare ledger entries.
+	// 3. Prepare ledger entries, linked into the ledger chains of both accounts.
 	debitEntry := models.LedgerEntry{
 		TransactionID: tx.Id,
 		EntryID:       uuid.New().String(),
 		AccountID:     tx.FromUserId,
+		Currency:      tx.Currency,
 		Debit:         tx.Amount,
 		Description:   fmt.Sprintf("Settlement for transaction %s", tx.Id),
 		Timestamp:     now,
@@ -103,18 +161,15 @@ func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) e
 		TransactionID: tx.Id,
 		EntryID:       uuid.New().String(),
 		AccountID:     tx.ToUserId,
+		Currency:      tx.Currency,
 		Credit:        tx.Amount,
 		Description:   fmt.Sprintf("Settlement for transaction %s", tx.Id),
 		Timestamp:     now,
 		GSI1PK:        "LEDGER_ENTRIES",
 	}
-	debitAV, err := attributevalue.MarshalMap(debitEntry)
+	entryItems, headItems, err := s.ledgerItems(ctx, []models.LedgerEntry{debitEntry, creditEntry})
 	if err != nil {
-		return fmt.Errorf("failed to marshal debit entry: %w", err)
-	}
-	creditAV, err := attributevalue.MarshalMap(creditEntry)
-	if err != nil {
-		return fmt.Errorf("failed to marshal credit entry: %w", err)
+		return fmt.Errorf("failed to prepare ledger entries: %w", err)
 	}
 
 	// Prepare attribute values for the transaction status update.
@@ -122,14 +177,14 @@ func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) e
 	if err != nil {
 		return fmt.Errorf("failed to marshal completed status: %w", err)
 	}
-	workingStatusAV, err := attributevalue.Marshal(models.WORKING)
-	if err != nil {
-		return fmt.Errorf("failed to marshal working status: %w", err)
-	}
 	nowAV, err := attributevalue.Marshal(now)
 	if err != nil {
 		return fmt.Errorf("failed to marshal timestamp for status update: %w", err)
 	}
+	statusCondition, statusValues := lifecycle.Condition(models.COMPLETED)
+	statusValues[":completed_status"] = completedStatusAV
+	statusValues[":now"] = nowAV
+	statusValues[":owner"] = &types.AttributeValueMemberS{Value: owner}
 
 	// 4. Construct the TransactWriteItems input.
 	input := &dynamodb.TransactWriteItemsInput{
@@ -139,16 +194,15 @@ func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) e
 				Update: &types.Update{
 					TableName: aws.String(s.WalletsTableName),
 					Key: map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: tx.FromUserId}},
-					UpdateExpression:    aws.String("SET reserved = reserved - :amount, version = version + :inc, #ttl = :ttl"),
-					ConditionExpression: aws.String("reserved >= :amount AND version = :version"),
+					UpdateExpression:    aws.String("SET balances.#currency.reserved = balances.#currency.reserved - :amount, version = version + :inc"),
+					ConditionExpression: aws.String("balances.#currency.reserved >= :amount AND version = :version"),
 					ExpressionAttributeNames: map[string]string{
-						"#ttl": "ttl",
+						"#currency": tx.Currency,
 					},
 					ExpressionAttributeValues: map[string]types.AttributeValue{
 						":amount":   amountAV,
 						":version":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", senderWallet.Version)},
 						":inc":      &types.AttributeValueMemberN{Value: "1"},
-						":ttl":      &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Add(24*time.Hour).Unix())},
 					},
 				},
 			},
@@ -157,58 +211,62 @@ func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction) e
 				Update: &types.Update{
 					TableName: aws.String(s.WalletsTableName),
 					Key: map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: tx.ToUserId}},
-					UpdateExpression:    aws.String("SET balance = balance + :amount, version = version + :inc, #ttl = :ttl"),
-					ConditionExpression: aws.String("version = :version"),
+					UpdateExpression:    aws.String("SET balances.#currency.balance = balances.#currency.balance + :amount, version = version + :inc"),
+					ConditionExpression: aws.String("attribute_exists(balances.#currency) AND version = :version"),
 					ExpressionAttributeNames: map[string]string{
-						"#ttl": "ttl",
+						"#currency": tx.Currency,
 					},
 					ExpressionAttributeValues: map[string]types.AttributeValue{
 						":amount":   amountAV,
 						":version":  &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", receiverWallet.Version)},
 						":inc":      &types.AttributeValueMemberN{Value: "1"},
-						":ttl":      &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", time.Now().Add(24*time.Hour).Unix())},
 					},
 				},
 			},
 			{
-				// Operation 3: Create debit ledger entry.
-				Put: &types.Put{
-					TableName:           aws.String(s.LedgerTableName),
-					Item:                debitAV,
-					ConditionExpression: aws.String("attribute_not_exists(entry_id)"),
-				},
-			},
-			{
-				// Operation 4: Create credit ledger entry.
-				Put: &types.Put{
-					TableName:           aws.String(s.LedgerTableName),
-					Item:                creditAV,
-					ConditionExpression: aws.String("attribute_not_exists(entry_id)"),
-				},
-			},
-			{
-				// Operation 5: Update the transaction status to COMPLETED.
+				// Operation 3: Update the transaction status to COMPLETED.
 				Update: &types.Update{
 					TableName: aws.String(s.TransactionsTableName),
 					Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: tx.Id}},
-					UpdateExpression:    aws.String("SET #status = :completed_status, updated_at = :now"),
-					ConditionExpression: aws.String("#status = :working_status"),
+					// A settled transaction has ledger entries, so it must never expire.
+					UpdateExpression:    aws.String("SET #status = :completed_status, updated_at = :now REMOVE #ttl"),
+					ConditionExpression: aws.String(statusCondition + " AND lease_owner = :owner"),
 					ExpressionAttributeNames: map[string]string{
 						"#status": "status",
+						"#ttl":    "ttl",
 					},
-					ExpressionAttributeValues: map[string]types.AttributeValue{
-						":completed_status": completedStatusAV,
-						":working_status":  workingStatusAV,
-						":now":              nowAV,
-					},
+					ExpressionAttributeValues: statusValues,
 				},
 			},
 		},
 	}
 
+	// The transaction status update follows the wallet updates.
+	statusUpdate := 2
+	if tx.FromUserId == tx.ToUserId {
+		// A transaction cannot name the same item twice, so a transfer to oneself moves the funds from
+		// reserved to balance in a single update of the wallet.
+		sender := input.TransactItems[0].Update
+		sender.UpdateExpression = aws.String("SET balances.#currency.reserved = balances.#currency.reserved - :amount, balances.#currency.balance = balances.#currency.balance + :amount, version = version + :inc")
+		input.TransactItems = slices.Delete(input.TransactItems, 1, 2)
+		statusUpdate = 1
+	}
+
+	// Then create the debit and credit ledger entries and advance the ledger chain heads.
+	input.TransactItems = append(input.TransactItems, entryItems...)
+	input.TransactItems = append(input.TransactItems, headItems...)
+
 	// 5. Execute the transaction.
 	_, err = s.Client.TransactWriteItems(ctx, input)
 	if err != nil {
+		if conditionFailed(err, 0) || conditionFailed(err, statusUpdate-1) || conditionFailedFrom(err, len(input.TransactItems)-len(headItems)) {
+			// One of the wallets or ledger chains changed since it was read.
+			return fmt.Errorf("failed to execute settlement transaction: %w: %w", storage.ErrVersionConflict, err)
+		}
+		if conditionFailed(err, statusUpdate) {
+			// Our lease expired and another process took the transaction over.
+			return fmt.Errorf("failed to execute settlement transaction: %w", storage.ErrLeaseLost)
+		}
 		return fmt.Errorf("failed to execute settlement transaction: %w", err)
 	}
 
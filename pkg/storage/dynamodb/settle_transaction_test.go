@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/mocks"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -17,9 +20,9 @@ import (
 
 func TestSettleTransaction(t *testing.T) {
 	txID := uuid.New().String()
-	tx := &models.Transaction{Id: txID, FromUserId: "user1", ToUserId: "user2", Amount: 100, Status: models.RESERVED}
-	senderWallet := &models.Wallet{UserId: "user1", Balance: 100, Reserved: 100, Version: 1}
-	receiverWallet := &models.Wallet{UserId: "user2", Balance: 50, Version: 1}
+	tx := &models.Transaction{Id: txID, FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", Status: models.RESERVED}
+	senderWallet := &models.Wallet{UserId: "user1", Balances: map[string]models.CurrencyBalance{"USD": {Balance: 100, Reserved: 100}}, Version: 1}
+	receiverWallet := &models.Wallet{UserId: "user2", Balances: usd(50), Version: 1}
 
 	t.Run("Success", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
@@ -34,10 +37,13 @@ func TestSettleTransaction(t *testing.T) {
 		receiverWalletAV, _ := attributevalue.MarshalMap(receiverWallet)
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: receiverWalletAV}, nil).Once()
 
+		// Mock the ledger chain head reads; both chains are still empty.
+		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil).Twice()
+
 		// Mock TransactWriteItems call for settlement
 		mockClient.On("TransactWriteItems", mock.Anything, mock.AnythingOfType("*dynamodb.TransactWriteItemsInput")).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
 
-		settled, err := store.SettleTransaction(context.Background(), tx)
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
 
 		assert.NoError(t, err)
 		assert.True(t, settled)
@@ -52,7 +58,7 @@ func TestSettleTransaction(t *testing.T) {
 		mockClient.On("UpdateItem", mock.Anything, mock.AnythingOfType("*dynamodb.UpdateItemInput")).Return(&dynamodb.UpdateItemOutput{}, nil).Once()
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(nil, errors.New("get wallet failed"))
 
-		settled, err := store.SettleTransaction(context.Background(), tx)
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
 
 		assert.Error(t, err)
 		assert.False(t, settled)
@@ -69,7 +75,7 @@ func TestSettleTransaction(t *testing.T) {
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(&dynamodb.GetItemOutput{Item: senderWalletAV}, nil)
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(nil, errors.New("get wallet failed"))
 
-		settled, err := store.SettleTransaction(context.Background(), tx)
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
 
 		assert.Error(t, err)
 		assert.False(t, settled)
@@ -86,10 +92,11 @@ func TestSettleTransaction(t *testing.T) {
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(&dynamodb.GetItemOutput{Item: senderWalletAV}, nil)
 		receiverWalletAV, _ := attributevalue.MarshalMap(receiverWallet)
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Once().Return(&dynamodb.GetItemOutput{Item: receiverWalletAV}, nil)
+		mockClient.On("GetItem", mock.Anything, mock.Anything).Twice().Return(&dynamodb.GetItemOutput{}, nil)
 
 		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Return(nil, errors.New("transaction failed"))
 
-		settled, err := store.SettleTransaction(context.Background(), tx)
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
 
 		assert.Error(t, err)
 		assert.False(t, settled)
@@ -104,10 +111,158 @@ func TestSettleTransaction(t *testing.T) {
 		// Mock UpdateItem call to fail with a conditional check failed exception
 		mockClient.On("UpdateItem", mock.Anything, mock.AnythingOfType("*dynamodb.UpdateItemInput")).Return(nil, &types.ConditionalCheckFailedException{}).Once()
 
-		settled, err := store.SettleTransaction(context.Background(), tx)
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
 
 		assert.NoError(t, err)
 		assert.False(t, settled)
 		mockClient.AssertExpectations(t)
 	})
 }
+
+func TestExecuteSettlementIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+	_, err := store.CreateWallet(ctx, &models.Wallet{UserId: "user1", Balances: usd(100), Version: 1})
+	assert.NoError(t, err)
+	_, err = store.CreateWallet(ctx, &models.Wallet{UserId: "user2", Balances: usd(50), Version: 1})
+	assert.NoError(t, err)
+	tx, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.NoError(t, store.acquireTransactionLock(ctx, tx.Id, "worker-1", time.Now().Add(storage.SettlementLease)))
+
+	// Bump the receiver's version between the wallet read and the write, as a concurrent writer would.
+	staleStore := *store
+	staleStore.Client = &bumpVersionOnTransact{DynamoDBAPI: store.Client, store: store, userID: "user2"}
+
+	err = staleStore.executeSettlement(ctx, tx, "worker-1")
+
+	var tce *types.TransactionCanceledException
+	assert.ErrorAs(t, err, &tce)
+	if assert.Len(t, tce.CancellationReasons, 7, "two wallets, the status, two ledger entries and two chain heads") {
+		assert.Equal(t, "None", *tce.CancellationReasons[0].Code)
+		assert.Equal(t, "ConditionalCheckFailed", *tce.CancellationReasons[1].Code)
+	}
+
+	sender, err := store.GetWallet(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(40), sender.Balances["USD"].Reserved, "the sender's reservation must be untouched")
+	receiver, err := store.GetWallet(ctx, "user2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), receiver.Balances["USD"].Balance)
+	entries, _, err := store.ListLedgerEntries(ctx, storage.PageRequest{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4, "a cancelled settlement must not add to the opening deposits")
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WORKING, stored.Status)
+}
+
+func TestSettleTransactionRetriesVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+	_, err := store.CreateWallet(ctx, &models.Wallet{UserId: "user1", Balances: usd(100), Version: 1})
+	assert.NoError(t, err)
+	_, err = store.CreateWallet(ctx, &models.Wallet{UserId: "user2", Balances: usd(50), Version: 1})
+	assert.NoError(t, err)
+	tx, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD"})
+	assert.NoError(t, err)
+
+	// A concurrent writer changes the receiver's wallet during the first settlement attempt only.
+	busyStore := *store
+	busyStore.Client = &bumpVersionOnTransact{DynamoDBAPI: store.Client, store: store, userID: "user2", once: true}
+
+	settled, err := busyStore.SettleTransaction(ctx, tx, "worker-1")
+
+	assert.NoError(t, err)
+	assert.True(t, settled)
+	receiver, err := store.GetWallet(ctx, "user2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(90), receiver.Balances["USD"].Balance)
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.COMPLETED, stored.Status)
+}
+
+func TestSettleTransactionLease(t *testing.T) {
+	newStore := func(t *testing.T) (*Store, *models.Transaction) {
+		store := newFakeStore(t)
+		_, err := store.CreateWallet(context.Background(), &models.Wallet{UserId: "user1", Balances: usd(100), Version: 1})
+		assert.NoError(t, err)
+		_, err = store.CreateWallet(context.Background(), &models.Wallet{UserId: "user2", Balances: usd(50), Version: 1})
+		assert.NoError(t, err)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD"})
+		assert.NoError(t, err)
+		return store, tx
+	}
+
+	t.Run("Expired Lease Is Taken Over", func(t *testing.T) {
+		store, tx := newStore(t)
+		assert.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "crashed", time.Now().Add(-time.Minute)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.COMPLETED, stored.Status)
+		assert.Equal(t, "worker-2", stored.LeaseOwner)
+	})
+
+	t.Run("Live Lease Is Kept", func(t *testing.T) {
+		store, tx := newStore(t)
+		assert.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-1", time.Now().Add(storage.SettlementLease)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.ErrorIs(t, err, storage.ErrSettlementInProgress, "the message is retried once the lease has expired")
+		assert.False(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+		assert.Equal(t, "worker-1", stored.LeaseOwner)
+	})
+
+	t.Run("Lost Lease Settles Nothing", func(t *testing.T) {
+		store, tx := newStore(t)
+		assert.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-1", time.Now().Add(-time.Minute)))
+		assert.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-2", time.Now().Add(storage.SettlementLease)))
+
+		err := store.executeSettlement(context.Background(), tx, "worker-1")
+
+		assert.ErrorIs(t, err, storage.ErrLeaseLost)
+		receiver, err := store.GetWallet(context.Background(), "user2")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(50), receiver.Balances["USD"].Balance)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+	})
+}
+
+// bumpVersionOnTransact increments a wallet's version just before forwarding TransactWriteItems,
+// on every call or, if once is set, on the first call only.
+type bumpVersionOnTransact struct {
+	DynamoDBAPI
+	store  *Store
+	userID string
+	once   bool
+	bumped bool
+}
+
+func (b *bumpVersionOnTransact) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if b.once && b.bumped {
+		return b.DynamoDBAPI.TransactWriteItems(ctx, params, optFns...)
+	}
+	b.bumped = true
+	_, err := b.DynamoDBAPI.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &b.store.WalletsTableName,
+		Key:                       map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: b.userID}},
+		UpdateExpression:          aws.String("SET version = version + :inc"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":inc": &types.AttributeValueMemberN{Value: "1"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.DynamoDBAPI.TransactWriteItems(ctx, params, optFns...)
+}
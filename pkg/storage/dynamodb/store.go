@@ -24,16 +24,20 @@ type Store struct {
 	WalletsTableName              string
 	LedgerTableName               string
 	WebsocketConnectionsTableName string
+	IdempotencyTableName          string
+	RecurringTransfersTableName   string
 }
 
 // New creates a new Store with all table dependencies.
-func New(client DynamoDBAPI, transactionsTable, walletsTable, ledgerTable, websocketConnectionsTable string) *Store {
+func New(client DynamoDBAPI, transactionsTable, walletsTable, ledgerTable, websocketConnectionsTable, idempotencyTable, recurringTransfersTable string) *Store {
 	return &Store{
 		Client:                client,
 		TransactionsTableName: transactionsTable,
 		WalletsTableName:      walletsTable,
 		LedgerTableName:             ledgerTable,
 		WebsocketConnectionsTableName: websocketConnectionsTable,
+		IdempotencyTableName:          idempotencyTable,
+		RecurringTransfersTableName:   recurringTransfersTable,
 	}
 }
 
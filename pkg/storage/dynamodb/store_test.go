@@ -0,0 +1,101 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/dynamodbtest"
+	"github.com/stretchr/testify/require"
+)
+
+var _ DynamoDBAPI = (*dynamodbtest.Client)(nil)
+
+// newFakeStore returns a Store backed by an in-memory client whose tables mirror template.yaml.
+func newFakeStore(t *testing.T) *Store {
+	t.Helper()
+	client := dynamodbtest.New()
+	stringAttrs := func(names ...string) []types.AttributeDefinition {
+		defs := make([]types.AttributeDefinition, len(names))
+		for i, name := range names {
+			defs[i] = types.AttributeDefinition{AttributeName: aws.String(name), AttributeType: types.ScalarAttributeTypeS}
+		}
+		return defs
+	}
+	keySchema := func(hash, rng string) []types.KeySchemaElement {
+		schema := []types.KeySchemaElement{{AttributeName: aws.String(hash), KeyType: types.KeyTypeHash}}
+		if rng != "" {
+			schema = append(schema, types.KeySchemaElement{AttributeName: aws.String(rng), KeyType: types.KeyTypeRange})
+		}
+		return schema
+	}
+	gsi := func(name, hash, rng string) types.GlobalSecondaryIndex {
+		return types.GlobalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  keySchema(hash, rng),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}
+	}
+
+	tables := []*dynamodb.CreateTableInput{
+		{
+			TableName:              aws.String("wallets"),
+			AttributeDefinitions:   stringAttrs("user_id", "gsi1pk", "created_at"),
+			KeySchema:              keySchema("user_id", ""),
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{gsi(walletsGSI, "gsi1pk", "created_at")},
+		},
+		{
+			TableName:            aws.String("transactions"),
+			AttributeDefinitions: stringAttrs("id", "status", "created_at", "from_user_id", "to_user_id", "execute_at"),
+			KeySchema:            keySchema("id", ""),
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+				gsi(stuckTransactionGSI, "status", "created_at"),
+				gsi("from_user_id-index", "from_user_id", ""),
+				gsi(fromUserIDIndex, "from_user_id", "created_at"),
+				gsi(toUserIDIndex, "to_user_id", "created_at"),
+				gsi("status-execute_at-index", "status", "execute_at"),
+			},
+		},
+		{
+			TableName:            aws.String("ledger"),
+			AttributeDefinitions: stringAttrs("entry_id", "gsi1pk", "timestamp", "account_id"),
+			KeySchema:            keySchema("entry_id", ""),
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+				gsi(ledgerGSI, "gsi1pk", "timestamp"),
+				gsi(accountLedgerIndex, "account_id", "timestamp"),
+			},
+		},
+		{
+			TableName:            aws.String("idempotency"),
+			AttributeDefinitions: stringAttrs("idempotency_key"),
+			KeySchema:            keySchema("idempotency_key", ""),
+		},
+		{
+			TableName:            aws.String("recurring_transfers"),
+			AttributeDefinitions: stringAttrs("id", "from_user_id", "created_at", "status", "next_run_at"),
+			KeySchema:            keySchema("id", ""),
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+				gsi(recurringFromUserIDIndex, "from_user_id", "created_at"),
+				gsi(recurringDueIndex, "status", "next_run_at"),
+			},
+		},
+		{
+			TableName:              aws.String("connections"),
+			AttributeDefinitions:   stringAttrs("connection_id", "pk"),
+			KeySchema:              keySchema("connection_id", ""),
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{gsi("pk-index", "pk", "")},
+		},
+	}
+	for _, table := range tables {
+		_, err := client.CreateTable(context.Background(), table)
+		require.NoError(t, err)
+	}
+	return New(client, "transactions", "wallets", "ledger", "connections", "idempotency", "recurring_transfers")
+}
+
+func usd(amount int64) map[string]models.CurrencyBalance {
+	return map[string]models.CurrencyBalance{"USD": {Balance: amount}}
+}
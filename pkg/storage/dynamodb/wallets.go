@@ -12,32 +12,58 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 )
 
-// CreateWallet creates a new wallet record in DynamoDB.
+// CreateWallet creates a new wallet record in DynamoDB. The wallet and the deposits that record its
+// opening balances are written in a single TransactWriteItems call.
 func (s *Store) CreateWallet(ctx context.Context, wallet *models.Wallet) (*models.Wallet, error) {
-	wallet.TTL = time.Now().Add(24 * time.Hour).Unix()
+	now := time.Now()
+	wallet.GSI1PK = walletsPartition
 	// Marshal the wallet object for the Put operation.
 	walletAV, err := attributevalue.MarshalMap(wallet)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal wallet: %w", err)
 	}
 
-	// Construct the PutItem input.
-	input := &dynamodb.PutItemInput{
-		TableName:           aws.String(s.WalletsTableName),
-		Item:                walletAV,
-		ConditionExpression: aws.String("attribute_not_exists(user_id)"), // Prevent overwriting existing wallets.
+	deposits := storage.OpeningDeposits(wallet, now)
+	txs := make([]*models.Transaction, len(deposits))
+	for i := range deposits {
+		txs[i] = &deposits[i]
 	}
 
-	// Execute the PutItem operation.
-	_, err = s.Client.PutItem(ctx, input)
-	if err != nil {
-		var condCheckFailed *types.ConditionalCheckFailedException
-		if errors.As(err, &condCheckFailed) {
-			return nil, fmt.Errorf("wallet for user ID %s already exists", wallet.UserId)
+	// Retry if another deposit extends a ledger chain of the opening deposits concurrently.
+	err = retryOnConflict(ctx, func() error {
+		items, heads, err := s.fundsItems(ctx, txs...)
+		if err != nil {
+			return err
+		}
+
+		// Construct the TransactWriteItems input. The wallet is the first item.
+		input := &dynamodb.TransactWriteItemsInput{
+			TransactItems: append([]types.TransactWriteItem{{
+				Put: &types.Put{
+					TableName:           aws.String(s.WalletsTableName),
+					Item:                walletAV,
+					ConditionExpression: aws.String("attribute_not_exists(user_id)"), // Prevent overwriting existing wallets.
+				},
+			}}, append(items, heads...)...),
+		}
+
+		// Execute the transaction.
+		if _, err := s.Client.TransactWriteItems(ctx, input); err != nil {
+			if conditionFailed(err, 0) {
+				return fmt.Errorf("%w: user ID %s", storage.ErrWalletExists, wallet.UserId)
+			}
+			if conditionFailedFrom(err, len(input.TransactItems)-len(heads)) {
+				return fmt.Errorf("failed to create wallet in DynamoDB: %w: %w", storage.ErrVersionConflict, err)
+			}
+			return fmt.Errorf("failed to create wallet in DynamoDB: %w", err)
 		}
-		return nil, fmt.Errorf("failed to create wallet in DynamoDB: %w", err)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Return the wallet object as it was successfully created.
@@ -64,7 +90,7 @@ func (s *Store) DeleteWallet(ctx context.Context, userID string) error {
 	if err != nil {
 		var condCheckFailed *types.ConditionalCheckFailedException
 		if errors.As(err, &condCheckFailed) {
-			return fmt.Errorf("wallet for user ID %s not found", userID)
+			return fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
 		}
 		return fmt.Errorf("failed to delete wallet from DynamoDB: %w", err)
 	}
@@ -79,9 +105,12 @@ func (s *Store) GetWallet(ctx context.Context, userID string) (*models.Wallet, e
 		return nil, fmt.Errorf("failed to marshal wallet user ID: %w", err)
 	}
 
+	// Writes are conditioned on the version read here, so an eventually consistent read would
+	// turn a recent change into a spurious version conflict.
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String(s.WalletsTableName),
-		Key:       key,
+		TableName:      aws.String(s.WalletsTableName),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
 	}
 
 	result, err := s.Client.GetItem(ctx, input)
@@ -90,7 +119,7 @@ func (s *Store) GetWallet(ctx context.Context, userID string) (*models.Wallet, e
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("wallet for user ID %s not found", userID)
+		return nil, fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
 	}
 
 	var wallet models.Wallet
@@ -101,24 +130,44 @@ func (s *Store) GetWallet(ctx context.Context, userID string) (*models.Wallet, e
 	return &wallet, nil
 }
 
-// ListWallets retrieves all wallets from DynamoDB.
-func (s *Store) ListWallets(ctx context.Context) ([]models.Wallet, error) {
-	// Prepare the Scan input.
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(s.WalletsTableName),
+const (
+	walletsGSI       = "gsi1pk-created_at-index"
+	walletsPartition = "WALLETS"
+)
+
+// ListWallets retrieves a page of wallets from DynamoDB, newest first.
+func (s *Store) ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error) {
+	// Prepare the Query input. Every wallet shares one partition of the index, so a
+	// query walks all of them in creation order instead of scanning the table.
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.WalletsTableName),
+		IndexName:              aws.String(walletsGSI),
+		KeyConditionExpression: aws.String("gsi1pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: walletsPartition},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	keyAttributes := []string{"user_id", "gsi1pk", "created_at"}
+	if err := paginate(input, page, keyAttributes...); err != nil {
+		return nil, "", err
 	}
 
-	// Execute the Scan operation.
-	result, err := s.Client.Scan(ctx, input)
+	// Execute the Query operation.
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query wallets table: %w", err)
+	}
+	items, next, err := nextPage(result, page, keyAttributes...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan wallets table: %w", err)
+		return nil, "", fmt.Errorf("failed to build wallets cursor: %w", err)
 	}
 
 	// Unmarshal the results.
 	var wallets []models.Wallet
-	if err := attributevalue.UnmarshalListOfMaps(result.Items, &wallets); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal wallets: %w", err)
+	if err := attributevalue.UnmarshalListOfMaps(items, &wallets); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal wallets: %w", err)
 	}
 
-	return wallets, nil
+	return wallets, next, nil
 }
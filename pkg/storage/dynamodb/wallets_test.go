@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/chris/delayed-wallet-transactions/pkg/storage/dynamodb/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,9 +22,11 @@ func TestCreateWallet(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
-		mockClient.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+			return len(input.TransactItems) == 1
+		})).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		createdWallet, err := store.CreateWallet(context.Background(), wallet)
 
 		assert.NoError(t, err)
@@ -31,21 +36,22 @@ func TestCreateWallet(t *testing.T) {
 
 	t.Run("Conflict", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
-		mockClient.On("PutItem", mock.Anything, mock.Anything).Return(nil, &types.ConditionalCheckFailedException{})
+		conflict := &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}}}
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Return(nil, conflict)
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		_, err := store.CreateWallet(context.Background(), wallet)
 
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "wallet for user ID test-user already exists")
+		assert.ErrorIs(t, err, storage.ErrWalletExists)
 		mockClient.AssertExpectations(t)
 	})
 
 	t.Run("Storage Error", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
-		mockClient.On("PutItem", mock.Anything, mock.Anything).Return(nil, errors.New("some other storage error"))
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Return(nil, errors.New("some other storage error"))
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		_, err := store.CreateWallet(context.Background(), wallet)
 
 		assert.Error(t, err)
@@ -54,6 +60,31 @@ func TestCreateWallet(t *testing.T) {
 	})
 }
 
+func TestWalletsDoNotExpire(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+	_, err := store.CreateWallet(ctx, &models.Wallet{UserId: "user1", Balances: usd(100), Version: 1})
+	assert.NoError(t, err)
+	_, err = store.CreateWallet(ctx, &models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+	assert.NoError(t, err)
+
+	// The wallets must outlive a transfer scheduled far ahead, whatever is written to them meanwhile.
+	executeAt := time.Now().Add(90 * 24 * time.Hour)
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD", ExecuteAt: &executeAt})
+	assert.NoError(t, err)
+	_, err = store.Deposit(ctx, &models.Transaction{ToUserId: "user1", Amount: 10, Currency: "USD"})
+	assert.NoError(t, err)
+
+	for _, userID := range []string{"user1", "user2"} {
+		result, err := store.Client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(store.WalletsTableName),
+			Key:       map[string]types.AttributeValue{"user_id": &types.AttributeValueMemberS{Value: userID}},
+		})
+		assert.NoError(t, err)
+		assert.NotContains(t, result.Item, "ttl", "the wallet of %s must not expire", userID)
+	}
+}
+
 func TestDeleteWallet(t *testing.T) {
 	userID := "test-user"
 
@@ -61,7 +92,7 @@ func TestDeleteWallet(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		mockClient.On("DeleteItem", mock.Anything, mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		err := store.DeleteWallet(context.Background(), userID)
 
 		assert.NoError(t, err)
@@ -72,11 +103,11 @@ func TestDeleteWallet(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		mockClient.On("DeleteItem", mock.Anything, mock.Anything).Return(nil, &types.ConditionalCheckFailedException{})
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		err := store.DeleteWallet(context.Background(), userID)
 
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "wallet for user ID test-user not found")
+		assert.ErrorIs(t, err, storage.ErrWalletNotFound)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -84,7 +115,7 @@ func TestDeleteWallet(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		mockClient.On("DeleteItem", mock.Anything, mock.Anything).Return(nil, errors.New("some other storage error"))
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		err := store.DeleteWallet(context.Background(), userID)
 
 		assert.Error(t, err)
@@ -95,14 +126,17 @@ func TestDeleteWallet(t *testing.T) {
 
 func TestGetWallet(t *testing.T) {
 	userID := "test-user"
-	wallet := &models.Wallet{UserId: userID, Balance: 100}
+	wallet := &models.Wallet{UserId: userID, Balances: usd(100)}
 
 	t.Run("Success", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		walletAV, _ := attributevalue.MarshalMap(wallet)
-		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: walletAV}, nil)
+		mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+			// The version read here conditions the next write, so it must not be stale.
+			return aws.ToBool(input.ConsistentRead)
+		})).Return(&dynamodb.GetItemOutput{Item: walletAV}, nil)
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		retrievedWallet, err := store.GetWallet(context.Background(), userID)
 
 		assert.NoError(t, err)
@@ -114,11 +148,11 @@ func TestGetWallet(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: nil}, nil)
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		_, err := store.GetWallet(context.Background(), userID)
 
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "wallet for user ID test-user not found")
+		assert.ErrorIs(t, err, storage.ErrWalletNotFound)
 		mockClient.AssertExpectations(t)
 	})
 
@@ -126,7 +160,7 @@ func TestGetWallet(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
 		mockClient.On("GetItem", mock.Anything, mock.Anything).Return(nil, errors.New("some other storage error"))
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
 		_, err := store.GetWallet(context.Background(), userID)
 
 		assert.Error(t, err)
@@ -146,25 +180,66 @@ func TestListWallets(t *testing.T) {
 			assert.NoError(t, err)
 			walletsAV = append(walletsAV, av)
 		}
-		mockClient.On("Scan", mock.Anything, mock.Anything).Return(&dynamodb.ScanOutput{Items: walletsAV}, nil)
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: walletsAV}, nil)
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
-		retrievedWallets, err := store.ListWallets(context.Background())
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
+		retrievedWallets, next, err := store.ListWallets(context.Background(), storage.PageRequest{})
 
 		assert.NoError(t, err)
 		assert.Equal(t, wallets, retrievedWallets)
+		assert.Empty(t, next)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Next Page", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		var walletsAV []map[string]types.AttributeValue
+		for _, userID := range []string{"user-3", "user-2", "user-1"} {
+			av, err := attributevalue.MarshalMap(models.Wallet{UserId: userID, GSI1PK: walletsPartition, CreatedAt: time.Now()})
+			assert.NoError(t, err)
+			walletsAV = append(walletsAV, av)
+		}
+		mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey == nil && *input.Limit == 3
+		})).Return(&dynamodb.QueryOutput{Items: walletsAV}, nil).Once()
+		mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return assert.ObjectsAreEqual(walletsAV[1]["user_id"], input.ExclusiveStartKey["user_id"])
+		})).Return(&dynamodb.QueryOutput{Items: walletsAV[2:]}, nil).Once()
+
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
+		first, next, err := store.ListWallets(context.Background(), storage.PageRequest{Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, first, 2)
+		assert.NotEmpty(t, next)
+
+		second, next, err := store.ListWallets(context.Background(), storage.PageRequest{Limit: 2, Cursor: next})
+		assert.NoError(t, err)
+		assert.Len(t, second, 1)
+		assert.Empty(t, next)
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		mockClient := new(mocks.DynamoDBAPI)
+		cursor, err := storage.EncodeCursor(map[string]string{"user_id": "test-user-1"})
+		assert.NoError(t, err)
+
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
+		_, _, err = store.ListWallets(context.Background(), storage.PageRequest{Cursor: cursor})
+
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+		mockClient.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+	})
+
 	t.Run("Storage Error", func(t *testing.T) {
 		mockClient := new(mocks.DynamoDBAPI)
-		mockClient.On("Scan", mock.Anything, mock.Anything).Return(nil, errors.New("some other storage error"))
+		mockClient.On("Query", mock.Anything, mock.Anything).Return(nil, errors.New("some other storage error"))
 
-		store := New(mockClient, "transactions", "wallets", "ledger", "")
-		_, err := store.ListWallets(context.Background())
+		store := New(mockClient, "transactions", "wallets", "ledger", "", "", "")
+		_, _, err := store.ListWallets(context.Background(), storage.PageRequest{})
 
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to scan wallets table")
+		assert.Contains(t, err.Error(), "failed to query wallets table")
 		mockClient.AssertExpectations(t)
 	})
 }
@@ -0,0 +1,26 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnections(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore(t)
+
+	assert.NoError(t, store.AddConnection(ctx, "conn1"))
+	assert.NoError(t, store.AddConnection(ctx, "conn2"))
+	assert.NoError(t, store.AddConnection(ctx, "conn1"), "adding a connection must be idempotent")
+
+	connections, err := store.GetAllConnections(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"conn1", "conn2"}, connections)
+
+	assert.NoError(t, store.RemoveConnection(ctx, "conn1"))
+	connections, err = store.GetAllConnections(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"conn2"}, connections)
+}
@@ -1,13 +1,126 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // ErrInsufficientFunds is returned when a wallet has an insufficient balance for a transaction.
 var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrInsufficientReservedFunds is returned when settling a transaction whose sender has less reserved than its amount.
+var ErrInsufficientReservedFunds = errors.New("insufficient reserved funds")
+
+// ErrCurrencyNotHeld is returned when the sender's or receiver's wallet has no balance in a transaction's currency.
+var ErrCurrencyNotHeld = errors.New("wallet does not hold currency")
 var ErrTransactionAlreadyProcessing = errors.New("transaction is already being processed")
 
 // ErrTransactionNotCancellable is returned when a transaction cannot be cancelled, e.g., because it's already completed or cancelled.
 var ErrTransactionNotCancellable = errors.New("transaction not in a cancellable state")
 
+// ErrTransactionNotPendingApproval is returned when approving or rejecting a transaction that is not waiting for approval.
+var ErrTransactionNotPendingApproval = errors.New("transaction is not pending approval")
+
 // ErrTransactionNotProcessable is returned when a transaction is not in a state that allows processing (e.g., it's already cancelled).
 var ErrTransactionNotProcessable = errors.New("transaction not in a processable state")
+
+// ErrLeaseLost is returned when a settlement worker's lease on a transaction expired and was taken over by another worker.
+var ErrLeaseLost = errors.New("settlement lease lost")
+
+// ErrSettlementInProgress is returned by SettleTransaction for a transaction that another worker is
+// settling under a lease that has not expired yet. It may be retried once the lease has expired.
+var ErrSettlementInProgress = errors.New("transaction is being settled under another lease")
+
+// ErrTransactionNotDue is returned when settling a transaction before its due time.
+var ErrTransactionNotDue = errors.New("transaction is not due yet")
+
+// NotDueError is returned by SettleTransaction for a transaction that is not due yet. It matches
+// ErrTransactionNotDue and carries the due time, so that the caller can retry once it has passed.
+type NotDueError struct {
+	TransactionID string
+	DueAt         time.Time
+}
+
+func (e *NotDueError) Error() string {
+	return fmt.Sprintf("transaction %s is not due until %s", e.TransactionID, e.DueAt.UTC().Format(time.RFC3339))
+}
+
+func (e *NotDueError) Unwrap() error {
+	return ErrTransactionNotDue
+}
+
+// ErrSettlementFailed is returned when a transaction can never be settled.
+var ErrSettlementFailed = errors.New("settlement failed")
+
+// SettlementFailedError is returned by SettleTransaction for a transaction that can never be settled,
+// for example because the receiver's wallet was deleted. The transaction has been moved to FAILED with
+// Reason, one of the Failure codes, as its failure reason and its reserved funds released back to the
+// sender. Err is the error that made the settlement fail. It matches ErrSettlementFailed.
+type SettlementFailedError struct {
+	TransactionID string
+	Reason        string
+	Err           error
+}
+
+func (e *SettlementFailedError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("transaction %s failed: %s", e.TransactionID, e.Reason)
+	}
+	return fmt.Sprintf("transaction %s failed: %s: %v", e.TransactionID, e.Reason, e.Err)
+}
+
+func (e *SettlementFailedError) Unwrap() error {
+	return ErrSettlementFailed
+}
+
+// The failure reasons recorded on FAILED transactions. They are stable codes that clients can match
+// on; the error behind a failure is only logged.
+const (
+	FailureWalletNotFound            = "wallet_not_found"
+	FailureCurrencyNotHeld           = "currency_not_held"
+	FailureInsufficientReservedFunds = "insufficient_reserved_funds"
+)
+
+// FailureReason returns the failure reason of a settlement error that means the transaction can
+// never be settled, or "" for any other error.
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrWalletNotFound):
+		return FailureWalletNotFound
+	case errors.Is(err, ErrCurrencyNotHeld):
+		return FailureCurrencyNotHeld
+	case errors.Is(err, ErrInsufficientReservedFunds):
+		return FailureInsufficientReservedFunds
+	default:
+		return ""
+	}
+}
+
+// IsPermanentSettlementError reports whether a settlement error means the transaction can never be
+// settled, however often it is retried: one of its wallets is gone or no longer holds its currency,
+// or the sender's reserved funds do not cover it.
+func IsPermanentSettlementError(err error) bool {
+	return FailureReason(err) != ""
+}
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrWalletNotFound is returned when no wallet exists for a user ID.
+var ErrWalletNotFound = errors.New("wallet not found")
+
+// ErrWalletExists is returned when creating a wallet for a user ID that already has one.
+var ErrWalletExists = errors.New("wallet already exists")
+
+// ErrTransactionNotFound is returned when no transaction exists with an ID.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrVersionConflict is returned when a wallet changed between being read and being written.
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrIdempotencyKeyExists is returned when creating a transaction with an idempotency key that was already used.
+var ErrIdempotencyKeyExists = errors.New("idempotency key already used")
+
+// ErrRecurringTransferNotFound is returned when no recurring transfer exists with an ID.
+var ErrRecurringTransferNotFound = errors.New("recurring transfer not found")
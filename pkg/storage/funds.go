@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/google/uuid"
+)
+
+// FundsManager defines the interface for moving funds between wallets and system accounts.
+// A movement takes effect immediately: the wallet's balance, a COMPLETED transaction and its
+// double-entry ledger records are written together, so that every wallet balance can be
+// rebuilt from the ledger.
+type FundsManager interface {
+	// Deposit credits the wallet of newTx.ToUserId with funds from models.FundingAccount and
+	// returns the completed transaction. The wallet must hold the transaction's currency,
+	// otherwise ErrCurrencyNotHeld is returned.
+	Deposit(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error)
+
+	// Withdraw debits the available balance of the wallet of newTx.FromUserId and pays the funds
+	// out to models.PayoutsAccount. It returns ErrInsufficientFunds if the balance is too low.
+	Withdraw(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error)
+}
+
+// CompleteFundsTransaction fills in the server-side details of a deposit or withdrawal. Funds
+// movements are never reserved, so the transaction is COMPLETED as soon as it is written.
+func CompleteFundsTransaction(tx *models.Transaction, now time.Time) {
+	tx.Id = uuid.New().String()
+	tx.Status = models.COMPLETED
+	tx.CreatedAt = now
+	tx.UpdatedAt = now
+}
+
+// OpeningDeposits returns a completed deposit for each currency in which a new wallet has a
+// positive opening balance, ordered by currency. Stores write them together with the wallet,
+// so that its opening balances are recorded in the ledger like any other deposit.
+func OpeningDeposits(wallet *models.Wallet, now time.Time) []models.Transaction {
+	var deposits []models.Transaction
+	for _, currency := range slices.Sorted(maps.Keys(wallet.Balances)) {
+		balance := wallet.Balances[currency]
+		if balance.Balance <= 0 {
+			continue
+		}
+		deposit := models.Transaction{
+			FromUserId: models.FundingAccount,
+			ToUserId:   wallet.UserId,
+			Amount:     balance.Balance,
+			Currency:   currency,
+		}
+		CompleteFundsTransaction(&deposit, now)
+		deposits = append(deposits, deposit)
+	}
+	return deposits
+}
+
+// FundsLedgerEntries returns the double-entry records of a completed deposit or withdrawal:
+// a debit of the account the funds leave and a credit of the account they enter.
+func FundsLedgerEntries(tx *models.Transaction) []models.LedgerEntry {
+	description := fmt.Sprintf("Deposit for transaction %s", tx.Id)
+	if tx.ToUserId == models.PayoutsAccount {
+		description = fmt.Sprintf("Withdrawal for transaction %s", tx.Id)
+	}
+	return []models.LedgerEntry{
+		{
+			TransactionID: tx.Id,
+			EntryID:       uuid.New().String(),
+			AccountID:     tx.FromUserId,
+			Currency:      tx.Currency,
+			Debit:         tx.Amount,
+			Description:   description,
+			Timestamp:     tx.CreatedAt,
+			GSI1PK:        "LEDGER_ENTRIES",
+		},
+		{
+			TransactionID: tx.Id,
+			EntryID:       uuid.New().String(),
+			AccountID:     tx.ToUserId,
+			Currency:      tx.Currency,
+			Credit:        tx.Amount,
+			Description:   description,
+			Timestamp:     tx.CreatedAt,
+			GSI1PK:        "LEDGER_ENTRIES",
+		},
+	}
+}
@@ -1,13 +1,108 @@
 package storage
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 )
 
 // LedgerReader defines the interface for reading ledger data.
 type LedgerReader interface {
-	// ListLedgerEntries retrieves the most recent ledger entries.
-	ListLedgerEntries(ctx context.Context, limit int32) ([]models.LedgerEntry, error)
+	// ListLedgerEntries retrieves a page of ledger entries, newest first.
+	// The returned cursor is empty when there are no more pages.
+	ListLedgerEntries(ctx context.Context, page PageRequest) ([]models.LedgerEntry, string, error)
+	// ListLedgerEntriesByAccount retrieves a page of one account's ledger entries in a currency with
+	// a timestamp in [from, to), oldest first, ordered by timestamp and then entry ID.
+	// The returned cursor is empty when there are no more pages.
+	ListLedgerEntriesByAccount(ctx context.Context, accountID, currency string, from, to time.Time, page PageRequest) ([]models.LedgerEntry, string, error)
+	// ListLedgerEntriesBetween retrieves a page of the ledger entries of every account with a
+	// timestamp in [from, to), oldest first, ordered by timestamp and then entry ID.
+	// The returned cursor is empty when there are no more pages.
+	ListLedgerEntriesBetween(ctx context.Context, from, to time.Time, page PageRequest) ([]models.LedgerEntry, string, error)
+	// SumLedgerEntries returns the credits minus the debits of one account's ledger entries in a
+	// currency that come before the given timestamp and entry ID in that order. An empty entry ID
+	// counts exactly the entries with an earlier timestamp.
+	SumLedgerEntries(ctx context.Context, accountID, currency string, before time.Time, entryID string) (int64, error)
+	// GetChainHead returns the stored head of a ledger chain: the sequence and hash of its last
+	// entry. A chain without entries has the zero head.
+	GetChainHead(ctx context.Context, key ChainKey) (ChainHead, error)
+}
+
+// statementCursor continues a ledger statement. It carries the balances computed for the first
+// page, so that later pages only read their own entries instead of the account's whole history.
+type statementCursor struct {
+	AccountID string    `json:"account_id"`
+	Currency  string    `json:"currency"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Opening   int64     `json:"opening"`
+	Closing   int64     `json:"closing"`
+	// Balance is the balance after the last line of the previous page.
+	Balance int64 `json:"balance"`
+	// Next is the cursor of the reader's next page of entries.
+	Next string `json:"next"`
+}
+
+// GetLedgerStatement assembles a page of the statement of an account in one currency for [from, to).
+// Every page carries the opening and closing balances of the whole range, and each line the
+// balance after it. The balances are summed from the account's history for the first page and
+// carried in the cursor after that, so a cursor continues the statement it was returned with,
+// range included, and from and to are only used for the first page.
+func GetLedgerStatement(ctx context.Context, reader LedgerReader, accountID, currency string, from, to time.Time, page PageRequest) (*models.LedgerStatement, string, error) {
+	state := statementCursor{AccountID: accountID, Currency: currency, From: from, To: to}
+	if page.Cursor != "" {
+		if err := DecodeCursor(page.Cursor, &state); err != nil {
+			return nil, "", err
+		}
+		if state.AccountID != accountID || state.Currency != currency {
+			return nil, "", fmt.Errorf("%w: cursor belongs to another statement", ErrInvalidCursor)
+		}
+	}
+
+	entries, next, err := reader.ListLedgerEntriesByAccount(ctx, accountID, currency, state.From, state.To, PageRequest{Limit: page.Limit, Cursor: state.Next})
+	if err != nil {
+		return nil, "", err
+	}
+	if page.Cursor == "" {
+		if state.Opening, err = reader.SumLedgerEntries(ctx, accountID, currency, from, ""); err != nil {
+			return nil, "", fmt.Errorf("failed to compute opening balance: %w", err)
+		}
+		if state.Closing, err = reader.SumLedgerEntries(ctx, accountID, currency, to, ""); err != nil {
+			return nil, "", fmt.Errorf("failed to compute closing balance: %w", err)
+		}
+		state.Balance = state.Opening
+	}
+	// Not every store orders entries with the same timestamp, and the balance of each line depends on it.
+	slices.SortStableFunc(entries, func(a, b models.LedgerEntry) int {
+		return cmp.Or(a.Timestamp.Compare(b.Timestamp), strings.Compare(a.EntryID, b.EntryID))
+	})
+
+	statement := &models.LedgerStatement{
+		AccountID:      accountID,
+		Currency:       currency,
+		From:           state.From,
+		To:             state.To,
+		OpeningBalance: state.Opening,
+		ClosingBalance: state.Closing,
+		Lines:          make([]models.StatementLine, len(entries)),
+	}
+	for i, entry := range entries {
+		state.Balance += entry.Credit - entry.Debit
+		statement.Lines[i] = models.StatementLine{LedgerEntry: entry, Balance: state.Balance}
+	}
+
+	if next == "" {
+		return statement, "", nil
+	}
+	state.Next = next
+	cursor, err := EncodeCursor(state)
+	if err != nil {
+		return nil, "", err
+	}
+	return statement, cursor, nil
 }
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// ApproveTransaction moves a PENDING_APPROVAL transaction to APPROVED. Its funds stay reserved.
+func (s *Store) ApproveTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[txID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get transaction for approval: %w: ID %s", storage.ErrTransactionNotFound, txID)
+	}
+	if !lifecycle.CanTransition(tx.Status, models.APPROVED) {
+		return nil, storage.ErrTransactionNotPendingApproval
+	}
+
+	tx.Status = models.APPROVED
+	tx.UpdatedAt = time.Now()
+	s.transactions[txID] = tx
+
+	return &tx, nil
+}
+
+// RejectTransaction releases the reserved funds of a PENDING_APPROVAL transaction back to the sender and marks it as REJECTED.
+func (s *Store) RejectTransaction(ctx context.Context, txID string) error {
+	return s.releaseReservation(ctx, txID, models.REJECTED, "", "", storage.ErrTransactionNotPendingApproval, "rejection")
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// CancelTransaction releases the reserved funds back to the sender and marks the transaction as CANCELLED.
+func (s *Store) CancelTransaction(ctx context.Context, txID string) error {
+	return s.releaseReservation(ctx, txID, models.CANCELLED, "", "", storage.ErrTransactionNotCancellable, "cancellation")
+}
+
+// releaseReservation releases the reserved funds of a transaction back to the sender and moves it to
+// the given status, recording reason as its failure reason if it is not empty. If owner is not empty,
+// the transaction must still be leased to it, or ErrLeaseLost is returned. notAllowed is returned
+// for a transaction whose status may not move to it.
+func (s *Store) releaseReservation(ctx context.Context, txID string, status models.TransactionStatus, reason, owner string, notAllowed error, operation string) error {
+	tx, err := s.GetTransaction(ctx, txID)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction for %s: %w", operation, err)
+	}
+
+	if !lifecycle.CanTransition(tx.Status, status) {
+		return notAllowed
+	}
+
+	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
+	if err != nil {
+		return fmt.Errorf("failed to get sender's wallet for %s: %w", operation, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wallet, err := s.checkVersion(tx.FromUserId, senderWallet.Version)
+	if err != nil {
+		return fmt.Errorf("failed to execute %s transaction: %w", operation, err)
+	}
+	current := s.transactions[txID]
+	if owner != "" && current.LeaseOwner != owner {
+		return storage.ErrLeaseLost
+	}
+	if !lifecycle.CanTransition(current.Status, status) {
+		return notAllowed
+	}
+
+	balance, err := storage.HeldBalance(&wallet, tx.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to execute %s transaction: %w", operation, err)
+	}
+	balance.Balance += tx.Amount
+	balance.Reserved -= tx.Amount
+	wallet.Balances[tx.Currency] = balance
+	wallet.Version++
+	s.wallets[wallet.UserId] = wallet
+
+	current.Status = status
+	current.FailureReason = reason
+	current.UpdatedAt = time.Now()
+	current.TTL = current.UpdatedAt.Add(24 * time.Hour).Unix()
+	s.transactions[txID] = current
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := newTestStore(t, models.Wallet{UserId: "user1", Balances: usd(200), Version: 1}, models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		assert.NoError(t, err)
+
+		err = store.CancelTransaction(context.Background(), tx.Id)
+
+		assert.NoError(t, err)
+		wallet, _ := store.GetWallet(context.Background(), "user1")
+		assert.Equal(t, int64(200), wallet.Balances["USD"].Balance)
+		assert.Equal(t, int64(0), wallet.Balances["USD"].Reserved)
+		cancelled, _ := store.GetTransaction(context.Background(), tx.Id)
+		assert.Equal(t, models.CANCELLED, cancelled.Status)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		store := New()
+
+		err := store.CancelTransaction(context.Background(), "missing")
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to get transaction for cancellation")
+	})
+
+	t.Run("Transaction Not Cancellable", func(t *testing.T) {
+		store := newTestStore(t, models.Wallet{UserId: "user1", Balances: usd(200), Version: 1}, models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		assert.NoError(t, err)
+		assert.NoError(t, store.CancelTransaction(context.Background(), tx.Id))
+
+		err = store.CancelTransaction(context.Background(), tx.Id)
+
+		assert.Equal(t, storage.ErrTransactionNotCancellable, err)
+	})
+}
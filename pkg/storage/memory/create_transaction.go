@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// CreateTransaction atomically reserves funds from the sender's wallet in the transaction's currency
+// and creates a new transaction record.
+func (s *Store) CreateTransaction(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	// 1. Get the current state of the sender's wallet.
+	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender's wallet: %w", err)
+	}
+
+	// 2. Complete the transaction object with server-side details.
+	now := time.Now()
+	tx.Id = uuid.New().String()
+	tx.Status = lifecycle.InitialStatus(tx)
+	tx.CreatedAt = now
+	tx.UpdatedAt = now
+	due := storage.DueAt(tx)
+	tx.DueAt = &due
+
+	// 3. Apply the reservation and the transaction record atomically.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.idempotencyKeys[tx.IdempotencyKey]; ok {
+		return nil, fmt.Errorf("%w: %s", storage.ErrIdempotencyKeyExists, tx.IdempotencyKey)
+	}
+	wallet, err := s.checkVersion(tx.FromUserId, senderWallet.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+	}
+	balance, err := storage.HeldBalance(&wallet, tx.Currency)
+	if err != nil {
+		return nil, err
+	}
+	receiver, ok := s.wallets[tx.ToUserId]
+	if !ok {
+		return nil, fmt.Errorf("failed to get receiver's wallet: %w: user ID %s", storage.ErrWalletNotFound, tx.ToUserId)
+	}
+	if _, err := storage.HeldBalance(&receiver, tx.Currency); err != nil {
+		return nil, err
+	}
+	if balance.Balance < tx.Amount {
+		return nil, storage.ErrInsufficientFunds
+	}
+	if _, ok := s.transactions[tx.Id]; ok {
+		return nil, fmt.Errorf("failed to execute transaction: transaction %s already exists", tx.Id)
+	}
+
+	balance.Balance -= tx.Amount
+	balance.Reserved += tx.Amount
+	wallet.Balances[tx.Currency] = balance
+	wallet.Version++
+	s.wallets[wallet.UserId] = wallet
+	s.transactions[tx.Id] = *tx
+	if tx.IdempotencyKey != "" {
+		s.idempotencyKeys[tx.IdempotencyKey] = tx.Id
+	}
+
+	return tx, nil
+}
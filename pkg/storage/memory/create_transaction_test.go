@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T, wallets ...models.Wallet) *Store {
+	t.Helper()
+	store := New()
+	for _, wallet := range wallets {
+		_, err := store.CreateWallet(context.Background(), &wallet)
+		assert.NoError(t, err)
+	}
+	return store
+}
+
+// usd returns the balances of a wallet that holds amount in US dollars.
+func usd(amount int64) map[string]models.CurrencyBalance {
+	return map[string]models.CurrencyBalance{"USD": {Balance: amount}}
+}
+
+func TestCreateTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := newTestStore(t, models.Wallet{UserId: "user1", Balances: usd(200), Version: 1}, models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tx.Id)
+		assert.Equal(t, models.RESERVED, tx.Status)
+
+		wallet, _ := store.GetWallet(context.Background(), "user1")
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Balance)
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Reserved)
+		assert.Equal(t, int64(2), wallet.Version)
+	})
+
+	t.Run("Sender Wallet Missing", func(t *testing.T) {
+		store := New()
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to get sender's wallet")
+	})
+
+	t.Run("Insufficient Funds", func(t *testing.T) {
+		store := newTestStore(t, models.Wallet{UserId: "user1", Balances: usd(50), Version: 1}, models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+		wallet, _ := store.GetWallet(context.Background(), "user1")
+		assert.Equal(t, int64(50), wallet.Balances["USD"].Balance)
+		assert.Equal(t, int64(0), wallet.Balances["USD"].Reserved)
+	})
+
+	t.Run("Currency Not Held", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+			models.Wallet{UserId: "user2", Balances: map[string]models.CurrencyBalance{"EUR": {}}, Version: 1},
+		)
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.ErrorIs(t, err, storage.ErrCurrencyNotHeld)
+		wallet, _ := store.GetWallet(context.Background(), "user1")
+		assert.Equal(t, int64(200), wallet.Balances["USD"].Balance)
+	})
+
+	t.Run("Returned Wallets Are Copies", func(t *testing.T) {
+		store := newTestStore(t, models.Wallet{UserId: "user1", Balances: usd(200), Version: 1}, models.Wallet{UserId: "user2", Balances: usd(0), Version: 1})
+		before, _ := store.GetWallet(context.Background(), "user1")
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(200), before.Balances["USD"].Balance, "a wallet read earlier must not change")
+	})
+}
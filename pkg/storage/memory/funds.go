@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Deposit credits a wallet with funds from the funding account and records the completed
+// transaction and its ledger entries atomically.
+func (s *Store) Deposit(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	tx.FromUserId = models.FundingAccount
+	if err := s.moveFunds(tx, tx.ToUserId, tx.Amount); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Withdraw debits a wallet's available balance to the payouts account and records the completed
+// transaction and its ledger entries atomically.
+func (s *Store) Withdraw(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	tx.ToUserId = models.PayoutsAccount
+	if err := s.moveFunds(tx, tx.FromUserId, -tx.Amount); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// moveFunds changes the balance of a wallet by change in a single critical section, together
+// with the transaction record and its ledger entries.
+func (s *Store) moveFunds(tx *models.Transaction, userID string, change int64) error {
+	now := time.Now()
+	storage.CompleteFundsTransaction(tx, now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wallet, ok := s.wallets[userID]
+	if !ok {
+		return fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
+	}
+	wallet = cloneWallet(wallet)
+	balance, err := storage.HeldBalance(&wallet, tx.Currency)
+	if err != nil {
+		return err
+	}
+	if balance.Balance+change < 0 {
+		return storage.ErrInsufficientFunds
+	}
+
+	balance.Balance += change
+	wallet.Balances[tx.Currency] = balance
+	wallet.Version++
+	s.wallets[userID] = wallet
+	s.recordFunds(*tx)
+
+	return nil
+}
+
+// recordFunds stores a completed deposit or withdrawal and its ledger entries.
+// It must be called with s.mu held.
+func (s *Store) recordFunds(tx models.Transaction) {
+	s.transactions[tx.Id] = tx
+	s.appendLedger(storage.FundsLedgerEntries(&tx)...)
+}
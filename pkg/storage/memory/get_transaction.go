@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// GetTransaction retrieves a transaction by its ID.
+func (s *Store) GetTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[txID]
+	if !ok {
+		return nil, fmt.Errorf("%w: ID %s", storage.ErrTransactionNotFound, txID)
+	}
+
+	return &tx, nil
+}
+
+// GetTransactionByIdempotencyKey retrieves the transaction that was created with an idempotency key.
+func (s *Store) GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[s.idempotencyKeys[key]]
+	if !ok {
+		return nil, fmt.Errorf("%w: idempotency key %s", storage.ErrTransactionNotFound, key)
+	}
+
+	return &tx, nil
+}
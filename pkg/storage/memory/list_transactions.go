@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// GetStuckTransactions retrieves a page of the transactions in a status that were created longer than maxAge ago, oldest first.
+func (s *Store) GetStuckTransactions(ctx context.Context, status models.TransactionStatus, maxAge time.Duration, page storage.PageRequest) ([]models.Transaction, string, error) {
+	cutoff := time.Now().Add(-maxAge)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []models.Transaction
+	for _, tx := range s.transactions {
+		if tx.Status == status && tx.CreatedAt.Before(cutoff) {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return paginate(transactions, page, transactionPosition, false)
+}
+
+// ListLedgerEntries retrieves a page of ledger entries, newest first.
+func (s *Store) ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]models.LedgerEntry, len(s.ledger))
+	copy(entries, s.ledger)
+
+	return paginate(entries, page, ledgerEntryPosition, true)
+}
+
+// ListLedgerEntriesByAccount retrieves a page of one account's ledger entries in a currency with a timestamp in [from, to), oldest first.
+func (s *Store) ListLedgerEntriesByAccount(ctx context.Context, accountID, currency string, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []models.LedgerEntry
+	for _, entry := range s.ledger {
+		if entry.AccountID == accountID && entry.Currency == currency && !entry.Timestamp.Before(from) && entry.Timestamp.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return paginate(entries, page, ledgerEntryPosition, false)
+}
+
+// ListLedgerEntriesBetween retrieves a page of the ledger entries of every account with a timestamp in [from, to), oldest first.
+func (s *Store) ListLedgerEntriesBetween(ctx context.Context, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []models.LedgerEntry
+	for _, entry := range s.ledger {
+		if !entry.Timestamp.Before(from) && entry.Timestamp.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return paginate(entries, page, ledgerEntryPosition, false)
+}
+
+// SumLedgerEntries returns the credits minus the debits of one account's ledger entries in a currency before the given position.
+func (s *Store) SumLedgerEntries(ctx context.Context, accountID, currency string, before time.Time, entryID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sum int64
+	for _, entry := range s.ledger {
+		if entry.AccountID == accountID && entry.Currency == currency && ledgerEntryPosition(entry).less(position{Time: before, ID: entryID}) {
+			sum += entry.Credit - entry.Debit
+		}
+	}
+	return sum, nil
+}
+
+// ListTransactionsByUserID retrieves a page of transactions sent by a specific user, newest first.
+func (s *Store) ListTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []models.Transaction
+	for _, tx := range s.transactions {
+		if tx.FromUserId == userID {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return paginate(transactions, page, transactionPosition, true)
+}
+
+// ListIncomingTransactionsByUserID retrieves a page of transactions sent to a specific user, newest first.
+func (s *Store) ListIncomingTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []models.Transaction
+	for _, tx := range s.transactions {
+		if tx.ToUserId == userID {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return paginate(transactions, page, transactionPosition, true)
+}
+
+// ListActivityByUserID retrieves a page of transactions sent or received by a specific user, newest first.
+func (s *Store) ListActivityByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []models.Transaction
+	for _, tx := range s.transactions {
+		if tx.FromUserId == userID || tx.ToUserId == userID {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return paginate(transactions, page, transactionPosition, true)
+}
+
+func transactionPosition(tx models.Transaction) position {
+	return position{Time: tx.CreatedAt, ID: tx.Id}
+}
+
+func ledgerEntryPosition(entry models.LedgerEntry) position {
+	return position{Time: entry.Timestamp, ID: entry.EntryID}
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// position identifies an item in a list ordered by timestamp and then ID.
+type position struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+// less reports whether p sorts before q in ascending order.
+func (p position) less(q position) bool {
+	if !p.Time.Equal(q.Time) {
+		return p.Time.Before(q.Time)
+	}
+	return p.ID < q.ID
+}
+
+// paginate sorts items by their position and returns the page that follows the cursor.
+func paginate[T any](items []T, page storage.PageRequest, positionOf func(T) position, descending bool) ([]T, string, error) {
+	// before reports whether p comes before q in the requested order.
+	before := func(p, q position) bool {
+		if descending {
+			return q.less(p)
+		}
+		return p.less(q)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return before(positionOf(items[i]), positionOf(items[j]))
+	})
+
+	if page.Cursor != "" {
+		var after position
+		if err := storage.DecodeCursor(page.Cursor, &after); err != nil {
+			return nil, "", err
+		}
+		start := sort.Search(len(items), func(i int) bool {
+			return before(after, positionOf(items[i]))
+		})
+		items = items[start:]
+	}
+
+	size := int(page.PageSize())
+	if len(items) <= size {
+		return items, "", nil
+	}
+	items = items[:size]
+	next, err := storage.EncodeCursor(positionOf(items[size-1]))
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// CreateRecurringTransfer stores a new recurring transfer.
+func (s *Store) CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	now := time.Now()
+	rt.Id = uuid.New().String()
+	rt.Version = 1
+	rt.CreatedAt = now
+	rt.UpdatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recurringTransfers[rt.Id] = cloneRecurringTransfer(*rt)
+	return rt, nil
+}
+
+// GetRecurringTransfer retrieves a recurring transfer by its ID.
+func (s *Store) GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, ok := s.recurringTransfers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: ID %s", storage.ErrRecurringTransferNotFound, id)
+	}
+	rt = cloneRecurringTransfer(rt)
+	return &rt, nil
+}
+
+// ListRecurringTransfersByUserID retrieves a page of the recurring transfers sent by a specific user, newest first.
+func (s *Store) ListRecurringTransfersByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transfers []models.RecurringTransfer
+	for _, rt := range s.recurringTransfers {
+		if rt.FromUserId == userID {
+			transfers = append(transfers, cloneRecurringTransfer(rt))
+		}
+	}
+
+	return paginate(transfers, page, recurringTransferPosition, true)
+}
+
+// ListDueRecurringTransfers retrieves a page of the active recurring transfers due at or before the given time, earliest first.
+func (s *Store) ListDueRecurringTransfers(ctx context.Context, before time.Time, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transfers []models.RecurringTransfer
+	for _, rt := range s.recurringTransfers {
+		if rt.Status == models.RecurringActive && rt.NextRunAt != nil && !rt.NextRunAt.After(before) {
+			transfers = append(transfers, cloneRecurringTransfer(rt))
+		}
+	}
+
+	return paginate(transfers, page, dueRecurringTransferPosition, false)
+}
+
+// UpdateRecurringTransfer replaces a recurring transfer if it still has the version that was read.
+func (s *Store) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.recurringTransfers[rt.Id]
+	if !ok {
+		return fmt.Errorf("%w: ID %s", storage.ErrRecurringTransferNotFound, rt.Id)
+	}
+	if stored.Version != rt.Version {
+		return fmt.Errorf("%w: recurring transfer %s", storage.ErrVersionConflict, rt.Id)
+	}
+
+	rt.Version++
+	rt.UpdatedAt = time.Now()
+	s.recurringTransfers[rt.Id] = cloneRecurringTransfer(*rt)
+	return nil
+}
+
+func recurringTransferPosition(rt models.RecurringTransfer) position {
+	return position{Time: rt.CreatedAt, ID: rt.Id}
+}
+
+func dueRecurringTransferPosition(rt models.RecurringTransfer) position {
+	return position{Time: *rt.NextRunAt, ID: rt.Id}
+}
+
+// cloneRecurringTransfer copies a recurring transfer together with the values its pointers
+// refer to, so that the store never shares them with a caller.
+func cloneRecurringTransfer(rt models.RecurringTransfer) models.RecurringTransfer {
+	rt.Schedule.EndAt = clonePointer(rt.Schedule.EndAt)
+	rt.Schedule.Count = clonePointer(rt.Schedule.Count)
+	rt.NextRunAt = clonePointer(rt.NextRunAt)
+	return rt
+}
+
+func clonePointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// SettleTransaction performs the final atomic settlement of a transaction.
+// Like the DynamoDB store, it first moves the transaction from RESERVED or APPROVED to WORKING, leased
+// to owner, and only then applies the settlement, so a duplicate delivery settles at most once. A
+// transaction left WORKING by an owner whose lease has expired is taken over.
+func (s *Store) SettleTransaction(ctx context.Context, tx *models.Transaction, owner string) (bool, error) {
+	if err := s.acquireTransactionLock(tx.Id, owner, storage.LeaseExpiry(ctx, time.Now())); err != nil {
+		if errors.Is(err, storage.ErrTransactionAlreadyProcessing) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire transaction lock: %w", err)
+	}
+
+	err := s.executeSettlement(ctx, tx, owner)
+	if err != nil && storage.IsPermanentSettlementError(err) {
+		err = s.failSettlement(ctx, tx.Id, owner, err)
+	}
+	if errors.Is(err, storage.ErrLeaseLost) {
+		// Another worker took the transaction over after our lease expired, and settles it instead.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// failSettlement moves a transaction that can never be settled from WORKING to FAILED, releasing its
+// reserved funds back to the sender, and returns the *SettlementFailedError that reports it.
+func (s *Store) failSettlement(ctx context.Context, txID, owner string, cause error) error {
+	reason := storage.FailureReason(cause)
+	if err := s.releaseReservation(ctx, txID, models.FAILED, reason, owner, storage.ErrTransactionNotProcessable, "failure"); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to release reserved funds of failed settlement: %w", err))
+	}
+	return &storage.SettlementFailedError{TransactionID: txID, Reason: reason, Err: cause}
+}
+
+// acquireTransactionLock atomically updates the transaction status from RESERVED or APPROVED to WORKING,
+// provided the transaction is due, and leases it to owner until expiresAt. A WORKING transaction whose
+// lease has expired is taken over.
+func (s *Store) acquireTransactionLock(txID, owner string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	current, ok := s.transactions[txID]
+	if !ok {
+		return storage.ErrTransactionAlreadyProcessing
+	}
+	if err := storage.CheckLease(&current, now); err != nil {
+		return err
+	}
+	if !lifecycle.CanTakeOver(current.Status) {
+		if !lifecycle.Settleable(current.Status) {
+			return storage.ErrTransactionAlreadyProcessing
+		}
+		if err := storage.CheckDue(&current, now); err != nil {
+			return err
+		}
+	}
+	current.Status = models.WORKING
+	current.LeaseOwner = owner
+	current.LeaseExpiresAt = &expiresAt
+	s.transactions[txID] = current
+
+	return nil
+}
+
+// executeSettlement moves the reserved funds to the receiver, writes the double-entry
+// ledger records and marks the transaction as COMPLETED in a single critical section, provided owner
+// still holds its lease.
+func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction, owner string) error {
+	// 1. Get the current state of both wallets for optimistic locking.
+	senderWallet, err := s.GetWallet(ctx, tx.FromUserId)
+	if err != nil {
+		return fmt.Errorf("failed to get sender's wallet for settlement: %w", err)
+	}
+	receiverWallet, err := s.GetWallet(ctx, tx.ToUserId)
+	if err != nil {
+		return fmt.Errorf("failed to get receiver's wallet for settlement: %w", err)
+	}
+
+	// 2. Prepare ledger entries.
+	now := time.Now()
+	debitEntry := models.LedgerEntry{
+		TransactionID: tx.Id,
+		EntryID:       uuid.New().String(),
+		AccountID:     tx.FromUserId,
+		Currency:      tx.Currency,
+		Debit:         tx.Amount,
+		Description:   fmt.Sprintf("Settlement for transaction %s", tx.Id),
+		Timestamp:     now,
+		GSI1PK:        "LEDGER_ENTRIES",
+	}
+	creditEntry := models.LedgerEntry{
+		TransactionID: tx.Id,
+		EntryID:       uuid.New().String(),
+		AccountID:     tx.ToUserId,
+		Currency:      tx.Currency,
+		Credit:        tx.Amount,
+		Description:   fmt.Sprintf("Settlement for transaction %s", tx.Id),
+		Timestamp:     now,
+		GSI1PK:        "LEDGER_ENTRIES",
+	}
+
+	// 3. Validate every condition before mutating anything.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sender, err := s.checkVersion(tx.FromUserId, senderWallet.Version)
+	if err != nil {
+		return fmt.Errorf("failed to execute settlement transaction: %w", err)
+	}
+	senderBalance, err := storage.HeldBalance(&sender, tx.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to execute settlement transaction: %w", err)
+	}
+	if senderBalance.Reserved < tx.Amount {
+		return fmt.Errorf("failed to execute settlement transaction: %w: user ID %s", storage.ErrInsufficientReservedFunds, tx.FromUserId)
+	}
+	receiver, err := s.checkVersion(tx.ToUserId, receiverWallet.Version)
+	if err != nil {
+		return fmt.Errorf("failed to execute settlement transaction: %w", err)
+	}
+	if _, err := storage.HeldBalance(&receiver, tx.Currency); err != nil {
+		return fmt.Errorf("failed to execute settlement transaction: %w", err)
+	}
+	current, ok := s.transactions[tx.Id]
+	if !ok || !lifecycle.CanTransition(current.Status, models.COMPLETED) || current.LeaseOwner != owner {
+		// Another worker took the transaction over after our lease expired.
+		return fmt.Errorf("failed to execute settlement transaction: %w: transaction %s", storage.ErrLeaseLost, tx.Id)
+	}
+
+	// 4. Apply the settlement.
+	senderBalance.Reserved -= tx.Amount
+	sender.Balances[tx.Currency] = senderBalance
+	sender.Version++
+	s.wallets[sender.UserId] = sender
+
+	// Re-read the receiver in case the sender and receiver are the same wallet.
+	if receiver.UserId == sender.UserId {
+		receiver = sender
+	}
+	receiverBalance := receiver.Balances[tx.Currency]
+	receiverBalance.Balance += tx.Amount
+	receiver.Balances[tx.Currency] = receiverBalance
+	receiver.Version++
+	s.wallets[receiver.UserId] = receiver
+
+	s.appendLedger(debitEntry, creditEntry)
+
+	current.Status = models.COMPLETED
+	current.UpdatedAt = now
+	s.transactions[tx.Id] = current
+
+	return nil
+}
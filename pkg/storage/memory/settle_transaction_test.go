@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettleTransaction(t *testing.T) {
+	newStore := func(t *testing.T) *Store {
+		return newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(50), Version: 1},
+		)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		store := newStore(t)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		assert.NoError(t, err)
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		sender, _ := store.GetWallet(context.Background(), "user1")
+		receiver, _ := store.GetWallet(context.Background(), "user2")
+		assert.Equal(t, int64(100), sender.Balances["USD"].Balance)
+		assert.Equal(t, int64(0), sender.Balances["USD"].Reserved)
+		assert.Equal(t, int64(150), receiver.Balances["USD"].Balance)
+
+		completed, _ := store.GetTransaction(context.Background(), tx.Id)
+		assert.Equal(t, models.COMPLETED, completed.Status)
+
+		entries, _, err := store.ListLedgerEntries(context.Background(), storage.PageRequest{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 6, "two opening deposits and the settlement")
+	})
+
+	t.Run("Already Settled", func(t *testing.T) {
+		store := newStore(t)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		assert.NoError(t, err)
+		_, err = store.SettleTransaction(context.Background(), tx, "worker-1")
+		assert.NoError(t, err)
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
+
+		assert.NoError(t, err)
+		assert.False(t, settled)
+		receiver, _ := store.GetWallet(context.Background(), "user2")
+		assert.Equal(t, int64(150), receiver.Balances["USD"].Balance)
+	})
+
+	t.Run("Receiver Wallet Missing", func(t *testing.T) {
+		store := newStore(t)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		assert.NoError(t, err)
+		assert.NoError(t, store.DeleteWallet(context.Background(), "user2"))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
+
+		assert.Error(t, err)
+		assert.False(t, settled)
+		assert.Contains(t, err.Error(), "failed to get receiver's wallet for settlement")
+	})
+}
+
+func TestSettleTransactionLease(t *testing.T) {
+	newStore := func(t *testing.T) (*Store, *models.Transaction) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(100), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(50), Version: 1},
+		)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD"})
+		assert.NoError(t, err)
+		return store, tx
+	}
+
+	t.Run("Expired Lease Is Taken Over", func(t *testing.T) {
+		store, tx := newStore(t)
+		assert.NoError(t, store.acquireTransactionLock(tx.Id, "crashed", time.Now().Add(-time.Minute)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.COMPLETED, stored.Status)
+		assert.Equal(t, "worker-2", stored.LeaseOwner)
+	})
+
+	t.Run("Lease Ends With The Invocation", func(t *testing.T) {
+		store, tx := newStore(t)
+		deadline := time.Now().Add(30 * time.Second)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		settled, err := store.SettleTransaction(ctx, tx, "worker-1")
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		if assert.NotNil(t, stored.LeaseExpiresAt) {
+			assert.True(t, stored.LeaseExpiresAt.Equal(deadline), "the lease must not outlive the invocation")
+		}
+	})
+
+	t.Run("Live Lease Is Kept", func(t *testing.T) {
+		store, tx := newStore(t)
+		assert.NoError(t, store.acquireTransactionLock(tx.Id, "worker-1", time.Now().Add(storage.SettlementLease)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.ErrorIs(t, err, storage.ErrSettlementInProgress, "the message is retried once the lease has expired")
+		assert.False(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+		assert.Equal(t, "worker-1", stored.LeaseOwner)
+	})
+
+	t.Run("Lost Lease Settles Nothing", func(t *testing.T) {
+		store, tx := newStore(t)
+		assert.NoError(t, store.acquireTransactionLock(tx.Id, "worker-1", time.Now().Add(-time.Minute)))
+		assert.NoError(t, store.acquireTransactionLock(tx.Id, "worker-2", time.Now().Add(storage.SettlementLease)))
+
+		err := store.executeSettlement(context.Background(), tx, "worker-1")
+
+		assert.ErrorIs(t, err, storage.ErrLeaseLost)
+		receiver, _ := store.GetWallet(context.Background(), "user2")
+		assert.Equal(t, int64(50), receiver.Balances["USD"].Balance)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+	})
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
+)
+
+// Store implements the Storage interface in process memory.
+// It mirrors the semantics of the DynamoDB store (atomic reservations, the
+// RESERVED -> WORKING -> COMPLETED settlement flow and optimistic versioning)
+// and is intended for local development and tests. All data is lost when the process exits.
+type Store struct {
+	mu                 sync.Mutex
+	wallets            map[string]models.Wallet
+	transactions       map[string]models.Transaction
+	recurringTransfers map[string]models.RecurringTransfer
+	ledger             []models.LedgerEntry
+	connections        map[string]struct{}
+	// idempotencyKeys maps each used idempotency key to the ID of the transaction it created.
+	idempotencyKeys map[string]string
+	// heads holds the last link of every ledger chain.
+	heads map[storage.ChainKey]storage.ChainHead
+}
+
+// New creates a new, empty in-memory Store.
+func New() *Store {
+	return &Store{
+		wallets:            make(map[string]models.Wallet),
+		transactions:       make(map[string]models.Transaction),
+		recurringTransfers: make(map[string]models.RecurringTransfer),
+		heads:              make(map[storage.ChainKey]storage.ChainHead),
+		connections:        make(map[string]struct{}),
+		idempotencyKeys:    make(map[string]string),
+	}
+}
+
+// Make sure we conform to the interfaces.
+var (
+	_ storage.Storage                 = (*Store)(nil)
+	_ websockets.ConnectionManager    = (*Store)(nil)
+	_ websockets.AllConnectionsGetter = (*Store)(nil)
+)
+
+// checkVersion verifies that the stored wallet still has the version that was read
+// before the write was prepared. It must be called with s.mu held.
+func (s *Store) checkVersion(userID string, version int64) (models.Wallet, error) {
+	wallet, ok := s.wallets[userID]
+	if !ok {
+		return models.Wallet{}, fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
+	}
+	if wallet.Version != version {
+		return models.Wallet{}, fmt.Errorf("wallet for user ID %s was modified concurrently", userID)
+	}
+	return cloneWallet(wallet), nil
+}
+
+// appendLedger links entries into their ledger chains and stores them.
+// It must be called with s.mu held.
+func (s *Store) appendLedger(entries ...models.LedgerEntry) {
+	storage.LinkEntries(entries, s.heads)
+	s.ledger = append(s.ledger, entries...)
+}
+
+// GetChainHead returns the stored head of a ledger chain.
+func (s *Store) GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heads[key], nil
+}
+
+// cloneWallet copies a wallet together with its balances, so that a wallet handed out by
+// the store and the one it keeps never share a balances map.
+func cloneWallet(wallet models.Wallet) models.Wallet {
+	wallet.Balances = maps.Clone(wallet.Balances)
+	return wallet
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// CreateWallet creates a new wallet record and records its opening balances as deposits.
+func (s *Store) CreateWallet(ctx context.Context, wallet *models.Wallet) (*models.Wallet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Prevent overwriting existing wallets.
+	if _, ok := s.wallets[wallet.UserId]; ok {
+		return nil, fmt.Errorf("%w: user ID %s", storage.ErrWalletExists, wallet.UserId)
+	}
+
+	now := time.Now()
+	s.wallets[wallet.UserId] = cloneWallet(*wallet)
+	for _, deposit := range storage.OpeningDeposits(wallet, now) {
+		s.recordFunds(deposit)
+	}
+
+	return wallet, nil
+}
+
+// DeleteWallet deletes a wallet record.
+func (s *Store) DeleteWallet(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.wallets[userID]; !ok {
+		return fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
+	}
+	delete(s.wallets, userID)
+
+	return nil
+}
+
+// GetWallet retrieves a user's wallet by their user ID.
+func (s *Store) GetWallet(ctx context.Context, userID string) (*models.Wallet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wallet, ok := s.wallets[userID]
+	if !ok {
+		return nil, fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
+	}
+
+	wallet = cloneWallet(wallet)
+	return &wallet, nil
+}
+
+// ListWallets retrieves a page of wallets, newest first.
+func (s *Store) ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wallets := make([]models.Wallet, 0, len(s.wallets))
+	for _, wallet := range s.wallets {
+		wallets = append(wallets, cloneWallet(wallet))
+	}
+
+	return paginate(wallets, page, func(wallet models.Wallet) position {
+		return position{Time: wallet.CreatedAt, ID: wallet.UserId}
+	}, true)
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWallet(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := New()
+
+		created, err := store.CreateWallet(context.Background(), &models.Wallet{UserId: "test-user", Balances: usd(100), Version: 1})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "test-user", created.UserId)
+
+		wallet, err := store.GetWallet(context.Background(), "test-user")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Balance)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		store := New()
+		_, err := store.CreateWallet(context.Background(), &models.Wallet{UserId: "test-user"})
+		assert.NoError(t, err)
+
+		_, err = store.CreateWallet(context.Background(), &models.Wallet{UserId: "test-user"})
+
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, storage.ErrWalletExists)
+	})
+}
+
+func TestDeleteWallet(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := New()
+		_, err := store.CreateWallet(context.Background(), &models.Wallet{UserId: "test-user"})
+		assert.NoError(t, err)
+
+		err = store.DeleteWallet(context.Background(), "test-user")
+
+		assert.NoError(t, err)
+		_, err = store.GetWallet(context.Background(), "test-user")
+		assert.Error(t, err)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		store := New()
+
+		err := store.DeleteWallet(context.Background(), "test-user")
+
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, storage.ErrWalletNotFound)
+	})
+}
+
+func TestListWallets(t *testing.T) {
+	store := New()
+	for _, userID := range []string{"user1", "user2"} {
+		_, err := store.CreateWallet(context.Background(), &models.Wallet{UserId: userID})
+		assert.NoError(t, err)
+	}
+
+	wallets, next, err := store.ListWallets(context.Background(), storage.PageRequest{})
+
+	assert.NoError(t, err)
+	assert.Len(t, wallets, 2)
+	assert.Empty(t, next)
+}
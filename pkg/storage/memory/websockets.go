@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"sort"
+)
+
+// AddConnection saves a new WebSocket connection ID idempotently.
+func (s *Store) AddConnection(ctx context.Context, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connections[connectionID] = struct{}{}
+	return nil
+}
+
+// RemoveConnection deletes a WebSocket connection ID.
+func (s *Store) RemoveConnection(ctx context.Context, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.connections, connectionID)
+	return nil
+}
+
+// GetAllConnections retrieves all active WebSocket connection IDs.
+func (s *Store) GetAllConnections(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	connectionIDs := make([]string, 0, len(s.connections))
+	for connectionID := range s.connections {
+		connectionIDs = append(connectionIDs, connectionID)
+	}
+	sort.Strings(connectionIDs)
+
+	return connectionIDs, nil
+}
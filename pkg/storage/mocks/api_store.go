@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -59,22 +60,22 @@ func (_m *ApiStore) GetTransaction(ctx context.Context, id string) (*models.Tran
 	return r0, r1
 }
 
-// ListTransactionsByUserID provides a mock function with given fields: ctx, userID
-func (_m *ApiStore) ListTransactionsByUserID(ctx context.Context, userID string) ([]models.Transaction, error) {
-	ret := _m.Called(ctx, userID)
+// GetTransactionByIdempotencyKey provides a mock function with given fields: ctx, key
+func (_m *ApiStore) GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error) {
+	ret := _m.Called(ctx, key)
 
-	var r0 []models.Transaction
-	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Transaction); ok {
-		r0 = rf(ctx, userID)
+	var r0 *models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Transaction); ok {
+		r0 = rf(ctx, key)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Transaction)
+			r0 = ret.Get(0).(*models.Transaction)
 		}
 	}
 
 	var r1 error
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+		r1 = rf(ctx, key)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -82,6 +83,96 @@ func (_m *ApiStore) ListTransactionsByUserID(ctx context.Context, userID string)
 	return r0, r1
 }
 
+// ListTransactionsByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *ApiStore) ListTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	var r0 []models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListIncomingTransactionsByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *ApiStore) ListIncomingTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	var r0 []models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListActivityByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *ApiStore) ListActivityByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	var r0 []models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // CancelTransaction provides a mock function with given fields: ctx, id
 func (_m *ApiStore) CancelTransaction(ctx context.Context, id string) error {
 	ret := _m.Called(ctx, id)
@@ -142,27 +233,34 @@ func (_m *ApiStore) GetWallet(ctx context.Context, userID string) (*models.Walle
 	return r0, r1
 }
 
-// ListWallets provides a mock function with given fields: ctx
-func (_m *ApiStore) ListWallets(ctx context.Context) ([]models.Wallet, error) {
-	ret := _m.Called(ctx)
+// ListWallets provides a mock function with given fields: ctx, page
+func (_m *ApiStore) ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error) {
+	ret := _m.Called(ctx, page)
 
 	var r0 []models.Wallet
-	if rf, ok := ret.Get(0).(func(context.Context) []models.Wallet); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.PageRequest) []models.Wallet); ok {
+		r0 = rf(ctx, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Wallet)
 		}
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, storage.PageRequest) string); ok {
+		r1 = rf(ctx, page)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, storage.PageRequest) error); ok {
+		r2 = rf(ctx, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
 // DeleteWallet provides a mock function with given fields: ctx, userID
@@ -179,22 +277,45 @@ func (_m *ApiStore) DeleteWallet(ctx context.Context, userID string) error {
 	return r0
 }
 
-// GetStuckTransactions provides a mock function with given fields: ctx, olderThan
-func (_m *ApiStore) GetStuckTransactions(ctx context.Context, olderThan time.Duration) ([]models.Transaction, error) {
-	ret := _m.Called(ctx, olderThan)
+// Deposit provides a mock function with given fields: ctx, newTx
+func (_m *ApiStore) Deposit(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error) {
+	ret := _m.Called(ctx, newTx)
 
-	var r0 []models.Transaction
-	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) []models.Transaction); ok {
-		r0 = rf(ctx, olderThan)
+	var r0 *models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) *models.Transaction); ok {
+		r0 = rf(ctx, newTx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Transaction)
+			r0 = ret.Get(0).(*models.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Transaction) error); ok {
+		r1 = rf(ctx, newTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Withdraw provides a mock function with given fields: ctx, newTx
+func (_m *ApiStore) Withdraw(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error) {
+	ret := _m.Called(ctx, newTx)
+
+	var r0 *models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) *models.Transaction); ok {
+		r0 = rf(ctx, newTx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Transaction)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, time.Duration) error); ok {
-		r1 = rf(ctx, olderThan)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Transaction) error); ok {
+		r1 = rf(ctx, newTx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -202,25 +323,321 @@ func (_m *ApiStore) GetStuckTransactions(ctx context.Context, olderThan time.Dur
 	return r0, r1
 }
 
-// ListLedgerEntries provides a mock function with given fields: ctx, limit
-func (_m *ApiStore) ListLedgerEntries(ctx context.Context, limit int32) ([]models.LedgerEntry, error) {
-	ret := _m.Called(ctx, limit)
+// GetStuckTransactions provides a mock function with given fields: ctx, status, maxAge, page
+func (_m *ApiStore) GetStuckTransactions(ctx context.Context, status models.TransactionStatus, maxAge time.Duration, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, status, maxAge, page)
+
+	var r0 []models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, status, maxAge, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) string); ok {
+		r1 = rf(ctx, status, maxAge, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) error); ok {
+		r2 = rf(ctx, status, maxAge, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListLedgerEntries provides a mock function with given fields: ctx, page
+func (_m *ApiStore) ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	ret := _m.Called(ctx, page)
+
+	var r0 []models.LedgerEntry
+	if rf, ok := ret.Get(0).(func(context.Context, storage.PageRequest) []models.LedgerEntry); ok {
+		r0 = rf(ctx, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.LedgerEntry)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, storage.PageRequest) string); ok {
+		r1 = rf(ctx, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, storage.PageRequest) error); ok {
+		r2 = rf(ctx, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListLedgerEntriesByAccount provides a mock function with given fields: ctx, accountID, currency, from, to, page
+func (_m *ApiStore) ListLedgerEntriesByAccount(ctx context.Context, accountID string, currency string, from time.Time, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	ret := _m.Called(ctx, accountID, currency, from, to, page)
 
 	var r0 []models.LedgerEntry
-	if rf, ok := ret.Get(0).(func(context.Context, int32) []models.LedgerEntry); ok {
-		r0 = rf(ctx, limit)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) []models.LedgerEntry); ok {
+		r0 = rf(ctx, accountID, currency, from, to, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.LedgerEntry)
 		}
 	}
 
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) string); ok {
+		r1 = rf(ctx, accountID, currency, from, to, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) error); ok {
+		r2 = rf(ctx, accountID, currency, from, to, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListLedgerEntriesBetween provides a mock function with given fields: ctx, from, to, page
+func (_m *ApiStore) ListLedgerEntriesBetween(ctx context.Context, from time.Time, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	ret := _m.Called(ctx, from, to, page)
+
+	var r0 []models.LedgerEntry
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, storage.PageRequest) []models.LedgerEntry); ok {
+		r0 = rf(ctx, from, to, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.LedgerEntry)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, storage.PageRequest) string); ok {
+		r1 = rf(ctx, from, to, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time, time.Time, storage.PageRequest) error); ok {
+		r2 = rf(ctx, from, to, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SumLedgerEntries provides a mock function with given fields: ctx, accountID, currency, before, entryID
+func (_m *ApiStore) SumLedgerEntries(ctx context.Context, accountID string, currency string, before time.Time, entryID string) (int64, error) {
+	ret := _m.Called(ctx, accountID, currency, before, entryID)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, string) int64); ok {
+		r0 = rf(ctx, accountID, currency, before, entryID)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, string) error); ok {
+		r1 = rf(ctx, accountID, currency, before, entryID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetChainHead provides a mock function with given fields: ctx, key
+func (_m *ApiStore) GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 storage.ChainHead
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ChainKey) storage.ChainHead); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(storage.ChainHead)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.ChainKey) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateRecurringTransfer provides a mock function with given fields: ctx, rt
+func (_m *ApiStore) CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	ret := _m.Called(ctx, rt)
+
+	var r0 *models.RecurringTransfer
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RecurringTransfer) *models.RecurringTransfer); ok {
+		r0 = rf(ctx, rt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RecurringTransfer)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *models.RecurringTransfer) error); ok {
+		r1 = rf(ctx, rt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRecurringTransfer provides a mock function with given fields: ctx, id
+func (_m *ApiStore) GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *models.RecurringTransfer
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.RecurringTransfer); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RecurringTransfer)
+		}
+	}
+
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
-		r1 = rf(ctx, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
+
+// ListDueRecurringTransfers provides a mock function with given fields: ctx, before, page
+func (_m *ApiStore) ListDueRecurringTransfers(ctx context.Context, before time.Time, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	ret := _m.Called(ctx, before, page)
+
+	var r0 []models.RecurringTransfer
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, storage.PageRequest) []models.RecurringTransfer); ok {
+		r0 = rf(ctx, before, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecurringTransfer)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, storage.PageRequest) string); ok {
+		r1 = rf(ctx, before, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time, storage.PageRequest) error); ok {
+		r2 = rf(ctx, before, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListRecurringTransfersByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *ApiStore) ListRecurringTransfersByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	var r0 []models.RecurringTransfer
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.RecurringTransfer); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecurringTransfer)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// UpdateRecurringTransfer provides a mock function with given fields: ctx, rt
+func (_m *ApiStore) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	ret := _m.Called(ctx, rt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RecurringTransfer) error); ok {
+		r0 = rf(ctx, rt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ApproveTransaction provides a mock function with given fields: ctx, txID
+func (_m *ApiStore) ApproveTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	ret := _m.Called(ctx, txID)
+
+	var r0 *models.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Transaction); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RejectTransaction provides a mock function with given fields: ctx, txID
+func (_m *ApiStore) RejectTransaction(ctx context.Context, txID string) error {
+	ret := _m.Called(ctx, txID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
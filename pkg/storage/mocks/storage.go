@@ -8,6 +8,8 @@ import (
 	models "github.com/chris/delayed-wallet-transactions/pkg/models"
 	mock "github.com/stretchr/testify/mock"
 
+	storage "github.com/chris/delayed-wallet-transactions/pkg/storage"
+
 	time "time"
 )
 
@@ -16,6 +18,36 @@ type Storage struct {
 	mock.Mock
 }
 
+// ApproveTransaction provides a mock function with given fields: ctx, txID
+func (_m *Storage) ApproveTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	ret := _m.Called(ctx, txID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApproveTransaction")
+	}
+
+	var r0 *models.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.Transaction, error)); ok {
+		return rf(ctx, txID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Transaction); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, txID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CancelTransaction provides a mock function with given fields: ctx, txID
 func (_m *Storage) CancelTransaction(ctx context.Context, txID string) error {
 	ret := _m.Called(ctx, txID)
@@ -34,6 +66,36 @@ func (_m *Storage) CancelTransaction(ctx context.Context, txID string) error {
 	return r0
 }
 
+// CreateRecurringTransfer provides a mock function with given fields: ctx, rt
+func (_m *Storage) CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	ret := _m.Called(ctx, rt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRecurringTransfer")
+	}
+
+	var r0 *models.RecurringTransfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RecurringTransfer) (*models.RecurringTransfer, error)); ok {
+		return rf(ctx, rt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RecurringTransfer) *models.RecurringTransfer); ok {
+		r0 = rf(ctx, rt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RecurringTransfer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *models.RecurringTransfer) error); ok {
+		r1 = rf(ctx, rt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateTransaction provides a mock function with given fields: ctx, newTx
 func (_m *Storage) CreateTransaction(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error) {
 	ret := _m.Called(ctx, newTx)
@@ -112,29 +174,29 @@ func (_m *Storage) DeleteWallet(ctx context.Context, userID string) error {
 	return r0
 }
 
-// GetStuckTransactions provides a mock function with given fields: ctx, maxAge
-func (_m *Storage) GetStuckTransactions(ctx context.Context, maxAge time.Duration) ([]models.Transaction, error) {
-	ret := _m.Called(ctx, maxAge)
+// Deposit provides a mock function with given fields: ctx, newTx
+func (_m *Storage) Deposit(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error) {
+	ret := _m.Called(ctx, newTx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetStuckTransactions")
+		panic("no return value specified for Deposit")
 	}
 
-	var r0 []models.Transaction
+	var r0 *models.Transaction
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) ([]models.Transaction, error)); ok {
-		return rf(ctx, maxAge)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) (*models.Transaction, error)); ok {
+		return rf(ctx, newTx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) []models.Transaction); ok {
-		r0 = rf(ctx, maxAge)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) *models.Transaction); ok {
+		r0 = rf(ctx, newTx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Transaction)
+			r0 = ret.Get(0).(*models.Transaction)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, time.Duration) error); ok {
-		r1 = rf(ctx, maxAge)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Transaction) error); ok {
+		r1 = rf(ctx, newTx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -142,6 +204,101 @@ func (_m *Storage) GetStuckTransactions(ctx context.Context, maxAge time.Duratio
 	return r0, r1
 }
 
+// GetChainHead provides a mock function with given fields: ctx, key
+func (_m *Storage) GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetChainHead")
+	}
+
+	var r0 storage.ChainHead
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ChainKey) (storage.ChainHead, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ChainKey) storage.ChainHead); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(storage.ChainHead)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.ChainKey) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRecurringTransfer provides a mock function with given fields: ctx, id
+func (_m *Storage) GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRecurringTransfer")
+	}
+
+	var r0 *models.RecurringTransfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.RecurringTransfer, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.RecurringTransfer); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.RecurringTransfer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStuckTransactions provides a mock function with given fields: ctx, status, maxAge, page
+func (_m *Storage) GetStuckTransactions(ctx context.Context, status models.TransactionStatus, maxAge time.Duration, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, status, maxAge, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStuckTransactions")
+	}
+
+	var r0 []models.Transaction
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) ([]models.Transaction, string, error)); ok {
+		return rf(ctx, status, maxAge, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, status, maxAge, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) string); ok {
+		r1 = rf(ctx, status, maxAge, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.TransactionStatus, time.Duration, storage.PageRequest) error); ok {
+		r2 = rf(ctx, status, maxAge, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetTransaction provides a mock function with given fields: ctx, txID
 func (_m *Storage) GetTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
 	ret := _m.Called(ctx, txID)
@@ -172,6 +329,36 @@ func (_m *Storage) GetTransaction(ctx context.Context, txID string) (*models.Tra
 	return r0, r1
 }
 
+// GetTransactionByIdempotencyKey provides a mock function with given fields: ctx, key
+func (_m *Storage) GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTransactionByIdempotencyKey")
+	}
+
+	var r0 *models.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.Transaction, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Transaction); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetWallet provides a mock function with given fields: ctx, userID
 func (_m *Storage) GetWallet(ctx context.Context, userID string) (*models.Wallet, error) {
 	ret := _m.Called(ctx, userID)
@@ -202,99 +389,360 @@ func (_m *Storage) GetWallet(ctx context.Context, userID string) (*models.Wallet
 	return r0, r1
 }
 
-// ListLedgerEntries provides a mock function with given fields: ctx, limit
-func (_m *Storage) ListLedgerEntries(ctx context.Context, limit int32) ([]models.LedgerEntry, error) {
-	ret := _m.Called(ctx, limit)
+// ListActivityByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *Storage) ListActivityByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListActivityByUserID")
+	}
+
+	var r0 []models.Transaction
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) ([]models.Transaction, string, error)); ok {
+		return rf(ctx, userID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListDueRecurringTransfers provides a mock function with given fields: ctx, before, page
+func (_m *Storage) ListDueRecurringTransfers(ctx context.Context, before time.Time, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	ret := _m.Called(ctx, before, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDueRecurringTransfers")
+	}
+
+	var r0 []models.RecurringTransfer
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, storage.PageRequest) ([]models.RecurringTransfer, string, error)); ok {
+		return rf(ctx, before, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, storage.PageRequest) []models.RecurringTransfer); ok {
+		r0 = rf(ctx, before, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecurringTransfer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, storage.PageRequest) string); ok {
+		r1 = rf(ctx, before, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time, storage.PageRequest) error); ok {
+		r2 = rf(ctx, before, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListIncomingTransactionsByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *Storage) ListIncomingTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListIncomingTransactionsByUserID")
+	}
+
+	var r0 []models.Transaction
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) ([]models.Transaction, string, error)); ok {
+		return rf(ctx, userID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListLedgerEntries provides a mock function with given fields: ctx, page
+func (_m *Storage) ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	ret := _m.Called(ctx, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListLedgerEntries")
 	}
 
 	var r0 []models.LedgerEntry
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int32) ([]models.LedgerEntry, error)); ok {
-		return rf(ctx, limit)
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.PageRequest) ([]models.LedgerEntry, string, error)); ok {
+		return rf(ctx, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int32) []models.LedgerEntry); ok {
-		r0 = rf(ctx, limit)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.PageRequest) []models.LedgerEntry); ok {
+		r0 = rf(ctx, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.LedgerEntry)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
-		r1 = rf(ctx, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.PageRequest) string); ok {
+		r1 = rf(ctx, page)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(context.Context, storage.PageRequest) error); ok {
+		r2 = rf(ctx, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// ListTransactionsByUserID provides a mock function with given fields: ctx, userID
-func (_m *Storage) ListTransactionsByUserID(ctx context.Context, userID string) ([]models.Transaction, error) {
-	ret := _m.Called(ctx, userID)
+// ListLedgerEntriesBetween provides a mock function with given fields: ctx, from, to, page
+func (_m *Storage) ListLedgerEntriesBetween(ctx context.Context, from time.Time, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	ret := _m.Called(ctx, from, to, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLedgerEntriesBetween")
+	}
+
+	var r0 []models.LedgerEntry
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, storage.PageRequest) ([]models.LedgerEntry, string, error)); ok {
+		return rf(ctx, from, to, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time, storage.PageRequest) []models.LedgerEntry); ok {
+		r0 = rf(ctx, from, to, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.LedgerEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time, storage.PageRequest) string); ok {
+		r1 = rf(ctx, from, to, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time, time.Time, storage.PageRequest) error); ok {
+		r2 = rf(ctx, from, to, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListLedgerEntriesByAccount provides a mock function with given fields: ctx, accountID, currency, from, to, page
+func (_m *Storage) ListLedgerEntriesByAccount(ctx context.Context, accountID string, currency string, from time.Time, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	ret := _m.Called(ctx, accountID, currency, from, to, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLedgerEntriesByAccount")
+	}
+
+	var r0 []models.LedgerEntry
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) ([]models.LedgerEntry, string, error)); ok {
+		return rf(ctx, accountID, currency, from, to, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) []models.LedgerEntry); ok {
+		r0 = rf(ctx, accountID, currency, from, to, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.LedgerEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) string); ok {
+		r1 = rf(ctx, accountID, currency, from, to, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, time.Time, time.Time, storage.PageRequest) error); ok {
+		r2 = rf(ctx, accountID, currency, from, to, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListRecurringTransfersByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *Storage) ListRecurringTransfersByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	ret := _m.Called(ctx, userID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecurringTransfersByUserID")
+	}
+
+	var r0 []models.RecurringTransfer
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) ([]models.RecurringTransfer, string, error)); ok {
+		return rf(ctx, userID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.RecurringTransfer); ok {
+		r0 = rf(ctx, userID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.RecurringTransfer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListTransactionsByUserID provides a mock function with given fields: ctx, userID, page
+func (_m *Storage) ListTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	ret := _m.Called(ctx, userID, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListTransactionsByUserID")
 	}
 
 	var r0 []models.Transaction
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Transaction, error)); ok {
-		return rf(ctx, userID)
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) ([]models.Transaction, string, error)); ok {
+		return rf(ctx, userID, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Transaction); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.PageRequest) []models.Transaction); ok {
+		r0 = rf(ctx, userID, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Transaction)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, storage.PageRequest) string); ok {
+		r1 = rf(ctx, userID, page)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(context.Context, string, storage.PageRequest) error); ok {
+		r2 = rf(ctx, userID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// ListWallets provides a mock function with given fields: ctx
-func (_m *Storage) ListWallets(ctx context.Context) ([]models.Wallet, error) {
-	ret := _m.Called(ctx)
+// ListWallets provides a mock function with given fields: ctx, page
+func (_m *Storage) ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error) {
+	ret := _m.Called(ctx, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListWallets")
 	}
 
 	var r0 []models.Wallet
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) ([]models.Wallet, error)); ok {
-		return rf(ctx)
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.PageRequest) ([]models.Wallet, string, error)); ok {
+		return rf(ctx, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) []models.Wallet); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.PageRequest) []models.Wallet); ok {
+		r0 = rf(ctx, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Wallet)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.PageRequest) string); ok {
+		r1 = rf(ctx, page)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(context.Context, storage.PageRequest) error); ok {
+		r2 = rf(ctx, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// SettleTransaction provides a mock function with given fields: ctx, tx
-func (_m *Storage) SettleTransaction(ctx context.Context, tx *models.Transaction) (bool, error) {
-	ret := _m.Called(ctx, tx)
+// RejectTransaction provides a mock function with given fields: ctx, txID
+func (_m *Storage) RejectTransaction(ctx context.Context, txID string) error {
+	ret := _m.Called(ctx, txID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RejectTransaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, txID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SettleTransaction provides a mock function with given fields: ctx, tx, owner
+func (_m *Storage) SettleTransaction(ctx context.Context, tx *models.Transaction, owner string) (bool, error) {
+	ret := _m.Called(ctx, tx, owner)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SettleTransaction")
@@ -302,17 +750,93 @@ func (_m *Storage) SettleTransaction(ctx context.Context, tx *models.Transaction
 
 	var r0 bool
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) (bool, error)); ok {
-		return rf(ctx, tx)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction, string) (bool, error)); ok {
+		return rf(ctx, tx, owner)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) bool); ok {
-		r0 = rf(ctx, tx)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction, string) bool); ok {
+		r0 = rf(ctx, tx, owner)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Transaction, string) error); ok {
+		r1 = rf(ctx, tx, owner)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumLedgerEntries provides a mock function with given fields: ctx, accountID, currency, before, entryID
+func (_m *Storage) SumLedgerEntries(ctx context.Context, accountID string, currency string, before time.Time, entryID string) (int64, error) {
+	ret := _m.Called(ctx, accountID, currency, before, entryID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumLedgerEntries")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, string) (int64, error)); ok {
+		return rf(ctx, accountID, currency, before, entryID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, string) int64); ok {
+		r0 = rf(ctx, accountID, currency, before, entryID)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, string) error); ok {
+		r1 = rf(ctx, accountID, currency, before, entryID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateRecurringTransfer provides a mock function with given fields: ctx, rt
+func (_m *Storage) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	ret := _m.Called(ctx, rt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateRecurringTransfer")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.RecurringTransfer) error); ok {
+		r0 = rf(ctx, rt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Withdraw provides a mock function with given fields: ctx, newTx
+func (_m *Storage) Withdraw(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error) {
+	ret := _m.Called(ctx, newTx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Withdraw")
+	}
+
+	var r0 *models.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) (*models.Transaction, error)); ok {
+		return rf(ctx, newTx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Transaction) *models.Transaction); ok {
+		r0 = rf(ctx, newTx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Transaction)
+		}
+	}
+
 	if rf, ok := ret.Get(1).(func(context.Context, *models.Transaction) error); ok {
-		r1 = rf(ctx, tx)
+		r1 = rf(ctx, newTx)
 	} else {
 		r1 = ret.Error(1)
 	}
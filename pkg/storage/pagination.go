@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// DefaultPageSize is the number of items returned when a PageRequest has no limit.
+	DefaultPageSize = 20
+	// MaxPageSize is the largest number of items a single page may contain.
+	MaxPageSize = 100
+)
+
+// PageRequest selects one page of a list operation.
+type PageRequest struct {
+	// Limit is the maximum number of items to return. Zero means DefaultPageSize.
+	Limit int32
+	// Cursor is the next cursor returned with the previous page, or empty for the first page.
+	Cursor string
+}
+
+// PageSize returns the effective limit, clamped to [1, MaxPageSize].
+func (p PageRequest) PageSize() int32 {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageSize
+	case p.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return p.Limit
+	}
+}
+
+// EncodeCursor serializes a backend-specific position into an opaque, URL-safe cursor.
+func EncodeCursor(position any) (string, error) {
+	b, err := json.Marshal(position)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor into position.
+// It returns ErrInvalidCursor if the cursor is malformed.
+func DecodeCursor(cursor string, position any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(b, position); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// CancelTransaction releases the reserved funds back to the sender and marks the transaction as CANCELLED.
+func (s *Store) CancelTransaction(ctx context.Context, txID string) error {
+	return s.releaseReservation(ctx, txID, models.CANCELLED, "", "", storage.ErrTransactionNotCancellable, "cancellation")
+}
+
+// releaseReservation releases the reserved funds of a transaction back to the sender and moves it to
+// the given status, recording reason as its failure reason if it is not empty. If owner is not empty,
+// the transaction must still be leased to it, or ErrLeaseLost is returned. notAllowed is returned
+// for a transaction whose status may not move to it.
+func (s *Store) releaseReservation(ctx context.Context, txID string, status models.TransactionStatus, reason, owner string, notAllowed error, operation string) error {
+	return s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		row := sqlTx.QueryRowContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1 FOR UPDATE`, txID)
+		tx, err := scanTransaction(row)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("%w: ID %s", storage.ErrTransactionNotFound, txID)
+			}
+			return fmt.Errorf("failed to get transaction for %s: %w", operation, err)
+		}
+
+		if owner != "" && tx.LeaseOwner != owner {
+			return storage.ErrLeaseLost
+		}
+		if !lifecycle.CanTransition(tx.Status, status) {
+			return notAllowed
+		}
+
+		if _, err := getWalletForUpdate(ctx, sqlTx, tx.FromUserId); err != nil {
+			return fmt.Errorf("failed to get sender's wallet for %s: %w", operation, err)
+		}
+
+		if err := adjustBalance(ctx, sqlTx, tx.FromUserId, tx.Currency, tx.Amount, -tx.Amount); err != nil {
+			return fmt.Errorf("failed to execute %s transaction: %w", operation, err)
+		}
+		if _, err := sqlTx.ExecContext(ctx,
+			`UPDATE transactions SET status = $1, failure_reason = $2, updated_at = $3 WHERE id = $4`,
+			status, nullString(reason), time.Now().UTC(), tx.Id,
+		); err != nil {
+			return fmt.Errorf("failed to execute %s transaction: %w", operation, err)
+		}
+		return nil
+	})
+}
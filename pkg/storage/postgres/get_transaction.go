@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+const transactionColumns = `id, from_user_id, to_user_id, amount, currency, delay_seconds, status, created_at, updated_at, idempotency_key, request_fingerprint, execute_at, due_at, failure_reason, lease_owner, lease_expires_at`
+
+// GetTransaction retrieves a transaction by its ID.
+func (s *Store) GetTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, txID)
+	tx, err := scanTransaction(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: ID %s", storage.ErrTransactionNotFound, txID)
+		}
+		return nil, fmt.Errorf("failed to get transaction from postgres: %w", err)
+	}
+
+	return tx, nil
+}
+
+// GetTransactionByIdempotencyKey retrieves the transaction that was created with an idempotency key.
+func (s *Store) GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE idempotency_key = $1`, key)
+	tx, err := scanTransaction(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: idempotency key %s", storage.ErrTransactionNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get transaction from postgres: %w", err)
+	}
+
+	return tx, nil
+}
+
+func scanTransaction(row rowScanner) (*models.Transaction, error) {
+	var (
+		tx                                 models.Transaction
+		delaySeconds                       sql.NullInt32
+		idempotencyKey, requestFingerprint sql.NullString
+		failureReason, leaseOwner          sql.NullString
+		executeAt, dueAt, leaseExpiresAt   sql.NullTime
+	)
+	if err := row.Scan(&tx.Id, &tx.FromUserId, &tx.ToUserId, &tx.Amount, &tx.Currency, &delaySeconds, &tx.Status, &tx.CreatedAt, &tx.UpdatedAt, &idempotencyKey, &requestFingerprint, &executeAt, &dueAt, &failureReason, &leaseOwner, &leaseExpiresAt); err != nil {
+		return nil, err
+	}
+	if delaySeconds.Valid {
+		tx.DelaySeconds = &delaySeconds.Int32
+	}
+	tx.IdempotencyKey = idempotencyKey.String
+	tx.RequestFingerprint = requestFingerprint.String
+	tx.FailureReason = failureReason.String
+	tx.LeaseOwner = leaseOwner.String
+	if executeAt.Valid {
+		tx.ExecuteAt = &executeAt.Time
+	}
+	if dueAt.Valid {
+		tx.DueAt = &dueAt.Time
+	}
+	if leaseExpiresAt.Valid {
+		tx.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+	return &tx, nil
+}
+
+func scanTransactions(rows *sql.Rows) ([]models.Transaction, error) {
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, *tx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+	return transactions, nil
+}
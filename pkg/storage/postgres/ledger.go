@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// insertLedgerEntries links entries into the ledger chains of their accounts and inserts them,
+// advancing the chain heads in the same database transaction. The heads are locked in a stable
+// order, so concurrent writers extend a chain one after the other and cannot deadlock.
+func insertLedgerEntries(ctx context.Context, sqlTx *sql.Tx, entries []models.LedgerEntry) error {
+	heads := make(map[storage.ChainKey]storage.ChainHead)
+	for _, key := range storage.ChainKeys(entries) {
+		// Create the head of a new chain first, so that there is always a row to lock.
+		if _, err := sqlTx.ExecContext(ctx,
+			`INSERT INTO ledger_heads (account_id, currency, sequence, hash) VALUES ($1, $2, 0, '')
+			 ON CONFLICT (account_id, currency) DO NOTHING`,
+			key.AccountID, key.Currency,
+		); err != nil {
+			return fmt.Errorf("failed to create ledger chain head: %w", err)
+		}
+		var head storage.ChainHead
+		if err := sqlTx.QueryRowContext(ctx,
+			`SELECT sequence, hash FROM ledger_heads WHERE account_id = $1 AND currency = $2 FOR UPDATE`,
+			key.AccountID, key.Currency,
+		).Scan(&head.Sequence, &head.Hash); err != nil {
+			return fmt.Errorf("failed to lock ledger chain head: %w", err)
+		}
+		heads[key] = head
+	}
+
+	storage.LinkEntries(entries, heads)
+	for _, entry := range entries {
+		if _, err := sqlTx.ExecContext(ctx,
+			`INSERT INTO ledger_entries (`+ledgerEntryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			entry.EntryID, entry.TransactionID, entry.AccountID, entry.Currency, entry.Debit, entry.Credit, entry.Description, entry.Timestamp,
+			sql.NullInt64{Int64: entry.Sequence, Valid: storage.Chained(&entry)}, entry.PreviousHash, entry.Hash,
+		); err != nil {
+			return fmt.Errorf("failed to insert ledger entry: %w", err)
+		}
+	}
+
+	for key, head := range heads {
+		if _, err := sqlTx.ExecContext(ctx,
+			`UPDATE ledger_heads SET sequence = $3, hash = $4 WHERE account_id = $1 AND currency = $2`,
+			key.AccountID, key.Currency, head.Sequence, head.Hash,
+		); err != nil {
+			return fmt.Errorf("failed to advance ledger chain head: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetChainHead returns the stored head of a ledger chain.
+func (s *Store) GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error) {
+	var head storage.ChainHead
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT sequence, hash FROM ledger_heads WHERE account_id = $1 AND currency = $2`,
+		key.AccountID, key.Currency,
+	).Scan(&head.Sequence, &head.Hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return storage.ChainHead{}, fmt.Errorf("failed to read ledger chain head: %w", err)
+	}
+	return head, nil
+}
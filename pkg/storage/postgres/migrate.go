@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key that serializes concurrent Migrate calls.
+const migrationLockID = 7_426_001
+
+// Migrate applies any schema migrations that have not yet been applied.
+// It is safe to call on every start-up and from several processes at once.
+func (s *Store) Migrate(ctx context.Context) error {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	return s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		if _, err := sqlTx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if _, err := sqlTx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			name       TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+			return fmt.Errorf("failed to create schema_migrations table: %w", err)
+		}
+
+		for _, name := range names {
+			var applied bool
+			if err := sqlTx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE name = $1)`, name).Scan(&applied); err != nil {
+				return fmt.Errorf("failed to check migration %s: %w", name, err)
+			}
+			if applied {
+				continue
+			}
+
+			script, err := migrationFiles.ReadFile(name)
+			if err != nil {
+				return fmt.Errorf("failed to read migration %s: %w", name, err)
+			}
+			if _, err := sqlTx.ExecContext(ctx, string(script)); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", name, err)
+			}
+			if _, err := sqlTx.ExecContext(ctx, `INSERT INTO schema_migrations (name) VALUES ($1)`, name); err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+-- Wallets hold the available balance and the funds reserved for pending transactions.
+CREATE TABLE IF NOT EXISTS wallets (
+    user_id    TEXT PRIMARY KEY,
+    name       TEXT        NOT NULL DEFAULT '',
+    balance    BIGINT      NOT NULL CHECK (balance >= 0),
+    reserved   BIGINT      NOT NULL CHECK (reserved >= 0),
+    version    BIGINT      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Transactions track each transfer from RESERVED to COMPLETED or CANCELLED.
+CREATE TABLE IF NOT EXISTS transactions (
+    id            TEXT PRIMARY KEY,
+    from_user_id  TEXT        NOT NULL,
+    to_user_id    TEXT        NOT NULL,
+    amount        BIGINT      NOT NULL CHECK (amount > 0),
+    delay_seconds INTEGER,
+    status        TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL
+);
+
+-- Equivalent of the status-created_at-index GSI used to find stuck transactions.
+CREATE INDEX IF NOT EXISTS transactions_status_created_at_idx ON transactions (status, created_at);
+
+-- Equivalent of the from_user_id-index GSI.
+CREATE INDEX IF NOT EXISTS transactions_from_user_id_idx ON transactions (from_user_id, created_at);
+
+-- Ledger entries are the append-only, double-entry audit trail written at settlement.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    entry_id       TEXT PRIMARY KEY,
+    transaction_id TEXT        NOT NULL,
+    account_id     TEXT        NOT NULL,
+    debit          BIGINT      NOT NULL DEFAULT 0,
+    credit         BIGINT      NOT NULL DEFAULT 0,
+    description    TEXT        NOT NULL,
+    "timestamp"    TIMESTAMPTZ NOT NULL
+);
+
+-- Equivalent of the gsi1pk-timestamp-index GSI.
+CREATE INDEX IF NOT EXISTS ledger_entries_timestamp_idx ON ledger_entries ("timestamp" DESC);
+
+CREATE TABLE IF NOT EXISTS websocket_connections (
+    connection_id TEXT PRIMARY KEY,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,14 @@
+-- Listings are paginated by (timestamp, id) keysets, so each listing index ends in the
+-- tie-breaking ID column and a page can be read by seeking past the previous page's last row.
+
+DROP INDEX IF EXISTS transactions_status_created_at_idx;
+CREATE INDEX IF NOT EXISTS transactions_status_created_at_idx ON transactions (status, created_at, id);
+
+DROP INDEX IF EXISTS transactions_from_user_id_idx;
+CREATE INDEX IF NOT EXISTS transactions_from_user_id_idx ON transactions (from_user_id, created_at, id);
+
+DROP INDEX IF EXISTS ledger_entries_timestamp_idx;
+CREATE INDEX IF NOT EXISTS ledger_entries_timestamp_idx ON ledger_entries ("timestamp", entry_id);
+
+-- Equivalent of the gsi1pk-created_at-index GSI used by ListWallets.
+CREATE INDEX IF NOT EXISTS wallets_created_at_idx ON wallets (created_at, user_id);
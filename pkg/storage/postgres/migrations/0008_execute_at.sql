@@ -0,0 +1,5 @@
+-- Transactions may be scheduled for an absolute time. The index finds the reserved transactions
+-- that are due by a given time.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS execute_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS transactions_execute_at_idx ON transactions (status, execute_at);
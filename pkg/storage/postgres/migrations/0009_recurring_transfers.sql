@@ -0,0 +1,29 @@
+-- Recurring transfers create a transaction at every occurrence of their schedule. The schedule is
+-- either a cron expression or a frequency with an interval.
+CREATE TABLE IF NOT EXISTS recurring_transfers (
+    id                  TEXT PRIMARY KEY,
+    from_user_id        TEXT        NOT NULL,
+    to_user_id          TEXT        NOT NULL,
+    amount              BIGINT      NOT NULL CHECK (amount > 0),
+    currency            TEXT        NOT NULL,
+    cron                TEXT        NOT NULL DEFAULT '',
+    frequency           TEXT        NOT NULL DEFAULT '',
+    repeat_interval     INTEGER     NOT NULL DEFAULT 0,
+    start_at            TIMESTAMPTZ NOT NULL,
+    end_at              TIMESTAMPTZ,
+    count               INTEGER,
+    status              TEXT        NOT NULL,
+    occurrences         INTEGER     NOT NULL DEFAULT 0,
+    next_run_at         TIMESTAMPTZ,
+    last_transaction_id TEXT        NOT NULL DEFAULT '',
+    last_error          TEXT        NOT NULL DEFAULT '',
+    version             BIGINT      NOT NULL,
+    created_at          TIMESTAMPTZ NOT NULL,
+    updated_at          TIMESTAMPTZ NOT NULL
+);
+
+-- Used by ListRecurringTransfersByUserID.
+CREATE INDEX IF NOT EXISTS recurring_transfers_from_user_id_idx ON recurring_transfers (from_user_id, created_at, id);
+
+-- Used by ListDueRecurringTransfers to find the active transfers that are due.
+CREATE INDEX IF NOT EXISTS recurring_transfers_due_idx ON recurring_transfers (status, next_run_at, id);
@@ -0,0 +1,4 @@
+-- A transaction being settled is leased to the worker settling it until the lease expires, after
+-- which another worker may take the settlement over.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS lease_owner TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
@@ -0,0 +1,10 @@
+-- Transactions whose delay is too long for the settlement queue are held here until they are due,
+-- so that a deployment on PostgreSQL does not need the DynamoDB schedules table.
+CREATE TABLE IF NOT EXISTS schedules (
+    transaction_id TEXT PRIMARY KEY,
+    due_at         TIMESTAMPTZ NOT NULL,
+    body           TEXT        NOT NULL
+);
+
+-- Used by ListDue.
+CREATE INDEX IF NOT EXISTS schedules_due_at_idx ON schedules (due_at, transaction_id);
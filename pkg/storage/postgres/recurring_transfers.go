@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+const recurringTransferColumns = `id, from_user_id, to_user_id, amount, currency, cron, frequency, repeat_interval, start_at, end_at, count, status, occurrences, next_run_at, last_transaction_id, last_error, version, created_at, updated_at`
+
+// CreateRecurringTransfer stores a new recurring transfer.
+func (s *Store) CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	now := time.Now().UTC()
+	rt.Id = uuid.New().String()
+	rt.Version = 1
+	rt.CreatedAt = now
+	rt.UpdatedAt = now
+
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO recurring_transfers (`+recurringTransferColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		rt.Id, rt.FromUserId, rt.ToUserId, rt.Amount, rt.Currency,
+		rt.Schedule.Cron, rt.Schedule.Frequency, rt.Schedule.Interval, rt.Schedule.StartAt.UTC(), rt.Schedule.EndAt, rt.Schedule.Count,
+		rt.Status, rt.Occurrences, rt.NextRunAt, rt.LastTransactionId, rt.LastError, rt.Version, rt.CreatedAt, rt.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recurring transfer in postgres: %w", err)
+	}
+
+	return rt, nil
+}
+
+// GetRecurringTransfer retrieves a recurring transfer by its ID.
+func (s *Store) GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+recurringTransferColumns+` FROM recurring_transfers WHERE id = $1`, id)
+	rt, err := scanRecurringTransfer(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: ID %s", storage.ErrRecurringTransferNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get recurring transfer from postgres: %w", err)
+	}
+
+	return rt, nil
+}
+
+// ListRecurringTransfersByUserID retrieves a page of the recurring transfers sent by a specific user, newest first.
+func (s *Store) ListRecurringTransfersByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	after, args, err := keyset(page, "created_at", "id", true, 2)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + recurringTransferColumns + ` FROM recurring_transfers WHERE from_user_id = $1`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args)+2)
+	args = append([]any{userID}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for recurring transfers by user ID: %w", err)
+	}
+	transfers, err := scanRecurringTransfers(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transfers, page.PageSize(), func(rt models.RecurringTransfer) position {
+		return position{Time: rt.CreatedAt, ID: rt.Id}
+	})
+}
+
+// ListDueRecurringTransfers retrieves a page of the active recurring transfers due at or before the given time, earliest first.
+func (s *Store) ListDueRecurringTransfers(ctx context.Context, before time.Time, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	after, args, err := keyset(page, "next_run_at", "id", false, 3)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + recurringTransferColumns + ` FROM recurring_transfers WHERE status = $1 AND next_run_at <= $2`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += fmt.Sprintf(` ORDER BY next_run_at, id LIMIT $%d`, len(args)+3)
+	args = append([]any{models.RecurringActive, before.UTC()}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for due recurring transfers: %w", err)
+	}
+	transfers, err := scanRecurringTransfers(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transfers, page.PageSize(), func(rt models.RecurringTransfer) position {
+		return position{Time: *rt.NextRunAt, ID: rt.Id}
+	})
+}
+
+// UpdateRecurringTransfer replaces a recurring transfer if it still has the version that was read.
+func (s *Store) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	updatedAt := time.Now().UTC()
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE recurring_transfers SET status = $1, occurrences = $2, next_run_at = $3, last_transaction_id = $4, last_error = $5, version = version + 1, updated_at = $6
+		WHERE id = $7 AND version = $8`,
+		rt.Status, rt.Occurrences, rt.NextRunAt, rt.LastTransactionId, rt.LastError, updatedAt, rt.Id, rt.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update recurring transfer in postgres: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update recurring transfer in postgres: %w", err)
+	} else if n == 0 {
+		if _, err := s.GetRecurringTransfer(ctx, rt.Id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: recurring transfer %s", storage.ErrVersionConflict, rt.Id)
+	}
+
+	rt.Version++
+	rt.UpdatedAt = updatedAt
+	return nil
+}
+
+func scanRecurringTransfer(row rowScanner) (*models.RecurringTransfer, error) {
+	var (
+		rt               models.RecurringTransfer
+		endAt, nextRunAt sql.NullTime
+		count            sql.NullInt32
+	)
+	if err := row.Scan(&rt.Id, &rt.FromUserId, &rt.ToUserId, &rt.Amount, &rt.Currency,
+		&rt.Schedule.Cron, &rt.Schedule.Frequency, &rt.Schedule.Interval, &rt.Schedule.StartAt, &endAt, &count,
+		&rt.Status, &rt.Occurrences, &nextRunAt, &rt.LastTransactionId, &rt.LastError, &rt.Version, &rt.CreatedAt, &rt.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if count.Valid {
+		rt.Schedule.Count = &count.Int32
+	}
+	if endAt.Valid {
+		rt.Schedule.EndAt = &endAt.Time
+	}
+	if nextRunAt.Valid {
+		rt.NextRunAt = &nextRunAt.Time
+	}
+	return &rt, nil
+}
+
+func scanRecurringTransfers(rows *sql.Rows) ([]models.RecurringTransfer, error) {
+	defer rows.Close()
+
+	var transfers []models.RecurringTransfer
+	for rows.Next() {
+		rt, err := scanRecurringTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recurring transfer: %w", err)
+		}
+		transfers = append(transfers, *rt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recurring transfers: %w", err)
+	}
+	return transfers, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+)
+
+// PutSchedule stores a schedule, replacing any schedule of the same transaction.
+func (s *Store) PutSchedule(ctx context.Context, schedule *scheduler.Schedule) error {
+	if _, err := s.DB.ExecContext(ctx,
+		`INSERT INTO schedules (transaction_id, due_at, body) VALUES ($1, $2, $3)
+		ON CONFLICT (transaction_id) DO UPDATE SET due_at = EXCLUDED.due_at, body = EXCLUDED.body`,
+		schedule.TransactionID, schedule.DueAt.UTC(), schedule.Body,
+	); err != nil {
+		return fmt.Errorf("failed to put schedule: %w", err)
+	}
+	return nil
+}
+
+// ListDue returns up to limit schedules due at or before the given time, earliest first.
+func (s *Store) ListDue(ctx context.Context, before time.Time, limit int) ([]scheduler.Schedule, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT transaction_id, due_at, body FROM schedules WHERE due_at <= $1 ORDER BY due_at, transaction_id LIMIT $2`,
+		before.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []scheduler.Schedule
+	for rows.Next() {
+		var schedule scheduler.Schedule
+		if err := rows.Scan(&schedule.TransactionID, &schedule.DueAt, &schedule.Body); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedule.DueAt = schedule.DueAt.UTC()
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule removes the schedule of a transaction.
+func (s *Store) DeleteSchedule(ctx context.Context, transactionID string) error {
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM schedules WHERE transaction_id = $1`, transactionID); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// SettleTransaction performs the final atomic settlement of a transaction.
+// As in the DynamoDB store, it first moves the transaction from RESERVED or APPROVED to WORKING, leased
+// to owner, and only then applies the settlement, so a duplicate delivery settles at most once. A
+// transaction left WORKING by an owner whose lease has expired is taken over.
+func (s *Store) SettleTransaction(ctx context.Context, tx *models.Transaction, owner string) (bool, error) {
+	if err := s.acquireTransactionLock(ctx, tx.Id, owner, storage.LeaseExpiry(ctx, time.Now())); err != nil {
+		if errors.Is(err, storage.ErrTransactionAlreadyProcessing) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire transaction lock: %w", err)
+	}
+
+	err := s.executeSettlement(ctx, tx, owner)
+	if err != nil && storage.IsPermanentSettlementError(err) {
+		err = s.failSettlement(ctx, tx.Id, owner, err)
+	}
+	if errors.Is(err, storage.ErrLeaseLost) {
+		// Another worker took the transaction over after our lease expired, and settles it instead.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// failSettlement moves a transaction that can never be settled from WORKING to FAILED, releasing its
+// reserved funds back to the sender, and returns the *SettlementFailedError that reports it.
+func (s *Store) failSettlement(ctx context.Context, txID, owner string, cause error) error {
+	reason := storage.FailureReason(cause)
+	if err := s.releaseReservation(ctx, txID, models.FAILED, reason, owner, storage.ErrTransactionNotProcessable, "failure"); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to release reserved funds of failed settlement: %w", err))
+	}
+	return &storage.SettlementFailedError{TransactionID: txID, Reason: reason, Err: cause}
+}
+
+// acquireTransactionLock atomically updates the transaction status from RESERVED or APPROVED to WORKING,
+// provided the transaction is due, and leases it to owner until expiresAt. A WORKING transaction whose
+// lease has expired, or that was locked before leases were recorded, is taken over.
+func (s *Store) acquireTransactionLock(ctx context.Context, txID, owner string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	condition, args := statusCondition(models.WORKING, 6)
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE transactions SET status = $1, lease_owner = $2, lease_expires_at = $3 WHERE id = $4 AND (`+
+			`(status = $1 AND (lease_expires_at IS NULL OR lease_expires_at < $5)) OR `+
+			`((due_at IS NULL OR due_at <= $5) AND `+condition+`))`,
+		append([]any{models.WORKING, owner, expiresAt.UTC(), txID, now}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update transaction status to WORKING: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update transaction status to WORKING: %w", err)
+	} else if n == 0 {
+		// Tell a settleable transaction that is not due yet apart from one that is already processed.
+		if current, err := s.GetTransaction(ctx, txID); err == nil {
+			if err := storage.CheckDue(current, now); err != nil {
+				return err
+			}
+			if err := storage.CheckLease(current, now); err != nil {
+				return err
+			}
+		}
+		return storage.ErrTransactionAlreadyProcessing
+	}
+
+	return nil
+}
+
+// executeSettlement moves the reserved funds to the receiver, writes the double-entry
+// ledger records and marks the transaction as COMPLETED in one database transaction, provided owner
+// still holds its lease.
+func (s *Store) executeSettlement(ctx context.Context, tx *models.Transaction, owner string) error {
+	return s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		// 1. Lock both wallets in a stable order so concurrent settlements cannot deadlock.
+		userIDs := []string{tx.FromUserId, tx.ToUserId}
+		sort.Strings(userIDs)
+		wallets := make(map[string]*models.Wallet, len(userIDs))
+		for _, userID := range userIDs {
+			wallet, err := getWalletForUpdate(ctx, sqlTx, userID)
+			if err != nil {
+				if userID == tx.FromUserId {
+					return fmt.Errorf("failed to get sender's wallet for settlement: %w", err)
+				}
+				return fmt.Errorf("failed to get receiver's wallet for settlement: %w", err)
+			}
+			wallets[userID] = wallet
+		}
+		senderBalance, err := storage.HeldBalance(wallets[tx.FromUserId], tx.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+		if _, err := storage.HeldBalance(wallets[tx.ToUserId], tx.Currency); err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+		if senderBalance.Reserved < tx.Amount {
+			return fmt.Errorf("failed to execute settlement transaction: %w: user ID %s", storage.ErrInsufficientReservedFunds, tx.FromUserId)
+		}
+
+		// 2. Move the funds.
+		now := time.Now().UTC()
+		if err := adjustBalance(ctx, sqlTx, tx.FromUserId, tx.Currency, 0, -tx.Amount); err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+		if err := adjustBalance(ctx, sqlTx, tx.ToUserId, tx.Currency, tx.Amount, 0); err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+
+		// 3. Create the debit and credit ledger entries.
+		description := fmt.Sprintf("Settlement for transaction %s", tx.Id)
+		if err := insertLedgerEntries(ctx, sqlTx, []models.LedgerEntry{
+			{EntryID: uuid.New().String(), TransactionID: tx.Id, AccountID: tx.FromUserId, Currency: tx.Currency, Debit: tx.Amount, Description: description, Timestamp: now},
+			{EntryID: uuid.New().String(), TransactionID: tx.Id, AccountID: tx.ToUserId, Currency: tx.Currency, Credit: tx.Amount, Description: description, Timestamp: now},
+		}); err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+
+		// 4. Update the transaction status to COMPLETED.
+		condition, args := statusCondition(models.COMPLETED, 5)
+		result, err := sqlTx.ExecContext(ctx,
+			`UPDATE transactions SET status = $1, updated_at = $2 WHERE id = $3 AND lease_owner = $4 AND `+condition,
+			append([]any{models.COMPLETED, now, tx.Id, owner}, args...)...,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to execute settlement transaction: %w", err)
+		} else if n == 0 {
+			// Another worker took the transaction over after our lease expired.
+			return fmt.Errorf("failed to execute settlement transaction: %w: transaction %s", storage.ErrLeaseLost, tx.Id)
+		}
+
+		return nil
+	})
+}
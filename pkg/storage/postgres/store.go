@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
+
+	// Register the pgx driver with database/sql.
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Store implements the Storage interface on top of PostgreSQL.
+// Database transactions and row locks take the place of DynamoDB's TransactWriteItems
+// and version condition expressions.
+type Store struct {
+	DB *sql.DB
+}
+
+// New creates a new Store using an existing database handle.
+func New(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Open connects to the PostgreSQL database at the given connection URL and verifies the connection.
+func Open(ctx context.Context, databaseURL string) (*Store, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	return New(db), nil
+}
+
+// Make sure we conform to the interfaces.
+var (
+	_ storage.Storage                 = (*Store)(nil)
+	_ websockets.ConnectionManager    = (*Store)(nil)
+	_ websockets.AllConnectionsGetter = (*Store)(nil)
+	_ scheduler.ScheduleTable         = (*Store)(nil)
+)
+
+// withTx runs fn inside a database transaction, committing if it returns nil and rolling back otherwise.
+func (s *Store) withTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	sqlTx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin database transaction: %w", err)
+	}
+
+	if err := fn(sqlTx); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, fmt.Errorf("failed to roll back database transaction: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit database transaction: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// statusCondition returns a condition that holds when a transaction's status may move to the given
+// status, and its arguments. Its placeholders are numbered from firstArg.
+func statusCondition(to models.TransactionStatus, firstArg int) (string, []any) {
+	sources := lifecycle.Sources(to)
+	placeholders := make([]string, len(sources))
+	args := make([]any, len(sources))
+	for i, from := range sources {
+		placeholders[i] = fmt.Sprintf("$%d", firstArg+i)
+		args[i] = from
+	}
+	return "status IN (" + strings.Join(placeholders, ", ") + ")", args
+}
@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore connects to the database named by POSTGRES_TEST_DATABASE_URL, applies the
+// migrations and empties every table. Tests are skipped when the variable is not set.
+func newTestStore(t *testing.T, wallets ...models.Wallet) *Store {
+	t.Helper()
+	databaseURL := os.Getenv("POSTGRES_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("POSTGRES_TEST_DATABASE_URL not set, skipping postgres integration test")
+	}
+
+	ctx := context.Background()
+	store, err := Open(ctx, databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.DB.Close() })
+
+	require.NoError(t, store.Migrate(ctx))
+	_, err = store.DB.ExecContext(ctx, `TRUNCATE wallets, wallet_balances, transactions, ledger_entries, ledger_heads, recurring_transfers, schedules, websocket_connections`)
+	require.NoError(t, err)
+
+	for _, wallet := range wallets {
+		wallet.CreatedAt = time.Now()
+		_, err := store.CreateWallet(ctx, &wallet)
+		require.NoError(t, err)
+	}
+	return store
+}
+
+func usd(amount int64) map[string]models.CurrencyBalance {
+	return map[string]models.CurrencyBalance{"USD": {Balance: amount}}
+}
+
+func TestCreateTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+		)
+
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.RESERVED, tx.Status)
+		wallet, _ := store.GetWallet(context.Background(), "user1")
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Balance)
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Reserved)
+		assert.Equal(t, int64(2), wallet.Version)
+	})
+
+	t.Run("Insufficient Funds", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(50), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+		)
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	})
+}
+
+func TestCancelTransaction(t *testing.T) {
+	store := newTestStore(t,
+		models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+		models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+	)
+	tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+
+	assert.NoError(t, store.CancelTransaction(context.Background(), tx.Id))
+	assert.Equal(t, storage.ErrTransactionNotCancellable, store.CancelTransaction(context.Background(), tx.Id))
+
+	wallet, _ := store.GetWallet(context.Background(), "user1")
+	assert.Equal(t, int64(200), wallet.Balances["USD"].Balance)
+	assert.Equal(t, int64(0), wallet.Balances["USD"].Reserved)
+}
+
+func TestSettleTransaction(t *testing.T) {
+	store := newTestStore(t,
+		models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+		models.Wallet{UserId: "user2", Balances: usd(50), Version: 1},
+	)
+	tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+
+	settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
+	assert.NoError(t, err)
+	assert.True(t, settled)
+
+	settled, err = store.SettleTransaction(context.Background(), tx, "worker-1")
+	assert.NoError(t, err)
+	assert.False(t, settled)
+
+	sender, _ := store.GetWallet(context.Background(), "user1")
+	receiver, _ := store.GetWallet(context.Background(), "user2")
+	assert.Equal(t, int64(0), sender.Balances["USD"].Reserved)
+	assert.Equal(t, int64(150), receiver.Balances["USD"].Balance)
+
+	entries, _, err := store.ListLedgerEntries(context.Background(), storage.PageRequest{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 6, "two opening deposits and the settlement")
+}
+
+func TestSettleTransactionLease(t *testing.T) {
+	newStore := func(t *testing.T) (*Store, *models.Transaction) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(100), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(50), Version: 1},
+		)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD"})
+		require.NoError(t, err)
+		return store, tx
+	}
+
+	t.Run("Expired Lease Is Taken Over", func(t *testing.T) {
+		store, tx := newStore(t)
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "crashed", time.Now().Add(-time.Minute)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.COMPLETED, stored.Status)
+		assert.Equal(t, "worker-2", stored.LeaseOwner)
+	})
+
+	t.Run("Live Lease Is Kept", func(t *testing.T) {
+		store, tx := newStore(t)
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-1", time.Now().Add(storage.SettlementLease)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.ErrorIs(t, err, storage.ErrSettlementInProgress, "the message is retried once the lease has expired")
+		assert.False(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+		assert.Equal(t, "worker-1", stored.LeaseOwner)
+	})
+
+	t.Run("Lost Lease Settles Nothing", func(t *testing.T) {
+		store, tx := newStore(t)
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-1", time.Now().Add(-time.Minute)))
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-2", time.Now().Add(storage.SettlementLease)))
+
+		err := store.executeSettlement(context.Background(), tx, "worker-1")
+
+		assert.ErrorIs(t, err, storage.ErrLeaseLost)
+		receiver, _ := store.GetWallet(context.Background(), "user2")
+		assert.Equal(t, int64(50), receiver.Balances["USD"].Balance)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+	})
+}
+
+func TestSchedules(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, hours := range []int{48, 1, 24} {
+		require.NoError(t, store.PutSchedule(ctx, &scheduler.Schedule{
+			TransactionID: fmt.Sprintf("tx-%d", i),
+			DueAt:         start.Add(time.Duration(hours) * time.Hour),
+			Body:          fmt.Sprintf(`{"id":"tx-%d"}`, i),
+		}))
+	}
+
+	due, err := store.ListDue(ctx, start.Add(24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, scheduler.Schedule{TransactionID: "tx-1", DueAt: start.Add(time.Hour), Body: `{"id":"tx-1"}`}, due[0])
+	assert.Equal(t, "tx-2", due[1].TransactionID, "a schedule due exactly at the bound is listed")
+
+	require.NoError(t, store.PutSchedule(ctx, &scheduler.Schedule{TransactionID: "tx-1", DueAt: start.Add(96 * time.Hour), Body: "{}"}))
+	require.NoError(t, store.DeleteSchedule(ctx, "tx-2"))
+	require.NoError(t, store.DeleteSchedule(ctx, "missing"))
+
+	due, err = store.ListDue(ctx, start.Add(96*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, "tx-0", due[0].TransactionID)
+	assert.Equal(t, "tx-1", due[1].TransactionID, "putting a schedule again replaces it")
+}
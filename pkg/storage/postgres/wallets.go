@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const walletColumns = `user_id, name, version, created_at`
+
+// uniqueViolation is the PostgreSQL error code for a duplicate primary key.
+const uniqueViolation = "23505"
+
+// CreateWallet creates a new wallet record and its balance in each currency, and records the
+// opening balances as deposits.
+func (s *Store) CreateWallet(ctx context.Context, wallet *models.Wallet) (*models.Wallet, error) {
+	deposits := storage.OpeningDeposits(wallet, time.Now().UTC())
+	err := s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		if _, err := sqlTx.ExecContext(ctx,
+			`INSERT INTO wallets (`+walletColumns+`) VALUES ($1, $2, $3, $4)`,
+			wallet.UserId, wallet.Name, wallet.Version, wallet.CreatedAt,
+		); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return fmt.Errorf("%w: user ID %s", storage.ErrWalletExists, wallet.UserId)
+			}
+			return fmt.Errorf("failed to create wallet in postgres: %w", err)
+		}
+
+		for currency, balance := range wallet.Balances {
+			if _, err := sqlTx.ExecContext(ctx,
+				`INSERT INTO wallet_balances (user_id, currency, balance, reserved) VALUES ($1, $2, $3, $4)`,
+				wallet.UserId, currency, balance.Balance, balance.Reserved,
+			); err != nil {
+				return fmt.Errorf("failed to create wallet balance in postgres: %w", err)
+			}
+		}
+		for i := range deposits {
+			if err := recordFunds(ctx, sqlTx, &deposits[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// DeleteWallet deletes a wallet record and its balances.
+func (s *Store) DeleteWallet(ctx context.Context, userID string) error {
+	return s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		result, err := sqlTx.ExecContext(ctx, `DELETE FROM wallets WHERE user_id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete wallet from postgres: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to delete wallet from postgres: %w", err)
+		} else if n == 0 {
+			return fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
+		}
+
+		if _, err := sqlTx.ExecContext(ctx, `DELETE FROM wallet_balances WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to delete wallet balances from postgres: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetWallet retrieves a user's wallet by their user ID.
+func (s *Store) GetWallet(ctx context.Context, userID string) (*models.Wallet, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+walletColumns+` FROM wallets WHERE user_id = $1`, userID)
+	wallet, err := scanWallet(row, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadBalances(ctx, s.DB, wallet); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// ListWallets retrieves a page of wallets, newest first.
+func (s *Store) ListWallets(ctx context.Context, page storage.PageRequest) ([]models.Wallet, string, error) {
+	after, args, err := keyset(page, "created_at", "user_id", true, 1)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + walletColumns + ` FROM wallets`
+	if after != "" {
+		query += ` WHERE ` + after
+	}
+	query += fmt.Sprintf(` ORDER BY created_at DESC, user_id DESC LIMIT $%d`, len(args)+1)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query wallets table: %w", err)
+	}
+	defer rows.Close()
+
+	var wallets []models.Wallet
+	for rows.Next() {
+		wallet, err := scanWallet(rows, "")
+		if err != nil {
+			return nil, "", err
+		}
+		wallets = append(wallets, *wallet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read wallets: %w", err)
+	}
+
+	pointers := make([]*models.Wallet, len(wallets))
+	for i := range wallets {
+		pointers[i] = &wallets[i]
+	}
+	if err := loadBalances(ctx, s.DB, pointers...); err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(wallets, page.PageSize(), func(wallet models.Wallet) position {
+		return position{Time: wallet.CreatedAt, ID: wallet.UserId}
+	})
+}
+
+// getWalletForUpdate reads a wallet and holds a row lock on it until the database transaction ends.
+// The lock on the wallet row also serialises changes to its balances.
+func getWalletForUpdate(ctx context.Context, sqlTx *sql.Tx, userID string) (*models.Wallet, error) {
+	return getWalletTx(ctx, sqlTx, userID, ` FOR UPDATE`)
+}
+
+// getWalletTx reads a wallet inside a database transaction, with an optional locking clause.
+func getWalletTx(ctx context.Context, sqlTx *sql.Tx, userID, lock string) (*models.Wallet, error) {
+	row := sqlTx.QueryRowContext(ctx, `SELECT `+walletColumns+` FROM wallets WHERE user_id = $1`+lock, userID)
+	wallet, err := scanWallet(row, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadBalances(ctx, sqlTx, wallet); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// adjustBalance adds to a wallet's balance and reserved funds in one currency and bumps the
+// wallet's version. The table's CHECK constraints reject a change that would make either negative.
+func adjustBalance(ctx context.Context, sqlTx *sql.Tx, userID, currency string, balance, reserved int64) error {
+	if _, err := sqlTx.ExecContext(ctx,
+		`UPDATE wallet_balances SET balance = balance + $1, reserved = reserved + $2 WHERE user_id = $3 AND currency = $4`,
+		balance, reserved, userID, currency,
+	); err != nil {
+		return err
+	}
+	_, err := sqlTx.ExecContext(ctx, `UPDATE wallets SET version = version + 1 WHERE user_id = $1`, userID)
+	return err
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// loadBalances reads the balances of wallets in every currency with a single query.
+func loadBalances(ctx context.Context, q queryer, wallets ...*models.Wallet) error {
+	if len(wallets) == 0 {
+		return nil
+	}
+	byUserID := make(map[string]*models.Wallet, len(wallets))
+	userIDs := make([]string, len(wallets))
+	for i, wallet := range wallets {
+		wallet.Balances = make(map[string]models.CurrencyBalance)
+		byUserID[wallet.UserId] = wallet
+		userIDs[i] = wallet.UserId
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT user_id, currency, balance, reserved FROM wallet_balances WHERE user_id = ANY($1)`, userIDs,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query wallet balances: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			userID, currency string
+			balance          models.CurrencyBalance
+		)
+		if err := rows.Scan(&userID, &currency, &balance.Balance, &balance.Reserved); err != nil {
+			return fmt.Errorf("failed to scan wallet balance: %w", err)
+		}
+		byUserID[userID].Balances[currency] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read wallet balances: %w", err)
+	}
+	return nil
+}
+
+func scanWallet(row rowScanner, userID string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := row.Scan(&wallet.UserId, &wallet.Name, &wallet.Version, &wallet.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: user ID %s", storage.ErrWalletNotFound, userID)
+		}
+		return nil, fmt.Errorf("failed to scan wallet: %w", err)
+	}
+	return &wallet, nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+)
+
+// RecurringTransferStore defines the interface for storing recurring transfers.
+type RecurringTransferStore interface {
+	// CreateRecurringTransfer stores a new recurring transfer and returns it with its ID, version and timestamps set.
+	CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error)
+
+	// GetRecurringTransfer retrieves a recurring transfer by its ID.
+	GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error)
+
+	// ListRecurringTransfersByUserID retrieves a page of the recurring transfers sent by a specific user, newest first.
+	// The returned cursor is empty when there are no more pages.
+	ListRecurringTransfersByUserID(ctx context.Context, userID string, page PageRequest) ([]models.RecurringTransfer, string, error)
+
+	// ListDueRecurringTransfers retrieves a page of the active recurring transfers whose next occurrence is at or
+	// before the given time, earliest first. The returned cursor is empty when there are no more pages.
+	ListDueRecurringTransfers(ctx context.Context, before time.Time, page PageRequest) ([]models.RecurringTransfer, string, error)
+
+	// UpdateRecurringTransfer replaces a recurring transfer if its stored version is still rt.Version, and
+	// increments rt.Version. It returns ErrVersionConflict if the transfer changed since it was read.
+	UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error
+}
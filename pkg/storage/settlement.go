@@ -2,10 +2,42 @@ package storage
 
 import (
 	"context"
+	"time"
 
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 )
 
+// SettlementLease is how long a settlement worker holds a WORKING transaction before another worker
+// may take it over, unless its context ends sooner. It is shorter than the visibility timeout of the
+// settlement queue, so a message delivered again after a worker crashed finds the lease expired.
+const SettlementLease = time.Minute
+
+// LeaseExpiry returns when the lease of a worker settling a transaction at now expires: after
+// SettlementLease, or when ctx ends if that is sooner, as the worker cannot settle anything after.
+func LeaseExpiry(ctx context.Context, now time.Time) time.Time {
+	expiresAt := now.Add(SettlementLease)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(expiresAt) {
+		return deadline
+	}
+	return expiresAt
+}
+
+// LeaseExpired reports whether the settlement lease on a WORKING transaction has run out at now.
+// Transactions locked before leases were recorded have none, and their lease has always run out.
+func LeaseExpired(tx *models.Transaction, now time.Time) bool {
+	return tx.LeaseExpiresAt == nil || tx.LeaseExpiresAt.Before(now)
+}
+
+// CheckLease returns ErrSettlementInProgress if a transaction is leased to a worker and the lease has
+// not expired at now.
+func CheckLease(tx *models.Transaction, now time.Time) error {
+	if lifecycle.CanTakeOver(tx.Status) && !LeaseExpired(tx, now) {
+		return ErrSettlementInProgress
+	}
+	return nil
+}
+
 // SettlementStore defines the highly-privileged interface for settling a transaction.
 // This operation is complex and involves atomic writes across multiple tables (Transactions, Wallets, Ledger).
 // It should only be exposed to the component responsible for final settlement.
@@ -13,5 +45,14 @@ type SettlementStore interface {
 	// SettleTransaction performs the final atomic settlement of a transaction.
 	// It returns a boolean indicating whether the settlement was actually performed,
 	// and an error if the settlement failed.
-	SettleTransaction(ctx context.Context, tx *models.Transaction) (bool, error)
+	// A transaction is not settled before its DueAt: a *NotDueError is returned instead, and the
+	// transaction is left RESERVED to be settled again once it is due.
+	// A transaction that can never be settled, see IsPermanentSettlementError, is moved to FAILED and
+	// its reserved funds released back to the sender in one atomic write; a *SettlementFailedError
+	// is returned.
+	// The settlement is leased to owner until LeaseExpiry. A transaction left WORKING by an owner
+	// whose lease has expired is taken over, and the owner that loses its lease settles nothing:
+	// false is returned. ErrSettlementInProgress is returned for a transaction another worker is
+	// settling under a live lease, so that the caller retries it once the lease has expired.
+	SettleTransaction(ctx context.Context, tx *models.Transaction, owner string) (bool, error)
 }
@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// ApproveTransaction moves a PENDING_APPROVAL transaction to APPROVED. Its funds stay reserved.
+func (s *Store) ApproveTransaction(ctx context.Context, txID string) (*models.Transaction, error) {
+	condition, args := statusCondition(models.APPROVED)
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE transactions SET status = ?, updated_at = ? WHERE id = ? AND `+condition,
+		append([]any{models.APPROVED, formatTime(time.Now()), txID}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute approval: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute approval: %w", err)
+	}
+
+	tx, err := s.GetTransaction(ctx, txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction for approval: %w", err)
+	}
+	if n == 0 {
+		return nil, storage.ErrTransactionNotPendingApproval
+	}
+	return tx, nil
+}
+
+// RejectTransaction releases the reserved funds of a PENDING_APPROVAL transaction back to the sender and marks it as REJECTED.
+func (s *Store) RejectTransaction(ctx context.Context, txID string) error {
+	return s.releaseReservation(ctx, txID, models.REJECTED, "", "", storage.ErrTransactionNotPendingApproval, "rejection")
+}
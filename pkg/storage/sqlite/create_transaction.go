@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+// CreateTransaction atomically reserves funds from the sender's wallet in the transaction's currency
+// and creates a new transaction record.
+func (s *Store) CreateTransaction(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	// 1. Complete the transaction object with server-side details.
+	now := time.Now().UTC()
+	tx.Id = uuid.New().String()
+	tx.Status = lifecycle.InitialStatus(tx)
+	tx.CreatedAt = now
+	tx.UpdatedAt = now
+	due := storage.DueAt(tx)
+	tx.DueAt = &due
+
+	err := s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		// A reused idempotency key is reported before anything else, even if the sender can no longer afford it.
+		if tx.IdempotencyKey != "" {
+			var used bool
+			if err := sqlTx.QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM transactions WHERE idempotency_key = ?)`, tx.IdempotencyKey,
+			).Scan(&used); err != nil {
+				return fmt.Errorf("failed to check idempotency key: %w", err)
+			}
+			if used {
+				return fmt.Errorf("%w: %s", storage.ErrIdempotencyKeyExists, tx.IdempotencyKey)
+			}
+		}
+
+		// 2. Read the sender's wallet under the write lock.
+		senderWallet, err := getWalletTx(ctx, sqlTx, tx.FromUserId)
+		if err != nil {
+			return fmt.Errorf("failed to get sender's wallet: %w", err)
+		}
+		balance, err := storage.HeldBalance(senderWallet, tx.Currency)
+		if err != nil {
+			return err
+		}
+		receiverWallet, err := getWalletTx(ctx, sqlTx, tx.ToUserId)
+		if err != nil {
+			return fmt.Errorf("failed to get receiver's wallet: %w", err)
+		}
+		if _, err := storage.HeldBalance(receiverWallet, tx.Currency); err != nil {
+			return err
+		}
+		if balance.Balance < tx.Amount {
+			return storage.ErrInsufficientFunds
+		}
+
+		// 3. Reserve the funds and create the transaction record.
+		if err := adjustBalance(ctx, sqlTx, tx.FromUserId, tx.Currency, -tx.Amount, tx.Amount); err != nil {
+			return fmt.Errorf("failed to execute transaction: %w", err)
+		}
+		// A concurrent request with the same idempotency key may have committed since the check above.
+		result, err := sqlTx.ExecContext(ctx,
+			`INSERT INTO transactions (`+transactionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (idempotency_key) DO NOTHING`,
+			tx.Id, tx.FromUserId, tx.ToUserId, tx.Amount, tx.Currency, tx.DelaySeconds, tx.Status, formatTime(tx.CreatedAt), formatTime(tx.UpdatedAt),
+			nullString(tx.IdempotencyKey), nullString(tx.RequestFingerprint), nullTime(tx.ExecuteAt), nullTime(tx.DueAt), nullString(tx.FailureReason), nullString(tx.LeaseOwner), nullTime(tx.LeaseExpiresAt),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to execute transaction: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to execute transaction: %w", err)
+		} else if n == 0 {
+			return fmt.Errorf("%w: %s", storage.ErrIdempotencyKeyExists, tx.IdempotencyKey)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrInsufficientFunds) {
+			return nil, storage.ErrInsufficientFunds
+		}
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// nullString stores an empty string as NULL, so that optional unique columns do not collide.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
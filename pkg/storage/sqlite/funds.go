@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// Deposit credits a wallet with funds from the funding account and records the completed
+// transaction and its ledger entries in one database transaction.
+func (s *Store) Deposit(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	tx.FromUserId = models.FundingAccount
+	if err := s.moveFunds(ctx, tx, tx.ToUserId, tx.Amount); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Withdraw debits a wallet's available balance to the payouts account and records the completed
+// transaction and its ledger entries in one database transaction.
+func (s *Store) Withdraw(ctx context.Context, tx *models.Transaction) (*models.Transaction, error) {
+	tx.ToUserId = models.PayoutsAccount
+	if err := s.moveFunds(ctx, tx, tx.FromUserId, -tx.Amount); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// moveFunds changes the balance of a wallet by change, together with the transaction record
+// and its ledger entries.
+func (s *Store) moveFunds(ctx context.Context, tx *models.Transaction, userID string, change int64) error {
+	storage.CompleteFundsTransaction(tx, time.Now().UTC())
+
+	return s.withTx(ctx, func(sqlTx *sql.Tx) error {
+		wallet, err := getWalletTx(ctx, sqlTx, userID)
+		if err != nil {
+			return err
+		}
+		balance, err := storage.HeldBalance(wallet, tx.Currency)
+		if err != nil {
+			return err
+		}
+		if balance.Balance+change < 0 {
+			return storage.ErrInsufficientFunds
+		}
+
+		if err := adjustBalance(ctx, sqlTx, userID, tx.Currency, change, 0); err != nil {
+			return fmt.Errorf("failed to move funds: %w", err)
+		}
+		return recordFunds(ctx, sqlTx, tx)
+	})
+}
+
+// recordFunds inserts a completed deposit or withdrawal and its ledger entries.
+func recordFunds(ctx context.Context, sqlTx *sql.Tx, tx *models.Transaction) error {
+	if _, err := sqlTx.ExecContext(ctx,
+		`INSERT INTO transactions (`+transactionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tx.Id, tx.FromUserId, tx.ToUserId, tx.Amount, tx.Currency, tx.DelaySeconds, tx.Status, formatTime(tx.CreatedAt), formatTime(tx.UpdatedAt),
+		nullString(tx.IdempotencyKey), nullString(tx.RequestFingerprint), nullTime(tx.ExecuteAt), nullTime(tx.DueAt), nullString(tx.FailureReason), nullString(tx.LeaseOwner), nullTime(tx.LeaseExpiresAt),
+	); err != nil {
+		return fmt.Errorf("failed to record funds transaction: %w", err)
+	}
+	if err := insertLedgerEntries(ctx, sqlTx, storage.FundsLedgerEntries(tx)); err != nil {
+		return fmt.Errorf("failed to record funds transaction: %w", err)
+	}
+	return nil
+}
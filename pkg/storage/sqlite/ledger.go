@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// insertLedgerEntries links entries into the ledger chains of their accounts and inserts them,
+// advancing the chain heads in the same database transaction. SQLite serializes writers, so the
+// heads read here cannot change before the transaction commits.
+func insertLedgerEntries(ctx context.Context, sqlTx *sql.Tx, entries []models.LedgerEntry) error {
+	heads := make(map[storage.ChainKey]storage.ChainHead)
+	for _, key := range storage.ChainKeys(entries) {
+		var head storage.ChainHead
+		err := sqlTx.QueryRowContext(ctx,
+			`SELECT sequence, hash FROM ledger_heads WHERE account_id = ? AND currency = ?`,
+			key.AccountID, key.Currency,
+		).Scan(&head.Sequence, &head.Hash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to read ledger chain head: %w", err)
+		}
+		heads[key] = head
+	}
+
+	storage.LinkEntries(entries, heads)
+	for _, entry := range entries {
+		if _, err := sqlTx.ExecContext(ctx,
+			`INSERT INTO ledger_entries (`+ledgerEntryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			entry.EntryID, entry.TransactionID, entry.AccountID, entry.Currency, entry.Debit, entry.Credit, entry.Description, formatTime(entry.Timestamp),
+			sql.NullInt64{Int64: entry.Sequence, Valid: storage.Chained(&entry)}, entry.PreviousHash, entry.Hash,
+		); err != nil {
+			return fmt.Errorf("failed to insert ledger entry: %w", err)
+		}
+	}
+
+	for key, head := range heads {
+		if _, err := sqlTx.ExecContext(ctx,
+			`INSERT INTO ledger_heads (account_id, currency, sequence, hash) VALUES (?, ?, ?, ?)
+			 ON CONFLICT (account_id, currency) DO UPDATE SET sequence = excluded.sequence, hash = excluded.hash`,
+			key.AccountID, key.Currency, head.Sequence, head.Hash,
+		); err != nil {
+			return fmt.Errorf("failed to advance ledger chain head: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetChainHead returns the stored head of a ledger chain.
+func (s *Store) GetChainHead(ctx context.Context, key storage.ChainKey) (storage.ChainHead, error) {
+	var head storage.ChainHead
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT sequence, hash FROM ledger_heads WHERE account_id = ? AND currency = ?`,
+		key.AccountID, key.Currency,
+	).Scan(&head.Sequence, &head.Hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return storage.ChainHead{}, fmt.Errorf("failed to read ledger chain head: %w", err)
+	}
+	return head, nil
+}
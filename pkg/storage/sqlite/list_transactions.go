@@ -0,0 +1,239 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// GetStuckTransactions retrieves a page of the transactions in a status that were created longer than maxAge ago, oldest first.
+func (s *Store) GetStuckTransactions(ctx context.Context, status models.TransactionStatus, maxAge time.Duration, page storage.PageRequest) ([]models.Transaction, string, error) {
+	after, args, err := keyset(page, "created_at", "id", false)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE status = ? AND created_at < ?`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += ` ORDER BY created_at, id LIMIT ?`
+	args = append([]any{status, formatTime(time.Now().Add(-maxAge))}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for stuck transactions: %w", err)
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transactions, page.PageSize(), transactionPosition)
+}
+
+// ListLedgerEntries retrieves a page of ledger entries, newest first.
+func (s *Store) ListLedgerEntries(ctx context.Context, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	after, args, err := keyset(page, "timestamp", "entry_id", true)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + ledgerEntryColumns + ` FROM ledger_entries`
+	if after != "" {
+		query += ` WHERE ` + after
+	}
+	query += ` ORDER BY timestamp DESC, entry_id DESC LIMIT ?`
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for ledger entries: %w", err)
+	}
+	entries, err := scanLedgerEntries(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(entries, page.PageSize(), ledgerEntryPosition)
+}
+
+// ListLedgerEntriesByAccount retrieves a page of one account's ledger entries in a currency with a timestamp in [from, to), oldest first.
+func (s *Store) ListLedgerEntriesByAccount(ctx context.Context, accountID, currency string, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	after, args, err := keyset(page, "timestamp", "entry_id", false)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + ledgerEntryColumns + ` FROM ledger_entries WHERE account_id = ? AND currency = ? AND timestamp >= ? AND timestamp < ?`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += ` ORDER BY timestamp, entry_id LIMIT ?`
+	args = append([]any{accountID, currency, formatTime(from), formatTime(to)}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for ledger entries by account: %w", err)
+	}
+	entries, err := scanLedgerEntries(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(entries, page.PageSize(), ledgerEntryPosition)
+}
+
+// ListLedgerEntriesBetween retrieves a page of the ledger entries of every account with a timestamp in [from, to), oldest first.
+func (s *Store) ListLedgerEntriesBetween(ctx context.Context, from, to time.Time, page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+	after, args, err := keyset(page, "timestamp", "entry_id", false)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + ledgerEntryColumns + ` FROM ledger_entries WHERE timestamp >= ? AND timestamp < ?`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += ` ORDER BY timestamp, entry_id LIMIT ?`
+	args = append([]any{formatTime(from), formatTime(to)}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for ledger entries between times: %w", err)
+	}
+	entries, err := scanLedgerEntries(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(entries, page.PageSize(), ledgerEntryPosition)
+}
+
+// SumLedgerEntries returns the credits minus the debits of one account's ledger entries in a currency before the given position.
+func (s *Store) SumLedgerEntries(ctx context.Context, accountID, currency string, before time.Time, entryID string) (int64, error) {
+	var sum int64
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(credit - debit), 0) FROM ledger_entries WHERE account_id = ? AND currency = ? AND (timestamp, entry_id) < (?, ?)`,
+		accountID, currency, formatTime(before), entryID,
+	).Scan(&sum)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum ledger entries: %w", err)
+	}
+	return sum, nil
+}
+
+const ledgerEntryColumns = `entry_id, transaction_id, account_id, currency, debit, credit, description, timestamp, sequence, previous_hash, hash`
+
+func scanLedgerEntries(rows *sql.Rows) ([]models.LedgerEntry, error) {
+	defer rows.Close()
+
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var (
+			entry              models.LedgerEntry
+			timestamp          string
+			sequence           sql.NullInt64
+			previousHash, hash sql.NullString
+			err                error
+		)
+		if err := rows.Scan(&entry.EntryID, &entry.TransactionID, &entry.AccountID, &entry.Currency, &entry.Debit, &entry.Credit, &entry.Description, &timestamp,
+			&sequence, &previousHash, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entry.Sequence, entry.PreviousHash, entry.Hash = sequence.Int64, previousHash.String, hash.String
+		if entry.Timestamp, err = parseTime(timestamp); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger entries: %w", err)
+	}
+	return entries, nil
+}
+
+func ledgerEntryPosition(entry models.LedgerEntry) position {
+	return position{Time: entry.Timestamp, ID: entry.EntryID}
+}
+
+// ListTransactionsByUserID retrieves a page of transactions sent by a specific user, newest first.
+func (s *Store) ListTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	transactions, next, err := s.listByParticipant(ctx, "from_user_id", userID, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for transactions by user ID: %w", err)
+	}
+	return transactions, next, nil
+}
+
+// ListIncomingTransactionsByUserID retrieves a page of transactions sent to a specific user, newest first.
+func (s *Store) ListIncomingTransactionsByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	transactions, next, err := s.listByParticipant(ctx, "to_user_id", userID, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for incoming transactions by user ID: %w", err)
+	}
+	return transactions, next, nil
+}
+
+// ListActivityByUserID retrieves a page of transactions sent or received by a specific user, newest first.
+// Each side is read through its own index and the two are merged, rather than filtering with an OR
+// that could use neither index for ordering.
+func (s *Store) ListActivityByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	after, afterArgs, err := keyset(page, "created_at", "id", true)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := page.PageSize() + 1
+
+	var args []any
+	side := func(column string) string {
+		query := `SELECT ` + transactionColumns + ` FROM transactions WHERE ` + column + ` = ?`
+		if after != "" {
+			query += ` AND ` + after
+		}
+		args = append(append(append(args, userID), afterArgs...), limit)
+		return `SELECT * FROM (` + query + ` ORDER BY created_at DESC, id DESC LIMIT ?)`
+	}
+	// UNION rather than UNION ALL so a transfer to oneself is listed once.
+	query := side("from_user_id") + ` UNION ` + side("to_user_id") + ` ORDER BY created_at DESC, id DESC LIMIT ?`
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for activity by user ID: %w", err)
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transactions, page.PageSize(), transactionPosition)
+}
+
+// listByParticipant retrieves a page of the transactions whose column, from_user_id or to_user_id,
+// matches userID, newest first.
+func (s *Store) listByParticipant(ctx context.Context, column, userID string, page storage.PageRequest) ([]models.Transaction, string, error) {
+	after, args, err := keyset(page, "created_at", "id", true)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE ` + column + ` = ?`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append([]any{userID}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", err
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transactions, page.PageSize(), transactionPosition)
+}
+
+func transactionPosition(tx models.Transaction) position {
+	return position{Time: tx.CreatedAt, ID: tx.Id}
+}
@@ -0,0 +1,47 @@
+-- Timestamps are stored as fixed-width UTC RFC 3339 strings so they sort correctly as text.
+
+-- Wallets hold the available balance and the funds reserved for pending transactions.
+CREATE TABLE IF NOT EXISTS wallets (
+    user_id    TEXT PRIMARY KEY,
+    name       TEXT    NOT NULL DEFAULT '',
+    balance    INTEGER NOT NULL CHECK (balance >= 0),
+    reserved   INTEGER NOT NULL CHECK (reserved >= 0),
+    version    INTEGER NOT NULL,
+    created_at TEXT    NOT NULL
+);
+
+-- Transactions track each transfer from RESERVED to COMPLETED or CANCELLED.
+CREATE TABLE IF NOT EXISTS transactions (
+    id            TEXT PRIMARY KEY,
+    from_user_id  TEXT    NOT NULL,
+    to_user_id    TEXT    NOT NULL,
+    amount        INTEGER NOT NULL CHECK (amount > 0),
+    delay_seconds INTEGER,
+    status        TEXT    NOT NULL,
+    created_at    TEXT    NOT NULL,
+    updated_at    TEXT    NOT NULL
+);
+
+-- Equivalent of the status-created_at-index GSI used to find stuck transactions.
+CREATE INDEX IF NOT EXISTS transactions_status_created_at_idx ON transactions (status, created_at);
+
+-- Equivalent of the from_user_id-index GSI used by ListTransactionsByUserID.
+CREATE INDEX IF NOT EXISTS transactions_from_user_id_idx ON transactions (from_user_id, created_at);
+
+-- Ledger entries are the append-only, double-entry audit trail written at settlement.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    entry_id       TEXT PRIMARY KEY,
+    transaction_id TEXT    NOT NULL,
+    account_id     TEXT    NOT NULL,
+    debit          INTEGER NOT NULL DEFAULT 0,
+    credit         INTEGER NOT NULL DEFAULT 0,
+    description    TEXT    NOT NULL,
+    timestamp      TEXT    NOT NULL
+);
+
+-- Equivalent of the gsi1pk-timestamp-index GSI used by ListLedgerEntries.
+CREATE INDEX IF NOT EXISTS ledger_entries_timestamp_idx ON ledger_entries (timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS websocket_connections (
+    connection_id TEXT PRIMARY KEY
+);
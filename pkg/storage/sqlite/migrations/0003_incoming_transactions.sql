@@ -0,0 +1,2 @@
+-- Equivalent of the to_user_id-created_at-index GSI used to list transfers a user has received.
+CREATE INDEX IF NOT EXISTS transactions_to_user_id_idx ON transactions (to_user_id, created_at, id);
@@ -0,0 +1,2 @@
+-- Equivalent of the account_id-timestamp-index GSI used for per-account ledger statements.
+CREATE INDEX IF NOT EXISTS ledger_entries_account_id_idx ON ledger_entries (account_id, timestamp, entry_id);
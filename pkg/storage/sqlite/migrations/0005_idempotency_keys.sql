@@ -0,0 +1,7 @@
+-- Transactions created with an Idempotency-Key record it with a fingerprint of the request,
+-- so that a retried request returns the original transaction instead of creating another.
+ALTER TABLE transactions ADD COLUMN idempotency_key TEXT;
+ALTER TABLE transactions ADD COLUMN request_fingerprint TEXT;
+
+-- Each key can create at most one transaction; rows without a key are not constrained.
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_idempotency_key_idx ON transactions (idempotency_key);
@@ -0,0 +1,22 @@
+-- Wallets hold a balance in each of their currencies, and every transaction and ledger entry
+-- is in a single currency. Everything recorded before currencies were introduced was in USD.
+CREATE TABLE IF NOT EXISTS wallet_balances (
+    user_id  TEXT    NOT NULL,
+    currency TEXT    NOT NULL,
+    balance  INTEGER NOT NULL CHECK (balance >= 0),
+    reserved INTEGER NOT NULL CHECK (reserved >= 0),
+    PRIMARY KEY (user_id, currency)
+);
+
+INSERT INTO wallet_balances (user_id, currency, balance, reserved)
+SELECT user_id, 'USD', balance, reserved FROM wallets;
+
+ALTER TABLE wallets DROP COLUMN balance;
+ALTER TABLE wallets DROP COLUMN reserved;
+
+ALTER TABLE transactions ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+ALTER TABLE ledger_entries ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+-- Statements are per account and currency.
+DROP INDEX IF EXISTS ledger_entries_account_id_idx;
+CREATE INDEX IF NOT EXISTS ledger_entries_account_id_idx ON ledger_entries (account_id, currency, timestamp, entry_id);
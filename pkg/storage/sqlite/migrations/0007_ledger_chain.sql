@@ -0,0 +1,16 @@
+-- Ledger entries form a hash chain per account and currency. Entries recorded before the chain
+-- was introduced have no chain columns and are not part of it.
+ALTER TABLE ledger_entries ADD COLUMN sequence INTEGER;
+ALTER TABLE ledger_entries ADD COLUMN previous_hash TEXT;
+ALTER TABLE ledger_entries ADD COLUMN hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_entries_chain_idx ON ledger_entries (account_id, currency, sequence);
+
+-- The last link of every chain, which each new entry is linked to.
+CREATE TABLE IF NOT EXISTS ledger_heads (
+    account_id TEXT    NOT NULL,
+    currency   TEXT    NOT NULL,
+    sequence   INTEGER NOT NULL,
+    hash       TEXT    NOT NULL,
+    PRIMARY KEY (account_id, currency)
+);
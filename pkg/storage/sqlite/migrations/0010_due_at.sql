@@ -0,0 +1,3 @@
+-- Transactions record the time they are due for settlement, and are not settled before it.
+-- Transactions created before this migration have no due time and settle whenever they are processed.
+ALTER TABLE transactions ADD COLUMN due_at TEXT;
@@ -0,0 +1,2 @@
+-- Transactions that can never be settled are FAILED and record why.
+ALTER TABLE transactions ADD COLUMN failure_reason TEXT;
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+)
+
+// position identifies a row in a listing ordered by a timestamp column and then an ID column.
+type position struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+// keyset returns a condition restricting a listing to the rows after the page's cursor,
+// using a row-value comparison so the (timestamp, id) index can seek straight to them.
+// The condition is empty for the first page.
+func keyset(page storage.PageRequest, timeColumn, idColumn string, descending bool) (string, []any, error) {
+	if page.Cursor == "" {
+		return "", nil, nil
+	}
+	var after position
+	if err := storage.DecodeCursor(page.Cursor, &after); err != nil {
+		return "", nil, err
+	}
+	op := ">"
+	if descending {
+		op = "<"
+	}
+	return "(" + timeColumn + ", " + idColumn + ") " + op + " (?, ?)", []any{formatTime(after.Time), after.ID}, nil
+}
+
+// nextPage trims rows, which were queried with a limit of one more than the page size,
+// to the page size and returns the cursor for the following page if there is one.
+func nextPage[T any](rows []T, size int32, positionOf func(T) position) ([]T, string, error) {
+	if len(rows) <= int(size) {
+		return rows, "", nil
+	}
+	rows = rows[:size]
+	next, err := storage.EncodeCursor(positionOf(rows[size-1]))
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, next, nil
+}
@@ -0,0 +1,192 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/google/uuid"
+)
+
+const recurringTransferColumns = `id, from_user_id, to_user_id, amount, currency, cron, frequency, repeat_interval, start_at, end_at, count, status, occurrences, next_run_at, last_transaction_id, last_error, version, created_at, updated_at`
+
+// CreateRecurringTransfer stores a new recurring transfer.
+func (s *Store) CreateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	now := time.Now().UTC()
+	rt.Id = uuid.New().String()
+	rt.Version = 1
+	rt.CreatedAt = now
+	rt.UpdatedAt = now
+
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO recurring_transfers (`+recurringTransferColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rt.Id, rt.FromUserId, rt.ToUserId, rt.Amount, rt.Currency,
+		rt.Schedule.Cron, rt.Schedule.Frequency, rt.Schedule.Interval, formatTime(rt.Schedule.StartAt), nullTime(rt.Schedule.EndAt), rt.Schedule.Count,
+		rt.Status, rt.Occurrences, nullTime(rt.NextRunAt), rt.LastTransactionId, rt.LastError, rt.Version, formatTime(rt.CreatedAt), formatTime(rt.UpdatedAt),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recurring transfer in sqlite: %w", err)
+	}
+
+	return rt, nil
+}
+
+// GetRecurringTransfer retrieves a recurring transfer by its ID.
+func (s *Store) GetRecurringTransfer(ctx context.Context, id string) (*models.RecurringTransfer, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+recurringTransferColumns+` FROM recurring_transfers WHERE id = ?`, id)
+	rt, err := scanRecurringTransfer(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: ID %s", storage.ErrRecurringTransferNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get recurring transfer from sqlite: %w", err)
+	}
+
+	return rt, nil
+}
+
+// ListRecurringTransfersByUserID retrieves a page of the recurring transfers sent by a specific user, newest first.
+func (s *Store) ListRecurringTransfersByUserID(ctx context.Context, userID string, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	after, args, err := keyset(page, "created_at", "id", true)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + recurringTransferColumns + ` FROM recurring_transfers WHERE from_user_id = ?`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append([]any{userID}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for recurring transfers by user ID: %w", err)
+	}
+	transfers, err := scanRecurringTransfers(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transfers, page.PageSize(), func(rt models.RecurringTransfer) position {
+		return position{Time: rt.CreatedAt, ID: rt.Id}
+	})
+}
+
+// ListDueRecurringTransfers retrieves a page of the active recurring transfers due at or before the given time, earliest first.
+func (s *Store) ListDueRecurringTransfers(ctx context.Context, before time.Time, page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+	after, args, err := keyset(page, "next_run_at", "id", false)
+	if err != nil {
+		return nil, "", err
+	}
+	query := `SELECT ` + recurringTransferColumns + ` FROM recurring_transfers WHERE status = ? AND next_run_at <= ?`
+	if after != "" {
+		query += ` AND ` + after
+	}
+	query += ` ORDER BY next_run_at, id LIMIT ?`
+	args = append([]any{models.RecurringActive, formatTime(before)}, args...)
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, page.PageSize()+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query for due recurring transfers: %w", err)
+	}
+	transfers, err := scanRecurringTransfers(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return nextPage(transfers, page.PageSize(), func(rt models.RecurringTransfer) position {
+		return position{Time: *rt.NextRunAt, ID: rt.Id}
+	})
+}
+
+// UpdateRecurringTransfer replaces a recurring transfer if it still has the version that was read.
+func (s *Store) UpdateRecurringTransfer(ctx context.Context, rt *models.RecurringTransfer) error {
+	updatedAt := time.Now().UTC()
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE recurring_transfers SET status = ?, occurrences = ?, next_run_at = ?, last_transaction_id = ?, last_error = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND version = ?`,
+		rt.Status, rt.Occurrences, nullTime(rt.NextRunAt), rt.LastTransactionId, rt.LastError, formatTime(updatedAt), rt.Id, rt.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update recurring transfer in sqlite: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update recurring transfer in sqlite: %w", err)
+	} else if n == 0 {
+		if _, err := s.GetRecurringTransfer(ctx, rt.Id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: recurring transfer %s", storage.ErrVersionConflict, rt.Id)
+	}
+
+	rt.Version++
+	rt.UpdatedAt = updatedAt
+	return nil
+}
+
+func scanRecurringTransfer(row rowScanner) (*models.RecurringTransfer, error) {
+	var (
+		rt                            models.RecurringTransfer
+		startAt, createdAt, updatedAt string
+		endAt, nextRunAt              sql.NullString
+		count                         sql.NullInt32
+	)
+	if err := row.Scan(&rt.Id, &rt.FromUserId, &rt.ToUserId, &rt.Amount, &rt.Currency,
+		&rt.Schedule.Cron, &rt.Schedule.Frequency, &rt.Schedule.Interval, &startAt, &endAt, &count,
+		&rt.Status, &rt.Occurrences, &nextRunAt, &rt.LastTransactionId, &rt.LastError, &rt.Version, &createdAt, &updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if count.Valid {
+		rt.Schedule.Count = &count.Int32
+	}
+	var err error
+	if rt.Schedule.StartAt, err = parseTime(startAt); err != nil {
+		return nil, err
+	}
+	if rt.Schedule.EndAt, err = parseNullTime(endAt); err != nil {
+		return nil, err
+	}
+	if rt.NextRunAt, err = parseNullTime(nextRunAt); err != nil {
+		return nil, err
+	}
+	if rt.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if rt.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+func scanRecurringTransfers(rows *sql.Rows) ([]models.RecurringTransfer, error) {
+	defer rows.Close()
+
+	var transfers []models.RecurringTransfer
+	for rows.Next() {
+		rt, err := scanRecurringTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recurring transfer: %w", err)
+		}
+		transfers = append(transfers, *rt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recurring transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+)
+
+// PutSchedule stores a schedule, replacing any schedule of the same transaction.
+func (s *Store) PutSchedule(ctx context.Context, schedule *scheduler.Schedule) error {
+	if _, err := s.DB.ExecContext(ctx,
+		`INSERT INTO schedules (transaction_id, due_at, body) VALUES (?, ?, ?)
+		ON CONFLICT (transaction_id) DO UPDATE SET due_at = excluded.due_at, body = excluded.body`,
+		schedule.TransactionID, formatTime(schedule.DueAt), schedule.Body,
+	); err != nil {
+		return fmt.Errorf("failed to put schedule: %w", err)
+	}
+	return nil
+}
+
+// ListDue returns up to limit schedules due at or before the given time, earliest first.
+func (s *Store) ListDue(ctx context.Context, before time.Time, limit int) ([]scheduler.Schedule, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT transaction_id, due_at, body FROM schedules WHERE due_at <= ? ORDER BY due_at, transaction_id LIMIT ?`,
+		formatTime(before), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []scheduler.Schedule
+	for rows.Next() {
+		var schedule scheduler.Schedule
+		var dueAt string
+		if err := rows.Scan(&schedule.TransactionID, &dueAt, &schedule.Body); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		if schedule.DueAt, err = parseTime(dueAt); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule removes the schedule of a transaction.
+func (s *Store) DeleteSchedule(ctx context.Context, transactionID string) error {
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM schedules WHERE transaction_id = ?`, transactionID); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/chris/delayed-wallet-transactions/pkg/websockets"
+
+	// Register the pure-Go SQLite driver with database/sql.
+	_ "modernc.org/sqlite"
+)
+
+// Store implements the Storage interface on top of an embedded SQLite database file.
+// Every write runs in a single IMMEDIATE transaction, which takes the database write
+// lock up front and so replaces DynamoDB's TransactWriteItems and version conditions.
+type Store struct {
+	DB *sql.DB
+}
+
+// New creates a new Store using an existing database handle.
+func New(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// Open opens (creating if needed) the SQLite database file at path and applies the schema migrations.
+// Use ":memory:" for a throwaway database.
+func Open(ctx context.Context, path string) (*Store, error) {
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(FULL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; one connection also keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	store := New(db)
+	if err := store.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Make sure we conform to the interfaces.
+var (
+	_ storage.Storage                 = (*Store)(nil)
+	_ websockets.ConnectionManager    = (*Store)(nil)
+	_ websockets.AllConnectionsGetter = (*Store)(nil)
+	_ scheduler.ScheduleTable         = (*Store)(nil)
+)
+
+// withTx runs fn inside a database transaction, committing if it returns nil and rolling back otherwise.
+func (s *Store) withTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	sqlTx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin database transaction: %w", err)
+	}
+
+	if err := fn(sqlTx); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, fmt.Errorf("failed to roll back database transaction: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit database transaction: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// statusCondition returns a condition that holds when a transaction's status may move to the given
+// status, and its arguments.
+func statusCondition(to models.TransactionStatus) (string, []any) {
+	sources := lifecycle.Sources(to)
+	placeholders := make([]string, len(sources))
+	args := make([]any, len(sources))
+	for i, from := range sources {
+		placeholders[i] = "?"
+		args[i] = from
+	}
+	return "status IN (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// timeLayout is a fixed-width UTC layout, so stored timestamps sort correctly as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(timeLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestamp %q: %w", value, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,282 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/scheduler"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, wallets ...models.Wallet) *Store {
+	t.Helper()
+	ctx := context.Background()
+	store, err := Open(ctx, filepath.Join(t.TempDir(), "wallet.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.DB.Close() })
+
+	for _, wallet := range wallets {
+		wallet.CreatedAt = time.Now()
+		_, err := store.CreateWallet(ctx, &wallet)
+		require.NoError(t, err)
+	}
+	return store
+}
+
+func usd(amount int64) map[string]models.CurrencyBalance {
+	return map[string]models.CurrencyBalance{"USD": {Balance: amount}}
+}
+
+func TestCreateWallet(t *testing.T) {
+	store := newTestStore(t, models.Wallet{UserId: "user1", Name: "Alice", Balances: usd(100), Version: 1})
+
+	wallet, err := store.GetWallet(context.Background(), "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", wallet.Name)
+	assert.Equal(t, int64(100), wallet.Balances["USD"].Balance)
+
+	_, err = store.CreateWallet(context.Background(), &models.Wallet{UserId: "user1"})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, storage.ErrWalletExists)
+}
+
+func TestCreateTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+		)
+		delay := int32(60)
+
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD", DelaySeconds: &delay})
+
+		assert.NoError(t, err)
+		wallet, _ := store.GetWallet(context.Background(), "user1")
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Balance)
+		assert.Equal(t, int64(100), wallet.Balances["USD"].Reserved)
+		assert.Equal(t, int64(2), wallet.Version)
+
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RESERVED, stored.Status)
+		assert.Equal(t, delay, *stored.DelaySeconds)
+	})
+
+	t.Run("Insufficient Funds", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(50), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+		)
+
+		_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+
+		assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+		txs, _, _ := store.ListTransactionsByUserID(context.Background(), "user1", storage.PageRequest{})
+		assert.Empty(t, txs)
+	})
+}
+
+func TestCancelTransaction(t *testing.T) {
+	store := newTestStore(t,
+		models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+		models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+	)
+	tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+	require.NoError(t, err)
+
+	assert.NoError(t, store.CancelTransaction(context.Background(), tx.Id))
+	assert.Equal(t, storage.ErrTransactionNotCancellable, store.CancelTransaction(context.Background(), tx.Id))
+
+	wallet, _ := store.GetWallet(context.Background(), "user1")
+	assert.Equal(t, int64(200), wallet.Balances["USD"].Balance)
+	assert.Equal(t, int64(0), wallet.Balances["USD"].Reserved)
+}
+
+func TestSettleTransaction(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(50), Version: 1},
+		)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		require.NoError(t, err)
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
+		assert.NoError(t, err)
+		assert.True(t, settled)
+
+		settled, err = store.SettleTransaction(context.Background(), tx, "worker-1")
+		assert.NoError(t, err)
+		assert.False(t, settled)
+
+		sender, _ := store.GetWallet(context.Background(), "user1")
+		receiver, _ := store.GetWallet(context.Background(), "user2")
+		assert.Equal(t, int64(0), sender.Balances["USD"].Reserved)
+		assert.Equal(t, int64(150), receiver.Balances["USD"].Balance)
+
+		entries, _, err := store.ListLedgerEntries(context.Background(), storage.PageRequest{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 6, "two opening deposits and the settlement")
+	})
+
+	t.Run("Receiver Wallet Missing", func(t *testing.T) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(200), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(0), Version: 1},
+		)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 100, Currency: "USD"})
+		require.NoError(t, err)
+		require.NoError(t, store.DeleteWallet(context.Background(), "user2"))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-1")
+
+		assert.Error(t, err)
+		assert.False(t, settled)
+		assert.Contains(t, err.Error(), "failed to get receiver's wallet for settlement")
+		entries, _, _ := store.ListLedgerEntries(context.Background(), storage.PageRequest{Limit: 10})
+		assert.Len(t, entries, 2, "only the opening deposit may be recorded")
+	})
+}
+
+func TestSettleTransactionLease(t *testing.T) {
+	newStore := func(t *testing.T) (*Store, *models.Transaction) {
+		store := newTestStore(t,
+			models.Wallet{UserId: "user1", Balances: usd(100), Version: 1},
+			models.Wallet{UserId: "user2", Balances: usd(50), Version: 1},
+		)
+		tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "user1", ToUserId: "user2", Amount: 40, Currency: "USD"})
+		require.NoError(t, err)
+		return store, tx
+	}
+
+	t.Run("Expired Lease Is Taken Over", func(t *testing.T) {
+		store, tx := newStore(t)
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "crashed", time.Now().Add(-time.Minute)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.NoError(t, err)
+		assert.True(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.COMPLETED, stored.Status)
+		assert.Equal(t, "worker-2", stored.LeaseOwner)
+	})
+
+	t.Run("Live Lease Is Kept", func(t *testing.T) {
+		store, tx := newStore(t)
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-1", time.Now().Add(storage.SettlementLease)))
+
+		settled, err := store.SettleTransaction(context.Background(), tx, "worker-2")
+
+		assert.ErrorIs(t, err, storage.ErrSettlementInProgress, "the message is retried once the lease has expired")
+		assert.False(t, settled)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+		assert.Equal(t, "worker-1", stored.LeaseOwner)
+	})
+
+	t.Run("Lost Lease Settles Nothing", func(t *testing.T) {
+		store, tx := newStore(t)
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-1", time.Now().Add(-time.Minute)))
+		require.NoError(t, store.acquireTransactionLock(context.Background(), tx.Id, "worker-2", time.Now().Add(storage.SettlementLease)))
+
+		err := store.executeSettlement(context.Background(), tx, "worker-1")
+
+		assert.ErrorIs(t, err, storage.ErrLeaseLost)
+		receiver, _ := store.GetWallet(context.Background(), "user2")
+		assert.Equal(t, int64(50), receiver.Balances["USD"].Balance)
+		stored, err := store.GetTransaction(context.Background(), tx.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.WORKING, stored.Status)
+	})
+}
+
+func TestSchedules(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, hours := range []int{48, 1, 24} {
+		require.NoError(t, store.PutSchedule(ctx, &scheduler.Schedule{
+			TransactionID: fmt.Sprintf("tx-%d", i),
+			DueAt:         start.Add(time.Duration(hours) * time.Hour),
+			Body:          fmt.Sprintf(`{"id":"tx-%d"}`, i),
+		}))
+	}
+
+	due, err := store.ListDue(ctx, start.Add(24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, scheduler.Schedule{TransactionID: "tx-1", DueAt: start.Add(time.Hour), Body: `{"id":"tx-1"}`}, due[0])
+	assert.Equal(t, "tx-2", due[1].TransactionID, "a schedule due exactly at the bound is listed")
+
+	require.NoError(t, store.PutSchedule(ctx, &scheduler.Schedule{TransactionID: "tx-1", DueAt: start.Add(96 * time.Hour), Body: "{}"}))
+	require.NoError(t, store.DeleteSchedule(ctx, "tx-2"))
+	require.NoError(t, store.DeleteSchedule(ctx, "missing"))
+
+	due, err = store.ListDue(ctx, start.Add(96*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, "tx-0", due[0].TransactionID)
+	assert.Equal(t, "tx-1", due[1].TransactionID, "putting a schedule again replaces it")
+}
+
+func TestQueriesUseIndexes(t *testing.T) {
+	store := newTestStore(t)
+
+	queries := map[string]string{
+		"transactions_from_user_id_idx":      `SELECT ` + transactionColumns + ` FROM transactions WHERE from_user_id = 'user1' AND (created_at, id) < ('x', 'y') ORDER BY created_at DESC, id DESC LIMIT 21`,
+		"transactions_to_user_id_idx":        `SELECT ` + transactionColumns + ` FROM transactions WHERE to_user_id = 'user1' AND (created_at, id) < ('x', 'y') ORDER BY created_at DESC, id DESC LIMIT 21`,
+		"transactions_status_created_at_idx": `SELECT ` + transactionColumns + ` FROM transactions WHERE status = 'RESERVED' AND created_at < 'x' AND (created_at, id) > ('w', 'y') ORDER BY created_at, id LIMIT 21`,
+		"transactions_idempotency_key_idx":   `SELECT ` + transactionColumns + ` FROM transactions WHERE idempotency_key = 'key-1'`,
+		"ledger_entries_timestamp_idx":       `SELECT entry_id FROM ledger_entries WHERE (timestamp, entry_id) < ('x', 'y') ORDER BY timestamp DESC, entry_id DESC LIMIT 21`,
+		"ledger_entries_account_id_idx":      `SELECT ` + ledgerEntryColumns + ` FROM ledger_entries WHERE account_id = 'user1' AND currency = 'USD' AND timestamp >= 'v' AND timestamp < 'x' AND (timestamp, entry_id) > ('w', 'y') ORDER BY timestamp, entry_id LIMIT 21`,
+		"wallets_created_at_idx":             `SELECT ` + walletColumns + ` FROM wallets WHERE (created_at, user_id) < ('x', 'y') ORDER BY created_at DESC, user_id DESC LIMIT 21`,
+		"schedules_due_at_idx":               `SELECT transaction_id, due_at, body FROM schedules WHERE due_at <= 'x' ORDER BY due_at, transaction_id LIMIT 100`,
+	}
+	for index, query := range queries {
+		plan := queryPlan(t, store, query)
+
+		assert.Contains(t, plan, index)
+		assert.NotContains(t, plan, "TEMP B-TREE", "%s must return rows in index order", index)
+	}
+
+	t.Run("Activity", func(t *testing.T) {
+		side := func(column string) string {
+			return `SELECT * FROM (SELECT ` + transactionColumns + ` FROM transactions WHERE ` + column + ` = 'user1' ORDER BY created_at DESC, id DESC LIMIT 21)`
+		}
+		plan := queryPlan(t, store, side("from_user_id")+` UNION `+side("to_user_id")+` ORDER BY created_at DESC, id DESC LIMIT 21`)
+
+		assert.Contains(t, plan, "SEARCH transactions USING INDEX transactions_from_user_id_idx")
+		assert.Contains(t, plan, "SEARCH transactions USING INDEX transactions_to_user_id_idx")
+	})
+
+	t.Run("SumLedgerEntries", func(t *testing.T) {
+		plan := queryPlan(t, store, `SELECT COALESCE(SUM(credit - debit), 0) FROM ledger_entries WHERE account_id = 'user1' AND currency = 'USD' AND (timestamp, entry_id) < ('x', 'y')`)
+
+		assert.Contains(t, plan, "SEARCH ledger_entries USING INDEX ledger_entries_account_id_idx (account_id=? AND currency=? AND")
+	})
+}
+
+func queryPlan(t *testing.T, store *Store, query string) string {
+	t.Helper()
+	rows, err := store.DB.Query(`EXPLAIN QUERY PLAN ` + query)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var plan string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		require.NoError(t, rows.Scan(&id, &parent, &notUsed, &detail))
+		plan += detail + "\n"
+	}
+	return plan
+}
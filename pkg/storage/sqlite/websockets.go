@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// AddConnection saves a new WebSocket connection ID to the database idempotently.
+func (s *Store) AddConnection(ctx context.Context, connectionID string) error {
+	if _, err := s.DB.ExecContext(ctx,
+		`INSERT INTO websocket_connections (connection_id) VALUES (?) ON CONFLICT (connection_id) DO NOTHING`,
+		connectionID,
+	); err != nil {
+		return fmt.Errorf("failed to insert connection: %w", err)
+	}
+	return nil
+}
+
+// RemoveConnection deletes a WebSocket connection ID from the database.
+func (s *Store) RemoveConnection(ctx context.Context, connectionID string) error {
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM websocket_connections WHERE connection_id = ?`, connectionID); err != nil {
+		return fmt.Errorf("failed to delete connection: %w", err)
+	}
+	return nil
+}
+
+// GetAllConnections retrieves all active WebSocket connection IDs from the database.
+func (s *Store) GetAllConnections(ctx context.Context) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT connection_id FROM websocket_connections ORDER BY connection_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query connections table: %w", err)
+	}
+	defer rows.Close()
+
+	var connectionIDs []string
+	for rows.Next() {
+		var connectionID string
+		if err := rows.Scan(&connectionID); err != nil {
+			return nil, fmt.Errorf("failed to scan connection: %w", err)
+		}
+		connectionIDs = append(connectionIDs, connectionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read connections: %w", err)
+	}
+
+	return connectionIDs, nil
+}
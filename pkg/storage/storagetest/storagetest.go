@@ -0,0 +1,1345 @@
+// Package storagetest provides a conformance test suite for storage.Storage implementations.
+//
+// Each backend runs the suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage { return memory.New() })
+//	}
+package storagetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chris/delayed-wallet-transactions/pkg/models"
+	"github.com/chris/delayed-wallet-transactions/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new, empty store. It is called once per subtest.
+type Factory func(t *testing.T) storage.Storage
+
+// leaseOwner is the settlement worker the suite settles transactions as.
+const leaseOwner = "settlement-worker"
+
+// Run checks the behavioral contract of a storage.Storage implementation.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store storage.Storage)
+	}{
+		{"Wallets", testWallets},
+		{"CreateTransactionReservesFunds", testCreateTransactionReservesFunds},
+		{"CreateTransactionInsufficientFunds", testCreateTransactionInsufficientFunds},
+		{"CreateTransactionCurrencyNotHeld", testCreateTransactionCurrencyNotHeld},
+		{"ExecuteAt", testExecuteAt},
+		{"CurrenciesAreSeparate", testCurrenciesAreSeparate},
+		{"IdempotencyKey", testIdempotencyKey},
+		{"ConcurrentIdempotencyKey", testConcurrentIdempotencyKey},
+		{"CancelTransaction", testCancelTransaction},
+		{"CancelAfterSettle", testCancelAfterSettle},
+		{"SettleTransaction", testSettleTransaction},
+		{"SettleSelfTransfer", testSettleSelfTransfer},
+		{"DoubleSettle", testDoubleSettle},
+		{"SettleCancelled", testSettleCancelled},
+		{"SettleBeforeDue", testSettleBeforeDue},
+		{"SettleFailure", testSettleFailure},
+		{"ApproveTransaction", testApproveTransaction},
+		{"RejectTransaction", testRejectTransaction},
+		{"CancelPendingApproval", testCancelPendingApproval},
+		{"FundsAreConserved", testFundsAreConserved},
+		{"Deposit", testDeposit},
+		{"Withdraw", testWithdraw},
+		{"LedgerRebuildsBalances", testLedgerRebuildsBalances},
+		{"LedgerChain", testLedgerChain},
+		{"ConcurrentDepositsKeepLedgerChain", testConcurrentDepositsKeepLedgerChain},
+		{"ConcurrentReservationsNeverOverdraw", testConcurrentReservations},
+		{"ListTransactionsByUserID", testListTransactionsByUserID},
+		{"ListIncomingTransactionsByUserID", testListIncomingTransactionsByUserID},
+		{"GetStuckTransactions", testGetStuckTransactions},
+		{"ListLedgerEntries", testListLedgerEntries},
+		{"LedgerStatement", testLedgerStatement},
+		{"PaginateWallets", testPaginateWallets},
+		{"PaginateTransactionsByUserID", testPaginateTransactionsByUserID},
+		{"PaginateActivityByUserID", testPaginateActivityByUserID},
+		{"PaginateStuckTransactions", testPaginateStuckTransactions},
+		{"PaginateLedgerEntries", testPaginateLedgerEntries},
+		{"PaginateLedgerEntriesBetween", testPaginateLedgerEntriesBetween},
+		{"RecurringTransfers", testRecurringTransfers},
+		{"PaginateRecurringTransfersByUserID", testPaginateRecurringTransfersByUserID},
+		{"PaginateDueRecurringTransfers", testPaginateDueRecurringTransfers},
+		{"InvalidCursor", testInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// currency is the currency of the wallets created by seedWallets and the transactions created by createTransaction.
+const currency = "USD"
+
+// seedWallets creates a wallet with the given balance in currency for each user ID.
+func seedWallets(t *testing.T, store storage.Storage, balances map[string]int64) {
+	t.Helper()
+	for userID, balance := range balances {
+		_, err := store.CreateWallet(context.Background(), &models.Wallet{
+			UserId:    userID,
+			Name:      userID,
+			Balances:  map[string]models.CurrencyBalance{currency: {Balance: balance}},
+			Version:   1,
+			CreatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+}
+
+// openingDeposit returns the deposit that recorded a seeded wallet's opening balance.
+func openingDeposit(t *testing.T, store storage.Storage, userID string) models.Transaction {
+	t.Helper()
+	txs, _, err := store.ListIncomingTransactionsByUserID(context.Background(), userID, storage.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, txs, 1, "a seeded wallet must have received exactly its opening deposit")
+	assert.Equal(t, models.FundingAccount, txs[0].FromUserId)
+	assert.Equal(t, models.COMPLETED, txs[0].Status)
+	return txs[0]
+}
+
+func getWallet(t *testing.T, store storage.Storage, userID string) *models.Wallet {
+	t.Helper()
+	wallet, err := store.GetWallet(context.Background(), userID)
+	require.NoError(t, err)
+	return wallet
+}
+
+// getBalance returns a wallet's balance in currency.
+func getBalance(t *testing.T, store storage.Storage, userID string) models.CurrencyBalance {
+	t.Helper()
+	balance, ok := getWallet(t, store, userID).Balances[currency]
+	require.True(t, ok, "wallet %s must hold %s", userID, currency)
+	return balance
+}
+
+func createTransaction(t *testing.T, store storage.Storage, from, to string, amount int64) *models.Transaction {
+	t.Helper()
+	tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: from, ToUserId: to, Amount: amount, Currency: currency})
+	require.NoError(t, err)
+	return tx
+}
+
+// totalFunds sums balance plus reserved in currency across the given wallets.
+func totalFunds(t *testing.T, store storage.Storage, userIDs ...string) int64 {
+	t.Helper()
+	var total int64
+	for _, userID := range userIDs {
+		balance := getBalance(t, store, userID)
+		total += balance.Balance + balance.Reserved
+	}
+	return total
+}
+
+func testWallets(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 50})
+
+	wallet := getWallet(t, store, "alice")
+	assert.Equal(t, "alice", wallet.UserId)
+	assert.Equal(t, map[string]models.CurrencyBalance{currency: {Balance: 100}}, wallet.Balances)
+
+	_, err := store.CreateWallet(ctx, &models.Wallet{UserId: "alice", Version: 1, CreatedAt: time.Now()})
+	assert.Error(t, err, "creating a duplicate wallet must fail")
+	assert.Equal(t, int64(100), getBalance(t, store, "alice").Balance, "a duplicate create must not overwrite the wallet")
+
+	_, err = store.CreateWallet(ctx, &models.Wallet{
+		UserId:    "carol",
+		Balances:  map[string]models.CurrencyBalance{"USD": {Balance: 10}, "EUR": {Balance: 20}},
+		Version:   1,
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.CurrencyBalance{"USD": {Balance: 10}, "EUR": {Balance: 20}}, getWallet(t, store, "carol").Balances)
+
+	wallets, next, err := store.ListWallets(ctx, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, wallets, 3)
+	assert.Empty(t, next, "a complete listing must not return a cursor")
+	for _, wallet := range wallets {
+		assert.Equal(t, getWallet(t, store, wallet.UserId).Balances, wallet.Balances, "listed wallets must carry their balances")
+	}
+
+	require.NoError(t, store.DeleteWallet(ctx, "bob"))
+	_, err = store.GetWallet(ctx, "bob")
+	assert.Error(t, err)
+	assert.Error(t, store.DeleteWallet(ctx, "bob"), "deleting a missing wallet must fail")
+}
+
+func testCreateTransactionReservesFunds(t *testing.T, store storage.Storage) {
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	before := getWallet(t, store, "alice")
+
+	tx := createTransaction(t, store, "alice", "bob", 40)
+
+	assert.NotEmpty(t, tx.Id)
+	assert.Equal(t, models.RESERVED, tx.Status)
+	assert.False(t, tx.CreatedAt.IsZero())
+
+	after := getWallet(t, store, "alice")
+	assert.Equal(t, models.CurrencyBalance{Balance: 60, Reserved: 40}, after.Balances[currency])
+	assert.Greater(t, after.Version, before.Version, "reserving funds must bump the wallet version")
+
+	stored, err := store.GetTransaction(context.Background(), tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RESERVED, stored.Status)
+	assert.Equal(t, int64(40), stored.Amount)
+	assert.Equal(t, currency, stored.Currency)
+}
+
+func testCreateTransactionInsufficientFunds(t *testing.T, store storage.Storage) {
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+
+	_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 101, Currency: currency})
+
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	wallet := getBalance(t, store, "alice")
+	assert.Equal(t, int64(100), wallet.Balance)
+	assert.Equal(t, int64(0), wallet.Reserved)
+
+	txs, _, err := store.ListTransactionsByUserID(context.Background(), "alice", storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, txs, "a rejected reservation must not create a transaction")
+}
+
+func testExecuteAt(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	executeAt := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+
+	scheduled, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 40, Currency: currency, ExecuteAt: &executeAt})
+	require.NoError(t, err)
+	unscheduled := createTransaction(t, store, "alice", "bob", 10)
+
+	stored, err := store.GetTransaction(ctx, scheduled.Id)
+	require.NoError(t, err)
+	if assert.NotNil(t, stored.ExecuteAt, "execute_at must be stored") {
+		assert.True(t, executeAt.Equal(*stored.ExecuteAt), "got %v, want %v", *stored.ExecuteAt, executeAt)
+	}
+
+	stored, err = store.GetTransaction(ctx, unscheduled.Id)
+	require.NoError(t, err)
+	assert.Nil(t, stored.ExecuteAt)
+}
+
+func testCreateTransactionCurrencyNotHeld(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100})
+	_, err := store.CreateWallet(ctx, &models.Wallet{
+		UserId:    "bob",
+		Balances:  map[string]models.CurrencyBalance{"EUR": {}},
+		Version:   1,
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 10, Currency: "EUR"})
+	assert.ErrorIs(t, err, storage.ErrCurrencyNotHeld, "the sender must hold the currency")
+
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 10, Currency: currency})
+	assert.ErrorIs(t, err, storage.ErrCurrencyNotHeld, "the receiver must hold the currency")
+
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "nobody", Amount: 10, Currency: currency})
+	assert.ErrorIs(t, err, storage.ErrWalletNotFound, "the receiver must exist")
+
+	assert.Equal(t, models.CurrencyBalance{Balance: 100}, getBalance(t, store, "alice"), "a rejected transaction must not reserve funds")
+	txs, _, err := store.ListTransactionsByUserID(ctx, "alice", storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, txs, "a rejected transaction must not be created")
+}
+
+func testCurrenciesAreSeparate(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	for _, userID := range []string{"alice", "bob"} {
+		_, err := store.CreateWallet(ctx, &models.Wallet{
+			UserId:    userID,
+			Balances:  map[string]models.CurrencyBalance{"USD": {Balance: 100}, "EUR": {Balance: 100}},
+			Version:   1,
+			CreatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+
+	tx, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 40, Currency: "EUR"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.CurrencyBalance{"USD": {Balance: 100}, "EUR": {Balance: 60, Reserved: 40}}, getWallet(t, store, "alice").Balances)
+
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 70, Currency: "EUR"})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds, "funds in another currency must not count")
+
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+	require.NoError(t, err)
+	require.True(t, settled)
+	assert.Equal(t, map[string]models.CurrencyBalance{"USD": {Balance: 100}, "EUR": {Balance: 60}}, getWallet(t, store, "alice").Balances)
+	assert.Equal(t, map[string]models.CurrencyBalance{"USD": {Balance: 100}, "EUR": {Balance: 140}}, getWallet(t, store, "bob").Balances)
+
+	entries, _, err := store.ListLedgerEntries(ctx, storage.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, entries, 10, "four opening deposits and the settlement")
+	for _, entry := range entries {
+		if entry.TransactionID == tx.Id {
+			assert.Equal(t, "EUR", entry.Currency)
+		}
+	}
+
+	end := time.Now().Add(time.Hour)
+	statement, _, err := storage.GetLedgerStatement(ctx, store, "bob", "EUR", time.Time{}, end, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, statement.Lines, 2)
+	assert.Equal(t, int64(140), statement.ClosingBalance)
+	statement, _, err = storage.GetLedgerStatement(ctx, store, "bob", "USD", time.Time{}, end, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, statement.Lines, 1, "a statement must only list entries in its currency")
+	assert.Equal(t, int64(100), statement.ClosingBalance)
+
+	cancelMe, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "bob", ToUserId: "alice", Amount: 30, Currency: "USD"})
+	require.NoError(t, err)
+	require.NoError(t, store.CancelTransaction(ctx, cancelMe.Id))
+	assert.Equal(t, map[string]models.CurrencyBalance{"USD": {Balance: 100}, "EUR": {Balance: 140}}, getWallet(t, store, "bob").Balances)
+}
+
+func testIdempotencyKey(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+
+	_, err := store.GetTransactionByIdempotencyKey(ctx, "key-1")
+	assert.ErrorIs(t, err, storage.ErrTransactionNotFound)
+
+	original, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 40, Currency: currency, IdempotencyKey: "key-1", RequestFingerprint: "fp-1"})
+	require.NoError(t, err)
+
+	// The key is reported as used even when the sender can no longer afford the retried request.
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 80, Currency: currency, IdempotencyKey: "key-1", RequestFingerprint: "fp-2"})
+	assert.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 40, Currency: currency, IdempotencyKey: "key-1", RequestFingerprint: "fp-1"})
+	assert.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+
+	wallet := getBalance(t, store, "alice")
+	assert.Equal(t, int64(60), wallet.Balance, "a reused key must not reserve funds again")
+	assert.Equal(t, int64(40), wallet.Reserved)
+
+	stored, err := store.GetTransactionByIdempotencyKey(ctx, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, original.Id, stored.Id)
+	assert.Equal(t, "key-1", stored.IdempotencyKey)
+	assert.Equal(t, "fp-1", stored.RequestFingerprint)
+
+	// Transactions without a key are never treated as duplicates of each other.
+	createTransaction(t, store, "alice", "bob", 10)
+	createTransaction(t, store, "alice", "bob", 10)
+	assert.Equal(t, int64(40), getBalance(t, store, "alice").Balance)
+}
+
+func testConcurrentIdempotencyKey(t *testing.T, store storage.Storage) {
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+
+	const attempts = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 10, Currency: currency, IdempotencyKey: "key-1", RequestFingerprint: "fp-1"})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, succeeded, 1, "an idempotency key can create at most one transaction")
+	assert.Equal(t, int64(succeeded)*10, getBalance(t, store, "alice").Reserved)
+}
+
+func testCancelTransaction(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createTransaction(t, store, "alice", "bob", 40)
+
+	require.NoError(t, store.CancelTransaction(ctx, tx.Id))
+
+	wallet := getBalance(t, store, "alice")
+	assert.Equal(t, int64(100), wallet.Balance)
+	assert.Equal(t, int64(0), wallet.Reserved)
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.CANCELLED, stored.Status)
+
+	assert.ErrorIs(t, store.CancelTransaction(ctx, tx.Id), storage.ErrTransactionNotCancellable)
+	assert.Equal(t, int64(100), getBalance(t, store, "alice").Balance, "a second cancel must not release funds again")
+}
+
+func testCancelAfterSettle(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createTransaction(t, store, "alice", "bob", 40)
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+	require.NoError(t, err)
+	require.True(t, settled)
+
+	err = store.CancelTransaction(ctx, tx.Id)
+
+	assert.ErrorIs(t, err, storage.ErrTransactionNotCancellable)
+	assert.Equal(t, int64(60), getBalance(t, store, "alice").Balance)
+	assert.Equal(t, int64(40), getBalance(t, store, "bob").Balance)
+}
+
+func testSettleTransaction(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 10})
+	tx := createTransaction(t, store, "alice", "bob", 40)
+
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+
+	require.NoError(t, err)
+	assert.True(t, settled)
+	sender := getBalance(t, store, "alice")
+	receiver := getBalance(t, store, "bob")
+	assert.Equal(t, int64(60), sender.Balance)
+	assert.Equal(t, int64(0), sender.Reserved)
+	assert.Equal(t, int64(50), receiver.Balance)
+	assert.Equal(t, int64(0), receiver.Reserved)
+
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.COMPLETED, stored.Status)
+	assert.Equal(t, leaseOwner, stored.LeaseOwner)
+	require.NotNil(t, stored.LeaseExpiresAt)
+	assert.True(t, stored.LeaseExpiresAt.After(time.Now()))
+}
+
+func testSettleSelfTransfer(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100})
+	tx := createTransaction(t, store, "alice", "alice", 40)
+	assert.Equal(t, models.CurrencyBalance{Balance: 60, Reserved: 40}, getBalance(t, store, "alice"))
+
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+
+	require.NoError(t, err)
+	assert.True(t, settled)
+	assert.Equal(t, models.CurrencyBalance{Balance: 100}, getBalance(t, store, "alice"), "the reserved funds must return to the balance")
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.COMPLETED, stored.Status)
+
+	broken, err := storage.VerifyChain(ctx, store, "alice", currency)
+	require.NoError(t, err)
+	assert.Nil(t, broken, "the debit and the credit must both be linked into the chain")
+}
+
+func testDoubleSettle(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createTransaction(t, store, "alice", "bob", 40)
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+	require.NoError(t, err)
+	require.True(t, settled)
+
+	settled, err = store.SettleTransaction(ctx, tx, leaseOwner)
+
+	assert.NoError(t, err)
+	assert.False(t, settled)
+	assert.Equal(t, int64(40), getBalance(t, store, "bob").Balance, "a second settle must not move funds again")
+
+	entries, _, err := store.ListLedgerEntries(ctx, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 4, "a second settle must not write ledger entries")
+}
+
+func testSettleCancelled(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createTransaction(t, store, "alice", "bob", 40)
+	require.NoError(t, store.CancelTransaction(ctx, tx.Id))
+
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+
+	assert.NoError(t, err)
+	assert.False(t, settled)
+	assert.Equal(t, int64(0), getBalance(t, store, "bob").Balance)
+	assert.Equal(t, int64(100), getBalance(t, store, "alice").Balance)
+}
+
+func testSettleBeforeDue(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	delay := int32(600)
+	delayed, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 40, Currency: currency, DelaySeconds: &delay})
+	require.NoError(t, err)
+	overdue := time.Now().Add(-time.Hour)
+	late, err := store.CreateTransaction(ctx, &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 10, Currency: currency, ExecuteAt: &overdue})
+	require.NoError(t, err)
+
+	stored, err := store.GetTransaction(ctx, delayed.Id)
+	require.NoError(t, err)
+	dueAt := storage.DueAt(delayed)
+	if assert.NotNil(t, stored.DueAt, "due_at must be stored") {
+		assert.True(t, dueAt.Equal(*stored.DueAt), "got %v, want %v", *stored.DueAt, dueAt)
+		assert.False(t, stored.DueAt.Before(delayed.CreatedAt.Add(10*time.Minute)), "a transaction must not be due before its delay has passed")
+		assert.Zero(t, stored.DueAt.Nanosecond(), "due times are whole seconds")
+	}
+
+	settled, err := store.SettleTransaction(ctx, delayed, leaseOwner)
+
+	assert.False(t, settled)
+	var notDue *storage.NotDueError
+	if assert.ErrorAs(t, err, &notDue) {
+		assert.ErrorIs(t, err, storage.ErrTransactionNotDue)
+		assert.True(t, dueAt.Equal(notDue.DueAt), "got %v, want %v", notDue.DueAt, dueAt)
+	}
+	stored, err = store.GetTransaction(ctx, delayed.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RESERVED, stored.Status, "an early settle must leave the transaction reserved")
+	assert.Equal(t, int64(0), getBalance(t, store, "bob").Balance)
+
+	settled, err = store.SettleTransaction(ctx, late, leaseOwner)
+	require.NoError(t, err)
+	assert.True(t, settled, "a transaction past its execute_at settles at once")
+}
+
+// createPendingTransaction creates a transaction in currency that needs approval, reserving amount from the sender.
+func createPendingTransaction(t *testing.T, store storage.Storage, from, to string, amount int64) *models.Transaction {
+	t.Helper()
+	tx, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: from, ToUserId: to, Amount: amount, Currency: currency, Status: models.PENDING_APPROVAL})
+	require.NoError(t, err)
+	return tx
+}
+
+func testSettleFailure(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createTransaction(t, store, "alice", "bob", 40)
+	require.NoError(t, store.DeleteWallet(ctx, "bob"))
+
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+
+	assert.False(t, settled)
+	var failed *storage.SettlementFailedError
+	require.ErrorAs(t, err, &failed)
+	assert.ErrorIs(t, err, storage.ErrSettlementFailed)
+	assert.Equal(t, tx.Id, failed.TransactionID)
+	assert.Equal(t, storage.FailureWalletNotFound, failed.Reason)
+	assert.ErrorIs(t, failed.Err, storage.ErrWalletNotFound, "the error behind the failure is kept for the logs")
+
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.FAILED, stored.Status)
+	assert.Equal(t, failed.Reason, stored.FailureReason)
+	assert.Equal(t, models.CurrencyBalance{Balance: 100}, getBalance(t, store, "alice"), "the reserved funds must be released")
+
+	entries, _, err := store.ListLedgerEntries(ctx, storage.PageRequest{})
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotEqual(t, tx.Id, entry.TransactionID, "a failed settlement must not write ledger entries")
+	}
+
+	// A failed transaction is final.
+	settled, err = store.SettleTransaction(ctx, tx, leaseOwner)
+	assert.NoError(t, err)
+	assert.False(t, settled)
+	assert.ErrorIs(t, store.CancelTransaction(ctx, tx.Id), storage.ErrTransactionNotCancellable)
+	assert.Equal(t, models.CurrencyBalance{Balance: 100}, getBalance(t, store, "alice"))
+}
+
+func testApproveTransaction(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createPendingTransaction(t, store, "alice", "bob", 40)
+	assert.Equal(t, models.PENDING_APPROVAL, tx.Status)
+	assert.Equal(t, int64(40), getBalance(t, store, "alice").Reserved, "funds are reserved while approval is pending")
+
+	settled, err := store.SettleTransaction(ctx, tx, leaseOwner)
+	require.NoError(t, err)
+	assert.False(t, settled, "a transaction pending approval must not settle")
+
+	approved, err := store.ApproveTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Id, approved.Id)
+	assert.Equal(t, models.APPROVED, approved.Status)
+	_, err = store.ApproveTransaction(ctx, tx.Id)
+	assert.ErrorIs(t, err, storage.ErrTransactionNotPendingApproval, "a transaction is approved once")
+	assert.ErrorIs(t, store.RejectTransaction(ctx, tx.Id), storage.ErrTransactionNotPendingApproval)
+
+	stuck, _, err := store.GetStuckTransactions(ctx, models.APPROVED, -time.Minute, storage.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, stuck, 1)
+	assert.Equal(t, tx.Id, stuck[0].Id)
+
+	settled, err = store.SettleTransaction(ctx, approved, leaseOwner)
+	require.NoError(t, err)
+	assert.True(t, settled)
+	assert.Equal(t, int64(60), getBalance(t, store, "alice").Balance)
+	assert.Equal(t, int64(0), getBalance(t, store, "alice").Reserved)
+	assert.Equal(t, int64(40), getBalance(t, store, "bob").Balance)
+
+	_, err = store.ApproveTransaction(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrTransactionNotFound)
+}
+
+func testRejectTransaction(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	tx := createPendingTransaction(t, store, "alice", "bob", 40)
+	reserved := createTransaction(t, store, "alice", "bob", 10)
+
+	require.NoError(t, store.RejectTransaction(ctx, tx.Id))
+
+	wallet := getBalance(t, store, "alice")
+	assert.Equal(t, int64(90), wallet.Balance)
+	assert.Equal(t, int64(10), wallet.Reserved)
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.REJECTED, stored.Status)
+
+	assert.ErrorIs(t, store.RejectTransaction(ctx, tx.Id), storage.ErrTransactionNotPendingApproval)
+	assert.Equal(t, int64(90), getBalance(t, store, "alice").Balance, "a second reject must not release funds again")
+	_, err = store.ApproveTransaction(ctx, tx.Id)
+	assert.ErrorIs(t, err, storage.ErrTransactionNotPendingApproval)
+	assert.ErrorIs(t, store.RejectTransaction(ctx, reserved.Id), storage.ErrTransactionNotPendingApproval, "a transaction that needs no approval cannot be rejected")
+	assert.ErrorIs(t, store.RejectTransaction(ctx, "missing"), storage.ErrTransactionNotFound)
+}
+
+func testCancelPendingApproval(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	pending := createPendingTransaction(t, store, "alice", "bob", 40)
+	approved := createPendingTransaction(t, store, "alice", "bob", 10)
+	_, err := store.ApproveTransaction(ctx, approved.Id)
+	require.NoError(t, err)
+
+	require.NoError(t, store.CancelTransaction(ctx, pending.Id))
+	require.NoError(t, store.CancelTransaction(ctx, approved.Id))
+
+	wallet := getBalance(t, store, "alice")
+	assert.Equal(t, int64(100), wallet.Balance)
+	assert.Equal(t, int64(0), wallet.Reserved)
+	_, err = store.ApproveTransaction(ctx, pending.Id)
+	assert.ErrorIs(t, err, storage.ErrTransactionNotPendingApproval, "a cancelled transaction cannot be approved")
+}
+
+func testFundsAreConserved(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 500, "bob": 300, "carol": 0})
+	users := []string{"alice", "bob", "carol"}
+	total := totalFunds(t, store, users...)
+
+	settleMe := createTransaction(t, store, "alice", "bob", 120)
+	assert.Equal(t, total, totalFunds(t, store, users...), "create must conserve funds")
+
+	cancelMe := createTransaction(t, store, "bob", "carol", 75)
+	assert.Equal(t, total, totalFunds(t, store, users...), "create must conserve funds")
+
+	require.NoError(t, store.CancelTransaction(ctx, cancelMe.Id))
+	assert.Equal(t, total, totalFunds(t, store, users...), "cancel must conserve funds")
+
+	_, err := store.SettleTransaction(ctx, settleMe, leaseOwner)
+	require.NoError(t, err)
+	assert.Equal(t, total, totalFunds(t, store, users...), "settle must conserve funds")
+
+	_, err = store.CreateTransaction(ctx, &models.Transaction{FromUserId: "carol", ToUserId: "alice", Amount: 1, Currency: currency})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds)
+	assert.Equal(t, total, totalFunds(t, store, users...), "a rejected create must conserve funds")
+
+	for _, userID := range users {
+		wallet := getBalance(t, store, userID)
+		assert.GreaterOrEqual(t, wallet.Balance, int64(0))
+		assert.Equal(t, int64(0), wallet.Reserved, "no transactions are pending, so nothing may stay reserved")
+	}
+}
+
+func testDeposit(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100})
+
+	tx, err := store.Deposit(ctx, &models.Transaction{ToUserId: "alice", Amount: 50, Currency: currency})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, tx.Id)
+	assert.Equal(t, models.FundingAccount, tx.FromUserId)
+	assert.Equal(t, models.COMPLETED, tx.Status)
+	assert.Equal(t, models.CurrencyBalance{Balance: 150}, getBalance(t, store, "alice"))
+	stored, err := store.GetTransaction(ctx, tx.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.COMPLETED, stored.Status)
+
+	end := time.Now().Add(time.Hour)
+	funding, err := store.SumLedgerEntries(ctx, models.FundingAccount, currency, end, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-150), funding, "the funding account must be debited for the opening balance and the deposit")
+
+	_, err = store.Deposit(ctx, &models.Transaction{ToUserId: "alice", Amount: 50, Currency: "EUR"})
+	assert.ErrorIs(t, err, storage.ErrCurrencyNotHeld)
+	_, err = store.Deposit(ctx, &models.Transaction{ToUserId: "nobody", Amount: 50, Currency: currency})
+	assert.ErrorIs(t, err, storage.ErrWalletNotFound)
+	assert.Equal(t, models.CurrencyBalance{Balance: 150}, getBalance(t, store, "alice"))
+}
+
+func testWithdraw(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+
+	tx, err := store.Withdraw(ctx, &models.Transaction{FromUserId: "alice", Amount: 30, Currency: currency})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.PayoutsAccount, tx.ToUserId)
+	assert.Equal(t, models.COMPLETED, tx.Status)
+	assert.Equal(t, models.CurrencyBalance{Balance: 70}, getBalance(t, store, "alice"))
+
+	createTransaction(t, store, "alice", "bob", 50)
+	_, err = store.Withdraw(ctx, &models.Transaction{FromUserId: "alice", Amount: 30, Currency: currency})
+	assert.ErrorIs(t, err, storage.ErrInsufficientFunds, "reserved funds must not be withdrawn")
+	assert.Equal(t, models.CurrencyBalance{Balance: 20, Reserved: 50}, getBalance(t, store, "alice"))
+
+	payouts, err := store.SumLedgerEntries(ctx, models.PayoutsAccount, currency, time.Now().Add(time.Hour), "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), payouts)
+}
+
+func testLedgerRebuildsBalances(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 500, "bob": 300, "carol": 0})
+	users := []string{"alice", "bob", "carol"}
+
+	_, err := store.Deposit(ctx, &models.Transaction{ToUserId: "carol", Amount: 25, Currency: currency})
+	require.NoError(t, err)
+	settleMe := createTransaction(t, store, "alice", "carol", 120)
+	_, err = store.SettleTransaction(ctx, settleMe, leaseOwner)
+	require.NoError(t, err)
+	createTransaction(t, store, "bob", "carol", 75)
+	_, err = store.Withdraw(ctx, &models.Transaction{FromUserId: "carol", Amount: 45, Currency: currency})
+	require.NoError(t, err)
+
+	// Reserved funds have not left the sender yet, so the ledger still counts them as theirs.
+	end := time.Now().Add(time.Hour)
+	for _, userID := range users {
+		sum, err := store.SumLedgerEntries(ctx, userID, currency, end, "")
+		require.NoError(t, err)
+		assert.Equal(t, totalFunds(t, store, userID), sum, "the ledger must rebuild the funds of %s", userID)
+	}
+}
+
+func testLedgerChain(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 500, "carol": 0})
+
+	_, err := store.Deposit(ctx, &models.Transaction{ToUserId: "carol", Amount: 25, Currency: currency})
+	require.NoError(t, err)
+	settleMe := createTransaction(t, store, "alice", "carol", 120)
+	_, err = store.SettleTransaction(ctx, settleMe, leaseOwner)
+	require.NoError(t, err)
+	_, err = store.Withdraw(ctx, &models.Transaction{FromUserId: "carol", Amount: 45, Currency: currency})
+	require.NoError(t, err)
+
+	for _, accountID := range []string{"alice", "carol", models.FundingAccount, models.PayoutsAccount} {
+		broken, err := storage.VerifyChain(ctx, store, accountID, currency)
+		require.NoError(t, err)
+		assert.Nil(t, broken, "the ledger chain of %s must verify", accountID)
+	}
+
+	// Carol's chain links the deposit, the settlement and the withdrawal in order.
+	entries, _, err := store.ListLedgerEntriesByAccount(ctx, "carol", currency, time.Time{}, time.Now().Add(time.Hour), storage.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, entry := range entries {
+		assert.Equal(t, int64(i+1), entry.Sequence)
+		assert.Equal(t, storage.EntryHash(&entry), entry.Hash)
+	}
+	assert.Empty(t, entries[0].PreviousHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PreviousHash)
+	assert.Equal(t, settleMe.Id, entries[1].TransactionID)
+	assert.Equal(t, entries[1].Hash, entries[2].PreviousHash)
+	head, err := store.GetChainHead(ctx, storage.ChainKey{AccountID: "carol", Currency: currency})
+	require.NoError(t, err)
+	assert.Equal(t, storage.ChainHead{Sequence: 3, Hash: entries[2].Hash}, head, "the chain head is the last entry")
+
+	// Rewriting or removing an entry breaks the chain at that entry.
+	rewritten := append([]models.LedgerEntry(nil), entries...)
+	rewritten[1].Credit++
+	assert.Equal(t, &storage.ChainBreak{Sequence: 2, EntryID: entries[1].EntryID, Reason: "hash does not match the entry's content"}, storage.VerifyLinks(rewritten, head))
+	removed := []models.LedgerEntry{entries[0], entries[2]}
+	if broken := storage.VerifyLinks(removed, head); assert.NotNil(t, broken) {
+		assert.Equal(t, int64(2), broken.Sequence)
+	}
+	// Removing the last entries leaves an intact chain that falls short of its head.
+	assert.Equal(t, &storage.ChainBreak{Sequence: 3, Reason: "entry is missing; the chain head is at sequence 3"}, storage.VerifyLinks(entries[:2], head))
+	assert.Equal(t, &storage.ChainBreak{Sequence: 1, Reason: "entry is missing; the chain head is at sequence 3"}, storage.VerifyLinks(nil, head))
+	forged := storage.ChainHead{Sequence: 3, Hash: entries[1].Hash}
+	assert.Equal(t, &storage.ChainBreak{Sequence: 3, EntryID: entries[2].EntryID, Reason: "hash does not match the chain head"}, storage.VerifyLinks(entries, forged))
+}
+
+func testConcurrentDepositsKeepLedgerChain(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	users := []string{"alice", "bob", "carol", "dave"}
+	seedWallets(t, store, map[string]int64{"alice": 0, "bob": 0, "carol": 0, "dave": 0})
+
+	// Deposits into different wallets only extend their own chains, so none of them conflicts.
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for _, userID := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Deposit(ctx, &models.Transaction{ToUserId: userID, Amount: 10, Currency: currency})
+			if assert.NoError(t, err) {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, len(users), succeeded, "every concurrent deposit must succeed")
+
+	for _, userID := range users {
+		broken, err := storage.VerifyChain(ctx, store, userID, currency)
+		require.NoError(t, err)
+		assert.Nil(t, broken, "the ledger chain of %s must verify", userID)
+	}
+	// The funding account is the counterparty of every deposit and is not chained.
+	entries, _, err := store.ListLedgerEntriesByAccount(ctx, models.FundingAccount, currency, time.Time{}, time.Now().Add(time.Hour), storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, entries, len(users))
+	for _, entry := range entries {
+		assert.Zero(t, entry.Sequence)
+	}
+}
+
+func testConcurrentReservations(t *testing.T, store storage.Storage) {
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+
+	const attempts = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.CreateTransaction(context.Background(), &models.Transaction{FromUserId: "alice", ToUserId: "bob", Amount: 30, Currency: currency})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	wallet := getBalance(t, store, "alice")
+	assert.LessOrEqual(t, succeeded, 3, "at most three reservations of 30 fit in a balance of 100")
+	assert.Equal(t, int64(succeeded)*30, wallet.Reserved)
+	assert.Equal(t, int64(100), wallet.Balance+wallet.Reserved)
+	assert.GreaterOrEqual(t, wallet.Balance, int64(0))
+}
+
+func testListTransactionsByUserID(t *testing.T, store storage.Storage) {
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 100})
+	first := createTransaction(t, store, "alice", "bob", 10)
+	second := createTransaction(t, store, "alice", "bob", 20)
+	createTransaction(t, store, "bob", "alice", 5)
+
+	txs, next, err := store.ListTransactionsByUserID(context.Background(), "alice", storage.PageRequest{})
+
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	ids := make([]string, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.Id
+	}
+	assert.ElementsMatch(t, []string{first.Id, second.Id}, ids)
+}
+
+func testListIncomingTransactionsByUserID(t *testing.T, store storage.Storage) {
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 100, "carol": 100})
+	opening := openingDeposit(t, store, "alice")
+	first := createTransaction(t, store, "bob", "alice", 10)
+	second := createTransaction(t, store, "carol", "alice", 20)
+	createTransaction(t, store, "alice", "bob", 5)
+
+	txs, next, err := store.ListIncomingTransactionsByUserID(context.Background(), "alice", storage.PageRequest{})
+
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	ids := make([]string, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.Id
+	}
+	assert.ElementsMatch(t, []string{opening.Id, first.Id, second.Id}, ids)
+}
+
+func testGetStuckTransactions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	reserved := createTransaction(t, store, "alice", "bob", 10)
+	cancelled := createTransaction(t, store, "alice", "bob", 10)
+	settled := createTransaction(t, store, "alice", "bob", 10)
+	require.NoError(t, store.CancelTransaction(ctx, cancelled.Id))
+	_, err := store.SettleTransaction(ctx, settled, leaseOwner)
+	require.NoError(t, err)
+
+	recent, _, err := store.GetStuckTransactions(ctx, models.RESERVED, time.Hour, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, recent, "transactions younger than maxAge are not stuck")
+
+	// A negative age moves the cutoff into the future, so every RESERVED transaction qualifies.
+	stuck, _, err := store.GetStuckTransactions(ctx, models.RESERVED, -time.Minute, storage.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, stuck, 1)
+	assert.Equal(t, reserved.Id, stuck[0].Id)
+}
+
+func testListLedgerEntries(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	for i := 0; i < 3; i++ {
+		tx := createTransaction(t, store, "alice", "bob", 10)
+		_, err := store.SettleTransaction(ctx, tx, leaseOwner)
+		require.NoError(t, err)
+	}
+
+	entries, _, err := store.ListLedgerEntries(ctx, storage.PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, entries, 8, "the opening deposit and three settlements")
+
+	perTransaction := make(map[string]int64)
+	for i, entry := range entries {
+		perTransaction[entry.TransactionID] += entry.Debit - entry.Credit
+		if i > 0 {
+			assert.False(t, entry.Timestamp.After(entries[i-1].Timestamp), "entries must be newest first")
+		}
+	}
+	for txID, net := range perTransaction {
+		assert.Zero(t, net, "debits and credits must balance for transaction %s", txID)
+	}
+
+	limited, next, err := store.ListLedgerEntries(ctx, storage.PageRequest{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+	assert.NotEmpty(t, next, "a partial listing must return a cursor")
+}
+
+// collectPages calls list with the given limit until it stops returning a cursor,
+// checking that every page but the last is full.
+func testLedgerStatement(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0, "carol": 0})
+	for _, to := range []string{"bob", "carol", "bob", "bob"} {
+		tx := createTransaction(t, store, "alice", to, 10)
+		_, err := store.SettleTransaction(ctx, tx, leaseOwner)
+		require.NoError(t, err)
+	}
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	var lines []models.StatementLine
+	collectPages(t, 2, func(page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+		statement, next, err := storage.GetLedgerStatement(ctx, store, "bob", currency, start, end, page)
+		if err != nil {
+			return nil, "", err
+		}
+		assert.Equal(t, int64(0), statement.OpeningBalance)
+		assert.Equal(t, int64(30), statement.ClosingBalance)
+		lines = append(lines, statement.Lines...)
+		entries := make([]models.LedgerEntry, len(statement.Lines))
+		for i, line := range statement.Lines {
+			entries[i] = line.LedgerEntry
+		}
+		return entries, next, nil
+	})
+
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		assert.Equal(t, "bob", line.AccountID)
+		assert.Equal(t, int64(10*(i+1)), line.Balance, "line %d must carry the running balance across pages", i)
+		if i > 0 {
+			assert.False(t, line.Timestamp.Before(lines[i-1].Timestamp), "lines must be oldest first")
+		}
+	}
+
+	// A range starting at the second entry opens with the first one's balance.
+	statement, next, err := storage.GetLedgerStatement(ctx, store, "bob", currency, lines[1].Timestamp, end, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Equal(t, int64(10), statement.OpeningBalance)
+	assert.Equal(t, int64(30), statement.ClosingBalance)
+	require.Len(t, statement.Lines, 2)
+	assert.Equal(t, []int64{20, 30}, []int64{statement.Lines[0].Balance, statement.Lines[1].Balance})
+
+	// A range ending at the last entry excludes it.
+	statement, _, err = storage.GetLedgerStatement(ctx, store, "bob", currency, start, lines[2].Timestamp, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, statement.Lines, 2)
+	assert.Equal(t, int64(20), statement.ClosingBalance)
+
+	sum, err := store.SumLedgerEntries(ctx, "alice", currency, end, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), sum, "debits must count against the opening deposit")
+}
+
+func collectPages[T any](t *testing.T, limit int32, list func(page storage.PageRequest) ([]T, string, error)) []T {
+	t.Helper()
+	var all []T
+	page := storage.PageRequest{Limit: limit}
+	for i := 0; ; i++ {
+		require.Less(t, i, 100, "pagination did not terminate")
+		items, next, err := list(page)
+		require.NoError(t, err)
+		all = append(all, items...)
+		if next == "" {
+			return all
+		}
+		require.Len(t, items, int(limit), "only the last page may be short")
+		page.Cursor = next
+	}
+}
+
+func testPaginateWallets(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	var want []string
+	for i := 0; i < 5; i++ {
+		userID := string(rune('a' + i))
+		_, err := store.CreateWallet(ctx, &models.Wallet{UserId: userID, Name: userID, Version: 1, CreatedAt: base.Add(time.Duration(i) * time.Second)})
+		require.NoError(t, err)
+		want = append([]string{userID}, want...)
+	}
+
+	wallets := collectPages(t, 2, func(page storage.PageRequest) ([]models.Wallet, string, error) {
+		return store.ListWallets(ctx, page)
+	})
+
+	got := make([]string, len(wallets))
+	for i, wallet := range wallets {
+		got[i] = wallet.UserId
+	}
+	assert.Equal(t, want, got, "pages must cover every wallet once, newest first")
+}
+
+func testPaginateTransactionsByUserID(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 100})
+	var want []string
+	for i := 0; i < 5; i++ {
+		want = append(want, createTransaction(t, store, "alice", "bob", 1).Id)
+	}
+	createTransaction(t, store, "bob", "alice", 1)
+
+	txs := collectPages(t, 2, func(page storage.PageRequest) ([]models.Transaction, string, error) {
+		return store.ListTransactionsByUserID(ctx, "alice", page)
+	})
+
+	got := make([]string, len(txs))
+	for i, tx := range txs {
+		got[i] = tx.Id
+		if i > 0 {
+			assert.False(t, tx.CreatedAt.After(txs[i-1].CreatedAt), "transactions must be newest first")
+		}
+	}
+	assert.ElementsMatch(t, want, got, "pages must cover every transaction once")
+}
+
+func testPaginateActivityByUserID(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 100, "carol": 100})
+	want := []string{openingDeposit(t, store, "alice").Id}
+	for i := 0; i < 3; i++ {
+		want = append(want, createTransaction(t, store, "alice", "bob", 1).Id)
+		want = append(want, createTransaction(t, store, "bob", "alice", 1).Id)
+	}
+	createTransaction(t, store, "carol", "bob", 1)
+
+	txs := collectPages(t, 4, func(page storage.PageRequest) ([]models.Transaction, string, error) {
+		return store.ListActivityByUserID(ctx, "alice", page)
+	})
+
+	got := make([]string, len(txs))
+	for i, tx := range txs {
+		got[i] = tx.Id
+		if i > 0 {
+			assert.False(t, tx.CreatedAt.After(txs[i-1].CreatedAt), "activity must be newest first")
+		}
+	}
+	assert.ElementsMatch(t, want, got, "pages must cover every sent and received transaction once")
+}
+
+func testPaginateStuckTransactions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	var want []string
+	for i := 0; i < 3; i++ {
+		want = append(want, createTransaction(t, store, "alice", "bob", 1).Id)
+	}
+
+	stuck := collectPages(t, 2, func(page storage.PageRequest) ([]models.Transaction, string, error) {
+		return store.GetStuckTransactions(ctx, models.RESERVED, -time.Minute, page)
+	})
+
+	got := make([]string, len(stuck))
+	for i, tx := range stuck {
+		got[i] = tx.Id
+		if i > 0 {
+			assert.False(t, tx.CreatedAt.Before(stuck[i-1].CreatedAt), "stuck transactions must be oldest first")
+		}
+	}
+	assert.ElementsMatch(t, want, got, "pages must cover every stuck transaction once")
+}
+
+func testPaginateLedgerEntries(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	for i := 0; i < 3; i++ {
+		tx := createTransaction(t, store, "alice", "bob", 10)
+		_, err := store.SettleTransaction(ctx, tx, leaseOwner)
+		require.NoError(t, err)
+	}
+
+	entries := collectPages(t, 4, func(page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+		return store.ListLedgerEntries(ctx, page)
+	})
+
+	require.Len(t, entries, 8)
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		assert.False(t, seen[entry.EntryID], "entry %s returned twice", entry.EntryID)
+		seen[entry.EntryID] = true
+		if i > 0 {
+			assert.False(t, entry.Timestamp.After(entries[i-1].Timestamp), "entries must be newest first")
+		}
+	}
+}
+
+func testPaginateLedgerEntriesBetween(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100, "bob": 0})
+	for i := 0; i < 3; i++ {
+		tx := createTransaction(t, store, "alice", "bob", 10)
+		_, err := store.SettleTransaction(ctx, tx, leaseOwner)
+		require.NoError(t, err)
+	}
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	entries := collectPages(t, 3, func(page storage.PageRequest) ([]models.LedgerEntry, string, error) {
+		return store.ListLedgerEntriesBetween(ctx, start, end, page)
+	})
+
+	require.Len(t, entries, 8, "the opening deposit and three settlements of every account")
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		assert.False(t, seen[entry.EntryID], "entry %s returned twice", entry.EntryID)
+		seen[entry.EntryID] = true
+		if i > 0 {
+			assert.False(t, entry.Timestamp.Before(entries[i-1].Timestamp), "entries must be oldest first")
+		}
+	}
+
+	// The range includes entries at from and excludes those at to.
+	last := entries[len(entries)-1].Timestamp
+	inRange, next, err := store.ListLedgerEntriesBetween(ctx, last, end, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Len(t, inRange, 2, "both entries of the last settlement")
+	inRange, _, err = store.ListLedgerEntriesBetween(ctx, start, last, storage.PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, inRange, 6)
+}
+
+func testInvalidCursor(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	seedWallets(t, store, map[string]int64{"alice": 100})
+	page := storage.PageRequest{Cursor: "not a cursor!"}
+
+	_, _, err := store.ListWallets(ctx, page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListTransactionsByUserID(ctx, "alice", page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListIncomingTransactionsByUserID(ctx, "alice", page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListActivityByUserID(ctx, "alice", page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.GetStuckTransactions(ctx, models.RESERVED, time.Hour, page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListLedgerEntries(ctx, page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListLedgerEntriesBetween(ctx, time.Time{}, time.Now(), page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListRecurringTransfersByUserID(ctx, "alice", page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = store.ListDueRecurringTransfers(ctx, time.Now(), page)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+// createRecurringTransfer stores a monthly recurring transfer to bob with the given status and next run time.
+func createRecurringTransfer(t *testing.T, store storage.Storage, from string, status models.RecurringTransferStatus, nextRunAt *time.Time) *models.RecurringTransfer {
+	t.Helper()
+	start := time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC)
+	rt, err := store.CreateRecurringTransfer(context.Background(), &models.RecurringTransfer{
+		FromUserId: from,
+		ToUserId:   "bob",
+		Amount:     25,
+		Currency:   currency,
+		Schedule:   models.RecurrenceSchedule{Frequency: models.MONTHLY, Interval: 1, StartAt: start},
+		Status:     status,
+		NextRunAt:  nextRunAt,
+	})
+	require.NoError(t, err)
+	return rt
+}
+
+func testRecurringTransfers(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	endAt := time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)
+	count := int32(6)
+	next := time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	cron, err := store.CreateRecurringTransfer(ctx, &models.RecurringTransfer{
+		FromUserId: "alice",
+		ToUserId:   "bob",
+		Amount:     25,
+		Currency:   currency,
+		Schedule:   models.RecurrenceSchedule{Cron: "0 9 * * 1", StartAt: next, EndAt: &endAt},
+		Status:     models.RecurringActive,
+		NextRunAt:  &next,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, cron.Id)
+	assert.Equal(t, int64(1), cron.Version)
+	rule, err := store.CreateRecurringTransfer(ctx, &models.RecurringTransfer{
+		FromUserId: "alice",
+		ToUserId:   "bob",
+		Amount:     25,
+		Currency:   currency,
+		Schedule:   models.RecurrenceSchedule{Frequency: models.MONTHLY, Interval: 1, StartAt: next, Count: &count},
+		Status:     models.RecurringActive,
+		NextRunAt:  &next,
+	})
+	require.NoError(t, err)
+
+	stored, err := store.GetRecurringTransfer(ctx, cron.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "0 9 * * 1", stored.Schedule.Cron)
+	assert.True(t, next.Equal(stored.Schedule.StartAt))
+	if assert.NotNil(t, stored.Schedule.EndAt) {
+		assert.True(t, endAt.Equal(*stored.Schedule.EndAt))
+	}
+	assert.Nil(t, stored.Schedule.Count)
+	stored, err = store.GetRecurringTransfer(ctx, rule.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.MONTHLY, stored.Schedule.Frequency)
+	assert.Equal(t, int32(1), stored.Schedule.Interval)
+	assert.Nil(t, stored.Schedule.EndAt)
+	if assert.NotNil(t, stored.Schedule.Count) {
+		assert.Equal(t, count, *stored.Schedule.Count)
+	}
+
+	_, err = store.GetRecurringTransfer(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrRecurringTransferNotFound)
+
+	// Update the transfer as the runner does after an occurrence.
+	later := next.AddDate(0, 1, 0)
+	stored.Occurrences = 1
+	stored.NextRunAt = &later
+	stored.LastTransactionId = "tx-1"
+	stored.LastError = "previous failure"
+	require.NoError(t, store.UpdateRecurringTransfer(ctx, stored))
+	assert.Equal(t, int64(2), stored.Version)
+
+	updated, err := store.GetRecurringTransfer(ctx, rule.Id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	assert.Equal(t, int32(1), updated.Occurrences)
+	assert.Equal(t, "tx-1", updated.LastTransactionId)
+	assert.Equal(t, "previous failure", updated.LastError)
+	if assert.NotNil(t, updated.NextRunAt) {
+		assert.True(t, later.Equal(*updated.NextRunAt))
+	}
+
+	// An update based on a stale read is rejected.
+	rule.Status = models.RecurringPaused
+	rule.NextRunAt = nil
+	assert.ErrorIs(t, store.UpdateRecurringTransfer(ctx, rule), storage.ErrVersionConflict)
+	unchanged, err := store.GetRecurringTransfer(ctx, rule.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecurringActive, unchanged.Status)
+
+	// Clearing the next run time is stored.
+	updated.Status = models.RecurringPaused
+	updated.NextRunAt = nil
+	require.NoError(t, store.UpdateRecurringTransfer(ctx, updated))
+	paused, err := store.GetRecurringTransfer(ctx, rule.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RecurringPaused, paused.Status)
+	assert.Nil(t, paused.NextRunAt)
+
+	missing := *updated
+	missing.Id = "missing"
+	assert.ErrorIs(t, store.UpdateRecurringTransfer(ctx, &missing), storage.ErrRecurringTransferNotFound)
+}
+
+func testPaginateRecurringTransfersByUserID(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	var want []string
+	for i := 0; i < 5; i++ {
+		want = append(want, createRecurringTransfer(t, store, "alice", models.RecurringActive, nil).Id)
+	}
+	createRecurringTransfer(t, store, "carol", models.RecurringActive, nil)
+
+	transfers := collectPages(t, 2, func(page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+		return store.ListRecurringTransfersByUserID(ctx, "alice", page)
+	})
+
+	got := make([]string, len(transfers))
+	for i, rt := range transfers {
+		got[i] = rt.Id
+		if i > 0 {
+			assert.False(t, rt.CreatedAt.After(transfers[i-1].CreatedAt), "recurring transfers must be newest first")
+		}
+	}
+	assert.ElementsMatch(t, want, got, "pages must cover every recurring transfer once")
+}
+
+func testPaginateDueRecurringTransfers(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 0; i < 5; i++ {
+		due := now.Add(-time.Duration(5-i) * time.Minute)
+		want = append(want, createRecurringTransfer(t, store, "alice", models.RecurringActive, &due).Id)
+	}
+	want = append(want, createRecurringTransfer(t, store, "alice", models.RecurringActive, &now).Id)
+	later := now.Add(time.Second)
+	createRecurringTransfer(t, store, "alice", models.RecurringActive, &later)
+	createRecurringTransfer(t, store, "alice", models.RecurringPaused, nil)
+
+	due := collectPages(t, 2, func(page storage.PageRequest) ([]models.RecurringTransfer, string, error) {
+		return store.ListDueRecurringTransfers(ctx, now, page)
+	})
+
+	got := make([]string, len(due))
+	for i, rt := range due {
+		got[i] = rt.Id
+	}
+	assert.Equal(t, want, got, "pages must cover every due transfer once, earliest first, including one due exactly now")
+}
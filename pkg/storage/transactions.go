@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/chris/delayed-wallet-transactions/pkg/lifecycle"
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 )
 
@@ -12,21 +13,49 @@ type TransactionReader interface {
 	// GetTransaction retrieves a transaction by its ID.
 	GetTransaction(ctx context.Context, txID string) (*models.Transaction, error)
 
-	// GetStuckTransactions retrieves transactions that are in a 'RESERVED' state for longer than the specified duration.
-	GetStuckTransactions(ctx context.Context, maxAge time.Duration) ([]models.Transaction, error)
+	// GetTransactionByIdempotencyKey retrieves the transaction that was created with an idempotency key.
+	GetTransactionByIdempotencyKey(ctx context.Context, key string) (*models.Transaction, error)
 
-	// ListTransactionsByUserID retrieves all transactions for a specific user.
-	ListTransactionsByUserID(ctx context.Context, userID string) ([]models.Transaction, error)
+	// GetStuckTransactions retrieves a page of the transactions in a status, such as 'RESERVED', that were
+	// created longer than the specified duration ago, oldest first. The returned cursor is empty when there
+	// are no more pages.
+	GetStuckTransactions(ctx context.Context, status models.TransactionStatus, maxAge time.Duration, page PageRequest) ([]models.Transaction, string, error)
+
+	// ListTransactionsByUserID retrieves a page of the transactions sent by a specific user, newest first.
+	// The returned cursor is empty when there are no more pages.
+	ListTransactionsByUserID(ctx context.Context, userID string, page PageRequest) ([]models.Transaction, string, error)
+
+	// ListIncomingTransactionsByUserID retrieves a page of the transactions sent to a specific user, newest first.
+	// The returned cursor is empty when there are no more pages.
+	ListIncomingTransactionsByUserID(ctx context.Context, userID string, page PageRequest) ([]models.Transaction, string, error)
+
+	// ListActivityByUserID retrieves a page of the transactions sent or received by a specific user, newest first.
+	// The returned cursor is empty when there are no more pages.
+	ListActivityByUserID(ctx context.Context, userID string, page PageRequest) ([]models.Transaction, string, error)
 }
 
 // TransactionManager defines the interface for creating and managing transactions before settlement.
 // This is suitable for components like the main API service.
 type TransactionManager interface {
 	// CreateTransaction creates a new transaction and returns the created transaction.
+	// Both the sender's and the receiver's wallets must hold the transaction's currency,
+	// otherwise ErrCurrencyNotHeld is returned.
+	// If newTx has an idempotency key that was already used, nothing is written and
+	// ErrIdempotencyKeyExists is returned.
+	// The transaction is created RESERVED, or PENDING_APPROVAL if newTx has that status; see lifecycle.InitialStatus.
 	CreateTransaction(ctx context.Context, newTx *models.Transaction) (*models.Transaction, error)
 
 	// CancelTransaction cancels a transaction if it's in a cancellable state.
 	CancelTransaction(ctx context.Context, txID string) error
+
+	// ApproveTransaction moves a PENDING_APPROVAL transaction to APPROVED, from where it settles like a
+	// RESERVED one, and returns the approved transaction. ErrTransactionNotPendingApproval is returned
+	// for a transaction in any other status.
+	ApproveTransaction(ctx context.Context, txID string) (*models.Transaction, error)
+
+	// RejectTransaction releases the reserved funds of a PENDING_APPROVAL transaction back to the sender
+	// and marks it REJECTED. ErrTransactionNotPendingApproval is returned for a transaction in any other status.
+	RejectTransaction(ctx context.Context, txID string) error
 }
 
 // TransactionStore combines the reader and manager interfaces.
@@ -34,3 +63,35 @@ type TransactionStore interface {
 	TransactionReader
 	TransactionManager
 }
+
+// DueAt returns the time at which a transaction is due for settlement: its ExecuteAt, or its
+// CreatedAt plus its DelaySeconds. The time is rounded up to the second, the precision of SQS
+// delays, so that a transaction is never due before the time it asked for. A transaction without
+// either is due when it is created, and its CreatedAt is truncated instead, so that it is not held
+// back for the rest of the second. Stores record it as DueAt when the transaction is created, so
+// that it can be checked at settlement; it can still be worked out for transactions recorded
+// before that.
+func DueAt(tx *models.Transaction) time.Time {
+	var due time.Time
+	switch {
+	case tx.ExecuteAt != nil:
+		due = *tx.ExecuteAt
+	case tx.DelaySeconds != nil && *tx.DelaySeconds > 0:
+		due = tx.CreatedAt.Add(time.Duration(*tx.DelaySeconds) * time.Second)
+	default:
+		return tx.CreatedAt.Truncate(time.Second)
+	}
+	if truncated := due.Truncate(time.Second); truncated.Before(due) {
+		return truncated.Add(time.Second)
+	}
+	return due
+}
+
+// CheckDue returns a *NotDueError if a settleable transaction with a recorded due time is not due at
+// now. Transactions recorded without a due time are always due.
+func CheckDue(tx *models.Transaction, now time.Time) error {
+	if !lifecycle.Settleable(tx.Status) || tx.DueAt == nil || !now.Before(*tx.DueAt) {
+		return nil
+	}
+	return &NotDueError{TransactionID: tx.Id, DueAt: *tx.DueAt}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/chris/delayed-wallet-transactions/pkg/models"
 )
@@ -11,12 +12,23 @@ type WalletStore interface {
 	// GetWallet retrieves a user's wallet by their user ID.
 	GetWallet(ctx context.Context, userID string) (*models.Wallet, error)
 
-	// CreateWallet creates a new wallet for a user.
+	// CreateWallet creates a new wallet for a user. Each positive opening balance is recorded
+	// as a deposit from models.FundingAccount in the same atomic write; see OpeningDeposits.
 	CreateWallet(ctx context.Context, wallet *models.Wallet) (*models.Wallet, error)
 
 	// DeleteWallet deletes a user's wallet.
 	DeleteWallet(ctx context.Context, userID string) error
 
-	// ListWallets retrieves all wallets from the storage.
-	ListWallets(ctx context.Context) ([]models.Wallet, error)
+	// ListWallets retrieves a page of wallets, newest first.
+	// The returned cursor is empty when there are no more pages.
+	ListWallets(ctx context.Context, page PageRequest) ([]models.Wallet, string, error)
+}
+
+// HeldBalance returns a wallet's balance in a currency, or ErrCurrencyNotHeld if the wallet has none.
+func HeldBalance(wallet *models.Wallet, currency string) (models.CurrencyBalance, error) {
+	balance, ok := wallet.Balances[currency]
+	if !ok {
+		return models.CurrencyBalance{}, fmt.Errorf("%w: user ID %s has no %s balance", ErrCurrencyNotHeld, wallet.UserId, currency)
+	}
+	return balance, nil
 }
@@ -6,6 +6,8 @@ type MessageType string
 const (
 	// MessageTypeWalletUpdate is for messages that update wallet balances.
 	MessageTypeWalletUpdate MessageType = "walletUpdate"
+	// MessageTypeTransactionFailed is for messages that report a transaction that could not be settled.
+	MessageTypeTransactionFailed MessageType = "transactionFailed"
 )
 
 // Message represents a generic WebSocket message.
@@ -18,6 +20,18 @@ type Message struct {
 type WalletUpdatePayload struct {
 	UserID        string `json:"user_id"`
 	TransactionID string `json:"transaction_id"`
+	Currency      string `json:"currency"`
 	Change        int64  `json:"change"`
 	NewBalance    int64  `json:"new_balance"`
 }
+
+// TransactionFailedPayload is the payload for a transactionFailed message, sent to the sender of a
+// transaction that could not be settled. Its amount has been returned to the sender's balance.
+type TransactionFailedPayload struct {
+	UserID        string `json:"user_id"`
+	TransactionID string `json:"transaction_id"`
+	Currency      string `json:"currency"`
+	Amount        int64  `json:"amount"`
+	Reason        string `json:"reason"`
+	NewBalance    int64  `json:"new_balance"`
+}
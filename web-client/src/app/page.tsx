@@ -20,8 +20,8 @@ export default function HomePage() {
 
   const fetchWallets = useCallback(async () => {
     try {
-      const walletList = await DefaultService.listWallets();
-      setWallets(walletList);
+      const walletPage = await DefaultService.listWallets(100);
+      setWallets(walletPage.items);
       setError(null);
     } catch (err) {
       setError(err instanceof ApiError ? `API Error: ${err.message}` : 'An unexpected error occurred.');
@@ -6,11 +6,27 @@ export { CancelablePromise, CancelError } from './core/CancelablePromise';
 export { OpenAPI } from './core/OpenAPI';
 export type { OpenAPIConfig } from './core/OpenAPI';
 
+export type { Activity } from './models/Activity';
+export type { ActivityPage } from './models/ActivityPage';
+export { Direction } from './models/Direction';
+export type { Currency } from './models/Currency';
 export type { Error } from './models/Error';
 export type { LedgerEntry } from './models/LedgerEntry';
+export type { LedgerEntryPage } from './models/LedgerEntryPage';
+export type { LedgerStatement } from './models/LedgerStatement';
+export type { NewFundsMovement } from './models/NewFundsMovement';
 export type { NewTransaction } from './models/NewTransaction';
+export type { NewRecurringTransfer } from './models/NewRecurringTransfer';
 export type { NewWallet } from './models/NewWallet';
+export type { OccurrenceList } from './models/OccurrenceList';
+export { RecurrenceSchedule } from './models/RecurrenceSchedule';
+export { RecurringTransfer } from './models/RecurringTransfer';
+export type { RecurringTransferPage } from './models/RecurringTransferPage';
+export type { StatementLine } from './models/StatementLine';
 export { Transaction } from './models/Transaction';
+export type { TransactionPage } from './models/TransactionPage';
 export type { Wallet } from './models/Wallet';
+export type { WalletBalance } from './models/WalletBalance';
+export type { WalletPage } from './models/WalletPage';
 
 export { DefaultService } from './services/DefaultService';
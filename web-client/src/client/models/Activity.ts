@@ -0,0 +1,10 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Direction } from './Direction';
+import type { Transaction } from './Transaction';
+export type Activity = {
+    direction: Direction;
+    transaction: Transaction;
+};
+
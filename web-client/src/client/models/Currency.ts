@@ -0,0 +1,7 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+/**
+ * An ISO 4217 currency code.
+ */
+export type Currency = string;
@@ -0,0 +1,10 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+/**
+ * Whether the user sent or received a transaction.
+ */
+export enum Direction {
+    SENT = 'SENT',
+    RECEIVED = 'RECEIVED',
+}
@@ -1,7 +1,29 @@
 /* generated using openapi-typescript-codegen -- do not edit */
 /* istanbul ignore file */
 /* tslint:disable */
+/**
+ * A problem details object (RFC 7807) describing why a request failed.
+ */
 export type Error = {
-    message?: string;
+    /**
+     * A URI reference that identifies the problem type.
+     */
+    type: string;
+    /**
+     * A short summary of the problem type.
+     */
+    title: string;
+    /**
+     * The HTTP status code of the response.
+     */
+    status: number;
+    /**
+     * An explanation specific to this occurrence of the problem.
+     */
+    detail?: string;
+    /**
+     * The request path that the problem occurred on.
+     */
+    instance?: string;
 };
 
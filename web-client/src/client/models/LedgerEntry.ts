@@ -1,13 +1,27 @@
 /* generated using openapi-typescript-codegen -- do not edit */
 /* istanbul ignore file */
 /* tslint:disable */
+import type { Currency } from './Currency';
 export type LedgerEntry = {
     entry_id?: string;
     transaction_id?: string;
     account_id?: string;
+    currency?: Currency;
     debit?: number;
     credit?: number;
     timestamp?: string;
     description?: string;
+    /**
+     * The entry's position in the hash chain of its account and currency, starting at 1. Absent on entries recorded before the ledger was chained.
+     */
+    sequence?: number;
+    /**
+     * The hash of the previous entry in the chain, or empty for the first entry.
+     */
+    previous_hash?: string;
+    /**
+     * The hex-encoded SHA-256 hash of the entry's content, sequence and previous hash.
+     */
+    hash?: string;
 };
 
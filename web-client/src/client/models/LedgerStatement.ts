@@ -0,0 +1,25 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Currency } from './Currency';
+import type { StatementLine } from './StatementLine';
+export type LedgerStatement = {
+    account_id: string;
+    currency: Currency;
+    from: string;
+    to: string;
+    /**
+     * The account balance before the first entry of the statement.
+     */
+    opening_balance: number;
+    /**
+     * The account balance after the last entry of the statement.
+     */
+    closing_balance: number;
+    lines: Array<StatementLine>;
+    /**
+     * An opaque cursor for the next page. Absent on the last page.
+     */
+    next_cursor?: string;
+};
+
@@ -0,0 +1,12 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Currency } from './Currency';
+export type NewFundsMovement = {
+    /**
+     * The amount to move in the smallest currency unit (e.g., cents).
+     */
+    amount: number;
+    currency: Currency;
+};
+
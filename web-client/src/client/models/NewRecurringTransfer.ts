@@ -0,0 +1,15 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Currency } from './Currency';
+import type { RecurrenceSchedule } from './RecurrenceSchedule';
+export type NewRecurringTransfer = {
+    from_user_id: string;
+    to_user_id: string;
+    /**
+     * The amount of each transaction in the smallest currency unit (e.g., cents).
+     */
+    amount: number;
+    currency: Currency;
+    schedule: RecurrenceSchedule;
+};
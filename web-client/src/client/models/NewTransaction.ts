@@ -1,6 +1,7 @@
 /* generated using openapi-typescript-codegen -- do not edit */
 /* istanbul ignore file */
 /* tslint:disable */
+import type { Currency } from './Currency';
 export type NewTransaction = {
     from_user_id: string;
     to_user_id: string;
@@ -8,9 +9,14 @@ export type NewTransaction = {
      * The amount of the transaction in the smallest currency unit (e.g., cents).
      */
     amount: number;
+    currency: Currency;
     /**
-     * An optional delay in seconds before the transaction is processed. Maximum 900 seconds (15 minutes).
+     * An optional delay in seconds before the transaction is processed. Maximum 7776000 seconds (90 days). Delays over 900 seconds (15 minutes) are held in the schedules table until they are due.
      */
     delay_seconds?: number;
+    /**
+     * An optional RFC 3339 time at which the transaction is processed, at most 90 days ahead. A time in the past is processed at once. Cannot be combined with delay_seconds.
+     */
+    execute_at?: string;
 };
 
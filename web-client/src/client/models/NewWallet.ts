@@ -1,8 +1,13 @@
 /* generated using openapi-typescript-codegen -- do not edit */
 /* istanbul ignore file */
 /* tslint:disable */
+import type { Currency } from './Currency';
 export type NewWallet = {
     user_id: string;
     name: string;
+    /**
+     * The currencies the wallet holds. Each balance is seeded with 1000 units.
+     */
+    currencies: Array<Currency>;
 };
 
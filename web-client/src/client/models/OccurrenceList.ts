@@ -0,0 +1,10 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+export type OccurrenceList = {
+    /**
+     * The upcoming occurrences, earliest first.
+     */
+    items: Array<string>;
+};
+
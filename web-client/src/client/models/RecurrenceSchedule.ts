@@ -0,0 +1,43 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+/**
+ * When a recurring transfer occurs, in UTC. Set either cron or frequency, and at most one of end_at and count. Without either, the transfer repeats until it is cancelled.
+ */
+export type RecurrenceSchedule = {
+    /**
+     * A five-field cron expression (minute, hour, day of month, month, day of week) evaluated in UTC. Fields accept *, numbers, ranges, lists and steps, such as "0 9 * * 1-5". Cannot be combined with frequency.
+     */
+    cron?: string;
+    /**
+     * Repeat every interval days, weeks or months from start_at. Monthly transfers that start on a day a month does not have fall on that month's last day.
+     */
+    frequency?: RecurrenceSchedule.frequency;
+    /**
+     * The number of days, weeks or months between occurrences. Defaults to 1. Only used with frequency.
+     */
+    interval?: number;
+    /**
+     * The first occurrence of a frequency, or the time from which the cron expression applies.
+     */
+    start_at: string;
+    /**
+     * The time after which the transfer does not occur.
+     */
+    end_at?: string;
+    /**
+     * The number of transactions to create before the transfer finishes.
+     */
+    count?: number;
+};
+export namespace RecurrenceSchedule {
+    /**
+     * Repeat every interval days, weeks or months from start_at. Monthly transfers that start on a day a month does not have fall on that month's last day.
+     */
+    export enum frequency {
+        DAILY = 'DAILY',
+        WEEKLY = 'WEEKLY',
+        MONTHLY = 'MONTHLY',
+    }
+}
+
@@ -0,0 +1,47 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Currency } from './Currency';
+import type { RecurrenceSchedule } from './RecurrenceSchedule';
+export type RecurringTransfer = {
+    id: string;
+    from_user_id: string;
+    to_user_id: string;
+    amount: number;
+    currency: Currency;
+    schedule: RecurrenceSchedule;
+    /**
+     * FINISHED transfers have passed their end_at or created count transactions.
+     */
+    status: RecurringTransfer.status;
+    /**
+     * The number of occurrences that have run, including those skipped for lack of funds.
+     */
+    occurrences: number;
+    /**
+     * The next occurrence. Only set while the transfer is active.
+     */
+    next_run_at?: string;
+    /**
+     * The ID of the transaction created by the most recent occurrence that ran.
+     */
+    last_transaction_id?: string;
+    /**
+     * Why the most recent occurrence was skipped. Cleared when an occurrence succeeds.
+     */
+    last_error?: string;
+    created_at: string;
+    updated_at: string;
+};
+export namespace RecurringTransfer {
+    /**
+     * FINISHED transfers have passed their end_at or created count transactions.
+     */
+    export enum status {
+        ACTIVE = 'ACTIVE',
+        PAUSED = 'PAUSED',
+        CANCELLED = 'CANCELLED',
+        FINISHED = 'FINISHED',
+    }
+}
+
@@ -0,0 +1,12 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { RecurringTransfer } from './RecurringTransfer';
+export type RecurringTransferPage = {
+    items: Array<RecurringTransfer>;
+    /**
+     * An opaque cursor for the next page. Absent on the last page.
+     */
+    next_cursor?: string;
+};
+
@@ -0,0 +1,16 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+export type StatementLine = {
+    entry_id?: string;
+    transaction_id?: string;
+    debit?: number;
+    credit?: number;
+    /**
+     * The account balance after this entry.
+     */
+    balance?: number;
+    timestamp?: string;
+    description?: string;
+};
+
@@ -2,16 +2,33 @@
 /* istanbul ignore file */
 /* tslint:disable */
 /* eslint-disable */
+import type { Currency } from './Currency';
 export type Transaction = {
     id?: string;
     from_user_id?: string;
     to_user_id?: string;
     amount?: number;
+    currency?: Currency;
+    /**
+     * `RESERVED` until the transaction settles and becomes `COMPLETED`. A transaction that needs approval is `PENDING_APPROVAL` until it is approved, becoming `APPROVED` and then `COMPLETED`, or rejected. A transaction that has not settled can be `CANCELLED`. One that can never be settled, for example because the receiver's wallet was deleted, becomes `FAILED` and its funds are returned to the sender.
+     */
     status?: Transaction.status;
     /**
      * The delay in seconds before the transaction is processed.
      */
     delay_seconds?: number;
+    /**
+     * The time at which the transaction is processed, if it was created with execute_at.
+     */
+    execute_at?: string;
+    /**
+     * The time from which the transaction can settle, worked out from delay_seconds or execute_at when it was created and rounded up to the second. Absent on transactions created before due times were recorded.
+     */
+    due_at?: string;
+    /**
+     * Why a `FAILED` transaction could not be settled: `wallet_not_found`, `currency_not_held` or `insufficient_reserved_funds`.
+     */
+    failure_reason?: string;
     created_at?: string;
     updated_at?: string;
     /**
@@ -20,12 +37,16 @@ export type Transaction = {
     ttl?: number;
 };
 export namespace Transaction {
+    /**
+     * `RESERVED` until the transaction settles and becomes `COMPLETED`. A transaction that needs approval is `PENDING_APPROVAL` until it is approved, becoming `APPROVED` and then `COMPLETED`, or rejected.
+     */
     export enum status {
         RESERVED = 'RESERVED',
         PENDING_APPROVAL = 'PENDING_APPROVAL',
         APPROVED = 'APPROVED',
         REJECTED = 'REJECTED',
         COMPLETED = 'COMPLETED',
+        CANCELLED = 'CANCELLED',
         FAILED = 'FAILED',
     }
 }
@@ -0,0 +1,12 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Transaction } from './Transaction';
+export type TransactionPage = {
+    items: Array<Transaction>;
+    /**
+     * An opaque cursor for the next page. Absent on the last page.
+     */
+    next_cursor?: string;
+};
+
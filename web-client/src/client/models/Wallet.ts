@@ -1,17 +1,14 @@
 /* generated using openapi-typescript-codegen -- do not edit */
 /* istanbul ignore file */
 /* tslint:disable */
+import type { WalletBalance } from './WalletBalance';
 export type Wallet = {
     user_id?: string;
     name?: string;
     /**
-     * The wallet balance in the smallest currency unit (e.g., cents).
+     * The wallet's balance in each currency it holds, ordered by currency code.
      */
-    balance?: number;
-    /**
-     * Funds reserved for pending transactions.
-     */
-    reserved?: number;
+    balances?: Array<WalletBalance>;
     version?: number;
     created_at?: string;
 };
@@ -0,0 +1,16 @@
+/* generated using openapi-typescript-codegen -- do not edit */
+/* istanbul ignore file */
+/* tslint:disable */
+import type { Currency } from './Currency';
+export type WalletBalance = {
+    currency: Currency;
+    /**
+     * The balance in the smallest currency unit (e.g., cents).
+     */
+    balance: number;
+    /**
+     * Funds reserved for pending transactions.
+     */
+    reserved: number;
+};
+
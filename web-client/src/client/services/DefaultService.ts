@@ -1,11 +1,22 @@
 /* generated using openapi-typescript-codegen -- do not edit */
 /* istanbul ignore file */
 /* tslint:disable */
-import type { LedgerEntry } from '../models/LedgerEntry';
+import type { ActivityPage } from '../models/ActivityPage';
+import type { Currency } from '../models/Currency';
+import type { Direction } from '../models/Direction';
+import type { LedgerEntryPage } from '../models/LedgerEntryPage';
+import type { LedgerStatement } from '../models/LedgerStatement';
+import type { NewFundsMovement } from '../models/NewFundsMovement';
 import type { NewTransaction } from '../models/NewTransaction';
+import type { NewRecurringTransfer } from '../models/NewRecurringTransfer';
 import type { NewWallet } from '../models/NewWallet';
+import type { OccurrenceList } from '../models/OccurrenceList';
+import type { RecurringTransfer } from '../models/RecurringTransfer';
+import type { RecurringTransferPage } from '../models/RecurringTransferPage';
 import type { Transaction } from '../models/Transaction';
+import type { TransactionPage } from '../models/TransactionPage';
 import type { Wallet } from '../models/Wallet';
+import type { WalletPage } from '../models/WalletPage';
 import type { CancelablePromise } from '../core/CancelablePromise';
 import { OpenAPI } from '../core/OpenAPI';
 import { request as __request } from '../core/request';
@@ -13,20 +24,26 @@ export class DefaultService {
     /**
      * Schedule a new transaction
      * @param requestBody
-     * @returns Transaction Transaction created successfully.
+     * @param idempotencyKey A unique key for this request, such as a UUID. Retrying a request with the same key and body returns the transaction created by the first request instead of creating another. Keys are kept for at least 24 hours.
+     * @returns Transaction Transaction created successfully, or the transaction created by an earlier request with the same Idempotency-Key.
      * @throws ApiError
      */
     public static scheduleTransaction(
         requestBody: NewTransaction,
+        idempotencyKey?: string,
     ): CancelablePromise<Transaction> {
         return __request(OpenAPI, {
             method: 'POST',
             url: '/transactions',
+            headers: {
+                'Idempotency-Key': idempotencyKey,
+            },
             body: requestBody,
             mediaType: 'application/json',
             errors: {
                 400: `Invalid request body`,
-                422: `Insufficient funds or other processing error`,
+                404: `The sender's or receiver's wallet was not found`,
+                422: `Insufficient funds, a wallet that does not hold the transaction's currency, or the Idempotency-Key was already used with a different request body`,
             },
         });
     }
@@ -71,6 +88,185 @@ export class DefaultService {
             },
         });
     }
+    /**
+     * Approve a transaction
+     * Approves a transaction that is waiting for approval, and enqueues it for settlement at its due time. A transaction needs approval when it matches one of the server's approval rules, such as an amount threshold or a recipient allow-list; it is created with status `PENDING_APPROVAL` and its funds are reserved until it is approved or rejected.
+     * @param transactionId
+     * @returns Transaction The approved transaction
+     * @throws ApiError
+     */
+    public static approveTransaction(
+        transactionId: string,
+    ): CancelablePromise<Transaction> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/transactions/{transactionId}/approve',
+            path: {
+                'transactionId': transactionId,
+            },
+            errors: {
+                404: `Transaction not found`,
+                409: `Transaction is not pending approval`,
+            },
+        });
+    }
+    /**
+     * Reject a transaction
+     * Rejects a transaction that is waiting for approval. Its reserved funds are released back to the sender.
+     * @param transactionId
+     * @returns Transaction The rejected transaction
+     * @throws ApiError
+     */
+    public static rejectTransaction(
+        transactionId: string,
+    ): CancelablePromise<Transaction> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/transactions/{transactionId}/reject',
+            path: {
+                'transactionId': transactionId,
+            },
+            errors: {
+                404: `Transaction not found`,
+                409: `Transaction is not pending approval`,
+            },
+        });
+    }
+    /**
+     * Create a recurring transfer
+     * Creates a transfer that repeats on a schedule. At every occurrence an ordinary transaction is created from the sender to the receiver and processed at once, as if it had been created through `POST /transactions`. An occurrence the sender cannot pay for is skipped and reported in `last_error`.
+     * @param requestBody
+     * @returns RecurringTransfer Recurring transfer created successfully
+     * @throws ApiError
+     */
+    public static createRecurringTransfer(
+        requestBody: NewRecurringTransfer,
+    ): CancelablePromise<RecurringTransfer> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/recurring-transfers',
+            body: requestBody,
+            mediaType: 'application/json',
+            errors: {
+                400: `Invalid request body or schedule`,
+                404: `The sender's or receiver's wallet was not found`,
+                422: `A wallet does not hold the transfer's currency`,
+            },
+        });
+    }
+    /**
+     * Get a recurring transfer by its ID
+     * @param recurringTransferId
+     * @returns RecurringTransfer A single recurring transfer
+     * @throws ApiError
+     */
+    public static getRecurringTransferById(
+        recurringTransferId: string,
+    ): CancelablePromise<RecurringTransfer> {
+        return __request(OpenAPI, {
+            method: 'GET',
+            url: '/recurring-transfers/{recurringTransferId}',
+            path: {
+                'recurringTransferId': recurringTransferId,
+            },
+            errors: {
+                404: `Recurring transfer not found`,
+            },
+        });
+    }
+    /**
+     * Pause a recurring transfer
+     * Stops an active recurring transfer from creating transactions until it is resumed.
+     * @param recurringTransferId
+     * @returns RecurringTransfer The updated recurring transfer
+     * @throws ApiError
+     */
+    public static pauseRecurringTransfer(
+        recurringTransferId: string,
+    ): CancelablePromise<RecurringTransfer> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/recurring-transfers/{recurringTransferId}/pause',
+            path: {
+                'recurringTransferId': recurringTransferId,
+            },
+            errors: {
+                404: `Recurring transfer not found`,
+                409: `The recurring transfer is not active`,
+            },
+        });
+    }
+    /**
+     * Resume a paused recurring transfer
+     * Makes a paused recurring transfer active again. Occurrences that fell while it was paused are skipped and do not count towards the schedule's count.
+     * @param recurringTransferId
+     * @returns RecurringTransfer The updated recurring transfer
+     * @throws ApiError
+     */
+    public static resumeRecurringTransfer(
+        recurringTransferId: string,
+    ): CancelablePromise<RecurringTransfer> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/recurring-transfers/{recurringTransferId}/resume',
+            path: {
+                'recurringTransferId': recurringTransferId,
+            },
+            errors: {
+                404: `Recurring transfer not found`,
+                409: `The recurring transfer is not paused`,
+            },
+        });
+    }
+    /**
+     * Cancel a recurring transfer
+     * Stops an active or paused recurring transfer for good. Transactions it has already created are not affected.
+     * @param recurringTransferId
+     * @returns RecurringTransfer The updated recurring transfer
+     * @throws ApiError
+     */
+    public static cancelRecurringTransfer(
+        recurringTransferId: string,
+    ): CancelablePromise<RecurringTransfer> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/recurring-transfers/{recurringTransferId}/cancel',
+            path: {
+                'recurringTransferId': recurringTransferId,
+            },
+            errors: {
+                404: `Recurring transfer not found`,
+                409: `The recurring transfer is already cancelled or finished`,
+            },
+        });
+    }
+    /**
+     * List the upcoming occurrences of a recurring transfer
+     * Returns the next times at which the recurring transfer will create a transaction, earliest first. A transfer that is not active has none.
+     * @param recurringTransferId
+     * @param limit The maximum number of items to return.
+     * @returns OccurrenceList The upcoming occurrences
+     * @throws ApiError
+     */
+    public static listRecurringTransferOccurrences(
+        recurringTransferId: string,
+        limit: number = 20,
+    ): CancelablePromise<OccurrenceList> {
+        return __request(OpenAPI, {
+            method: 'GET',
+            url: '/recurring-transfers/{recurringTransferId}/occurrences',
+            path: {
+                'recurringTransferId': recurringTransferId,
+            },
+            query: {
+                'limit': limit,
+            },
+            errors: {
+                400: `Invalid limit`,
+                404: `Recurring transfer not found`,
+            },
+        });
+    }
     /**
      * Create a new wallet
      * @param requestBody
@@ -91,14 +287,26 @@ export class DefaultService {
         });
     }
     /**
-     * List all wallets
-     * @returns Wallet A list of wallets
+     * List wallets, newest first
+     * @param limit The maximum number of items to return.
+     * @param cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+     * @returns WalletPage A page of wallets
      * @throws ApiError
      */
-    public static listWallets(): CancelablePromise<Array<Wallet>> {
+    public static listWallets(
+        limit: number = 20,
+        cursor?: string,
+    ): CancelablePromise<WalletPage> {
         return __request(OpenAPI, {
             method: 'GET',
             url: '/wallets',
+            query: {
+                'limit': limit,
+                'cursor': cursor,
+            },
+            errors: {
+                400: `Invalid limit or cursor`,
+            },
         });
     }
     /**
@@ -142,36 +350,231 @@ export class DefaultService {
         });
     }
     /**
-     * List all transactions for a user
+     * Deposit funds into a wallet
+     * Moves funds from the SYSTEM:FUNDING account into the wallet immediately and records the movement in the ledger. The returned transaction is already COMPLETED.
      * @param userId
-     * @returns Transaction A list of transactions for the user
+     * @param requestBody
+     * @returns Transaction Deposit completed
+     * @throws ApiError
+     */
+    public static createDeposit(
+        userId: string,
+        requestBody: NewFundsMovement,
+    ): CancelablePromise<Transaction> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/wallets/{userId}/deposits',
+            path: {
+                'userId': userId,
+            },
+            body: requestBody,
+            mediaType: 'application/json',
+            errors: {
+                400: `Invalid request body`,
+                404: `Wallet not found`,
+                422: `The wallet does not hold the deposit's currency`,
+            },
+        });
+    }
+    /**
+     * Withdraw funds from a wallet
+     * Moves funds from the wallet's available balance to the SYSTEM:PAYOUTS account immediately and records the movement in the ledger. The returned transaction is already COMPLETED.
+     * @param userId
+     * @param requestBody
+     * @returns Transaction Withdrawal completed
+     * @throws ApiError
+     */
+    public static createWithdrawal(
+        userId: string,
+        requestBody: NewFundsMovement,
+    ): CancelablePromise<Transaction> {
+        return __request(OpenAPI, {
+            method: 'POST',
+            url: '/wallets/{userId}/withdrawals',
+            path: {
+                'userId': userId,
+            },
+            body: requestBody,
+            mediaType: 'application/json',
+            errors: {
+                400: `Invalid request body`,
+                404: `Wallet not found`,
+                422: `Insufficient funds, or the wallet does not hold the withdrawal's currency`,
+            },
+        });
+    }
+    /**
+     * Get a ledger statement for a wallet, oldest entry first
+     * Lists the wallet's debits and credits in one currency with a timestamp from `from` up to but excluding `to`, with the balance after each entry. Every page carries the opening and closing balances of the whole range.
+     * @param userId
+     * @param currency The currency of the statement.
+     * @param from The start of the statement, inclusive. Omit it to start at the wallet's first entry.
+     * @param to The end of the statement, exclusive. Omit it to end now.
+     * @param limit The maximum number of items to return.
+     * @param cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+     * @returns LedgerStatement A page of the wallet's ledger statement
+     * @throws ApiError
+     */
+    public static getLedgerStatement(
+        userId: string,
+        currency: Currency,
+        from?: string,
+        to?: string,
+        limit: number = 20,
+        cursor?: string,
+    ): CancelablePromise<LedgerStatement> {
+        return __request(OpenAPI, {
+            method: 'GET',
+            url: '/wallets/{userId}/ledger',
+            path: {
+                'userId': userId,
+            },
+            query: {
+                'currency': currency,
+                'from': from,
+                'to': to,
+                'limit': limit,
+                'cursor': cursor,
+            },
+            errors: {
+                400: `Invalid currency, range, limit or cursor`,
+            },
+        });
+    }
+    /**
+     * List the transactions sent by a user, newest first
+     * @param userId
+     * @param limit The maximum number of items to return.
+     * @param cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+     * @returns TransactionPage A page of transactions for the user
      * @throws ApiError
      */
     public static listTransactionsByUserId(
         userId: string,
-    ): CancelablePromise<Array<Transaction>> {
+        limit: number = 20,
+        cursor?: string,
+    ): CancelablePromise<TransactionPage> {
         return __request(OpenAPI, {
             method: 'GET',
             url: '/users/{userId}/transactions',
             path: {
                 'userId': userId,
             },
+            query: {
+                'limit': limit,
+                'cursor': cursor,
+            },
+            errors: {
+                400: `Invalid limit or cursor`,
+            },
+        });
+    }
+    /**
+     * List the transactions sent or received by a user, newest first
+     * @param userId
+     * @param direction Only return transactions in this direction. Omit it to return both.
+     * @param limit The maximum number of items to return.
+     * @param cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+     * @returns ActivityPage A page of the user's activity
+     * @throws ApiError
+     */
+    public static listUserActivity(
+        userId: string,
+        direction?: Direction,
+        limit: number = 20,
+        cursor?: string,
+    ): CancelablePromise<ActivityPage> {
+        return __request(OpenAPI, {
+            method: 'GET',
+            url: '/users/{userId}/activity',
+            path: {
+                'userId': userId,
+            },
+            query: {
+                'direction': direction,
+                'limit': limit,
+                'cursor': cursor,
+            },
+            errors: {
+                400: `Invalid direction, limit or cursor`,
+            },
         });
     }
     /**
-     * List recent ledger entries
-     * @param limit
-     * @returns LedgerEntry A list of recent ledger entries
+     * List the recurring transfers sent by a user, newest first
+     * @param userId
+     * @param limit The maximum number of items to return.
+     * @param cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+     * @returns RecurringTransferPage A page of recurring transfers for the user
+     * @throws ApiError
+     */
+    public static listRecurringTransfersByUserId(
+        userId: string,
+        limit: number = 20,
+        cursor?: string,
+    ): CancelablePromise<RecurringTransferPage> {
+        return __request(OpenAPI, {
+            method: 'GET',
+            url: '/users/{userId}/recurring-transfers',
+            path: {
+                'userId': userId,
+            },
+            query: {
+                'limit': limit,
+                'cursor': cursor,
+            },
+            errors: {
+                400: `Invalid limit or cursor`,
+            },
+        });
+    }
+    /**
+     * List ledger entries, newest first
+     * @param limit The maximum number of items to return.
+     * @param cursor The next_cursor returned with the previous page. Omit it to fetch the first page.
+     * @returns LedgerEntryPage A page of ledger entries
      * @throws ApiError
      */
     public static listLedgerEntries(
         limit: number = 20,
-    ): CancelablePromise<Array<LedgerEntry>> {
+        cursor?: string,
+    ): CancelablePromise<LedgerEntryPage> {
         return __request(OpenAPI, {
             method: 'GET',
             url: '/ledger',
             query: {
                 'limit': limit,
+                'cursor': cursor,
+            },
+            errors: {
+                400: `Invalid limit or cursor`,
+            },
+        });
+    }
+    /**
+     * Export the ledger for accounting
+     * Streams every ledger entry with a timestamp from `from` up to but excluding `to`, oldest first, as a file to download. `csv` writes one row per entry with the fields of `LedgerEntry` and amounts in minor units. `beancount` writes one balanced transaction per ledger transaction in major units, with wallets as `Liabilities:Wallets:<userId>` accounts and the system accounts as `Assets:Funding` and `Assets:Payouts`.
+     * @param format The file format of the export.
+     * @param from The start of the export, inclusive. Omit it to start at the first entry.
+     * @param to The end of the export, exclusive. Omit it to end now.
+     * @returns string The ledger export
+     * @throws ApiError
+     */
+    public static exportLedger(
+        format: 'csv' | 'beancount',
+        from?: string,
+        to?: string,
+    ): CancelablePromise<string> {
+        return __request(OpenAPI, {
+            method: 'GET',
+            url: '/ledger/export',
+            query: {
+                'format': format,
+                'from': from,
+                'to': to,
+            },
+            errors: {
+                400: `Invalid format or range`,
             },
         });
     }
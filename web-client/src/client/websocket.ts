@@ -2,7 +2,7 @@
 const WEBSOCKET_URL = 'wss://1ex23kq855.execute-api.us-west-2.amazonaws.com/ws';
 
 export interface WebSocketMessage {
-  type: 'walletUpdate' | string;
+  type: 'walletUpdate' | 'transactionFailed' | string;
   payload: unknown;
 }
 
@@ -29,31 +29,36 @@ import { toast } from 'sonner';
 const createWalletSchema = z.object({
   user_id: z.string().min(1, 'User ID is required'),
   name: z.string().min(1, 'Wallet name is required'),
+  currencies: z.string().regex(/^\s*[A-Za-z]{3}(\s*,\s*[A-Za-z]{3})*\s*$/, 'Enter currency codes separated by commas, e.g. USD, EUR'),
 });
 
 export function CreateWalletDialog({ onWalletCreated }: { onWalletCreated: () => void }) {
   const [isOpen, setIsOpen] = useState(false);
   const form = useForm<z.infer<typeof createWalletSchema>>({
     resolver: zodResolver(createWalletSchema),
-    defaultValues: { user_id: '', name: '' },
+    defaultValues: { user_id: '', name: '', currencies: 'USD' },
   });
 
   const handleOpenChange = (open: boolean) => {
     if (open) {
-      form.reset({ user_id: uuidv4(), name: '' });
+      form.reset({ user_id: uuidv4(), name: '', currencies: 'USD' });
     }
     setIsOpen(open);
   };
 
   const onSubmit = async (values: z.infer<typeof createWalletSchema>) => {
     try {
-      await DefaultService.createWallet(values);
+      await DefaultService.createWallet({
+        user_id: values.user_id,
+        name: values.name,
+        currencies: values.currencies.split(',').map((code) => code.trim().toUpperCase()),
+      });
       toast.success('Wallet created successfully!');
       form.reset();
       setIsOpen(false);
       onWalletCreated();
     } catch (err) {
-      const errorMessage = err instanceof ApiError ? `Failed to create wallet: ${err.body?.message}` : 'An unexpected error occurred.';
+      const errorMessage = err instanceof ApiError ? `Failed to create wallet: ${err.body?.detail ?? err.statusText}` : 'An unexpected error occurred.';
       toast.error(errorMessage);
       console.error(err);
     }
@@ -71,6 +76,7 @@ export function CreateWalletDialog({ onWalletCreated }: { onWalletCreated: () =>
         <Form {...form}>
           <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
             <FormField control={form.control} name="name" render={({ field }) => <FormItem><FormLabel>Wallet Name</FormLabel><FormControl><Input placeholder="e.g., My Savings" {...field} /></FormControl><FormMessage /></FormItem>} />
+            <FormField control={form.control} name="currencies" render={({ field }) => <FormItem><FormLabel>Currencies</FormLabel><FormControl><Input placeholder="e.g., USD, EUR" {...field} /></FormControl><FormMessage /></FormItem>} />
             <Button type="submit" disabled={form.formState.isSubmitting}>{form.formState.isSubmitting ? 'Creating...' : 'Create'}</Button>
           </form>
         </Form>
@@ -20,8 +20,8 @@ export function LedgerDrawer() {
   const fetchLedger = async () => {
     setIsLoading(true);
     try {
-      const entries = await DefaultService.listLedgerEntries();
-      setLedgerEntries(entries);
+      const entryPage = await DefaultService.listLedgerEntries();
+      setLedgerEntries(entryPage.items);
     } catch (err) {
       console.error('Failed to fetch ledger entries:', err);
       toast.error('Failed to fetch ledger entries.');
@@ -1,6 +1,6 @@
 'use client';
 
-import { useState, useEffect, useCallback } from 'react';
+import { useState, useEffect, useCallback, useRef } from 'react';
 import { useForm } from 'react-hook-form';
 import { zodResolver } from '@hookform/resolvers/zod';
 import { z } from 'zod';
@@ -46,6 +46,7 @@ import { toast } from 'sonner';
 
 const newTransactionSchema = z.object({
     to_user_id: z.string().min(1, 'Destination wallet is required.'),
+    currency: z.string().min(1, 'Currency is required.'),
     amount: z.string().min(1, 'Amount is required.'),
     delay_seconds: z.string().optional(),
 });
@@ -61,6 +62,7 @@ const CANCELLABLE_STATUSES: Array<Transaction['status']> = [
 const NON_CANCELLABLE_STATUSES: Array<Transaction['status']> = [
   Transaction.status.COMPLETED,
   Transaction.status.REJECTED,
+  Transaction.status.CANCELLED,
   Transaction.status.FAILED,
 ];
 
@@ -77,13 +79,19 @@ export function NewTransactionDialog({ sourceWallet, allWallets, isOpen, onOpenC
   const [isLoading, setIsLoading] = useState(false);
   const [transactionToCancel, setTransactionToCancel] = useState<Transaction | null>(null);
   const [currentSourceWallet, setCurrentSourceWallet] = useState<Wallet>(sourceWallet);
+  // Resubmitting after a failed or lost response reuses the key, so the transfer is never made twice.
+  const idempotencyKey = useRef(crypto.randomUUID());
 
   const form = useForm<TransactionFormValues>({
     resolver: zodResolver(newTransactionSchema),
-    defaultValues: { to_user_id: '', amount: '', delay_seconds: '' },
+    defaultValues: { to_user_id: '', currency: sourceWallet.balances?.[0]?.currency ?? '', amount: '', delay_seconds: '' },
   });
 
-  const destinationWallets = allWallets.filter(w => w.user_id && w.user_id !== sourceWallet.user_id);
+  const selectedCurrency = form.watch('currency');
+  // Only wallets that hold the selected currency can receive the transfer.
+  const destinationWallets = allWallets.filter(w =>
+    w.user_id && w.user_id !== sourceWallet.user_id && w.balances?.some(b => b.currency === selectedCurrency)
+  );
 
   useEffect(() => {
     const updatedSource = allWallets.find(w => w.user_id === sourceWallet.user_id);
@@ -96,8 +104,8 @@ export function NewTransactionDialog({ sourceWallet, allWallets, isOpen, onOpenC
     if (!sourceWallet.user_id) return;
     setIsLoading(true);
     try {
-      const userTransactions = await DefaultService.listTransactionsByUserId(sourceWallet.user_id);
-      setTransactions(userTransactions);
+      const transactionPage = await DefaultService.listTransactionsByUserId(sourceWallet.user_id);
+      setTransactions(transactionPage.items);
       setView('transactions');
     } catch (err) {
       toast.error('Failed to fetch transactions.');
@@ -146,19 +154,21 @@ export function NewTransactionDialog({ sourceWallet, allWallets, isOpen, onOpenC
       from_user_id: sourceWallet.user_id,
       to_user_id: values.to_user_id,
       amount: amount,
+      currency: values.currency,
       delay_seconds: delay_seconds,
     };
 
     try {
-      await DefaultService.scheduleTransaction(transactionData);
+      await DefaultService.scheduleTransaction(transactionData, idempotencyKey.current);
+      idempotencyKey.current = crypto.randomUUID();
       toast.success('Transaction scheduled successfully!');
       setView('transactions');
       onTransactionScheduled();
     } catch (err) {
       let errorMessage = 'An unexpected error occurred.';
       if (err instanceof ApiError) {
-        const body = err.body as { message?: string };
-        errorMessage = `Failed to schedule transaction: ${body?.message || err.statusText}`;
+        const body = err.body as { detail?: string };
+        errorMessage = `Failed to schedule transaction: ${body?.detail || err.statusText}`;
       } else if (err instanceof Error) {
         errorMessage = err.message;
       }
@@ -187,8 +197,8 @@ export function NewTransactionDialog({ sourceWallet, allWallets, isOpen, onOpenC
     } catch (err) {
       let errorMessage = 'An unexpected error occurred.';
       if (err instanceof ApiError) {
-        const body = err.body as { message?: string };
-        errorMessage = `Failed to cancel transaction: ${body?.message}`;
+        const body = err.body as { detail?: string };
+        errorMessage = `Failed to cancel transaction: ${body?.detail || err.statusText}`;
       } else if (err instanceof Error) {
         errorMessage = err.message;
       }
@@ -202,12 +212,30 @@ export function NewTransactionDialog({ sourceWallet, allWallets, isOpen, onOpenC
       <DialogContent className="w-full max-w-lg">
         <DialogHeader>
           <DialogTitle>New Transaction from {currentSourceWallet.name}</DialogTitle>
-          <DialogDescription>Balance: {currentSourceWallet.balance}</DialogDescription>
+          <DialogDescription>Balance: {currentSourceWallet.balances?.map(b => `${b.balance} ${b.currency}`).join(', ')}</DialogDescription>
         </DialogHeader>
         
         {view === 'form' ? (
           <Form {...form}>
             <form onSubmit={form.handleSubmit(onSubmit)} className="space-y-4">
+              <FormField
+                control={form.control}
+                name="currency"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>Currency</FormLabel>
+                    <Select onValueChange={field.onChange} defaultValue={field.value}>
+                      <FormControl><SelectTrigger><SelectValue placeholder="Select a currency" /></SelectTrigger></FormControl>
+                      <SelectContent>
+                        {currentSourceWallet.balances?.map(b => (
+                          <SelectItem key={b.currency} value={b.currency}>{b.currency}</SelectItem>
+                        ))}
+                      </SelectContent>
+                    </Select>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
               <FormField
                 control={form.control}
                 name="to_user_id"
@@ -246,7 +274,7 @@ export function NewTransactionDialog({ sourceWallet, allWallets, isOpen, onOpenC
                   <li key={tx.id} className="flex justify-between items-center border-b pb-2 text-sm">
                     <div>
                       <p><strong>To:</strong> {tx.to_user_id}</p>
-                      <p><strong>Amount:</strong> {tx.amount}</p>
+                      <p><strong>Amount:</strong> {tx.amount} {tx.currency}</p>
                       <div className="flex items-center gap-2">
                         <strong>Status:</strong>
                         <StatusBadge status={tx.status} />
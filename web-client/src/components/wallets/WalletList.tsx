@@ -18,7 +18,9 @@ export function WalletList({ wallets, onWalletClick }: WalletListProps) {
             onClick={() => onWalletClick(wallet)}
           >
             <h2 className="text-xl font-semibold mb-2">{wallet.name}</h2>
-            <p className="text-lg font-mono mt-2">Balance: {wallet.balance}</p>
+            {wallet.balances?.map((balance) => (
+              <p key={balance.currency} className="text-lg font-mono mt-2">Balance: {balance.balance} {balance.currency}</p>
+            ))}
           </div>
         ))
       ) : (
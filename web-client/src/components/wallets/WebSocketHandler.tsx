@@ -6,7 +6,7 @@ import { webSocketClient, WebSocketMessage } from '@/client/websocket';
 import { Wallet, Transaction } from '@/client';
 
 // Type guard to check if the payload is a valid WalletUpdatePayload
-function isWalletUpdatePayload(payload: unknown): payload is { user_id: string; transaction_id?: string; transaction_status?: Transaction['status']; change: number; new_balance: number; } {
+function isWalletUpdatePayload(payload: unknown): payload is { user_id: string; transaction_id?: string; transaction_status?: Transaction['status']; currency: string; change: number; new_balance: number; } {
   return (
     typeof payload === 'object' &&
     payload !== null &&
@@ -16,6 +16,17 @@ function isWalletUpdatePayload(payload: unknown): payload is { user_id: string;
   );
 }
 
+// Type guard to check if the payload is a valid TransactionFailedPayload
+function isTransactionFailedPayload(payload: unknown): payload is { user_id: string; transaction_id: string; currency: string; amount: number; reason: string; new_balance: number; } {
+  return (
+    typeof payload === 'object' &&
+    payload !== null &&
+    'user_id' in payload &&
+    'transaction_id' in payload &&
+    'reason' in payload
+  );
+}
+
 interface WebSocketHandlerProps {
   wallets: Wallet[];
   onWalletUpdate: () => void;
@@ -29,10 +40,23 @@ export function WebSocketHandler({ wallets, onWalletUpdate, onTransactionUpdate
     webSocketClient.connect();
 
     const handleWalletUpdate = (message: WebSocketMessage) => {
+      if (message.type === 'transactionFailed' && isTransactionFailedPayload(message.payload)) {
+        const { user_id, transaction_id, currency, amount, reason, new_balance } = message.payload;
+        onWalletUpdate();
+        onTransactionUpdate({ id: transaction_id, status: Transaction.status.FAILED, failure_reason: reason });
+
+        const wallet = wallets.find((w) => w.user_id === user_id);
+        const ownerName = wallet ? wallet.name : 'Unknown';
+        toast.error(`A transfer from ${ownerName}'s wallet failed`, {
+          description: `${amount} ${currency} was returned: ${reason}. New balance: ${new_balance} ${currency}.`,
+        });
+        return;
+      }
+
       if (message.type === 'walletUpdate' && isWalletUpdatePayload(message.payload)) {
         onWalletUpdate(); // Keep this to refresh wallet balances
 
-        const { user_id, transaction_id, change, new_balance } = message.payload;
+        const { user_id, transaction_id, currency, change, new_balance } = message.payload;
 
         if (transaction_id) {
           onTransactionUpdate({ id: transaction_id, status: Transaction.status.COMPLETED });
@@ -57,7 +81,7 @@ export function WebSocketHandler({ wallets, onWalletUpdate, onTransactionUpdate
 
         if (change > 0) {
           toast.success(`${ownerName}'s wallet was credited!`, {
-            description: `+${change} units. New balance: ${new_balance} units.`,
+            description: `+${change} ${currency}. New balance: ${new_balance} ${currency}.`,
             icon: <div style={{ color: 'oklch(var(--chart-4))' }}>✓</div>,
           });
         }